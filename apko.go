@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// apkoConfigDigest returns the digest of an apko config file, recorded to
+// tell which config an image was built from
+func apkoConfigDigest(config string) (string, error) {
+	b, err := os.ReadFile(config)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// defaultApkoTag is the tag an apko config is built as without --apko-tag
+func defaultApkoTag(config string) string {
+	base := strings.TrimSuffix(filepath.Base(config), filepath.Ext(config))
+	return "apko.local/" + strings.ToLower(base) + ":latest"
+}
+
+// buildApkoImage builds an apko config for one architecture into a tarball
+// in dir, returning its path
+func buildApkoImage(ctx context.Context, config string, tag string, arch string, dir string) (string, error) {
+	path := filepath.Join(dir, "image.tar")
+	args := []string{"build", "--arch", arch, config, tag, path}
+	fmt.Printf("Running build command \"apko %s\"...\n", strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, "apko", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("building %s with apko: %w", config, err)
+	}
+	return path, nil
+}
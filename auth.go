@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+// credentialFlags control how the BigQuery client authenticates. With
+// neither set, Application Default Credentials are used.
+type credentialFlags struct {
+	credentialsFile           *string
+	impersonateServiceAccount *string
+}
+
+func addCredentialFlags(fs *flag.FlagSet) *credentialFlags {
+	return &credentialFlags{
+		credentialsFile:           fs.String("credentials-file", "", "Path to a service account key or external account (workload identity federation) JSON file, instead of Application Default Credentials"),
+		impersonateServiceAccount: fs.String("impersonate-service-account", "", "Email of a service account to impersonate when calling BigQuery or Cloud Storage"),
+	}
+}
+
+// clientOptions returns the options for creating Google Cloud clients, with
+// scope being the OAuth scope needed when impersonating
+func (c *credentialFlags) clientOptions(ctx context.Context, scope string) ([]option.ClientOption, error) {
+	opts := []option.ClientOption{}
+	if *c.credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(*c.credentialsFile))
+	}
+	if *c.impersonateServiceAccount == "" {
+		return opts, nil
+	}
+	// The credentials file (or ADC) is used to obtain tokens for the target account
+	ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: *c.impersonateServiceAccount,
+		Scopes:          []string{scope},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return []option.ClientOption{option.WithTokenSource(ts)}, nil
+}
+
+func (c *credentialFlags) bigqueryClient(ctx context.Context, project string) (*bigquery.Client, error) {
+	opts, err := c.clientOptions(ctx, bigquery.Scope)
+	if err != nil {
+		return nil, err
+	}
+	return bigquery.NewClient(ctx, project, opts...)
+}
+
+func (c *credentialFlags) storageClient(ctx context.Context) (*storage.Client, error) {
+	opts, err := c.clientOptions(ctx, storage.ScopeReadWrite)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewClient(ctx, opts...)
+}
+
+func (c *credentialFlags) pubsubClient(ctx context.Context, project string) (*pubsub.Client, error) {
+	opts, err := c.clientOptions(ctx, pubsub.ScopePubSub)
+	if err != nil {
+		return nil, err
+	}
+	return pubsub.NewClient(ctx, project, opts...)
+}
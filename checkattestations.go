@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// attestationCheck is the result of "rumble check-attestations", printed
+// as-is with --output json
+type attestationCheck struct {
+	Image        string `json:"image"`
+	Attestations int    `json:"attestations"`
+
+	// Latest describes the most recently finished scan, if there is one
+	Latest *attestedScan `json:"latest,omitempty"`
+
+	OK       bool     `json:"ok"`
+	Problems []string `json:"problems"`
+}
+
+type attestedScan struct {
+	Scanner        string `json:"scanner"`
+	ScannerVersion string `json:"scanner_version"`
+	ScanFinishedOn string `json:"scan_finished_on"`
+	DbBuiltOn      string `json:"db_built_on,omitempty"`
+}
+
+// runCheckAttestations implements "rumble check-attestations", which checks
+// that an image has a vuln attestation from a recent enough scan, using a
+// recent enough scanner database. It exits with status 1 if not.
+func runCheckAttestations(args []string) {
+	fs := flag.NewFlagSet("check-attestations", flag.ExitOnError)
+	image := fs.String("image", "", "Image whose vuln attestations to check")
+	maxAge := fs.String("max-age", "7d", "Maximum age of the latest scan (e.g. \"7d\", \"12h\")")
+	maxDbAge := fs.String("max-db-age", "", "If set, maximum age of the scanner database used by the latest scan (e.g. \"48h\")")
+	dockerConfig := fs.String("docker-config", "", "explicit location of docker config directory")
+	output := fs.String("output", outputTable, "Output format, (\"table\" or \"json\")")
+	fs.Parse(args)
+	if *image == "" {
+		fmt.Fprintln(os.Stderr, "--image is required")
+		os.Exit(2)
+	}
+	if *output != outputTable && *output != outputJSON {
+		panic(fmt.Errorf("invalid output format: %s", *output))
+	}
+
+	now := time.Now()
+	scanCutoff, err := query.ParseSince(*maxAge, now)
+	if err != nil {
+		panic(err)
+	}
+	dbCutoff, err := query.ParseSince(*maxDbAge, now)
+	if err != nil {
+		panic(err)
+	}
+
+	predicates, err := downloadVulnAttestations(*image, *dockerConfig)
+	if err != nil {
+		panic(err)
+	}
+	check := checkAttestations(*image, predicates, scanCutoff, dbCutoff)
+
+	if *output == outputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		if err := enc.Encode(check); err != nil {
+			panic(err)
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "IMAGE\t%s\n", check.Image)
+		fmt.Fprintf(w, "ATTESTATIONS\t%d\n", check.Attestations)
+		if check.Latest != nil {
+			fmt.Fprintf(w, "LATEST SCAN\t%s (%s %s)\n", check.Latest.ScanFinishedOn, check.Latest.Scanner, check.Latest.ScannerVersion)
+			fmt.Fprintf(w, "DB BUILT\t%s\n", check.Latest.DbBuiltOn)
+		}
+		fmt.Fprintf(w, "OK\t%t\n", check.OK)
+		w.Flush()
+		for _, problem := range check.Problems {
+			fmt.Printf("- %s\n", problem)
+		}
+	}
+	if !check.OK {
+		os.Exit(1)
+	}
+}
+
+// checkAttestations checks that the latest scan finished after scanCutoff,
+// and (unless dbCutoff is zero) that its database was built after dbCutoff
+func checkAttestations(image string, predicates []types.InTotoStatement, scanCutoff time.Time, dbCutoff time.Time) *attestationCheck {
+	check := &attestationCheck{Image: image, Attestations: len(predicates), Problems: []string{}}
+	var latestTime time.Time
+	for _, predicate := range predicates {
+		finished, err := time.Parse(time.RFC3339, predicate.Metadata.ScanFinishedOn)
+		if err != nil {
+			continue
+		}
+		if check.Latest == nil || finished.After(latestTime) {
+			latestTime = finished
+			check.Latest = &attestedScan{
+				Scanner:        predicate.Scanner.URI,
+				ScannerVersion: predicate.Scanner.Version,
+				ScanFinishedOn: predicate.Metadata.ScanFinishedOn,
+				DbBuiltOn:      predicate.Metadata.DbBuiltOn,
+			}
+		}
+	}
+
+	if check.Latest == nil {
+		check.Problems = append(check.Problems, "no vuln attestations found")
+		return check
+	}
+	if latestTime.Before(scanCutoff) {
+		check.Problems = append(check.Problems, fmt.Sprintf("latest scan finished %s, before %s", check.Latest.ScanFinishedOn, scanCutoff.UTC().Format(time.RFC3339)))
+	}
+	if !dbCutoff.IsZero() {
+		built, err := time.Parse(time.RFC3339, check.Latest.DbBuiltOn)
+		if err != nil {
+			check.Problems = append(check.Problems, "latest scan does not record when its database was built")
+		} else if built.Before(dbCutoff) {
+			check.Problems = append(check.Problems, fmt.Sprintf("latest scan used a database built %s, before %s", check.Latest.DbBuiltOn, dbCutoff.UTC().Format(time.RFC3339)))
+		}
+	}
+	check.OK = len(check.Problems) == 0
+	return check
+}
+
+// downloadVulnAttestations returns the predicates of the vuln attestations
+// attached to an image, using "cosign download attestation"
+func downloadVulnAttestations(image string, dockerConfig string) ([]types.InTotoStatement, error) {
+	env := os.Environ()
+	if dockerConfig != "" {
+		env = append(env, fmt.Sprintf("DOCKER_CONFIG=%s", dockerConfig))
+	}
+	args := []string{"download", "attestation", "--predicate-type", attTypeVuln, image}
+	fmt.Fprintf(os.Stderr, "Running download command \"cosign %s\"...\n", strings.Join(args, " "))
+	var out bytes.Buffer
+	cmd := exec.Command("cosign", args...)
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	// One DSSE envelope is printed per line
+	predicates := []types.InTotoStatement{}
+	scanner := bufio.NewScanner(&out)
+	scanner.Buffer(nil, 256*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var envelope types.DSSEEnvelope
+		if err := json.Unmarshal(scanner.Bytes(), &envelope); err != nil {
+			return nil, fmt.Errorf("parsing attestation: %w", err)
+		}
+		var statement types.InTotoV01Statement
+		if err := json.Unmarshal(envelope.Payload, &statement); err != nil {
+			return nil, fmt.Errorf("parsing attestation statement: %w", err)
+		}
+		if statement.PredicateType == attTypeVuln {
+			predicates = append(predicates, statement.Predicate)
+		}
+	}
+	return predicates, scanner.Err()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestCheckAttestations(t *testing.T) {
+	predicate := func(scanner string, finished string, dbBuilt string) types.InTotoStatement {
+		var p types.InTotoStatement
+		p.Scanner.URI = scanner
+		p.Metadata.ScanFinishedOn = finished
+		p.Metadata.DbBuiltOn = dbBuilt
+		return p
+	}
+	predicates := []types.InTotoStatement{
+		predicate("grype", "2024-01-01T00:00:00Z", "2023-12-31T00:00:00Z"),
+		predicate("trivy", "2024-01-03T00:00:00Z", "2024-01-02T00:00:00Z"),
+		predicate("broken", "yesterday", ""),
+	}
+	date := func(day int) time.Time { return time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC) }
+
+	check := checkAttestations("cgr.dev/chainguard/static", predicates, date(2), date(1))
+	if !check.OK || check.Attestations != 3 || check.Latest == nil || check.Latest.Scanner != "trivy" || len(check.Problems) != 0 {
+		t.Errorf("expected the latest (trivy) scan to pass, got %+v", check)
+	}
+
+	// Too old, and with too old a database
+	check = checkAttestations("cgr.dev/chainguard/static", predicates, date(4), date(3))
+	if check.OK || len(check.Problems) != 2 || !strings.Contains(check.Problems[0], "latest scan finished 2024-01-03T00:00:00Z") || !strings.Contains(check.Problems[1], "database built 2024-01-02T00:00:00Z") {
+		t.Errorf("expected the scan and database age to fail, got %+v", check)
+	}
+
+	// The database age is only checked with a cutoff
+	check = checkAttestations("cgr.dev/chainguard/static", predicates[2:], date(1), time.Time{})
+	if check.OK || check.Latest != nil || len(check.Problems) != 1 || check.Problems[0] != "no vuln attestations found" {
+		t.Errorf("expected no attestations to be usable, got %+v", check)
+	}
+	check = checkAttestations("cgr.dev/chainguard/static", []types.InTotoStatement{predicate("grype", "2024-01-03T00:00:00Z", "")}, date(2), time.Time{})
+	if !check.OK {
+		t.Errorf("expected no database check without a cutoff, got %+v", check)
+	}
+	check = checkAttestations("cgr.dev/chainguard/static", []types.InTotoStatement{predicate("grype", "2024-01-03T00:00:00Z", "")}, date(2), date(1))
+	if check.OK || len(check.Problems) != 1 || !strings.Contains(check.Problems[0], "does not record when its database was built") {
+		t.Errorf("expected an unknown database build time to fail, got %+v", check)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/chainguard-dev/rumble/pkg/scanner"
+)
+
+// Downloads the pinned grype and trivy releases into pkg/scanner/embedded,
+// so that "go build -tags embedded_scanners" compiles them into rumble.
+// These are the scanners' release binaries, not grype and trivy as Go
+// libraries, and only releases pinned to a checksum in scanner.Releases
+// (see cmd/pinscanners) are downloaded.
+func main() {
+	dir := flag.String("dir", filepath.Join("pkg", "scanner", "embedded"), "directory to write the scanner binaries to")
+	goos := flag.String("os", runtime.GOOS, "operating system the binaries are for, matching GOOS")
+	goarch := flag.String("arch", runtime.GOARCH, "architecture the binaries are for, matching GOARCH")
+	flag.Parse()
+
+	ctx := context.Background()
+	for _, name := range []string{scanner.Grype, scanner.Trivy} {
+		release := scanner.Releases[name]
+		if err := release.Download(ctx, http.DefaultClient, *goos+"/"+*goarch, name, *dir); err != nil {
+			panic(err)
+		}
+		fmt.Printf("Wrote %s %s for %s/%s to %s\n", name, release.Version, *goos, *goarch, filepath.Join(*dir, name))
+	}
+	if err := os.WriteFile(filepath.Join(*dir, "PLATFORM"), []byte(*goos+"/"+*goarch+"\n"), 0644); err != nil {
+		panic(err)
+	}
+}
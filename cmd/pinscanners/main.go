@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/chainguard-dev/rumble/pkg/scanner"
+)
+
+// Prints the Assets of the pinned grype and trivy releases with the SHA-256
+// of each, as listed in their checksums files, to paste into
+// scanner.Releases after checking them against the release pages
+func main() {
+	ctx := context.Background()
+	for _, name := range []string{scanner.Grype, scanner.Trivy} {
+		release := scanner.Releases[name]
+		if err := release.Pin(ctx, http.DefaultClient); err != nil {
+			panic(err)
+		}
+		platforms := []string{}
+		for platform := range release.Assets {
+			platforms = append(platforms, platform)
+		}
+		sort.Strings(platforms)
+		fmt.Printf("// %s %s\n", name, release.Version)
+		for _, platform := range platforms {
+			asset := release.Assets[platform]
+			fmt.Printf("%q: {Name: %q, SHA256: %q},\n", platform, asset.Name, asset.SHA256)
+		}
+	}
+}
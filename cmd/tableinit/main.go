@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
 
 	"cloud.google.com/go/bigquery"
-	"github.com/chainguard-dev/rumble/pkg/types"
+	"github.com/chainguard-dev/rumble/pkg/query"
 )
 
 var (
@@ -18,6 +20,15 @@ var (
 	// This is a table that holds individual vulns found in a single rumble run/scan
 	// The scan_id field on this table refers to the rumble run id (acting as a foreign key)
 	GcloudTableVulns = os.Getenv("GCLOUD_TABLE_VULNS")
+
+	// This is an optional table that holds secrets and misconfigurations found in a single rumble run/scan
+	GcloudTableFindings = os.Getenv("GCLOUD_TABLE_FINDINGS")
+
+	// This is an optional table that holds the licenses found in a single rumble run/scan
+	GcloudTableLicenses = os.Getenv("GCLOUD_TABLE_LICENSES")
+
+	// This is an optional table that holds every package found in a single rumble run/scan
+	GcloudTablePackages = os.Getenv("GCLOUD_TABLE_PACKAGES")
 )
 
 func main() {
@@ -26,25 +37,20 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	dataset := client.Dataset(GcloudDataset)
-
-	// 1. Image scan summary
-	schema, err := bigquery.InferSchema(types.ImageScanSummary{})
-	if err != nil {
-		panic(err)
+	created, added, err := query.CreateTables(ctx, client.Dataset(GcloudDataset), query.Tables{
+		Summary:  GcloudTable,
+		Vulns:    GcloudTableVulns,
+		Findings: GcloudTableFindings,
+		Licenses: GcloudTableLicenses,
+		Packages: GcloudTablePackages,
+	})
+	for _, table := range created {
+		fmt.Printf("Created table %s\n", table)
 	}
-	table := dataset.Table(GcloudTable)
-	if err := table.Create(ctx, &bigquery.TableMetadata{Schema: schema}); err != nil {
-		panic(err)
+	for table, columns := range added {
+		fmt.Printf("Added column(s) %s to table %s\n", strings.Join(columns, ", "), table)
 	}
-
-	// 2. Individual vulnerabilties
-	schema, err = bigquery.InferSchema(types.Vuln{})
 	if err != nil {
 		panic(err)
 	}
-	table = dataset.Table(GcloudTableVulns)
-	if err := table.Create(ctx, &bigquery.TableMetadata{Schema: schema}); err != nil {
-		panic(err)
-	}
 }
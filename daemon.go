@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Container runtimes whose local images can be scanned, given as e.g.
+// "docker://app:dev"
+var daemonSchemes = []string{"docker", "podman", "containerd"}
+
+// daemonImage returns the runtime and reference of an image in a local
+// daemon, or empty strings for any other image
+func daemonImage(image string) (string, string) {
+	for _, daemon := range daemonSchemes {
+		if ref := strings.TrimPrefix(image, daemon+"://"); ref != image {
+			return daemon, ref
+		}
+	}
+	return "", ""
+}
+
+// exportDaemonImage saves an image from a local daemon to a tarball in dir
+// with the runtime's own CLI, so that it is read from the daemon just once
+// and scanned (and its digest computed) the same way as any other tarball.
+// containerd images are exported from $CONTAINERD_NAMESPACE, as with ctr.
+func exportDaemonImage(ctx context.Context, daemon string, ref string, dir string) (string, error) {
+	path := filepath.Join(dir, "image.tar")
+	var cmd *exec.Cmd
+	switch daemon {
+	case "docker":
+		cmd = exec.CommandContext(ctx, "docker", "save", "-o", path, ref)
+	case "podman":
+		cmd = exec.CommandContext(ctx, "podman", "save", "--format", "docker-archive", "-o", path, ref)
+	case "containerd":
+		// ctr includes a Docker-style manifest.json alongside the OCI index
+		cmd = exec.CommandContext(ctx, "ctr", "images", "export", path, ref)
+	default:
+		return "", fmt.Errorf("invalid container runtime: %s", daemon)
+	}
+	fmt.Printf("Exporting %s from %s...\n", ref, daemon)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("exporting %s from %s: %w", ref, daemon, err)
+	}
+	return path, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/vulndb"
+)
+
+// runDB implements "rumble db pull|export|import", which manage snapshots of
+// the scanners' vulnerability databases for hosts without network access
+func runDB(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: rumble db pull|export|import [flags]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("db "+args[0], flag.ExitOnError)
+	dir := fs.String("dir", defaultDBDir(), "Directory holding the database snapshot (defaults to $RUMBLE_DB_DIR, or a directory under the user cache directory)")
+	switch args[0] {
+	case "pull":
+		scanners := fs.String("scanner", "grype,trivy", "Comma-separated scanners to pull the databases of, (\"grype\" and \"trivy\")")
+		fs.Parse(args[1:])
+		m, err := vulndb.Pull(context.Background(), *dir, strings.Split(*scanners, ","))
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Pulled database snapshot %s (%d file(s)) into %s\n", m.ID, len(m.Files), *dir)
+	case "export":
+		output := fs.String("output", "", "File to write the snapshot bundle to, as a gzipped tarball")
+		fs.Parse(args[1:])
+		if *output == "" {
+			panic(fmt.Errorf("--output is required"))
+		}
+		f, err := os.Create(*output)
+		if err != nil {
+			panic(err)
+		}
+		m, err := vulndb.Export(*dir, f)
+		if err == nil {
+			err = f.Close()
+		} else {
+			f.Close()
+			os.Remove(*output)
+		}
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Exported database snapshot %s (%d file(s)) to %s\n", m.ID, len(m.Files), *output)
+	case "import":
+		input := fs.String("input", "", "Snapshot bundle to import, as written by \"rumble db export\"")
+		fs.Parse(args[1:])
+		if *input == "" {
+			panic(fmt.Errorf("--input is required"))
+		}
+		f, err := os.Open(*input)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		m, err := vulndb.Import(f, *dir)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Imported database snapshot %s (%d file(s)) into %s\n", m.ID, len(m.Files), *dir)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown db command %q, expected pull, export or import\n", args[0])
+		os.Exit(2)
+	}
+}
+
+func defaultDBDir() string {
+	if dir := os.Getenv("RUMBLE_DB_DIR"); dir != "" {
+		return dir
+	}
+	return vulndb.DefaultDir()
+}
+
+// useDBSnapshot points the scanners at the database snapshot in dir, which
+// rumble's subprocesses inherit, returning the snapshot's ID
+func useDBSnapshot(dir string) (string, error) {
+	m, err := vulndb.ReadManifest(dir)
+	if err != nil {
+		return "", fmt.Errorf("reading --db-dir: %w", err)
+	}
+	for _, env := range vulndb.Env(dir) {
+		key, value, _ := strings.Cut(env, "=")
+		if err := os.Setenv(key, value); err != nil {
+			return "", err
+		}
+	}
+	return m.ID, nil
+}
+
+const (
+	dbAgeModeWarn = "warn"
+	dbAgeModeFail = "fail"
+)
+
+// dbBuiltAt normalizes when the scanner reported its database was built to
+// RFC 3339 in UTC, or "" if it reported no (or an unknown) time
+func dbBuiltAt(built string) string {
+	t, err := time.Parse(time.RFC3339Nano, built)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// checkDBAge returns an error if the database was built before the cutoff,
+// or it isn't known when it was built
+func checkDBAge(scanner string, builtAt string, cutoff time.Time) error {
+	if builtAt == "" {
+		return fmt.Errorf("%s didn't report when its database was built, so its age can't be checked against --max-db-age", scanner)
+	}
+	built, err := time.Parse(time.RFC3339, builtAt)
+	if err != nil {
+		return err
+	}
+	if built.Before(cutoff) {
+		return fmt.Errorf("the %s database was built %s, before %s (--max-db-age)", scanner, builtAt, cutoff.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/diff"
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/sink"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// previousScans returns the filter for earlier scans of the same image as
+// a summary by the same scanner: of its repository and tag where it has
+// them, so scans by digest (as with a digest-pinned --attest-ref) match each
+// other and those by tag, and otherwise of the exact image reference
+func previousScans(summary *types.ImageScanSummary) query.Filter {
+	filter := query.Filter{Scanner: summary.Scanner, Limit: 1}
+	if summary.Repository != "" {
+		filter.Repository, filter.Tag = summary.Repository, summary.Tag
+	} else {
+		filter.Image = summary.Image
+	}
+	return filter
+}
+
+// latestPreviousScan returns the latest scan matching a filter from
+// previousScans, or nil if there is none. Scans recorded before their
+// repository and tag were have neither (and tables not yet updated by
+// rumble init have no such columns), so when nothing matches by repository
+// and tag it falls back on the exact image reference.
+func latestPreviousScan(summary *types.ImageScanSummary, filter query.Filter, summaries func(query.Filter) ([]*types.ImageScanSummary, error)) (*types.ImageScanSummary, error) {
+	previous, err := summaries(filter)
+	if filter.Repository == "" {
+		if err != nil || len(previous) == 0 {
+			return nil, err
+		}
+		return previous[0], nil
+	}
+	if err != nil && !strings.Contains(err.Error(), "Unrecognized name: repository") {
+		return nil, err
+	}
+	if err == nil && len(previous) > 0 {
+		return previous[0], nil
+	}
+	filter.Repository, filter.Tag, filter.Image = "", "", summary.Image
+	return latestPreviousScan(summary, filter, summaries)
+}
+
+// previousScanDiff returns the vulns added and removed since the latest scan
+// of a different digest of the image, by the same scanner, or nil for the
+// first scan of an image
+func previousScanDiff(ctx context.Context, client *query.Client, table string, vulnsTable string, summary *types.ImageScanSummary, vulns []*types.Vuln) (*diff.Predicate, error) {
+	filter := previousScans(summary)
+	filter.ExcludeDigest = summary.Digest
+	previous, err := latestPreviousScan(summary, filter, func(filter query.Filter) ([]*types.ImageScanSummary, error) {
+		return query.Summaries(ctx, client, table, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if previous == nil {
+		fmt.Printf("No previous scan of %s to diff against\n", summary.Image)
+		return nil, nil
+	}
+	previousVulns, err := query.ScanVulns(ctx, client, table, vulnsTable, previous.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	added, removed := diff.Vulns(previousVulns, vulns)
+	fmt.Printf("Comparing with scan of %s (scan_id=\"%s\"): %d vuln(s) added, %d removed\n",
+		previous.Digest, previous.ID, len(added), len(removed))
+	return &diff.Predicate{
+		Previous: diffScan(previous),
+		Current:  diffScan(summary),
+		Added:    added,
+		Removed:  removed,
+	}, nil
+}
+
+// deltaScan has a scan record only the vulns added and removed since the
+// latest scan of the image by the same scanner, unless that scan is
+// already fullEvery-1 deltas after a full one, so reading a scan's vulns
+// never has to follow a long chain of them
+func deltaScan(ctx context.Context, client *query.Client, table string, vulnsTable string, scan *sink.Scan, fullEvery int) error {
+	summary := scan.Summary
+	previous, err := latestPreviousScan(summary, previousScans(summary), func(filter query.Filter) ([]*types.ImageScanSummary, error) {
+		return query.Summaries(ctx, client, table, filter)
+	})
+	if err != nil {
+		return err
+	}
+	if previous == nil || previous.ID == summary.ID {
+		fmt.Printf("No previous scan of %s to record changes against, recording every vuln\n", summary.Image)
+		return nil
+	}
+	base, err := query.State(ctx, client, table, vulnsTable, previous.ID)
+	if err != nil {
+		return err
+	}
+	if base.Depth+1 >= fullEvery {
+		fmt.Printf("Scan %s is %d delta(s) after a full scan, recording every vuln\n", base.ScanID, base.Depth)
+		return nil
+	}
+	rows := query.Delta(base.Vulns, scan.Vulns, summary.ID, summary.Time)
+	fmt.Printf("Recording %d vuln change(s) since scan %s instead of %d vuln(s)\n", len(rows), base.ScanID, len(scan.Vulns))
+	scan.Vulns = rows
+	summary.UploadMode, summary.BaseScanID, summary.DeltaDepth = query.UploadModeDelta, base.ScanID, base.Depth+1
+	return nil
+}
+
+// attestVulnDiff attests a vuln diff to the image, returning the index of
+// the Rekor entry created for it, if any
+func attestVulnDiff(ctx context.Context, predicate *diff.Predicate, image string, dockerConfig string, sigstore *sigstoreFlags) (int64, error) {
+	b, err := json.MarshalIndent(predicate, "", "    ")
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.CreateTemp("", "rumble-vuln-diff-*.json")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	if err := printFile(f.Name()); err != nil {
+		return 0, err
+	}
+	return cosignAttest(ctx, image, diff.PredicateType, f.Name(), dockerConfig, sigstore)
+}
+
+func diffScan(summary *types.ImageScanSummary) diff.Scan {
+	return diff.Scan{
+		Image:   summary.Image,
+		Digest:  summary.Digest,
+		ScanID:  summary.ID,
+		Scanner: summary.Scanner,
+		Time:    summary.Time,
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+)
+
+// runExport implements "rumble export", which exports summary and vuln rows
+// to files in Cloud Storage for use outside of BigQuery
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	tables := addTableFlags(fs)
+	format := fs.String("format", "parquet", "Export file format, (\"parquet\", \"csv\", \"json\" or \"avro\")")
+	since := fs.String("since", "30d", "Export rows since this time, as an age (e.g. \"30d\", \"12h\") or a date (e.g. \"2006-01-02\")")
+	out := fs.String("out", "", "Cloud Storage prefix to export to (e.g. gs://bucket/path)")
+	raw := fs.Bool("raw", false, "Include the raw scanner output column in the summary export")
+	vulns := fs.Bool("vulns", true, "Also export rows from the vulns table")
+	anonymize := fs.Bool("anonymize", false, "Export a dataset for sharing outside: the CVE counts and CVEs of scans of --public-images, without registry hostnames, scan IDs, labels, paths or raw scanner output")
+	publicImages := fs.String("public-images", "", "With --anonymize, comma-separated glob patterns of the images to export (e.g. \"cgr.dev/chainguard/*\"), where \"*\" matches anything")
+	fs.Parse(args)
+	tables.check(*vulns)
+	if *out == "" {
+		panic(fmt.Errorf("--out is required"))
+	}
+	if *anonymize && *raw {
+		panic(fmt.Errorf("--raw can't be used with --anonymize"))
+	}
+	if *publicImages != "" && !*anonymize {
+		panic(fmt.Errorf("--public-images requires --anonymize"))
+	}
+
+	sinceTime, err := query.ParseSince(*since, time.Now())
+	if err != nil {
+		panic(err)
+	}
+	ctx := context.Background()
+	client, err := tables.client(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	// BigQuery shards large exports across files, so each table gets a wildcard
+	prefix := strings.TrimSuffix(*out, "/")
+	ext := strings.ToLower(*format)
+	if *anonymize {
+		images := []string{}
+		for _, pattern := range strings.Split(*publicImages, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				images = append(images, pattern)
+			}
+		}
+		summaryURI := fmt.Sprintf("%s/public-summaries-*.%s", prefix, ext)
+		vulnsURI := ""
+		if *vulns {
+			vulnsURI = fmt.Sprintf("%s/public-vulns-*.%s", prefix, ext)
+		}
+		fmt.Printf("Exporting anonymized scans of %s to %s\n", strings.Join(images, ", "), prefix)
+		if err := query.AnonymizedExport(ctx, client, tables.summaryTable(), tables.vulnsTableName(), images, summaryURI, vulnsURI, *format, sinceTime); err != nil {
+			panic(err)
+		}
+		return
+	}
+	var columns []string
+	if !*raw {
+		columns = query.SummaryColumns
+	}
+	uri := fmt.Sprintf("%s/summaries-*.%s", prefix, ext)
+	fmt.Printf("Exporting table \"%s\" to %s\n", tables.summaryTable(), uri)
+	if err := query.Export(ctx, client, tables.summaryTable(), columns, uri, *format, sinceTime); err != nil {
+		panic(err)
+	}
+	if *vulns {
+		uri := fmt.Sprintf("%s/vulns-*.%s", prefix, ext)
+		fmt.Printf("Exporting table \"%s\" to %s\n", tables.vulnsTableName(), uri)
+		if err := query.Export(ctx, client, tables.vulnsTableName(), nil, uri, *format, sinceTime); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/report"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// maxAnnotations is how many critical and how many high vulns are annotated,
+// as GitHub only shows the first few annotations of each kind for a step
+const maxAnnotations = 10
+
+// maxSummaryVulns is how many critical and high vulns are listed in the job
+// summary, which GitHub limits in size
+const maxSummaryVulns = 100
+
+// reportGitHubActions reports a scan to GitHub Actions: an ::error or
+// ::warning annotation (written to w) for each critical or high vuln, a job
+// summary appended to $GITHUB_STEP_SUMMARY, and the counts and scan ID as
+// step outputs in $GITHUB_OUTPUT
+func reportGitHubActions(w io.Writer, summary *types.ImageScanSummary, vulns []*types.Vuln) error {
+	critical, high := []*types.Vuln{}, []*types.Vuln{}
+	for _, vuln := range vulns {
+		switch strings.ToLower(report.Severity(vuln)) {
+		case "critical":
+			critical = append(critical, vuln)
+		case "high":
+			high = append(high, vuln)
+		}
+	}
+	for _, annotation := range []struct {
+		command string
+		vulns   []*types.Vuln
+	}{{"error", critical}, {"warning", high}} {
+		for i, vuln := range annotation.vulns {
+			if i == maxAnnotations {
+				fmt.Fprintf(w, "::%s title=%s::%s\n", annotation.command, escapeProperty(summary.Image),
+					escapeData(fmt.Sprintf("%d more %s vulns, see the job summary", len(annotation.vulns)-i, strings.ToLower(report.Severity(vuln)))))
+				break
+			}
+			fmt.Fprintf(w, "::%s title=%s::%s\n", annotation.command, escapeProperty(vuln.Vulnerability+" ("+report.Severity(vuln)+")"), escapeData(vulnText(vuln)))
+		}
+	}
+
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+		if err := appendFile(path, jobSummary(summary, append(critical, high...))); err != nil {
+			return fmt.Errorf("writing the job summary: %w", err)
+		}
+	} else {
+		fmt.Println("WARNING: $GITHUB_STEP_SUMMARY isn't set, not writing a job summary")
+	}
+
+	if path := os.Getenv("GITHUB_OUTPUT"); path != "" {
+		var outputs strings.Builder
+		for _, output := range []struct {
+			name  string
+			value interface{}
+		}{
+			{"scan_id", summary.ID},
+			{"digest", summary.Digest},
+			{"critical", summary.CritCveCount},
+			{"high", summary.HighCveCount},
+			{"medium", summary.MedCveCount},
+			{"low", summary.LowCveCount},
+			{"negligible", summary.NegligibleCveCount},
+			{"unknown", summary.UnknownCveCount},
+			{"total", summary.TotCveCount},
+		} {
+			fmt.Fprintf(&outputs, "%s=%v\n", output.name, output.value)
+		}
+		if err := appendFile(path, outputs.String()); err != nil {
+			return fmt.Errorf("writing the step outputs: %w", err)
+		}
+	} else {
+		fmt.Println("WARNING: $GITHUB_OUTPUT isn't set, not writing step outputs")
+	}
+	return nil
+}
+
+// jobSummary is the Markdown job summary of a scan, listing the given vulns
+func jobSummary(summary *types.ImageScanSummary, vulns []*types.Vuln) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Scan of `%s` with %s %s\n\n", summary.Image, summary.Scanner, summary.ScannerVersion)
+	if summary.Digest != "" {
+		fmt.Fprintf(&b, "Digest `%s`, scan ID `%s`\n\n", summary.Digest, summary.ID)
+	}
+	b.WriteString("| Critical | High | Medium | Low | Negligible | Unknown | Total |\n")
+	b.WriteString("| ---: | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %d | %d |\n\n", summary.CritCveCount, summary.HighCveCount, summary.MedCveCount,
+		summary.LowCveCount, summary.NegligibleCveCount, summary.UnknownCveCount, summary.TotCveCount)
+	if len(vulns) == 0 {
+		b.WriteString("No critical or high vulnerabilities found.\n")
+		return b.String()
+	}
+	b.WriteString("| Vulnerability | Severity | Package | Installed | Fixed in |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	for i, vuln := range vulns {
+		if i == maxSummaryVulns {
+			fmt.Fprintf(&b, "\n%d more not listed.\n", len(vulns)-i)
+			break
+		}
+		name := escapeCell(vuln.Vulnerability)
+		if vuln.DataSource != "" {
+			name = fmt.Sprintf("[%s](%s)", name, vuln.DataSource)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", name, escapeCell(report.Severity(vuln)), escapeCell(vuln.Name), escapeCell(vuln.Installed), escapeCell(vuln.FixedIn))
+	}
+	return b.String()
+}
+
+func vulnText(vuln *types.Vuln) string {
+	text := fmt.Sprintf("%s %s is vulnerable to %s", vuln.Name, vuln.Installed, vuln.Vulnerability)
+	if vuln.FixedIn != "" {
+		text += ", fixed in " + vuln.FixedIn
+	}
+	if vuln.Target != "" {
+		text += " (in " + vuln.Target + ")"
+	}
+	return text
+}
+
+// escapeData and escapeProperty escape the message and properties of a
+// workflow command, as the runner expects
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+func escapeCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
+
+func appendFile(path string, s string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(s); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestEscape(t *testing.T) {
+	if got := escapeData("100% done\r\nnext"); got != "100%25 done%0D%0Anext" {
+		t.Errorf("got escaped data %q", got)
+	}
+	if got := escapeProperty("CVE-2024-0001 (High): a, b"); got != "CVE-2024-0001 (High)%3A a%2C b" {
+		t.Errorf("got escaped property %q", got)
+	}
+}
+
+func TestJobSummary(t *testing.T) {
+	summary := &types.ImageScanSummary{Image: "cgr.dev/chainguard/static:latest", Scanner: "grype", ScannerVersion: "0.74.7", Digest: "sha256:abc", ID: "scan1", CritCveCount: 1, TotCveCount: 1}
+	empty := jobSummary(summary, nil)
+	for _, expected := range []string{
+		"### Scan of `cgr.dev/chainguard/static:latest` with grype 0.74.7",
+		"Digest `sha256:abc`, scan ID `scan1`",
+		"| 1 | 0 | 0 | 0 | 0 | 0 | 1 |",
+		"No critical or high vulnerabilities found.",
+	} {
+		if !strings.Contains(empty, expected) {
+			t.Errorf("expected the job summary to contain %q, got %s", expected, empty)
+		}
+	}
+
+	vulns := []*types.Vuln{}
+	for i := 0; i < maxSummaryVulns+2; i++ {
+		vulns = append(vulns, &types.Vuln{Vulnerability: "CVE-2024-0001", Severity: "Critical", Name: "a|b", Installed: "1.0", DataSource: "https://example.com/CVE-2024-0001"})
+	}
+	listed := jobSummary(summary, vulns)
+	if !strings.Contains(listed, "| [CVE-2024-0001](https://example.com/CVE-2024-0001) | Critical | a\\|b | 1.0 |  |") {
+		t.Errorf("expected the vulns to be listed with escaped cells, got %s", listed)
+	}
+	if strings.Count(listed, "[CVE-2024-0001]") != maxSummaryVulns || !strings.Contains(listed, "2 more not listed.") {
+		t.Errorf("expected only %d vulns to be listed, got %s", maxSummaryVulns, listed)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+)
+
+const outputSparkline = "sparkline"
+
+// runHistory implements "rumble history <image>", which prints the CVE
+// counts of every scan of an image over time, oldest first
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	tables := addTableFlags(fs)
+	scanner := fs.String("scanner", "", "Only show scans by this scanner, (\"trivy\" or \"grype\")")
+	since := fs.String("since", "", "Only show scans since this time, as an age (e.g. \"30d\", \"12h\") or a date (e.g. \"2006-01-02\")")
+	output := fs.String("output", outputTable, "Output format, (\"table\", \"wide\", \"json\", \"csv\" or \"sparkline\")")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: rumble history [flags] <image>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	// Allow flags after the image argument too
+	if fs.NArg() > 1 {
+		image := fs.Arg(0)
+		fs.Parse(append(fs.Args()[1:], image))
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	tables.check(false)
+	defer tables.close()
+	if *output != outputSparkline {
+		if err := checkOutputFormat(*output); err != nil {
+			panic(err)
+		}
+	}
+
+	sinceTime, err := query.ParseSince(*since, time.Now())
+	if err != nil {
+		panic(err)
+	}
+	filter := query.Filter{
+		Image:   fs.Arg(0),
+		Scanner: *scanner,
+		Since:   sinceTime,
+	}
+
+	summaries, err := tables.summaries(context.Background(), filter)
+	if err != nil {
+		panic(err)
+	}
+	// Summaries are returned newest first
+	for i, j := 0, len(summaries)-1; i < j; i, j = i+1, j-1 {
+		summaries[i], summaries[j] = summaries[j], summaries[i]
+	}
+
+	if *output == outputSparkline {
+		err = printSparklines(os.Stdout, summaries)
+	} else {
+		err = printSummaries(os.Stdout, *output, summaries)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
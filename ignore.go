@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"github.com/chainguard-dev/rumble/pkg/scanner"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// grypeConfig returns the grype config to scan with: --grype-config with
+// the ignore rules and VEX documents of rumble's config added, or nil if
+// there's neither
+func (opts *summaryOptions) grypeConfig() ([]byte, error) {
+	var base []byte
+	if opts.grypeConfigFile != "" {
+		b, err := os.ReadFile(opts.grypeConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(opts.ignore) == 0 && len(opts.vexDocuments) == 0 {
+			return b, nil
+		}
+		base = b
+	} else if len(opts.ignore) == 0 && len(opts.vexDocuments) == 0 {
+		return nil, nil
+	}
+	return scanner.GrypeConfig(base, opts.ignore, opts.vexDocuments)
+}
+
+// writeGrypeConfig writes the grype config to scan with to a temporary file,
+// returning its path, or "" if there's no config
+func (opts *summaryOptions) writeGrypeConfig() (string, error) {
+	b, err := opts.grypeConfig()
+	if err != nil || b == nil {
+		return "", err
+	}
+	file, err := os.CreateTemp("", "grype-config-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Write(b); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
+
+// ignoreTrivy applies the ignore rules of rumble's config to trivy's
+// output, which unlike grype has no equivalent of its own
+func (opts *summaryOptions) ignoreTrivy(output *types.TrivyScanOutput) []types.IgnoredMatch {
+	if len(opts.ignore) == 0 {
+		return nil
+	}
+	return output.Ignore(func(resultType string, vuln *types.TrivyScanOutputResultVulnerability) string {
+		fixState := "not-fixed"
+		if vuln.FixedVersion != "" {
+			fixState = "fixed"
+		}
+		pkg := config.IgnorePackage{Name: vuln.PkgName, Version: vuln.InstalledVersion, Type: resultType, Location: vuln.PkgPath}
+		for _, rule := range opts.ignore {
+			if rule.Matches(vuln.VulnerabilityID, fixState, pkg) {
+				// Described as grype describes its rules
+				grypeRule := types.GrypeIgnoreRule{Vulnerability: rule.Vulnerability, Reason: rule.Reason, FixState: rule.FixState}
+				if rule.Package != nil {
+					grypeRule.Package = &types.GrypeIgnoreRulePackage{Name: rule.Package.Name, Version: rule.Package.Version, Type: rule.Package.Type, Location: rule.Package.Location}
+				}
+				return grypeRule.Describe()
+			}
+		}
+		return ""
+	})
+}
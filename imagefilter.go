@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/config"
+)
+
+// imageFilterFlags skip scanning images by glob patterns, in addition to
+// the images patterns of the config file
+type imageFilterFlags struct {
+	include *string
+	exclude *string
+}
+
+func addImageFilterFlags(fs *flag.FlagSet) *imageFilterFlags {
+	return &imageFilterFlags{
+		include: fs.String("include", "", "Comma-separated glob patterns of the only images to scan, where \"*\" matches anything (adding to the config file's images.include)"),
+		exclude: fs.String("exclude", "", "Comma-separated glob patterns of images to skip, e.g. internal-only or deprecated repositories (adding to the config file's images.exclude)"),
+	}
+}
+
+// filter returns the patterns of the flags and the config file, if any
+func (f *imageFilterFlags) filter(cfg *config.Config) *config.ImageFilter {
+	filter := &config.ImageFilter{}
+	if cfg != nil {
+		filter.Include = append(filter.Include, cfg.Images.Include...)
+		filter.Exclude = append(filter.Exclude, cfg.Images.Exclude...)
+	}
+	filter.Include = append(filter.Include, patterns(*f.include)...)
+	filter.Exclude = append(filter.Exclude, patterns(*f.exclude)...)
+	return filter
+}
+
+func patterns(value string) []string {
+	patterns := []string{}
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+)
+
+// runInit implements "rumble init", which creates the BigQuery tables and,
+// with --create-views, views of them for read-only consumers
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	tables := addTableFlags(fs)
+	findingsTable := fs.String("findings-table", GcloudTableFindings, "If set, also create this table for secrets and misconfigurations (defaults to $GCLOUD_TABLE_FINDINGS)")
+	licensesTable := fs.String("licenses-table", GcloudTableLicenses, "If set, also create this table for package licenses (defaults to $GCLOUD_TABLE_LICENSES)")
+	packagesTable := fs.String("packages-table", GcloudTablePackages, "If set, also create this table for the package inventory (defaults to $GCLOUD_TABLE_PACKAGES)")
+	createViews := fs.Bool("create-views", false, "If enabled, also create views of the summary table without the raw scanner output in --views-dataset, authorized to read the tables")
+	viewsDataset := fs.String("views-dataset", "", "Dataset for the views, created if needed (defaults to the --dataset name with a \"_views\" suffix)")
+	viewReaders := fs.String("view-readers", "", "Comma-separated members to grant read access to the views (but not the tables), e.g. \"group:security@example.com,serviceAccount:dash@project.iam.gserviceaccount.com\"")
+	fs.Parse(args)
+	tables.check(true)
+	if *tables.local != "" || *tables.postgres != "" {
+		panic(fmt.Errorf("--local and --postgres are not supported by init, which creates BigQuery tables"))
+	}
+	if *viewsDataset == "" {
+		*viewsDataset = *tables.dataset + "_views"
+	}
+	readers := []string{}
+	for _, reader := range strings.Split(*viewReaders, ",") {
+		if reader = strings.TrimSpace(reader); reader != "" {
+			readers = append(readers, reader)
+		}
+	}
+	if len(readers) > 0 && !*createViews {
+		panic(fmt.Errorf("--view-readers requires --create-views"))
+	}
+
+	ctx := context.Background()
+	client, err := tables.client(ctx)
+	if err != nil {
+		panic(err)
+	}
+	dataset := client.Dataset(*tables.dataset)
+	created, added, err := query.CreateTables(ctx, dataset, query.Tables{
+		Summary:  *tables.table,
+		Vulns:    *tables.vulnsTable,
+		Findings: *findingsTable,
+		Licenses: *licensesTable,
+		Packages: *packagesTable,
+	})
+	for _, table := range created {
+		fmt.Printf("Created table %s.%s\n", *tables.dataset, table)
+	}
+	for table, columns := range added {
+		fmt.Printf("Added column(s) %s to table %s.%s\n", strings.Join(columns, ", "), *tables.dataset, table)
+	}
+	if err != nil {
+		panic(err)
+	}
+	if len(created) == 0 && len(added) == 0 {
+		fmt.Println("Every table exists already, with every column")
+	}
+	if !*createViews {
+		return
+	}
+
+	views := query.Views(tables.summaryTable())
+	if err := query.CreateViews(ctx, dataset, client.Dataset(*viewsDataset), views, readers); err != nil {
+		panic(err)
+	}
+	for _, view := range views {
+		fmt.Printf("Created view %s.%s\n", *viewsDataset, view.Name)
+	}
+	if len(readers) > 0 {
+		fmt.Printf("Granted %s read access to dataset %s\n", strings.Join(readers, ", "), *viewsDataset)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/jira"
+)
+
+// jiraFlags configure filing Jira issues for high and critical CVEs
+type jiraFlags struct {
+	url             *string
+	username        *string
+	token           *string
+	project         *string
+	issueType       *string
+	groupBy         *string
+	closeTransition *string
+	labels          *string
+}
+
+func addJiraFlags(fs *flag.FlagSet) *jiraFlags {
+	return &jiraFlags{
+		url:             fs.String("jira-url", os.Getenv("RUMBLE_JIRA_URL"), "Jira to file issues for high and critical CVEs in, e.g. https://example.atlassian.net (defaults to $RUMBLE_JIRA_URL)"),
+		username:        fs.String("jira-username", os.Getenv("RUMBLE_JIRA_USERNAME"), "Jira Cloud account email to authenticate with, along with --jira-token (defaults to $RUMBLE_JIRA_USERNAME)"),
+		token:           fs.String("jira-token", os.Getenv("RUMBLE_JIRA_TOKEN"), "Jira Cloud API token, or without --jira-username a Data Center personal access token (defaults to $RUMBLE_JIRA_TOKEN)"),
+		project:         fs.String("jira-project", "", "Key of the Jira project to file issues in"),
+		issueType:       fs.String("jira-issue-type", "Bug", "Type of the Jira issues filed"),
+		groupBy:         fs.String("jira-group-by", jira.GroupByImage, "Whether to file a Jira issue per image, (\"image\") or per CVE across images (\"cve\")"),
+		closeTransition: fs.String("jira-close-transition", "Done", "Name of the workflow transition that closes Jira issues once their CVEs are no longer found"),
+		labels:          fs.String("jira-labels", "", "Comma-separated labels to add to the Jira issues filed, besides rumble's own"),
+	}
+}
+
+// tracker returns the configured Jira tracker, or nil if --jira-url isn't set
+func (f *jiraFlags) tracker() (*jira.Tracker, error) {
+	if *f.url == "" {
+		return nil, nil
+	}
+	if *f.project == "" {
+		return nil, fmt.Errorf("--jira-url requires --jira-project")
+	}
+	if *f.groupBy != jira.GroupByImage && *f.groupBy != jira.GroupByCVE {
+		return nil, fmt.Errorf("invalid --jira-group-by: %s", *f.groupBy)
+	}
+	labels := []string{}
+	for _, l := range strings.Split(*f.labels, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			if strings.ContainsAny(l, " \t") {
+				return nil, fmt.Errorf("invalid Jira label %q, which can't contain spaces", l)
+			}
+			labels = append(labels, l)
+		}
+	}
+	return &jira.Tracker{
+		Client:          jira.NewClient(*f.url, *f.username, *f.token),
+		Project:         *f.project,
+		IssueType:       *f.issueType,
+		GroupBy:         *f.groupBy,
+		CloseTransition: *f.closeTransition,
+		Labels:          labels,
+	}, nil
+}
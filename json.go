@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// These helpers work on scanner output files without loading them into
+// memory in full, since grype/trivy output for large images can be
+// hundreds of megabytes.
+
+// printFile streams the contents of filename to stdout
+func printFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(os.Stdout, f); err != nil {
+		return err
+	}
+	fmt.Println()
+	return nil
+}
+
+// decodeJSONFile decodes the JSON document in filename into v
+func decodeJSONFile(filename string, v interface{}) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewDecoder(bufio.NewReader(f)).Decode(v)
+}
+
+// compactJSONFile returns the contents of filename with insignificant
+// whitespace removed, as json.Compact does, but read a buffer at a time so
+// the file isn't held in memory alongside the result. Like json.Compact, it
+// copies everything else as is. The input is assumed to be valid JSON (e.g.
+// already checked by decodeJSONFile).
+func compactJSONFile(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.Grow(int(info.Size()))
+	r := bufio.NewReader(f)
+	inString, escaped := false, false
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case !inString && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String(), nil
+}
+
+// writeStatementFile writes statement to filename with the JSON document in
+// resultFile as its scanner result, copying the document across rather than
+// decoding it. filename may be resultFile itself, which is then replaced.
+func writeStatementFile(filename string, statement types.InTotoStatement, resultFile string) error {
+	// Quotes within JSON strings are escaped, so this is only ever the key
+	statement.Scanner.Result = nil
+	b, err := json.Marshal(statement)
+	if err != nil {
+		return err
+	}
+	before, after, ok := bytes.Cut(b, []byte(`"result":null`))
+	if !ok {
+		return fmt.Errorf("no scanner result in the in-toto statement")
+	}
+
+	in, err := os.Open(resultFile)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+	if err := out.Chmod(0644); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	w.Write(before)
+	w.WriteString(`"result":`)
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	w.Write(after)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Rename(out.Name(), filename)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestCompactJSONFile(t *testing.T) {
+	// Real grype output, and trivy-style output with the strings that could
+	// trip up a compactor: escaped quotes and backslashes, whitespace and
+	// non-ASCII within strings
+	trivy := filepath.Join(t.TempDir(), "trivy-scan.json")
+	if err := os.WriteFile(trivy, []byte(`{
+	"SchemaVersion": 2,
+	"ArtifactName": "cgr.dev/chainguard/static:latest",
+	"Results": [
+		{
+			"Target": "C:\\Program Files\\app",
+			"Vulnerabilities": [
+				{
+					"VulnerabilityID": "CVE-2024-0001",
+					"Title": "a \"quoted\" title\\",
+					"Description": "  spaced\tout \n text  — with \u2028 unicode ",
+					"CVSS": {"nvd": {"V3Score": 9.8, "V2Score": 1e1}},
+					"References": [ ],
+					"FixedVersion": ""
+				}
+			]
+		}
+	]
+}
+`), 0644); err != nil {
+		t.Fatalf("expected no error on WriteFile(), got %v", err)
+	}
+	for _, filename := range []string{filepath.Join("pkg", "types", "testdata", "grype-scan.json"), trivy} {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("expected no error on ReadFile(), got %v", err)
+		}
+		var expected bytes.Buffer
+		if err := json.Compact(&expected, b); err != nil {
+			t.Fatalf("expected no error on json.Compact() of %s, got %v", filename, err)
+		}
+		got, err := compactJSONFile(filename)
+		if err != nil {
+			t.Fatalf("expected no error on compactJSONFile(), got %v", err)
+		}
+		if got != expected.String() {
+			t.Errorf("expected compactJSONFile() of %s to match json.Compact(), got %s", filename, got)
+		}
+	}
+}
+
+func TestWriteStatementFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "scan.sarif")
+	sarif := "{\n    \"runs\": [{\"tool\": {\"driver\": {\"name\": \"grype\"}}}]\n}"
+	if err := os.WriteFile(filename, []byte(sarif), 0644); err != nil {
+		t.Fatalf("expected no error on WriteFile(), got %v", err)
+	}
+	statement := types.InTotoStatement{
+		Invocation: types.InTotoStatementInvocation{URI: "https://github.com/chainguard-dev/rumble/actions/runs/1", BuilderID: `"result":null`},
+		Scanner:    types.InTotoStatementScanner{URI: "https://github.com/anchore/grype", Version: "0.62.3"},
+		Metadata:   types.InTotoStatementMetadata{ScanStartedOn: "2023-06-22T02:38:46Z"},
+	}
+	if err := writeStatementFile(filename, statement, filename); err != nil {
+		t.Fatalf("expected no error on writeStatementFile(), got %v", err)
+	}
+
+	var got types.InTotoStatement
+	if err := decodeJSONFile(filename, &got); err != nil {
+		t.Fatalf("expected no error decoding the statement, got %v", err)
+	}
+	if string(got.Scanner.Result) != sarif {
+		t.Errorf("got scanner result %s, wanted the SARIF document as-is", got.Scanner.Result)
+	}
+	got.Scanner.Result = nil
+	if got.Invocation != statement.Invocation || got.Scanner.URI != statement.Scanner.URI || got.Scanner.Version != statement.Scanner.Version || got.Metadata != statement.Metadata {
+		t.Errorf("got statement %+v, wanted %+v", got, statement)
+	}
+}
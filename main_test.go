@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/types"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestWriteAttestation(t *testing.T) {
+	subject, err := name.NewDigest("cgr.dev/chainguard/static@sha256:0000000000000000000000000000000000000000000000000000000000000000")
+	if err != nil {
+		t.Fatalf("expected no error on name.NewDigest(), got %v", err)
+	}
+	var predicate types.InTotoStatement
+	predicate.Scanner.URI = "https://github.com/anchore/grype"
+	filename := filepath.Join(t.TempDir(), "attestation.json")
+	if err := writeAttestation(filename, &subject, predicate); err != nil {
+		t.Fatalf("expected no error on writeAttestation(), got %v", err)
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("expected no error reading the attestation, got %v", err)
+	}
+	var envelope types.DSSEEnvelope
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		t.Fatalf("expected no error decoding the envelope, got %v", err)
+	}
+	if envelope.PayloadType != "application/vnd.in-toto+json" || envelope.Signatures == nil || len(envelope.Signatures) != 0 {
+		t.Errorf("expected an unsigned in-toto envelope, got %+v", envelope)
+	}
+	var statement types.InTotoV01Statement
+	if err := json.Unmarshal(envelope.Payload, &statement); err != nil {
+		t.Fatalf("expected no error decoding the statement, got %v", err)
+	}
+	if statement.PredicateType != attTypeVuln || statement.Predicate.Scanner.URI != "https://github.com/anchore/grype" {
+		t.Errorf("expected the vuln predicate, got %+v", statement)
+	}
+	if len(statement.Subject) != 1 || statement.Subject[0].Name != "cgr.dev/chainguard/static" || statement.Subject[0].Digest["sha256"] != "0000000000000000000000000000000000000000000000000000000000000000" {
+		t.Errorf("expected the image pinned by digest as the subject, got %+v", statement.Subject)
+	}
+}
+
+func TestRepoDigest(t *testing.T) {
+	for _, tc := range []struct {
+		repoDigests []string
+		expected    string
+	}{
+		{nil, ""},
+		{[]string{"cgr.dev/chainguard/static", "cgr.dev/chainguard/static@sha256:abc"}, "sha256:abc"},
+		{[]string{"cgr.dev/chainguard/static@"}, ""},
+	} {
+		if got := repoDigest(tc.repoDigests); got != tc.expected {
+			t.Errorf("expected repoDigest(%v) to be %q, got %q", tc.repoDigests, tc.expected, got)
+		}
+	}
+}
+
+func TestKnownInvocation(t *testing.T) {
+	if got := knownInvocation("unknown"); got != "" {
+		t.Errorf("expected an unknown invocation to be recorded as empty, got %q", got)
+	}
+	if got := knownInvocation("https://github.com/chainguard-dev/rumble/actions/runs/1"); got != "https://github.com/chainguard-dev/rumble/actions/runs/1" {
+		t.Errorf("expected a known invocation to be recorded as is, got %q", got)
+	}
+}
+
+func TestCheckTableConfig(t *testing.T) {
+	if err := checkTableConfig("GCS", map[string]string{"--gcs-prefix": "gs://bucket"}); err != nil {
+		t.Errorf("expected no error on checkTableConfig() with every setting, got %v", err)
+	}
+	err := checkTableConfig("Kafka", map[string]string{"--kafka-topic": "", "--kafka-brokers": ""})
+	if err == nil || err.Error() != "missing Kafka configuration, set the following flags (or environment variables): --kafka-brokers, --kafka-topic" {
+		t.Errorf("expected an error listing the missing Kafka settings, got %v", err)
+	}
+}
+
+func TestPreviousScans(t *testing.T) {
+	// A digest-pinned reference matches earlier scans of its repository
+	// and tag, not only of itself
+	filter := previousScans(&types.ImageScanSummary{Image: "cgr.dev/chainguard/static@sha256:abc", Repository: "cgr.dev/chainguard/static", Tag: "latest", Scanner: "grype"})
+	if filter.Image != "" || filter.Repository != "cgr.dev/chainguard/static" || filter.Tag != "latest" || filter.Scanner != "grype" || filter.Limit != 1 {
+		t.Errorf("expected a filter on the repository and tag, got %+v", filter)
+	}
+	filter = previousScans(&types.ImageScanSummary{Image: "oci-dir:/tmp/layout", Scanner: "trivy"})
+	if filter.Image != "oci-dir:/tmp/layout" || filter.Repository != "" || filter.Scanner != "trivy" {
+		t.Errorf("expected a filter on the image without a repository, got %+v", filter)
+	}
+}
+
+func TestLatestPreviousScan(t *testing.T) {
+	summary := &types.ImageScanSummary{Image: "cgr.dev/chainguard/static:latest", Repository: "cgr.dev/chainguard/static", Tag: "latest", Scanner: "grype"}
+	old := &types.ImageScanSummary{ID: "old", Image: summary.Image}
+	for _, tc := range []struct {
+		name     string
+		byRepo   []*types.ImageScanSummary
+		repoErr  error
+		expected *types.ImageScanSummary
+	}{
+		{"by repository", []*types.ImageScanSummary{{ID: "new"}}, nil, &types.ImageScanSummary{ID: "new"}},
+		{"without a repository", nil, nil, old},
+		{"without the column", nil, errors.New("googleapi: Error 400: Unrecognized name: repository at [1:200], invalidQuery"), old},
+	} {
+		filters := []query.Filter{}
+		previous, err := latestPreviousScan(summary, previousScans(summary), func(filter query.Filter) ([]*types.ImageScanSummary, error) {
+			filters = append(filters, filter)
+			if filter.Repository != "" {
+				return tc.byRepo, tc.repoErr
+			}
+			return []*types.ImageScanSummary{old}, nil
+		})
+		if err != nil || previous == nil || previous.ID != tc.expected.ID {
+			t.Errorf("%s: expected scan %s, got %+v (err %v)", tc.name, tc.expected.ID, previous, err)
+		}
+		if len(filters) == 2 && (filters[1].Image != summary.Image || filters[1].Repository != "" || filters[1].Scanner != "grype") {
+			t.Errorf("%s: expected to fall back on the image, got %+v", tc.name, filters[1])
+		}
+	}
+
+	_, err := latestPreviousScan(summary, previousScans(summary), func(filter query.Filter) ([]*types.ImageScanSummary, error) {
+		return nil, errors.New("permission denied")
+	})
+	if err == nil {
+		t.Errorf("expected other errors to be returned")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/chainguard-dev/rumble/pkg/scanner"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// scannerAll runs every supported scanner
+const scannerAll = "all"
+
+// scannerNames returns the scanners named by --scanner, which may be a
+// single scanner, a comma-separated list or "all"
+func scannerNames(value string) []string {
+	if value == scannerAll {
+		return []string{scanner.Grype, scanner.Trivy}
+	}
+	names := []string{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// runScanners scans with each scanner at once (at most concurrency at a
+// time, or all of them if zero) by running rumble itself with the same
+// arguments, so each scan is recorded as usual with its own temp files.
+// The counts are compared once all are done, the summaries of those that
+// succeeded returned and written as a JSON array to summaryOutput if set,
+// and each scan's run manifest merged into manifest (whose metrics are
+// written for all of them). The scanners' output is written to
+// diagnosticsFile if set, one after another. The scans' stderr is sent to
+// stderr as is if set (for --progress events), and prefixed like stdout
+// otherwise.
+func runScanners(ctx context.Context, names []string, args []string, concurrency int, summaryOutput string, diagnosticsFile string, manifest *runManifest, stderr io.Writer) ([]*types.ImageScanSummary, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "rumble-scanners-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if concurrency <= 0 || concurrency > len(names) {
+		concurrency = len(names)
+	}
+
+	// Each line of output is prefixed with the scanner it came from
+	var mu sync.Mutex
+	sem := make(chan struct{}, concurrency)
+	summaries := make([]*types.ImageScanSummary, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			out := &prefixWriter{w: os.Stdout, prefix: "[" + name + "] ", mu: &mu}
+			defer out.Flush()
+			summaryFile := filepath.Join(dir, name+".json")
+			// Flags given later win, so these override the originals
+			cmd := exec.CommandContext(ctx, self, append(append([]string{}, args...), "--scanner", name, "--summary-output", summaryFile, "--run-manifest", filepath.Join(dir, name+".manifest.json"), "--diagnostics-file", filepath.Join(dir, name+".diagnostics"), "--metrics-file", "")...)
+			cmd.Stdout = out
+			cmd.Stderr = out
+			if stderr != nil {
+				cmd.Stderr = stderr
+			}
+			if err := cmd.Run(); err != nil {
+				errs[i] = fmt.Errorf("scan with %s failed: %w", name, err)
+				return
+			}
+			b, err := os.ReadFile(summaryFile)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			summary := &types.ImageScanSummary{}
+			if err := json.Unmarshal(b, summary); err != nil {
+				errs[i] = err
+				return
+			}
+			summaries[i] = summary
+		}(i, name)
+	}
+	wg.Wait()
+	for i, name := range names {
+		manifest.merge(filepath.Join(dir, name+".manifest.json"), name, errs[i])
+	}
+	if diagnosticsFile != "" {
+		var all strings.Builder
+		for _, name := range names {
+			// A scan that failed before running its scanner wrote none
+			b, _ := os.ReadFile(filepath.Join(dir, name+".diagnostics"))
+			fmt.Fprintf(&all, "==> %s <==\n%s\n", name, b)
+		}
+		writeDiagnostics(diagnosticsFile, all.String())
+	}
+
+	if err := printScannerComparison(os.Stdout, names, summaries); err != nil {
+		return nil, err
+	}
+	done := []*types.ImageScanSummary{}
+	for _, summary := range summaries {
+		if summary != nil {
+			done = append(done, summary)
+		}
+	}
+	if summaryOutput != "" {
+		b, err := json.Marshal(done)
+		if err != nil {
+			return done, err
+		}
+		if err := os.WriteFile(summaryOutput, b, 0644); err != nil {
+			return done, err
+		}
+	}
+	failed := []string{}
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return done, fmt.Errorf("%d of %d scan(s) failed: %s", len(failed), len(names), strings.Join(failed, "; "))
+	}
+	return done, nil
+}
+
+// printScannerComparison prints the counts found by each scanner side by side
+func printScannerComparison(w io.Writer, names []string, summaries []*types.ImageScanSummary) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCANNER\tVERSION\tDIGEST\tCRITICAL\tHIGH\tMEDIUM\tLOW\tOTHER\tTOTAL")
+	for i, summary := range summaries {
+		if summary == nil {
+			fmt.Fprintf(tw, "%s\t(failed)\t\t\t\t\t\t\t\n", names[i])
+			continue
+		}
+		other := summary.NegligibleCveCount + summary.UnknownCveCount
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\n", summary.Scanner, summary.ScannerVersion, summary.Digest,
+			summary.CritCveCount, summary.HighCveCount, summary.MedCveCount, summary.LowCveCount, other, summary.TotCveCount)
+	}
+	return tw.Flush()
+}
+
+// prefixWriter writes each complete line with a prefix, holding a lock
+// shared with other writers so lines from concurrent scans don't interleave
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return len(b), err
+		}
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes any partial last line
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		p.writeLine(append(p.buf, '\n'))
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := fmt.Fprintf(p.w, "%s%s", p.prefix, line)
+	return err
+}
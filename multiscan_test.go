@@ -0,0 +1,22 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScannerNames(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected []string
+	}{
+		{"grype", []string{"grype"}},
+		{"all", []string{"grype", "trivy"}},
+		{" trivy, grype ,trivy,", []string{"trivy", "grype"}},
+		{"", []string{}},
+	} {
+		if got := scannerNames(tc.value); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("expected scannerNames(%q) to be %v, got %v", tc.value, tc.expected, got)
+		}
+	}
+}
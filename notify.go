@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/chainguard-dev/rumble/pkg/kev"
+	"github.com/chainguard-dev/rumble/pkg/notify"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// notifyFlags configure where to notify about each scan once it is done
+type notifyFlags struct {
+	slackWebhookURL *string
+	slackTemplate   *string
+	webhookURL      *string
+	webhookTemplate *string
+	teamsWebhookURL *string
+	teamsTemplate   *string
+	chatWebhookURL  *string
+	chatTemplate    *string
+	link            *string
+	smtpServer      *string
+	smtpTLS         *string
+	smtpUsername    *string
+	smtpPassword    *string
+	emailFrom       *string
+	emailTo         *string
+	emailSubject    *string
+	emailTemplate   *string
+	emailThreshold  *string
+	emailDigest     *bool
+	pagerDutyKey    *string
+	opsgenieAPIKey  *string
+	opsgenieURL     *string
+	kevCacheDir     *string
+}
+
+func addNotifyFlags(fs *flag.FlagSet) *notifyFlags {
+	return &notifyFlags{
+		slackWebhookURL: fs.String("slack-webhook-url", os.Getenv("RUMBLE_SLACK_WEBHOOK_URL"), "Slack incoming webhook to post a message about each scan to (defaults to $RUMBLE_SLACK_WEBHOOK_URL)"),
+		slackTemplate:   fs.String("slack-template", "", "Go template file rendering the Slack message, instead of the default"),
+		webhookURL:      fs.String("webhook-url", os.Getenv("RUMBLE_WEBHOOK_URL"), "URL to post each scan to, as JSON unless --webhook-template says otherwise (defaults to $RUMBLE_WEBHOOK_URL)"),
+		webhookTemplate: fs.String("webhook-template", "", "Go template file rendering the --webhook-url request body, instead of the default JSON"),
+		teamsWebhookURL: fs.String("teams-webhook-url", os.Getenv("RUMBLE_TEAMS_WEBHOOK_URL"), "Microsoft Teams incoming webhook to post a card about each scan to (defaults to $RUMBLE_TEAMS_WEBHOOK_URL)"),
+		teamsTemplate:   fs.String("teams-template", "", "Go template file rendering the --teams-webhook-url request body, instead of the default card"),
+		chatWebhookURL:  fs.String("google-chat-webhook-url", os.Getenv("RUMBLE_GOOGLE_CHAT_WEBHOOK_URL"), "Google Chat space webhook to post a card about each scan to (defaults to $RUMBLE_GOOGLE_CHAT_WEBHOOK_URL)"),
+		chatTemplate:    fs.String("google-chat-template", "", "Go template file rendering the --google-chat-webhook-url request body, instead of the default card"),
+		link:            fs.String("notify-link", "", "Go template rendering the link to each scan in notifications, e.g. \"https://reports.example.com/{{ .Summary.ID }}\", instead of its BigQuery table"),
+		smtpServer:      fs.String("smtp-server", os.Getenv("RUMBLE_SMTP_SERVER"), "SMTP server (host:port) to send --email-to emails through (defaults to $RUMBLE_SMTP_SERVER)"),
+		smtpTLS:         fs.String("smtp-tls", notify.TLSStartTLS, "How to secure the connection to --smtp-server, (\"starttls\", \"tls\" for implicit TLS, or \"none\")"),
+		smtpUsername:    fs.String("smtp-username", os.Getenv("RUMBLE_SMTP_USERNAME"), "Username to authenticate to --smtp-server with (defaults to $RUMBLE_SMTP_USERNAME)"),
+		smtpPassword:    fs.String("smtp-password", os.Getenv("RUMBLE_SMTP_PASSWORD"), "Password to authenticate to --smtp-server with (defaults to $RUMBLE_SMTP_PASSWORD)"),
+		emailFrom:       fs.String("email-from", os.Getenv("RUMBLE_EMAIL_FROM"), "Address to send emails from (defaults to $RUMBLE_EMAIL_FROM)"),
+		emailTo:         fs.String("email-to", "", "Comma-separated addresses to email about each scan over --email-threshold"),
+		emailSubject:    fs.String("email-subject", "", "Go template file rendering the subject of emails about a single scan, instead of the default"),
+		emailTemplate:   fs.String("email-template", "", "Go template file rendering the plain text body of emails (once per scan in a digest), instead of the default"),
+		emailThreshold:  fs.String("email-threshold", "", "Only email about scans with at least these counts of any severity, e.g. \"critical=1,high=5\" (\"total\" for the total count), instead of every scan"),
+		emailDigest:     fs.Bool("email-digest", false, "If enabled, send a single email about the scans of all of --scanner's scanners, instead of one per scan"),
+		pagerDutyKey:    fs.String("pagerduty-routing-key", os.Getenv("RUMBLE_PAGERDUTY_ROUTING_KEY"), "PagerDuty Events API v2 routing key to trigger an incident with for each KEV or new fixable critical CVE, per image (defaults to $RUMBLE_PAGERDUTY_ROUTING_KEY)"),
+		opsgenieAPIKey:  fs.String("opsgenie-api-key", os.Getenv("RUMBLE_OPSGENIE_API_KEY"), "Opsgenie API key to create an alert with for each KEV or new fixable critical CVE, per image (defaults to $RUMBLE_OPSGENIE_API_KEY)"),
+		opsgenieURL:     fs.String("opsgenie-url", notify.DefaultOpsgenieURL, "Opsgenie alerts API, e.g. https://api.eu.opsgenie.com/v2/alerts for EU accounts"),
+		kevCacheDir:     fs.String("kev-cache-dir", kev.DefaultCacheDir(), "directory used to cache CISA's Known Exploited Vulnerabilities catalog for --pagerduty-routing-key and --opsgenie-api-key"),
+	}
+}
+
+// namedNotifier is a configured notifier, named for its warnings
+type namedNotifier struct {
+	name string
+	notify.Notifier
+}
+
+// notifiers returns the configured notifiers, having read their templates so
+// that a broken one fails the run before the scan rather than after
+func (f *notifyFlags) notifiers() ([]namedNotifier, error) {
+	notifiers := []namedNotifier{}
+	if *f.slackTemplate != "" && *f.slackWebhookURL == "" {
+		return nil, fmt.Errorf("--slack-template requires --slack-webhook-url")
+	}
+	if *f.webhookTemplate != "" && *f.webhookURL == "" {
+		return nil, fmt.Errorf("--webhook-template requires --webhook-url")
+	}
+	for _, templated := range []struct{ url, template, flag string }{
+		{*f.teamsWebhookURL, *f.teamsTemplate, "teams"},
+		{*f.chatWebhookURL, *f.chatTemplate, "google-chat"},
+	} {
+		if templated.template != "" && templated.url == "" {
+			return nil, fmt.Errorf("--%s-template requires --%s-webhook-url", templated.flag, templated.flag)
+		}
+	}
+	if *f.slackWebhookURL != "" {
+		tmpl, err := readTemplate(*f.slackTemplate)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, namedNotifier{"Slack", &notify.Slack{WebhookURL: *f.slackWebhookURL, Template: tmpl}})
+	}
+	if *f.webhookURL != "" {
+		tmpl, err := readTemplate(*f.webhookTemplate)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, namedNotifier{"webhook", &notify.Webhook{URL: *f.webhookURL, Template: tmpl}})
+	}
+	if *f.teamsWebhookURL != "" {
+		tmpl, err := readTemplate(*f.teamsTemplate)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, namedNotifier{"Teams", &notify.Teams{WebhookURL: *f.teamsWebhookURL, Template: tmpl}})
+	}
+	if *f.chatWebhookURL != "" {
+		tmpl, err := readTemplate(*f.chatTemplate)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, namedNotifier{"Google Chat", &notify.GoogleChat{WebhookURL: *f.chatWebhookURL, Template: tmpl}})
+	}
+	// The incident notifiers share a KEV client, so the catalog is
+	// fetched once
+	if *f.pagerDutyKey != "" || *f.opsgenieAPIKey != "" {
+		client := kev.NewClient(*f.kevCacheDir)
+		if *f.pagerDutyKey != "" {
+			notifiers = append(notifiers, namedNotifier{"PagerDuty", &notify.PagerDuty{RoutingKey: *f.pagerDutyKey, KEV: client}})
+		}
+		if *f.opsgenieAPIKey != "" {
+			notifiers = append(notifiers, namedNotifier{"Opsgenie", &notify.Opsgenie{APIKey: *f.opsgenieAPIKey, URL: *f.opsgenieURL, KEV: client}})
+		}
+	}
+	email, err := f.email()
+	if err != nil {
+		return nil, err
+	}
+	if email != nil {
+		notifiers = append(notifiers, namedNotifier{"email", email})
+	}
+	return notifiers, nil
+}
+
+// email returns the configured email notifier, or nil if --email-to isn't set
+func (f *notifyFlags) email() (*notify.Email, error) {
+	if *f.emailTo == "" {
+		return nil, nil
+	}
+	if *f.smtpServer == "" {
+		return nil, fmt.Errorf("--email-to requires --smtp-server")
+	}
+	if *f.emailFrom == "" {
+		return nil, fmt.Errorf("--email-to requires --email-from")
+	}
+	switch *f.smtpTLS {
+	case notify.TLSStartTLS, notify.TLSImplicit, notify.TLSNone:
+	default:
+		return nil, fmt.Errorf("invalid --smtp-tls: %s", *f.smtpTLS)
+	}
+	to := []string{}
+	for _, addr := range strings.Split(*f.emailTo, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	threshold, err := notify.ParseThreshold(*f.emailThreshold)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := readTemplate(*f.emailSubject)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := readTemplate(*f.emailTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &notify.Email{
+		Server: &notify.SMTP{
+			Addr:     *f.smtpServer,
+			TLS:      *f.smtpTLS,
+			Username: *f.smtpUsername,
+			Password: *f.smtpPassword,
+		},
+		From:      *f.emailFrom,
+		To:        to,
+		Subject:   subject,
+		Template:  tmpl,
+		Threshold: threshold,
+	}, nil
+}
+
+// linkTemplate parses --notify-link, returning nil if it isn't set
+func (f *notifyFlags) linkTemplate() (*template.Template, error) {
+	if *f.link == "" {
+		return nil, nil
+	}
+	return notify.ParseTemplate("notify-link", *f.link)
+}
+
+// readTemplate reads a notification template, or returns nil for the
+// default if path is empty
+func readTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return notify.ReadTemplate(path)
+}
+
+// notifyScan sends a message about a scan to each notifier, only warning
+// about those that fail, as the scan itself has been recorded by then
+func notifyScan(ctx context.Context, notifiers []namedNotifier, msg *notify.Message) {
+	for _, n := range notifiers {
+		fmt.Printf("Sending %s notification for %s (scan_id=\"%s\")\n", n.name, msg.Summary.Image, msg.Summary.ID)
+		if err := n.Notify(ctx, msg); err != nil {
+			fmt.Printf("WARNING: could not send %s notification: %s\n", n.name, err.Error())
+		}
+	}
+}
+
+// emailDigest sends a single email about the scans of several scanners,
+// only warning if it fails, like notifyScan
+func emailDigest(ctx context.Context, email *notify.Email, summaries []*types.ImageScanSummary) {
+	if len(summaries) == 0 {
+		return
+	}
+	msgs := make([]*notify.Message, len(summaries))
+	for i, summary := range summaries {
+		msgs[i] = notify.NewMessage(summary, nil, nil)
+	}
+	fmt.Printf("Sending email digest for %d scan(s) of %s\n", len(summaries), summaries[0].Image)
+	if err := email.NotifyDigest(ctx, msgs); err != nil {
+		fmt.Printf("WARNING: could not send email digest: %s\n", err.Error())
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+const (
+	outputTable = "table"
+	outputWide  = "wide"
+	outputJSON  = "json"
+	outputCSV   = "csv"
+)
+
+var summaryHeaders = []string{
+	"time", "image", "scanner", "digest", "scanner_version", "scanner_db_version", "created",
+	"critical", "high", "medium", "low", "negligible", "unknown", "total",
+}
+
+// summaryFields returns the values of a summary in the order of summaryHeaders
+func summaryFields(s *types.ImageScanSummary) []string {
+	return []string{
+		s.Time, s.Image, s.Scanner, s.Digest, s.ScannerVersion, s.ScannerDbVersion, s.Created,
+		strconv.Itoa(s.CritCveCount), strconv.Itoa(s.HighCveCount), strconv.Itoa(s.MedCveCount),
+		strconv.Itoa(s.LowCveCount), strconv.Itoa(s.NegligibleCveCount), strconv.Itoa(s.UnknownCveCount),
+		strconv.Itoa(s.TotCveCount),
+	}
+}
+
+func checkOutputFormat(format string) error {
+	switch format {
+	case outputTable, outputWide, outputJSON, outputCSV:
+		return nil
+	}
+	return fmt.Errorf("invalid output format: %s", format)
+}
+
+// printSummaries writes scan summaries to w in the given output format
+func printSummaries(w io.Writer, format string, summaries []*types.ImageScanSummary) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(summaries)
+	case outputCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(summaryHeaders); err != nil {
+			return err
+		}
+		for _, s := range summaries {
+			if err := cw.Write(summaryFields(s)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case outputTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tIMAGE\tSCANNER\tCRITICAL\tHIGH\tMEDIUM\tLOW\tTOTAL")
+		for _, s := range summaries {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\n",
+				s.Time, s.Image, s.Scanner, s.CritCveCount, s.HighCveCount, s.MedCveCount, s.LowCveCount, s.TotCveCount)
+		}
+		return tw.Flush()
+	case outputWide:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for i, header := range summaryHeaders {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, strings.ToUpper(header))
+		}
+		fmt.Fprintln(tw)
+		for _, s := range summaries {
+			fmt.Fprintln(tw, strings.Join(summaryFields(s), "\t"))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("invalid output format: %s", format)
+}
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders values as a string of bar characters scaled to the
+// largest value
+func sparkline(values []int) string {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	ticks := make([]rune, len(values))
+	for i, v := range values {
+		if max == 0 {
+			ticks[i] = sparkTicks[0]
+			continue
+		}
+		ticks[i] = sparkTicks[v*(len(sparkTicks)-1)/max]
+	}
+	return string(ticks)
+}
+
+// printSparklines writes one sparkline per severity across summaries,
+// along with the first and last values
+func printSparklines(w io.Writer, summaries []*types.ImageScanSummary) error {
+	if len(summaries) == 0 {
+		_, err := fmt.Fprintln(w, "No scans found")
+		return err
+	}
+	series := []struct {
+		name  string
+		count func(*types.ImageScanSummary) int
+	}{
+		{"critical", func(s *types.ImageScanSummary) int { return s.CritCveCount }},
+		{"high", func(s *types.ImageScanSummary) int { return s.HighCveCount }},
+		{"medium", func(s *types.ImageScanSummary) int { return s.MedCveCount }},
+		{"low", func(s *types.ImageScanSummary) int { return s.LowCveCount }},
+		{"total", func(s *types.ImageScanSummary) int { return s.TotCveCount }},
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%d scans from %s to %s\n", len(summaries), summaries[0].Time, summaries[len(summaries)-1].Time)
+	for _, s := range series {
+		values := make([]int, len(summaries))
+		for i, summary := range summaries {
+			values[i] = s.count(summary)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d -> %d\n", s.name, sparkline(values), values[0], values[len(values)-1])
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,4 @@
+package api
+
+// The gRPC service served by "rumble serve" is generated from rumble.proto
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative rumble.proto
@@ -0,0 +1,251 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.12
+// source: rumble.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// RumbleClient is the client API for Rumble service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RumbleClient interface {
+	// SubmitScan queues a scan of an image, returning its job. The scan's
+	// result is delivered via StreamEvents.
+	SubmitScan(ctx context.Context, in *SubmitScanRequest, opts ...grpc.CallOption) (*Job, error)
+	// GetScan returns a recorded scan by its scan ID.
+	GetScan(ctx context.Context, in *GetScanRequest, opts ...grpc.CallOption) (*Scan, error)
+	// ListScans returns recorded scans, most recent first.
+	ListScans(ctx context.Context, in *ListScansRequest, opts ...grpc.CallOption) (*ListScansResponse, error)
+	// StreamEvents streams job state changes until the client disconnects.
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (Rumble_StreamEventsClient, error)
+}
+
+type rumbleClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRumbleClient(cc grpc.ClientConnInterface) RumbleClient {
+	return &rumbleClient{cc}
+}
+
+func (c *rumbleClient) SubmitScan(ctx context.Context, in *SubmitScanRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
+	err := c.cc.Invoke(ctx, "/rumble.v1.Rumble/SubmitScan", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rumbleClient) GetScan(ctx context.Context, in *GetScanRequest, opts ...grpc.CallOption) (*Scan, error) {
+	out := new(Scan)
+	err := c.cc.Invoke(ctx, "/rumble.v1.Rumble/GetScan", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rumbleClient) ListScans(ctx context.Context, in *ListScansRequest, opts ...grpc.CallOption) (*ListScansResponse, error) {
+	out := new(ListScansResponse)
+	err := c.cc.Invoke(ctx, "/rumble.v1.Rumble/ListScans", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rumbleClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (Rumble_StreamEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Rumble_ServiceDesc.Streams[0], "/rumble.v1.Rumble/StreamEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rumbleStreamEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Rumble_StreamEventsClient interface {
+	Recv() (*ScanEvent, error)
+	grpc.ClientStream
+}
+
+type rumbleStreamEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *rumbleStreamEventsClient) Recv() (*ScanEvent, error) {
+	m := new(ScanEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RumbleServer is the server API for Rumble service.
+// All implementations must embed UnimplementedRumbleServer
+// for forward compatibility
+type RumbleServer interface {
+	// SubmitScan queues a scan of an image, returning its job. The scan's
+	// result is delivered via StreamEvents.
+	SubmitScan(context.Context, *SubmitScanRequest) (*Job, error)
+	// GetScan returns a recorded scan by its scan ID.
+	GetScan(context.Context, *GetScanRequest) (*Scan, error)
+	// ListScans returns recorded scans, most recent first.
+	ListScans(context.Context, *ListScansRequest) (*ListScansResponse, error)
+	// StreamEvents streams job state changes until the client disconnects.
+	StreamEvents(*StreamEventsRequest, Rumble_StreamEventsServer) error
+	mustEmbedUnimplementedRumbleServer()
+}
+
+// UnimplementedRumbleServer must be embedded to have forward compatible implementations.
+type UnimplementedRumbleServer struct {
+}
+
+func (UnimplementedRumbleServer) SubmitScan(context.Context, *SubmitScanRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitScan not implemented")
+}
+func (UnimplementedRumbleServer) GetScan(context.Context, *GetScanRequest) (*Scan, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetScan not implemented")
+}
+func (UnimplementedRumbleServer) ListScans(context.Context, *ListScansRequest) (*ListScansResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListScans not implemented")
+}
+func (UnimplementedRumbleServer) StreamEvents(*StreamEventsRequest, Rumble_StreamEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedRumbleServer) mustEmbedUnimplementedRumbleServer() {}
+
+// UnsafeRumbleServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RumbleServer will
+// result in compilation errors.
+type UnsafeRumbleServer interface {
+	mustEmbedUnimplementedRumbleServer()
+}
+
+func RegisterRumbleServer(s grpc.ServiceRegistrar, srv RumbleServer) {
+	s.RegisterService(&Rumble_ServiceDesc, srv)
+}
+
+func _Rumble_SubmitScan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RumbleServer).SubmitScan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rumble.v1.Rumble/SubmitScan",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RumbleServer).SubmitScan(ctx, req.(*SubmitScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rumble_GetScan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RumbleServer).GetScan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rumble.v1.Rumble/GetScan",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RumbleServer).GetScan(ctx, req.(*GetScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rumble_ListScans_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListScansRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RumbleServer).ListScans(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rumble.v1.Rumble/ListScans",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RumbleServer).ListScans(ctx, req.(*ListScansRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rumble_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RumbleServer).StreamEvents(m, &rumbleStreamEventsServer{stream})
+}
+
+type Rumble_StreamEventsServer interface {
+	Send(*ScanEvent) error
+	grpc.ServerStream
+}
+
+type rumbleStreamEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *rumbleStreamEventsServer) Send(m *ScanEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Rumble_ServiceDesc is the grpc.ServiceDesc for Rumble service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Rumble_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rumble.v1.Rumble",
+	HandlerType: (*RumbleServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitScan",
+			Handler:    _Rumble_SubmitScan_Handler,
+		},
+		{
+			MethodName: "GetScan",
+			Handler:    _Rumble_GetScan_Handler,
+		},
+		{
+			MethodName: "ListScans",
+			Handler:    _Rumble_ListScans_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _Rumble_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "rumble.proto",
+}
@@ -0,0 +1,210 @@
+package apk
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Package is an APK package, as described by the .PKGINFO of its .apk file
+// or its entry in an APKINDEX
+type Package struct {
+	Name    string
+	Version string
+	Arch    string
+	Origin  string
+	License string
+}
+
+// Load reads the packages at path: an .apk file, an APKINDEX.tar.gz, or a
+// directory (such as a repository for one architecture) holding either
+func Load(path string) ([]*Package, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return loadFile(path)
+	}
+	files, err := filepath.Glob(filepath.Join(path, "*.apk"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		files = []string{filepath.Join(path, "APKINDEX.tar.gz")}
+	}
+	pkgs := []*Package{}
+	for _, file := range files {
+		loaded, err := loadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, loaded...)
+	}
+	return pkgs, nil
+}
+
+func loadFile(path string) ([]*Package, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.HasPrefix(filepath.Base(path), "APKINDEX") {
+		pkgs, err := ReadIndex(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		return pkgs, nil
+	}
+	pkg, err := ReadPackage(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return []*Package{pkg}, nil
+}
+
+// ReadPackage reads the .PKGINFO of an .apk file. The file's gzipped
+// segments (signature, control and data) are read as a single tarball.
+func ReadPackage(r io.Reader) (*Package, error) {
+	b, err := readFile(r, ".PKGINFO")
+	if err != nil {
+		return nil, err
+	}
+	pkg := &Package{}
+	lines := bufio.NewScanner(strings.NewReader(string(b)))
+	for lines.Scan() {
+		key, value, ok := strings.Cut(lines.Text(), " = ")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		switch key {
+		case "pkgname":
+			pkg.Name = value
+		case "pkgver":
+			pkg.Version = value
+		case "arch":
+			pkg.Arch = value
+		case "origin":
+			pkg.Origin = value
+		case "license":
+			pkg.License = value
+		}
+	}
+	if pkg.Name == "" || pkg.Version == "" {
+		return nil, fmt.Errorf(".PKGINFO has no pkgname or pkgver")
+	}
+	return pkg, nil
+}
+
+// ReadIndex reads the packages listed in an APKINDEX.tar.gz
+func ReadIndex(r io.Reader) ([]*Package, error) {
+	b, err := readFile(r, "APKINDEX")
+	if err != nil {
+		return nil, err
+	}
+	pkgs := []*Package{}
+	pkg := &Package{}
+	for _, line := range strings.Split(string(b)+"\n", "\n") {
+		if line == "" {
+			if pkg.Name != "" && pkg.Version != "" {
+				pkgs = append(pkgs, pkg)
+			}
+			pkg = &Package{}
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "P":
+			pkg.Name = value
+		case "V":
+			pkg.Version = value
+		case "A":
+			pkg.Arch = value
+		case "o":
+			pkg.Origin = value
+		case "L":
+			pkg.License = value
+		}
+	}
+	return pkgs, nil
+}
+
+// readFile returns the contents of the file called name in a gzipped
+// tarball, or in any of the tarballs of its gzip members in turn
+func readFile(r io.Reader, name string) ([]byte, error) {
+	br := bufio.NewReader(r)
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	for {
+		gz.Multistream(false)
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if hdr.Typeflag == tar.TypeReg && hdr.Name == name {
+				return io.ReadAll(tr)
+			}
+		}
+		if _, err := io.Copy(io.Discard, gz); err != nil {
+			return nil, err
+		}
+		if err := gz.Reset(br); errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("no %s in the archive", name)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// WriteRoot writes a minimal root filesystem to dir, holding an APK
+// database in which the packages are installed and an os-release for the
+// distro (e.g. "wolfi", or "alpine" with version "3.19"), so that scanners
+// match the packages as they would in an image of that distro
+func WriteRoot(dir string, pkgs []*Package, distro string, version string) error {
+	db := filepath.Join(dir, "lib", "apk", "db")
+	if err := os.MkdirAll(db, 0755); err != nil {
+		return err
+	}
+	sorted := append([]*Package{}, pkgs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	var installed strings.Builder
+	for _, pkg := range sorted {
+		fmt.Fprintf(&installed, "P:%s\nV:%s\n", pkg.Name, pkg.Version)
+		for _, field := range []struct{ key, value string }{{"A", pkg.Arch}, {"o", pkg.Origin}, {"L", pkg.License}} {
+			if field.value != "" {
+				fmt.Fprintf(&installed, "%s:%s\n", field.key, field.value)
+			}
+		}
+		installed.WriteString("\n")
+	}
+	if err := os.WriteFile(filepath.Join(db, "installed"), []byte(installed.String()), 0644); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "etc"), 0755); err != nil {
+		return err
+	}
+	osRelease := fmt.Sprintf("ID=%s\n", distro)
+	if version != "" {
+		osRelease += fmt.Sprintf("VERSION_ID=%s\n", version)
+	}
+	return os.WriteFile(filepath.Join(dir, "etc", "os-release"), []byte(osRelease), 0644)
+}
@@ -0,0 +1,80 @@
+package apk
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gzipTar returns a gzipped tarball holding the given files
+func gzipTar(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("expected no error on WriteHeader(), got %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("expected no error on Write(), got %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("expected no error on tw.Close(), got %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("expected no error on gz.Close(), got %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	// An .apk is a signature segment followed by the control segment
+	apk := append(gzipTar(t, map[string]string{".SIGN.RSA.key.rsa.pub": "signature"}), gzipTar(t, map[string]string{
+		".PKGINFO": "# Generated by melange\npkgname = openssl\npkgver = 3.2.1-r0\narch = x86_64\norigin = openssl\nlicense = Apache-2.0\n",
+	})...)
+	if err := os.WriteFile(filepath.Join(dir, "openssl-3.2.1-r0.apk"), apk, 0644); err != nil {
+		t.Fatalf("expected no error on os.WriteFile(), got %v", err)
+	}
+	pkgs, err := Load(dir)
+	if err != nil {
+		t.Fatalf("expected no error on Load(), got %v", err)
+	}
+	if len(pkgs) != 1 || *pkgs[0] != (Package{Name: "openssl", Version: "3.2.1-r0", Arch: "x86_64", Origin: "openssl", License: "Apache-2.0"}) {
+		t.Errorf("expected openssl 3.2.1-r0, got %+v", pkgs)
+	}
+
+	index := filepath.Join(dir, "APKINDEX.tar.gz")
+	if err := os.WriteFile(index, gzipTar(t, map[string]string{
+		"APKINDEX": "C:Q1abc=\nP:busybox\nV:1.36.1-r2\nA:x86_64\nL:GPL-2.0-only\n\nP:libcrypto3\nV:3.2.1-r0\nA:x86_64\no:openssl\n",
+	}), 0644); err != nil {
+		t.Fatalf("expected no error on os.WriteFile(), got %v", err)
+	}
+	if pkgs, err = Load(index); err != nil {
+		t.Fatalf("expected no error on Load(), got %v", err)
+	}
+	if len(pkgs) != 2 || pkgs[0].Name != "busybox" || pkgs[1].Origin != "openssl" {
+		t.Errorf("expected busybox and libcrypto3, got %+v", pkgs)
+	}
+
+	root := t.TempDir()
+	if err := WriteRoot(root, pkgs, "alpine", "3.19"); err != nil {
+		t.Fatalf("expected no error on WriteRoot(), got %v", err)
+	}
+	installed, err := os.ReadFile(filepath.Join(root, "lib", "apk", "db", "installed"))
+	if err != nil {
+		t.Fatalf("expected the APK database to be written, got %v", err)
+	}
+	if !strings.Contains(string(installed), "P:libcrypto3\nV:3.2.1-r0\nA:x86_64\no:openssl\n\n") {
+		t.Errorf("expected libcrypto3 to be installed, got %q", installed)
+	}
+	osRelease, err := os.ReadFile(filepath.Join(root, "etc", "os-release"))
+	if err != nil || string(osRelease) != "ID=alpine\nVERSION_ID=3.19\n" {
+		t.Errorf("expected an alpine 3.19 os-release, got %q (%v)", osRelease, err)
+	}
+}
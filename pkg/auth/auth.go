@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrUnauthenticated is returned for a missing or unknown token
+	ErrUnauthenticated = errors.New("a valid API token is required")
+
+	// ErrForbidden is returned for a token without the scope needed
+	ErrForbidden = errors.New("the API token doesn't allow this")
+)
+
+// submitMethods are the gRPC methods needing config.ScopeSubmit, where every
+// other method needs config.ScopeRead
+var submitMethods = map[string]bool{
+	"/rumble.v1.Rumble/SubmitScan": true,
+}
+
+type token struct {
+	name   string
+	sum    []byte
+	scopes map[string]bool
+}
+
+// Authenticator checks API tokens, sent as bearer tokens (or as the
+// password of basic auth, for browsers) over HTTP and in the
+// "authorization" metadata over gRPC. An Authenticator without tokens
+// allows everything, so the API stays open until tokens are configured.
+type Authenticator struct {
+	tokens []token
+}
+
+// New returns an Authenticator accepting these tokens, which are expected
+// to be validated already, e.g. by config.Parse
+func New(tokens []config.Token) (*Authenticator, error) {
+	a := &Authenticator{}
+	for _, t := range tokens {
+		sum, err := hex.DecodeString(t.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("token %s: %w", t.Name, err)
+		}
+		if t.Token != "" {
+			s := sha256.Sum256([]byte(t.Token))
+			sum = s[:]
+		}
+		scopes := map[string]bool{}
+		for _, scope := range t.Scopes {
+			scopes[scope] = true
+		}
+		a.tokens = append(a.tokens, token{name: t.Name, sum: sum, scopes: scopes})
+	}
+	return a, nil
+}
+
+// Enabled reports whether tokens are required
+func (a *Authenticator) Enabled() bool {
+	return len(a.tokens) > 0
+}
+
+// Authorize checks that a token grants a scope, returning the token's name
+func (a *Authenticator) Authorize(presented string, scope string) (string, error) {
+	if !a.Enabled() {
+		return "", nil
+	}
+	if presented == "" {
+		return "", ErrUnauthenticated
+	}
+	// Tokens are compared by their hashes, in constant time, so neither
+	// the comparison nor where it stops leaks a token
+	sum := sha256.Sum256([]byte(presented))
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(sum[:], t.sum) != 1 {
+			continue
+		}
+		if !t.scopes[scope] {
+			return t.name, fmt.Errorf("%w: %s has no %q scope", ErrForbidden, t.name, scope)
+		}
+		return t.name, nil
+	}
+	return "", ErrUnauthenticated
+}
+
+// UnaryInterceptor checks the token of each unary gRPC call
+func (a *Authenticator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := a.authorizeGRPC(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor checks the token of each streaming gRPC call
+func (a *Authenticator) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := a.authorizeGRPC(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (a *Authenticator) authorizeGRPC(ctx context.Context, method string) error {
+	scope := config.ScopeRead
+	if submitMethods[method] {
+		scope = config.ScopeSubmit
+	}
+	presented := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			presented = bearer(values[0])
+		}
+	}
+	_, err := a.Authorize(presented, scope)
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case err != nil:
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+// Handler requires a token with a scope for every request to h
+func (a *Authenticator) Handler(scope string, h http.Handler) http.Handler {
+	if !a.Enabled() {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented := bearer(r.Header.Get("Authorization"))
+		if _, password, ok := r.BasicAuth(); ok {
+			presented = password
+		}
+		_, err := a.Authorize(presented, scope)
+		switch {
+		case errors.Is(err, ErrUnauthenticated):
+			w.Header().Set("WWW-Authenticate", `Basic realm="rumble", charset="UTF-8"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			h.ServeHTTP(w, r)
+		}
+	})
+}
+
+// bearer returns the token of a "Bearer" authorization header
+func bearer(header string) string {
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func testAuthenticator(t *testing.T) *Authenticator {
+	a, err := New([]config.Token{
+		{Name: "dashboards", Token: "read-token", Scopes: []string{config.ScopeRead}},
+		// sha256 of "ci-token"
+		{Name: "ci", SHA256: "948b8c2427cd29047839b8e4a27a08763f8befbafa86be5cce8e46217d75e58a", Scopes: []string{config.ScopeRead, config.ScopeSubmit}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error on New(), got %v", err)
+	}
+	return a
+}
+
+func TestAuthorize(t *testing.T) {
+	a := testAuthenticator(t)
+	for _, tc := range []struct {
+		token string
+		scope string
+		name  string
+		err   error
+	}{
+		{"read-token", config.ScopeRead, "dashboards", nil},
+		{"read-token", config.ScopeSubmit, "dashboards", ErrForbidden},
+		{"ci-token", config.ScopeSubmit, "ci", nil},
+		{"wrong", config.ScopeRead, "", ErrUnauthenticated},
+		{"", config.ScopeRead, "", ErrUnauthenticated},
+	} {
+		name, err := a.Authorize(tc.token, tc.scope)
+		if name != tc.name || !errors.Is(err, tc.err) {
+			t.Errorf("Authorize(%q, %q) = %q, %v, wanted %q, %v", tc.token, tc.scope, name, err, tc.name, tc.err)
+		}
+	}
+
+	// Without tokens, everything is allowed
+	open, _ := New(nil)
+	if _, err := open.Authorize("", config.ScopeSubmit); err != nil {
+		t.Errorf("expected no error without tokens, got %v", err)
+	}
+}
+
+func TestUnaryInterceptor(t *testing.T) {
+	interceptor := testAuthenticator(t).UnaryInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	for _, tc := range []struct {
+		method string
+		token  string
+		code   codes.Code
+	}{
+		{"/rumble.v1.Rumble/GetScan", "Bearer read-token", codes.OK},
+		{"/rumble.v1.Rumble/SubmitScan", "Bearer read-token", codes.PermissionDenied},
+		{"/rumble.v1.Rumble/SubmitScan", "bearer ci-token", codes.OK},
+		{"/rumble.v1.Rumble/ListScans", "", codes.Unauthenticated},
+	} {
+		ctx := context.Background()
+		if tc.token != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tc.token))
+		}
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler)
+		if status.Code(err) != tc.code {
+			t.Errorf("expected %s calling %s with %q, got %v", tc.code, tc.method, tc.token, err)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	h := testAuthenticator(t).Handler(config.ScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range []struct {
+		header string
+		status int
+	}{
+		{"Bearer read-token", http.StatusOK},
+		{"Basic " + base64.StdEncoding.EncodeToString([]byte("anyone:ci-token")), http.StatusOK},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/queue", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("expected status %d with %q, got %d", tc.status, tc.header, w.Code)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("expected a WWW-Authenticate header with status 401")
+		}
+	}
+}
+
+func TestLoadSecret(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		data := base64.StdEncoding.EncodeToString([]byte(`{"tokens": [{"name": "dashboards", "token": "read-token", "scopes": ["read"]}]}`))
+		json.NewEncoder(w).Encode(map[string]interface{}{"payload": map[string]string{"data": data}})
+	}))
+	defer srv.Close()
+
+	tokens, err := LoadSecret(context.Background(), "projects/p/secrets/rumble-tokens", option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("expected no error on LoadSecret(), got %v", err)
+	}
+	if path != "/v1/projects/p/secrets/rumble-tokens/versions/latest:access" {
+		t.Errorf("expected the latest version to be accessed, got %s", path)
+	}
+	if len(tokens) != 1 || tokens[0].Name != "dashboards" {
+		t.Errorf("expected the dashboards token, got %+v", tokens)
+	}
+
+	if _, err := LoadSecret(context.Background(), "rumble-tokens"); err == nil {
+		t.Errorf("expected error on LoadSecret() with an invalid name, got nil")
+	}
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"google.golang.org/api/option"
+	secretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// LoadSecret reads tokens from a Google Secret Manager secret, given as
+// "projects/<project>/secrets/<secret>" (for its latest version) or with
+// "/versions/<version>". The secret has the same JSON as the config file,
+// e.g. {"tokens": [...]}.
+func LoadSecret(ctx context.Context, name string, opts ...option.ClientOption) ([]config.Token, error) {
+	parts := strings.Split(name, "/")
+	switch {
+	case len(parts) == 4 && parts[0] == "projects" && parts[2] == "secrets":
+		name += "/versions/latest"
+	case len(parts) == 6 && parts[0] == "projects" && parts[2] == "secrets" && parts[4] == "versions":
+	default:
+		return nil, fmt.Errorf("invalid secret %q, expected projects/<project>/secrets/<secret>[/versions/<version>]", name)
+	}
+	svc, err := secretmanager.NewService(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := svc.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("reading secret %s: %w", name, err)
+	}
+	if resp.Payload == nil {
+		return nil, fmt.Errorf("secret %s is empty", name)
+	}
+	b, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return nil, fmt.Errorf("reading secret %s: %w", name, err)
+	}
+	cfg, err := config.Parse(b, "secret "+name)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Tokens, nil
+}
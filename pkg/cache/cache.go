@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Entries not used for this long are removed when new ones are added. Keys
+// include the scanner database checksum, so entries are rarely useful for
+// longer than the database lasts anyway.
+const maxAge = 7 * 24 * time.Hour
+
+// Cache keeps scanner output files on disk, keyed by what determines them,
+// so repeated scans of the same image (e.g. matrix CI jobs) are instant
+type Cache struct {
+	Dir string
+}
+
+// DefaultDir returns the directory used to cache scan results when none is
+// provided explicitly
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "rumble", "scans")
+}
+
+// Key returns the key for a scan of an image digest with a scanner and its
+// database, along with any options that change the scanner's output
+func Key(digest string, scanner string, dbChecksum string, options ...string) string {
+	h := sha256.New()
+	for _, part := range append([]string{digest, scanner, dbChecksum}, options...) {
+		// Length-prefixed, so different parts can't run together
+		fmt.Fprintf(h, "%d:%s\n", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) entryDir(key string) string {
+	return filepath.Join(c.Dir, key)
+}
+
+// Get copies the named files of an entry to their destination paths,
+// returning false if the entry (or any of the files) isn't cached
+func (c *Cache) Get(key string, files map[string]string) (bool, error) {
+	dir := c.entryDir(key)
+	for name := range files {
+		if _, err := os.Stat(filepath.Join(dir, name)); os.IsNotExist(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+	}
+	for name, dst := range files {
+		if err := copyFile(filepath.Join(dir, name), dst); err != nil {
+			return false, err
+		}
+	}
+	// Mark the entry as used, so it isn't evicted
+	now := time.Now()
+	os.Chtimes(dir, now, now)
+	return true, nil
+}
+
+// Put copies files (names to source paths) into an entry, replacing any
+// existing entry, and evicts entries that haven't been used recently
+func (c *Cache) Put(key string, files map[string]string) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	// Write to a temporary directory and rename it into place, so readers
+	// never see a partial entry
+	tmp, err := os.MkdirTemp(c.Dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	for name, src := range files {
+		if strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("invalid cached file name %q", name)
+		}
+		if err := copyFile(src, filepath.Join(tmp, name)); err != nil {
+			return err
+		}
+	}
+	dir := c.entryDir(key)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return err
+	}
+	return c.evict(time.Now())
+}
+
+// evict removes entries last used before maxAge ago
+func (c *Cache) evict(now time.Time) error {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.IsDir() || now.Sub(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(c.Dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	key := Key("sha256:abc", "grype", "sha256:db", "sarif")
+	if key != Key("sha256:abc", "grype", "sha256:db", "sarif") {
+		t.Errorf("expected the same key for the same scan")
+	}
+	for _, other := range []string{
+		Key("sha256:abc", "grype", "sha256:db2", "sarif"),
+		Key("sha256:abc", "trivy", "sha256:db", "sarif"),
+		Key("sha256:abc", "grype", "sha256:db"),
+		Key("sha256:abc", "grype", "sha256:dbs", "arif"),
+	} {
+		if other == key {
+			t.Errorf("expected a different key, got the same %s", key)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	c := &Cache{Dir: filepath.Join(dir, "cache")}
+	src := filepath.Join(dir, "scan.json")
+	if err := os.WriteFile(src, []byte(`{"matches":[]}`), 0644); err != nil {
+		t.Fatalf("expected no error writing %s, got %v", src, err)
+	}
+	dst := filepath.Join(dir, "restored.json")
+
+	if hit, err := c.Get("key", map[string]string{"scan.json": dst}); hit || err != nil {
+		t.Fatalf("expected a miss on an empty cache, got %v (err %v)", hit, err)
+	}
+	if err := c.Put("key", map[string]string{"scan.json": src}); err != nil {
+		t.Fatalf("expected no error on Put(), got %v", err)
+	}
+	if hit, err := c.Get("key", map[string]string{"scan.json": dst, "scan.sarif": dst}); hit || err != nil {
+		t.Errorf("expected a miss when a file isn't cached, got %v (err %v)", hit, err)
+	}
+	if hit, err := c.Get("key", map[string]string{"scan.json": dst}); !hit || err != nil {
+		t.Fatalf("expected a hit, got %v (err %v)", hit, err)
+	}
+	if b, _ := os.ReadFile(dst); string(b) != `{"matches":[]}` {
+		t.Errorf("restored %s, wanted the cached output", b)
+	}
+
+	// Entries that haven't been used in a while are evicted
+	old := time.Now().Add(-2 * maxAge)
+	os.Chtimes(c.entryDir("key"), old, old)
+	if err := c.Put("other", map[string]string{"scan.json": src}); err != nil {
+		t.Fatalf("expected no error on Put(), got %v", err)
+	}
+	if hit, _ := c.Get("key", map[string]string{"scan.json": dst}); hit {
+		t.Errorf("expected the old entry to be evicted")
+	}
+	if hit, _ := c.Get("other", map[string]string{"scan.json": dst}); !hit {
+		t.Errorf("expected the new entry to be kept")
+	}
+}
@@ -0,0 +1,98 @@
+package ci
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// The CI systems detected from their environment
+const (
+	GitHubActions = "github-actions"
+	GitLabCI      = "gitlab-ci"
+	CloudBuild    = "cloud-build"
+)
+
+// Invocation identifies the CI run a scan was invoked from, as recorded in
+// the invocation of the in-toto predicate
+type Invocation struct {
+	// Provider is the CI system, e.g. GitHubActions
+	Provider string
+
+	// URI links to the run (or job) itself
+	URI string
+
+	// EventID identifies the run, and is the same for every job of it
+	EventID string
+
+	// BuilderID identifies what was run: the workflow, pipeline definition
+	// or trigger
+	BuilderID string
+}
+
+// Detect returns the invocation of the CI run described by the environment
+// (as read by getenv, e.g. os.Getenv), or nil outside of a known CI system.
+// Cloud Build doesn't set environment variables in build steps by itself,
+// so it's only detected when a step passes $BUILD_ID and $PROJECT_ID.
+func Detect(getenv func(string) string) *Invocation {
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		return gitHubActions(getenv)
+	case getenv("GITLAB_CI") == "true":
+		return gitLabCI(getenv)
+	case getenv("BUILD_ID") != "" && getenv("PROJECT_ID") != "":
+		return cloudBuild(getenv)
+	}
+	return nil
+}
+
+// gitHubActions matches the defaults of the inputs of action.yaml
+func gitHubActions(getenv func(string) string) *Invocation {
+	server := getenv("GITHUB_SERVER_URL")
+	if server == "" {
+		server = "https://github.com"
+	}
+	runID := getenv("GITHUB_RUN_ID")
+	return &Invocation{
+		Provider:  GitHubActions,
+		URI:       fmt.Sprintf("%s/%s/actions/runs/%s", strings.TrimSuffix(server, "/"), getenv("GITHUB_REPOSITORY"), runID),
+		EventID:   runID,
+		BuilderID: getenv("GITHUB_WORKFLOW"),
+	}
+}
+
+func gitLabCI(getenv func(string) string) *Invocation {
+	uri := getenv("CI_JOB_URL")
+	if uri == "" {
+		uri = getenv("CI_PIPELINE_URL")
+	}
+	// The pipeline definition, at the commit it ran from
+	builderID := getenv("CI_PROJECT_PATH")
+	if project, config := getenv("CI_PROJECT_URL"), getenv("CI_CONFIG_PATH"); project != "" && config != "" {
+		builderID = fmt.Sprintf("%s/-/blob/%s/%s", project, getenv("CI_COMMIT_SHA"), config)
+	}
+	return &Invocation{
+		Provider:  GitLabCI,
+		URI:       uri,
+		EventID:   getenv("CI_PIPELINE_ID"),
+		BuilderID: builderID,
+	}
+}
+
+func cloudBuild(getenv func(string) string) *Invocation {
+	buildID, project := getenv("BUILD_ID"), getenv("PROJECT_ID")
+	path := "builds/" + url.PathEscape(buildID)
+	if location := getenv("LOCATION"); location != "" && location != "global" {
+		path = "builds;region=" + url.PathEscape(location) + "/" + url.PathEscape(buildID)
+	}
+	builderID := "cloud-build"
+	if trigger := getenv("TRIGGER_NAME"); trigger != "" {
+		builderID = "cloud-build/triggers/" + trigger
+	}
+	return &Invocation{
+		Provider:  CloudBuild,
+		URI:       fmt.Sprintf("https://console.cloud.google.com/cloud-build/%s?project=%s", path, url.QueryEscape(project)),
+		EventID:   buildID,
+		BuilderID: builderID,
+	}
+}
@@ -0,0 +1,81 @@
+package ci
+
+import (
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		env      map[string]string
+		expected *Invocation
+	}{
+		{
+			name:     "none",
+			env:      map[string]string{"BUILD_ID": "42"},
+			expected: nil,
+		},
+		{
+			name: "github actions",
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_SERVER_URL": "https://github.com",
+				"GITHUB_REPOSITORY": "chainguard-dev/rumble",
+				"GITHUB_RUN_ID":     "5345201031",
+				"GITHUB_WORKFLOW":   "scan",
+			},
+			expected: &Invocation{
+				Provider:  GitHubActions,
+				URI:       "https://github.com/chainguard-dev/rumble/actions/runs/5345201031",
+				EventID:   "5345201031",
+				BuilderID: "scan",
+			},
+		},
+		{
+			name: "gitlab ci",
+			env: map[string]string{
+				"GITLAB_CI":       "true",
+				"CI_JOB_URL":      "https://gitlab.com/acme/app/-/jobs/4505858016",
+				"CI_PIPELINE_ID":  "908415213",
+				"CI_PROJECT_PATH": "acme/app",
+				"CI_PROJECT_URL":  "https://gitlab.com/acme/app",
+				"CI_CONFIG_PATH":  ".gitlab-ci.yml",
+				"CI_COMMIT_SHA":   "8f1c2e0",
+			},
+			expected: &Invocation{
+				Provider:  GitLabCI,
+				URI:       "https://gitlab.com/acme/app/-/jobs/4505858016",
+				EventID:   "908415213",
+				BuilderID: "https://gitlab.com/acme/app/-/blob/8f1c2e0/.gitlab-ci.yml",
+			},
+		},
+		{
+			name: "cloud build",
+			env: map[string]string{
+				"BUILD_ID":     "b7a4c7e2-91a3-4d2f-a7d0-3f1e02c5b9a1",
+				"PROJECT_ID":   "acme-prod",
+				"LOCATION":     "us-central1",
+				"TRIGGER_NAME": "nightly-scan",
+			},
+			expected: &Invocation{
+				Provider:  CloudBuild,
+				URI:       "https://console.cloud.google.com/cloud-build/builds;region=us-central1/b7a4c7e2-91a3-4d2f-a7d0-3f1e02c5b9a1?project=acme-prod",
+				EventID:   "b7a4c7e2-91a3-4d2f-a7d0-3f1e02c5b9a1",
+				BuilderID: "cloud-build/triggers/nightly-scan",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Detect(func(key string) string { return tc.env[key] })
+			if tc.expected == nil {
+				if got != nil {
+					t.Errorf("expected no invocation, got %+v", got)
+				}
+				return
+			}
+			if got == nil || *got != *tc.expected {
+				t.Errorf("got invocation %+v, wanted %+v", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Config is the optional rumble configuration file, passed with --config
+type Config struct {
+	// Routes send scans of matching images to different BigQuery tables.
+	// The first matching route wins.
+	Routes []Route `json:"routes"`
+
+	// Tokens are the API tokens "rumble serve" accepts. Without any, its
+	// API is open to anyone who can reach it.
+	Tokens []Token `json:"tokens"`
+
+	// Ignore suppresses matching findings: grype is given them as ignore
+	// rules, and they are applied to trivy's output. Either way the
+	// findings are recorded as ignored matches rather than counted.
+	Ignore []IgnoreRule `json:"ignore"`
+
+	// VEXDocuments are OpenVEX documents for grype to apply to its matches
+	VEXDocuments []string `json:"vex_documents"`
+
+	// Teams own images, for "rumble rollup" to count by team. An image
+	// belongs to the first team with a matching pattern.
+	Teams []Team `json:"teams"`
+
+	// Webhooks are posted to by "rumble serve" when the jobs it runs
+	// finish
+	Webhooks []Webhook `json:"webhooks"`
+
+	// Images skips scanning images, e.g. internal-only or deprecated
+	// repositories, in scans, rescans and submissions to "rumble serve"
+	Images ImageFilter `json:"images"`
+
+	// Scanners choose the scanners of matching images, and extra arguments
+	// for them. The first matching rule wins.
+	Scanners []ScannerRule `json:"scanners"`
+}
+
+// The scanners a ScannerRule can choose
+var Scanners = []string{"grype", "trivy"}
+
+// ScannerRule chooses the scanners of the images matching a glob pattern
+// (see Match), unless a scan is given --scanner, and extra arguments passed
+// to each scanner's command, e.g. to skip some paths. Arguments choosing
+// the scanner's output are rejected, as rumble reads that itself.
+type ScannerRule struct {
+	Image     string   `json:"image"`
+	Scanners  []string `json:"scanners,omitempty"`
+	GrypeArgs []string `json:"grype_args,omitempty"`
+	TrivyArgs []string `json:"trivy_args,omitempty"`
+}
+
+// outputArgs are the scanners' flags choosing what they output and where
+var outputArgs = map[string][]string{
+	"grype": {"-o", "--output", "--file", "-c", "--config"},
+	"trivy": {"-f", "--format", "-o", "--output", "--template"},
+}
+
+// ImageFilter decides which images to scan with glob patterns (see Match).
+// Images matching an Exclude pattern are skipped, as are images matching no
+// Include pattern when there are any.
+type ImageFilter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Skip returns why an image is skipped, or an empty string if it's scanned
+func (f *ImageFilter) Skip(image string) string {
+	for _, pattern := range f.Exclude {
+		if Match(pattern, image) {
+			return fmt.Sprintf("it matches the exclude pattern %q", pattern)
+		}
+	}
+	if len(f.Include) == 0 {
+		return ""
+	}
+	for _, pattern := range f.Include {
+		if Match(pattern, image) {
+			return ""
+		}
+	}
+	return "it matches no include pattern"
+}
+
+// Team is a team owning the images matching any of its glob patterns (see
+// Match)
+type Team struct {
+	Name   string   `json:"name"`
+	Images []string `json:"images"`
+}
+
+// Webhook is a URL that "rumble serve" posts a payload to when a job for
+// a matching image succeeds or fails. With a secret, each request is signed
+// with an HMAC of it, which is either given or read from an environment
+// variable, the latter keeping it out of the file.
+type Webhook struct {
+	// Name identifies the webhook in logs
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	Secret    string `json:"secret,omitempty"`
+	SecretEnv string `json:"secret_env,omitempty"`
+
+	// Images are glob patterns (see Match) of the images to post about,
+	// defaulting to every image
+	Images []string `json:"images,omitempty"`
+}
+
+// The fix states an IgnoreRule can match, as named by grype
+var FixStates = []string{"fixed", "not-fixed", "wont-fix", "unknown"}
+
+// IgnoreRule matches findings to ignore, in the format of grype's ignore
+// rules so they can be passed on as they are. Empty fields match anything.
+type IgnoreRule struct {
+	Vulnerability string         `json:"vulnerability,omitempty"`
+	Reason        string         `json:"reason,omitempty"`
+	FixState      string         `json:"fix-state,omitempty"`
+	Package       *IgnorePackage `json:"package,omitempty"`
+}
+
+// IgnorePackage matches the package of a finding. Type is as the scanner
+// names it (e.g. "apk" for grype but "wolfi" for trivy), and Location is a
+// glob of the package's path, where "*" matches any characters.
+type IgnorePackage struct {
+	Name     string `json:"name,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+// The scopes a Token can grant
+const (
+	// ScopeRead allows reading recorded scans, jobs and the queue
+	ScopeRead = "read"
+
+	// ScopeSubmit allows submitting scans. Clients waiting for the scans
+	// they submit need ScopeRead too.
+	ScopeSubmit = "submit"
+)
+
+// Token is an API token for "rumble serve". Either the token itself or its
+// SHA-256 (as hex) is given, the latter keeping the token out of the file.
+type Token struct {
+	// Name identifies the token's holder in logs
+	Name   string   `json:"name"`
+	Token  string   `json:"token,omitempty"`
+	SHA256 string   `json:"sha256,omitempty"`
+	Scopes []string `json:"scopes"`
+}
+
+// Route maps an image pattern to the BigQuery tables its scans are uploaded
+// to. Empty fields keep the value given by flags or the environment.
+type Route struct {
+	// Image is a glob matched against the full image reference, where "*"
+	// matches any sequence of characters (including "/")
+	Image string `json:"image"`
+
+	Project    string `json:"project,omitempty"`
+	Dataset    string `json:"dataset,omitempty"`
+	Table      string `json:"table,omitempty"`
+	VulnsTable string `json:"vulns_table,omitempty"`
+
+	FindingsTable string `json:"findings_table,omitempty"`
+	LicensesTable string `json:"licenses_table,omitempty"`
+	PackagesTable string `json:"packages_table,omitempty"`
+}
+
+// Load reads a JSON config file
+func Load(filename string) (*Config, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b, "config file "+filename)
+}
+
+// Parse parses a JSON config, e.g. read from a file or a secret, naming the
+// source in errors
+func Parse(b []byte, source string) (*Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", source, err)
+	}
+	for i, route := range cfg.Routes {
+		if route.Image == "" {
+			return nil, fmt.Errorf("parsing %s: route %d has no image pattern", source, i)
+		}
+	}
+	for i, token := range cfg.Tokens {
+		if err := token.validate(); err != nil {
+			return nil, fmt.Errorf("parsing %s: token %d: %w", source, i, err)
+		}
+	}
+	for i, rule := range cfg.Ignore {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("parsing %s: ignore rule %d: %w", source, i, err)
+		}
+	}
+	for i, team := range cfg.Teams {
+		if team.Name == "" || len(team.Images) == 0 {
+			return nil, fmt.Errorf("parsing %s: team %d needs a name and image patterns", source, i)
+		}
+	}
+	for i, rule := range cfg.Scanners {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("parsing %s: scanner rule %d: %w", source, i, err)
+		}
+	}
+	for i, webhook := range cfg.Webhooks {
+		if err := webhook.validate(); err != nil {
+			return nil, fmt.Errorf("parsing %s: webhook %d: %w", source, i, err)
+		}
+	}
+	return &cfg, nil
+}
+
+func (r *IgnoreRule) validate() error {
+	if r.Vulnerability == "" && r.Package == nil {
+		return fmt.Errorf("needs a vulnerability or a package, or it would ignore everything")
+	}
+	if r.FixState != "" {
+		for _, state := range FixStates {
+			if r.FixState == state {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid fix-state %q, expected one of %s", r.FixState, strings.Join(FixStates, ", "))
+	}
+	return nil
+}
+
+// Matches reports whether the rule matches a finding of a vulnerability in
+// a package
+func (r *IgnoreRule) Matches(vulnerability string, fixState string, pkg IgnorePackage) bool {
+	if (r.Vulnerability != "" && r.Vulnerability != vulnerability) || (r.FixState != "" && r.FixState != fixState) {
+		return false
+	}
+	if p := r.Package; p != nil {
+		if (p.Name != "" && p.Name != pkg.Name) || (p.Version != "" && p.Version != pkg.Version) || (p.Type != "" && p.Type != pkg.Type) {
+			return false
+		}
+		if p.Location != "" && !Match(p.Location, pkg.Location) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *Token) validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("no name")
+	}
+	if (t.Token == "") == (t.SHA256 == "") {
+		return fmt.Errorf("%s needs either a token or its sha256", t.Name)
+	}
+	if t.SHA256 != "" {
+		if b, err := hex.DecodeString(t.SHA256); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("%s has an invalid sha256, expected 64 hex characters", t.Name)
+		}
+	}
+	if len(t.Scopes) == 0 {
+		return fmt.Errorf("%s has no scopes", t.Name)
+	}
+	for _, scope := range t.Scopes {
+		if scope != ScopeRead && scope != ScopeSubmit {
+			return fmt.Errorf("%s has an invalid scope %q, expected %q or %q", t.Name, scope, ScopeRead, ScopeSubmit)
+		}
+	}
+	return nil
+}
+
+func (r *ScannerRule) validate() error {
+	if r.Image == "" {
+		return fmt.Errorf("no image pattern")
+	}
+	if len(r.Scanners) == 0 && len(r.GrypeArgs) == 0 && len(r.TrivyArgs) == 0 {
+		return fmt.Errorf("%s needs scanners or scanner args", r.Image)
+	}
+	for _, scanner := range r.Scanners {
+		valid := false
+		for _, name := range Scanners {
+			valid = valid || scanner == name
+		}
+		if !valid {
+			return fmt.Errorf("%s has an invalid scanner %q, expected one of %s", r.Image, scanner, strings.Join(Scanners, ", "))
+		}
+	}
+	for scanner, args := range map[string][]string{"grype": r.GrypeArgs, "trivy": r.TrivyArgs} {
+		for _, arg := range args {
+			for _, output := range outputArgs[scanner] {
+				if arg == output || strings.HasPrefix(arg, output+"=") {
+					return fmt.Errorf("%s has %s arg %q, but rumble chooses the scanner's output itself", r.Image, scanner, arg)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Args returns the extra arguments of a scanner
+func (r *ScannerRule) Args(scanner string) []string {
+	switch scanner {
+	case "grype":
+		return r.GrypeArgs
+	case "trivy":
+		return r.TrivyArgs
+	}
+	return nil
+}
+
+func (w *Webhook) validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("no name")
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s has an invalid url %q, expected an http or https URL", w.Name, w.URL)
+	}
+	if w.Secret != "" && w.SecretEnv != "" {
+		return fmt.Errorf("%s needs either a secret or a secret_env, not both", w.Name)
+	}
+	return nil
+}
+
+// SigningSecret returns the secret to sign the webhook's requests with, or
+// an empty string to leave them unsigned
+func (w *Webhook) SigningSecret() (string, error) {
+	if w.SecretEnv == "" {
+		return w.Secret, nil
+	}
+	secret := os.Getenv(w.SecretEnv)
+	if secret == "" {
+		return "", fmt.Errorf("webhook %s: $%s isn't set", w.Name, w.SecretEnv)
+	}
+	return secret, nil
+}
+
+// Route returns the first route matching an image, or nil if none match
+func (c *Config) Route(image string) *Route {
+	for i, route := range c.Routes {
+		if Match(route.Image, image) {
+			return &c.Routes[i]
+		}
+	}
+	return nil
+}
+
+// ScannerRule returns the first scanner rule matching an image, or nil if
+// none match
+func (c *Config) ScannerRule(image string) *ScannerRule {
+	for i, rule := range c.Scanners {
+		if Match(rule.Image, image) {
+			return &c.Scanners[i]
+		}
+	}
+	return nil
+}
+
+// Team returns the name of the first team owning an image, or an empty
+// string if none do
+func (c *Config) Team(image string) string {
+	for _, team := range c.Teams {
+		for _, pattern := range team.Images {
+			if Match(pattern, image) {
+				return team.Name
+			}
+		}
+	}
+	return ""
+}
+
+// Match reports whether an image reference matches a glob pattern
+func Match(pattern string, image string) bool {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$").MatchString(image)
+}
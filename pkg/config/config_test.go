@@ -0,0 +1,218 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		image    string
+		expected bool
+	}{
+		{"cgr.dev/chainguard/static:latest", "cgr.dev/chainguard/static:latest", true},
+		{"cgr.dev/chainguard/*", "cgr.dev/chainguard/static:latest", true},
+		{"cgr.dev/*:latest", "cgr.dev/chainguard/static:latest", true},
+		{"cgr.dev/*:latest", "cgr.dev/chainguard/static:1.0", false},
+		{"cgr.dev/chainguard/*", "cgr.dev/customer/static:latest", false},
+		{"*", "anything", true},
+		{"cgr.dev/chainguard/static", "cgr.dev/chainguard/static:latest", false},
+	}
+	for _, test := range tests {
+		if actual := Match(test.pattern, test.image); actual != test.expected {
+			t.Errorf("Match(%q, %q) is %t, wanted %t", test.pattern, test.image, actual, test.expected)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rumble.json")
+	if err := os.WriteFile(filename, []byte(`{"routes": [
+		{"image": "cgr.dev/chainguard/*", "dataset": "internal"},
+		{"image": "*", "dataset": "customers", "table": "scans"}
+	]}`), 0644); err != nil {
+		t.Fatalf("expected no error writing config file, got %v", err)
+	}
+	cfg, err := Load(filename)
+	if err != nil {
+		t.Fatalf("expected no error on Load(), got %v", err)
+	}
+	if route := cfg.Route("cgr.dev/chainguard/static:latest"); route == nil || route.Dataset != "internal" {
+		t.Errorf("expected route with dataset \"internal\", got %+v", route)
+	}
+	if route := cfg.Route("example.com/app:1.0"); route == nil || route.Dataset != "customers" || route.Table != "scans" {
+		t.Errorf("expected route with dataset \"customers\" and table \"scans\", got %+v", route)
+	}
+
+	if err := os.WriteFile(filename, []byte(`{"routes": [{"dataset": "internal"}]}`), 0644); err != nil {
+		t.Fatalf("expected no error writing config file, got %v", err)
+	}
+	if _, err := Load(filename); err == nil {
+		t.Errorf("expected error on Load() with a route missing its image pattern, got nil")
+	}
+}
+
+func TestParseTokens(t *testing.T) {
+	cfg, err := Parse([]byte(`{"tokens": [
+		{"name": "dashboards", "token": "s3cret", "scopes": ["read"]},
+		{"name": "ci", "sha256": "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b", "scopes": ["read", "submit"]}
+	]}`), "test")
+	if err != nil {
+		t.Fatalf("expected no error on Parse(), got %v", err)
+	}
+	if len(cfg.Tokens) != 2 || cfg.Tokens[1].Name != "ci" {
+		t.Errorf("expected 2 tokens, got %+v", cfg.Tokens)
+	}
+
+	for _, invalid := range []string{
+		`{"tokens": [{"token": "s3cret", "scopes": ["read"]}]}`,
+		`{"tokens": [{"name": "dashboards", "scopes": ["read"]}]}`,
+		`{"tokens": [{"name": "dashboards", "token": "s3cret", "sha256": "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b", "scopes": ["read"]}]}`,
+		`{"tokens": [{"name": "dashboards", "sha256": "2bb8", "scopes": ["read"]}]}`,
+		`{"tokens": [{"name": "dashboards", "token": "s3cret"}]}`,
+		`{"tokens": [{"name": "dashboards", "token": "s3cret", "scopes": ["admin"]}]}`,
+	} {
+		if _, err := Parse([]byte(invalid), "test"); err == nil {
+			t.Errorf("expected error on Parse() of %s, got nil", invalid)
+		}
+	}
+}
+
+func TestParseIgnore(t *testing.T) {
+	cfg, err := Parse([]byte(`{"ignore": [
+		{"vulnerability": "CVE-2024-0001", "reason": "not reachable"},
+		{"fix-state": "wont-fix", "package": {"name": "zlib", "location": "/usr/lib/*"}}
+	]}`), "test")
+	if err != nil {
+		t.Fatalf("expected no error on Parse(), got %v", err)
+	}
+	for _, tc := range []struct {
+		rule          int
+		vulnerability string
+		fixState      string
+		pkg           IgnorePackage
+		matches       bool
+	}{
+		{0, "CVE-2024-0001", "fixed", IgnorePackage{Name: "openssl"}, true},
+		{0, "CVE-2024-0002", "fixed", IgnorePackage{Name: "openssl"}, false},
+		{1, "CVE-2024-0003", "wont-fix", IgnorePackage{Name: "zlib", Location: "/usr/lib/libz.so.1"}, true},
+		{1, "CVE-2024-0003", "not-fixed", IgnorePackage{Name: "zlib", Location: "/usr/lib/libz.so.1"}, false},
+		{1, "CVE-2024-0003", "wont-fix", IgnorePackage{Name: "zlib", Location: "/opt/libz.so.1"}, false},
+	} {
+		if got := cfg.Ignore[tc.rule].Matches(tc.vulnerability, tc.fixState, tc.pkg); got != tc.matches {
+			t.Errorf("expected rule %d matching %s (%s) in %+v to be %t, got %t", tc.rule, tc.vulnerability, tc.fixState, tc.pkg, tc.matches, got)
+		}
+	}
+
+	for _, invalid := range []string{
+		`{"ignore": [{"reason": "everything"}]}`,
+		`{"ignore": [{"vulnerability": "CVE-2024-0001", "fix-state": "maybe"}]}`,
+	} {
+		if _, err := Parse([]byte(invalid), "test"); err == nil {
+			t.Errorf("expected error on Parse() of %s, got nil", invalid)
+		}
+	}
+}
+
+func TestParseTeams(t *testing.T) {
+	cfg, err := Parse([]byte(`{"teams": [
+		{"name": "platform", "images": ["cgr.dev/chainguard/static:*", "cgr.dev/chainguard/go:*"]},
+		{"name": "apps", "images": ["*"]}
+	]}`), "test")
+	if err != nil {
+		t.Fatalf("expected no error on Parse(), got %v", err)
+	}
+	for image, expected := range map[string]string{
+		"cgr.dev/chainguard/go:latest": "platform",
+		"example.com/app:1.0":          "apps",
+	} {
+		if team := cfg.Team(image); team != expected {
+			t.Errorf("expected %s to belong to %q, got %q", image, expected, team)
+		}
+	}
+	if _, err := Parse([]byte(`{"teams": [{"name": "platform"}]}`), "test"); err == nil {
+		t.Errorf("expected error on Parse() with a team without images, got nil")
+	}
+}
+
+func TestParseWebhooks(t *testing.T) {
+	t.Setenv("RUMBLE_TEST_WEBHOOK_SECRET", "s3cret")
+	cfg, err := Parse([]byte(`{"webhooks": [
+		{"name": "automation", "url": "https://example.com/hooks/rumble", "secret_env": "RUMBLE_TEST_WEBHOOK_SECRET", "images": ["cgr.dev/chainguard/*"]},
+		{"name": "unsigned", "url": "http://localhost:9000/"}
+	]}`), "test")
+	if err != nil {
+		t.Fatalf("expected no error on Parse(), got %v", err)
+	}
+	for i, expected := range []string{"s3cret", ""} {
+		if secret, err := cfg.Webhooks[i].SigningSecret(); err != nil || secret != expected {
+			t.Errorf("expected webhook %d to be signed with %q, got %q (error %v)", i, expected, secret, err)
+		}
+	}
+	missing := Webhook{Name: "automation", URL: "https://example.com/", SecretEnv: "RUMBLE_TEST_WEBHOOK_UNSET"}
+	if _, err := missing.SigningSecret(); err == nil {
+		t.Errorf("expected error on SigningSecret() with an unset variable, got nil")
+	}
+	for _, webhook := range []string{
+		`{"url": "https://example.com/"}`,
+		`{"name": "automation", "url": "example.com/hooks"}`,
+		`{"name": "automation", "url": "ftp://example.com/"}`,
+		`{"name": "automation", "url": "https://example.com/", "secret": "s3cret", "secret_env": "SECRET"}`,
+	} {
+		if _, err := Parse([]byte(`{"webhooks": [`+webhook+`]}`), "test"); err == nil {
+			t.Errorf("expected error on Parse() with webhook %s, got nil", webhook)
+		}
+	}
+}
+
+func TestParseImages(t *testing.T) {
+	cfg, err := Parse([]byte(`{"images": {"include": ["cgr.dev/chainguard/*"], "exclude": ["*-internal:*", "cgr.dev/chainguard/deprecated:*"]}}`), "test")
+	if err != nil {
+		t.Fatalf("expected no error on Parse(), got %v", err)
+	}
+	for image, skipped := range map[string]bool{
+		"cgr.dev/chainguard/static:latest":     false,
+		"cgr.dev/chainguard/deprecated:latest": true,
+		"cgr.dev/chainguard/app-internal:1.0":  true,
+		"example.com/app:latest":               true,
+	} {
+		if reason := cfg.Images.Skip(image); (reason != "") != skipped {
+			t.Errorf("expected %s to be skipped: %v, got %q", image, skipped, reason)
+		}
+	}
+	if reason := (&ImageFilter{}).Skip("example.com/app:latest"); reason != "" {
+		t.Errorf("expected an empty filter to scan every image, got %q", reason)
+	}
+}
+
+func TestParseScanners(t *testing.T) {
+	cfg, err := Parse([]byte(`{"scanners": [
+		{"image": "cgr.dev/chainguard/*-jdk:*", "scanners": ["trivy"], "trivy_args": ["--skip-dirs", "/usr/share/doc"]},
+		{"image": "cgr.dev/chainguard/*", "scanners": ["grype", "trivy"]},
+		{"image": "*", "grype_args": ["--only-fixed"]}
+	]}`), "test")
+	if err != nil {
+		t.Fatalf("expected no error on Parse(), got %v", err)
+	}
+	rule := cfg.ScannerRule("cgr.dev/chainguard/maven-jdk:latest")
+	if rule == nil || strings.Join(rule.Scanners, ",") != "trivy" || strings.Join(rule.Args("trivy"), " ") != "--skip-dirs /usr/share/doc" || len(rule.Args("grype")) != 0 {
+		t.Errorf("expected the first rule to match, got %+v", rule)
+	}
+	if rule := cfg.ScannerRule("example.com/app:latest"); rule == nil || len(rule.Scanners) != 0 || rule.Args("grype")[0] != "--only-fixed" {
+		t.Errorf("expected the catch-all rule to match, got %+v", rule)
+	}
+	for _, rule := range []string{
+		`{"scanners": ["trivy"]}`,
+		`{"image": "*"}`,
+		`{"image": "*", "scanners": ["clair"]}`,
+		`{"image": "*", "grype_args": ["-o", "table"]}`,
+		`{"image": "*", "trivy_args": ["--format=table"]}`,
+	} {
+		if _, err := Parse([]byte(`{"scanners": [`+rule+`]}`), "test"); err == nil {
+			t.Errorf("expected error on Parse() with scanner rule %s, got nil", rule)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package diff
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// PredicateType is the in-toto predicate type of a vuln diff attestation
+const PredicateType = "https://github.com/chainguard-dev/rumble/attestation/vuln-diff/v1"
+
+// Predicate lists the vulnerabilities added and removed between two scans
+// of an image, typically of the previous and the current digest
+type Predicate struct {
+	Previous Scan    `json:"previous"`
+	Current  Scan    `json:"current"`
+	Added    []Entry `json:"added"`
+	Removed  []Entry `json:"removed"`
+}
+
+// Scan identifies one side of a diff
+type Scan struct {
+	Image   string `json:"image"`
+	Digest  string `json:"digest"`
+	ScanID  string `json:"scanId"`
+	Scanner string `json:"scanner"`
+	Time    string `json:"time"`
+}
+
+// Entry is a single vulnerability in a package
+type Entry struct {
+	Vulnerability string `json:"vulnerability"`
+	Package       string `json:"package"`
+	Installed     string `json:"installed"`
+	FixedIn       string `json:"fixedIn,omitempty"`
+	Type          string `json:"type"`
+	Severity      string `json:"severity"`
+}
+
+// Vulns returns the vulnerabilities in after but not before (added), and
+// in before but not after (removed). Vulnerabilities are matched on their
+// ID, package name and package type, so a version bump that doesn't fix a
+// vulnerability is not reported as a change.
+func Vulns(before []*types.Vuln, after []*types.Vuln) ([]Entry, []Entry) {
+	return missing(after, before), missing(before, after)
+}
+
+// missing returns the entries of a that are not in b
+func missing(a []*types.Vuln, b []*types.Vuln) []Entry {
+	seen := map[string]bool{}
+	for _, vuln := range b {
+		seen[key(vuln)] = true
+	}
+	entries := []Entry{}
+	for _, vuln := range a {
+		if seen[key(vuln)] {
+			continue
+		}
+		seen[key(vuln)] = true
+		entries = append(entries, NewEntry(vuln))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Vulnerability != entries[j].Vulnerability {
+			return entries[i].Vulnerability < entries[j].Vulnerability
+		}
+		return entries[i].Package < entries[j].Package
+	})
+	return entries
+}
+
+// NewEntry returns the entry for a vulnerability
+func NewEntry(vuln *types.Vuln) Entry {
+	return Entry{
+		Vulnerability: vuln.Vulnerability,
+		Package:       vuln.Name,
+		Installed:     vuln.Installed,
+		FixedIn:       vuln.FixedIn,
+		Type:          vuln.Type,
+		Severity:      vuln.Severity,
+	}
+}
+
+func key(vuln *types.Vuln) string {
+	return strings.Join([]string{vuln.Vulnerability, vuln.Name, vuln.Type}, "--")
+}
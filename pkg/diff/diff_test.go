@@ -0,0 +1,27 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestVulns(t *testing.T) {
+	before := []*types.Vuln{
+		{Vulnerability: "CVE-2023-0001", Name: "openssl", Installed: "3.0.1", Type: "apk"},
+		{Vulnerability: "CVE-2023-0002", Name: "busybox", Installed: "1.36.0", Type: "apk"},
+	}
+	after := []*types.Vuln{
+		// Still vulnerable after a version bump
+		{Vulnerability: "CVE-2023-0001", Name: "openssl", Installed: "3.0.2", Type: "apk"},
+		{Vulnerability: "CVE-2023-0003", Name: "zlib", Installed: "1.2.13", Type: "apk"},
+		{Vulnerability: "CVE-2023-0003", Name: "zlib", Installed: "1.2.13", Type: "apk"},
+	}
+	added, removed := Vulns(before, after)
+	if len(added) != 1 || added[0].Vulnerability != "CVE-2023-0003" {
+		t.Errorf("expected only CVE-2023-0003 to be added, got %+v", added)
+	}
+	if len(removed) != 1 || removed[0].Vulnerability != "CVE-2023-0002" {
+		t.Errorf("expected only CVE-2023-0002 to be removed, got %+v", removed)
+	}
+}
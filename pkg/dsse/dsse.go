@@ -0,0 +1,168 @@
+package dsse
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// ErrUnverified is returned when no signature of an envelope is valid
+var ErrUnverified = errors.New("no signature verified with the key")
+
+// PAE returns DSSE's pre-authentication encoding of a payload, which is
+// what is signed rather than the payload itself
+func PAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// Signer signs DSSE envelopes with a private key: ECDSA signatures (as
+// cosign makes them) and RSA PKCS #1 v1.5 signatures are of the SHA-256 of
+// the encoding, and Ed25519 signatures of the encoding itself
+type Signer struct {
+	key crypto.Signer
+
+	// KeyID is the hex SHA-256 of the public key in PKIX form, set as the
+	// keyid of signatures so consumers can tell rotated keys apart
+	KeyID string
+}
+
+// NewSigner returns a signer for an ECDSA, Ed25519 or RSA private key
+func NewSigner(key crypto.Signer) (*Signer, error) {
+	switch key.(type) {
+	case *ecdsa.PrivateKey, ed25519.PrivateKey, *rsa.PrivateKey:
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T, expected ECDSA, Ed25519 or RSA", key)
+	}
+	id, err := KeyID(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{key: key, KeyID: id}, nil
+}
+
+// LoadSigner reads an unencrypted PEM private key, in PKCS #8 ("PRIVATE
+// KEY"), SEC 1 ("EC PRIVATE KEY") or PKCS #1 ("RSA PRIVATE KEY") form
+func LoadSigner(filename string) (*Signer, error) {
+	block, err := readPEM(filename)
+	if err != nil {
+		return nil, err
+	}
+	var key interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s has a %q PEM block, expected an unencrypted private key", filename, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s has an unsupported key type %T", filename, key)
+	}
+	return NewSigner(signer)
+}
+
+// LoadPublicKey reads a PEM public key in PKIX ("PUBLIC KEY") form
+func LoadPublicKey(filename string) (crypto.PublicKey, error) {
+	block, err := readPEM(filename)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("%s has a %q PEM block, expected a public key", filename, block.Type)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	return key, nil
+}
+
+// KeyID returns the ID of a public key, as used in signatures
+func KeyID(key crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Sign returns an envelope of a payload with a single signature
+func (s *Signer) Sign(payloadType string, payload []byte) (*types.DSSEEnvelope, error) {
+	message := PAE(payloadType, payload)
+	var sig []byte
+	var err error
+	if _, ok := s.key.(ed25519.PrivateKey); ok {
+		sig, err = s.key.Sign(rand.Reader, message, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(message)
+		sig, err = s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signing %s: %w", payloadType, err)
+	}
+	return &types.DSSEEnvelope{
+		PayloadType: payloadType,
+		Payload:     payload,
+		Signatures:  []types.DSSESignature{{KeyID: s.KeyID, Sig: base64.StdEncoding.EncodeToString(sig)}},
+	}, nil
+}
+
+// Verify checks that an envelope has a signature by a public key, returning
+// ErrUnverified if it has none
+func Verify(envelope *types.DSSEEnvelope, key crypto.PublicKey) error {
+	message := PAE(envelope.PayloadType, envelope.Payload)
+	digest := sha256.Sum256(message)
+	for _, signature := range envelope.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(signature.Sig)
+		if err != nil {
+			continue
+		}
+		var ok bool
+		switch key := key.(type) {
+		case *ecdsa.PublicKey:
+			ok = ecdsa.VerifyASN1(key, digest[:], sig)
+		case ed25519.PublicKey:
+			ok = ed25519.Verify(key, message, sig)
+		case *rsa.PublicKey:
+			ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+		default:
+			return fmt.Errorf("unsupported public key type %T, expected ECDSA, Ed25519 or RSA", key)
+		}
+		if ok {
+			return nil
+		}
+	}
+	return ErrUnverified
+}
+
+func readPEM(filename string) (*pem.Block, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s has no PEM block", filename)
+	}
+	return block, nil
+}
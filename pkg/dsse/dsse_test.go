@@ -0,0 +1,89 @@
+package dsse
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPAE(t *testing.T) {
+	// The example from the DSSE protocol
+	expected := "DSSEv1 29 http://example.com/HelloWorld 11 hello world"
+	if got := string(PAE("http://example.com/HelloWorld", []byte("hello world"))); got != expected {
+		t.Errorf("got %q, wanted %q", got, expected)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for _, key := range []crypto.Signer{ecKey, edKey, rsaKey} {
+		signer, err := NewSigner(key)
+		if err != nil {
+			t.Fatalf("expected no error on NewSigner() with %T, got %v", key, err)
+		}
+		env, err := signer.Sign("application/vnd.rumble.summary+json", []byte(`{"id": "testing123"}`))
+		if err != nil {
+			t.Fatalf("expected no error on Sign() with %T, got %v", key, err)
+		}
+		if len(env.Signatures) != 1 || env.Signatures[0].KeyID != signer.KeyID {
+			t.Errorf("expected a signature with key ID %s, got %+v", signer.KeyID, env.Signatures)
+		}
+		if err := Verify(env, key.Public()); err != nil {
+			t.Errorf("expected no error on Verify() with %T, got %v", key, err)
+		}
+		if err := Verify(env, otherKey.Public()); !errors.Is(err, ErrUnverified) {
+			t.Errorf("expected ErrUnverified on Verify() with another key, got %v", err)
+		}
+		env.Payload = []byte(`{"id": "testing456"}`)
+		if err := Verify(env, key.Public()); !errors.Is(err, ErrUnverified) {
+			t.Errorf("expected ErrUnverified on Verify() of a changed payload with %T, got %v", key, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	private, _ := x509.MarshalECPrivateKey(key)
+	public, _ := x509.MarshalPKIXPublicKey(key.Public())
+	write := func(name string, blockType string, b []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: b}), 0600); err != nil {
+			t.Fatalf("expected no error writing %s, got %v", name, err)
+		}
+		return path
+	}
+	signer, err := LoadSigner(write("key.pem", "EC PRIVATE KEY", private))
+	if err != nil {
+		t.Fatalf("expected no error on LoadSigner(), got %v", err)
+	}
+	publicKey, err := LoadPublicKey(write("key.pub", "PUBLIC KEY", public))
+	if err != nil {
+		t.Fatalf("expected no error on LoadPublicKey(), got %v", err)
+	}
+	env, err := signer.Sign("text/plain", []byte("hello"))
+	if err != nil {
+		t.Fatalf("expected no error on Sign(), got %v", err)
+	}
+	if err := Verify(env, publicKey); err != nil {
+		t.Errorf("expected no error on Verify(), got %v", err)
+	}
+	if _, err := LoadSigner(write("encrypted.pem", "ENCRYPTED SIGSTORE PRIVATE KEY", private)); err == nil {
+		t.Errorf("expected error on LoadSigner() with an encrypted key, got nil")
+	}
+	if _, err := LoadPublicKey(filepath.Join(dir, "key.pem")); err == nil {
+		t.Errorf("expected error on LoadPublicKey() with a private key, got nil")
+	}
+}
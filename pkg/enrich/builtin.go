@@ -0,0 +1,68 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/eol"
+	"github.com/chainguard-dev/rumble/pkg/jira"
+	"github.com/chainguard-dev/rumble/pkg/nvd"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// NVD sets the NVD severity and CVSS score of each vuln. Failed lookups are
+// only warned about, leaving the vuln with its scanner severity.
+func NVD(client *nvd.Client) Enricher {
+	return Func(func(ctx context.Context, summary *types.ImageScanSummary, vulns []*types.Vuln) error {
+		for _, vuln := range vulns {
+			cvss, err := client.Lookup(vuln.Vulnerability)
+			if err != nil {
+				fmt.Printf("WARNING: NVD lookup failed for %s: %s\n", vuln.Vulnerability, err.Error())
+				continue
+			}
+			if cvss != nil {
+				vuln.NvdSeverity = cvss.Severity
+				vuln.NvdCvssScore = cvss.Score
+			}
+		}
+		return nil
+	})
+}
+
+// EOL checks whether the scanned OS release is past end-of-life. Failed
+// checks are only warned about, and Windows isn't checked at all, as
+// endoflife.date doesn't track it by build.
+func EOL(client *eol.Client) Enricher {
+	return Func(func(ctx context.Context, summary *types.ImageScanSummary, vulns []*types.Vuln) error {
+		if summary.OsName == "windows" {
+			fmt.Printf("WARNING: not checking end-of-life status of Windows %s, which endoflife.date doesn't track by build\n", summary.OsVersion)
+			return nil
+		}
+		status, err := client.Check(summary.OsName, summary.OsVersion, time.Now())
+		if err != nil {
+			fmt.Printf("WARNING: could not check end-of-life status of %s %s: %s\n", summary.OsName, summary.OsVersion, err.Error())
+			return nil
+		}
+		if status != nil {
+			summary.EOL = status.EOL
+			summary.EOLDetails = status.Details
+			if status.EOL {
+				fmt.Printf("WARNING: %s\n", status.Details)
+			}
+		}
+		return nil
+	})
+}
+
+// Jira files and updates Jira issues for the scan's high and critical vulns,
+// setting their JiraIssue. Failures are only warned about, as the scan is
+// still worth recording without the issue keys.
+func Jira(tracker *jira.Tracker) Enricher {
+	return Func(func(ctx context.Context, summary *types.ImageScanSummary, vulns []*types.Vuln) error {
+		if err := tracker.Track(ctx, summary, vulns); err != nil {
+			fmt.Printf("WARNING: could not file Jira issues for %s: %s\n", summary.Image, err.Error())
+		}
+		return nil
+	})
+}
@@ -0,0 +1,84 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// Enricher adds to a scan's summary and vulns after the scan and before
+// they are recorded, e.g. with severities from another source. Custom
+// enrichers (e.g. internal asset criticality) are compiled in by registering
+// them with Register.
+type Enricher interface {
+	Enrich(ctx context.Context, summary *types.ImageScanSummary, vulns []*types.Vuln) error
+}
+
+// Func is an Enricher that is a plain function
+type Func func(ctx context.Context, summary *types.ImageScanSummary, vulns []*types.Vuln) error
+
+func (f Func) Enrich(ctx context.Context, summary *types.ImageScanSummary, vulns []*types.Vuln) error {
+	return f(ctx, summary, vulns)
+}
+
+// Stage is a named step of a Pipeline
+type Stage struct {
+	Name     string
+	Enricher Enricher
+}
+
+var (
+	mu         sync.Mutex
+	registered []Stage
+)
+
+// Register adds an enrichment stage that is run on every scan after the
+// built-in ones, typically from the init function of a package imported for
+// its side effects. It panics if the name is already taken.
+func Register(name string, enricher Enricher) {
+	mu.Lock()
+	defer mu.Unlock()
+	if enricher == nil {
+		panic(fmt.Sprintf("enrich: Register enricher %s is nil", name))
+	}
+	for _, stage := range registered {
+		if stage.Name == name {
+			panic(fmt.Sprintf("enrich: Register called twice for enricher %s", name))
+		}
+	}
+	registered = append(registered, Stage{Name: name, Enricher: enricher})
+}
+
+// Registered returns the registered stages, in the order they were registered
+func Registered() []Stage {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Stage{}, registered...)
+}
+
+// Pipeline is the enrichment stages run on a scan, in order
+type Pipeline []Stage
+
+// Names returns the names of the stages
+func (p Pipeline) Names() []string {
+	names := make([]string, len(p))
+	for i, stage := range p {
+		names[i] = stage.Name
+	}
+	return names
+}
+
+// Enrich runs each stage in turn, stopping at the first that fails
+func (p Pipeline) Enrich(ctx context.Context, summary *types.ImageScanSummary, vulns []*types.Vuln) error {
+	for _, stage := range p {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := stage.Enricher.Enrich(ctx, summary, vulns); err != nil {
+			return fmt.Errorf("enrichment stage %s: %w", stage.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/eol"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestPipeline(t *testing.T) {
+	var ran []string
+	stage := func(name string, err error) Stage {
+		return Stage{Name: name, Enricher: Func(func(ctx context.Context, summary *types.ImageScanSummary, vulns []*types.Vuln) error {
+			ran = append(ran, name)
+			for _, vuln := range vulns {
+				vuln.Description = name
+			}
+			return err
+		})}
+	}
+	vulns := []*types.Vuln{{ID: "v1"}, {ID: "v2"}}
+
+	// Stages run in order, each seeing what the last one did
+	p := Pipeline{stage("first", nil), stage("second", nil)}
+	if err := p.Enrich(context.Background(), &types.ImageScanSummary{}, vulns); err != nil {
+		t.Fatalf("expected no error on Enrich(), got %v", err)
+	}
+	if len(ran) != 2 || ran[0] != "first" || ran[1] != "second" {
+		t.Errorf("expected the stages to run in order, got %v", ran)
+	}
+	if vulns[0].Description != "second" {
+		t.Errorf("expected the last stage to win, got %q", vulns[0].Description)
+	}
+
+	// The first stage to fail stops the pipeline, and is named
+	ran = nil
+	failed := errors.New("unavailable")
+	p = Pipeline{stage("first", failed), stage("second", nil)}
+	err := p.Enrich(context.Background(), &types.ImageScanSummary{}, vulns)
+	if !errors.Is(err, failed) || err.Error() != "enrichment stage first: unavailable" {
+		t.Errorf("expected the first stage's error, got %v", err)
+	}
+	if len(ran) != 1 {
+		t.Errorf("expected only the first stage to run, got %v", ran)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer func() { registered = nil }()
+	noop := Func(func(ctx context.Context, summary *types.ImageScanSummary, vulns []*types.Vuln) error { return nil })
+	Register("criticality", noop)
+	Register("owner", noop)
+	if names := Pipeline(Registered()).Names(); len(names) != 2 || names[0] != "criticality" || names[1] != "owner" {
+		t.Errorf("expected the stages in the order registered, got %v", names)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected registering a name twice to panic")
+		}
+	}()
+	Register("owner", noop)
+}
+
+func TestEOL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/alpine.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`[{"cycle": "3.19", "eol": "2025-11-01"}, {"cycle": "3.1", "eol": "2016-05-01"}]`))
+	}))
+	defer srv.Close()
+	client := eol.NewClient(t.TempDir())
+	client.BaseURL = srv.URL
+
+	summary := &types.ImageScanSummary{OsName: "alpine", OsVersion: "3.1.4"}
+	if err := EOL(client).Enrich(context.Background(), summary, nil); err != nil {
+		t.Fatalf("expected no error on Enrich(), got %v", err)
+	}
+	if !summary.EOL || summary.EOLDetails == "" {
+		t.Errorf("expected alpine 3.1.4 to be end-of-life, got %t (%q)", summary.EOL, summary.EOLDetails)
+	}
+
+	// Windows isn't looked up at all
+	summary = &types.ImageScanSummary{OsName: "windows", OsVersion: "10.0.20348.2227"}
+	if err := EOL(client).Enrich(context.Background(), summary, nil); err != nil || summary.EOL {
+		t.Errorf("expected Windows to be skipped, got %t (%v)", summary.EOL, err)
+	}
+}
@@ -0,0 +1,200 @@
+package eol
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://endoflife.date/api"
+
+	// Cached endoflife.date responses older than this are refreshed
+	cacheTTL = 24 * time.Hour
+)
+
+// Products maps the distro names reported by grype and trivy to their
+// endoflife.date product names
+var Products = map[string]string{
+	"alpine":    "alpine",
+	"amazon":    "amazon-linux",
+	"almalinux": "almalinux",
+	"centos":    "centos",
+	"debian":    "debian",
+	"fedora":    "fedora",
+	"ol":        "oracle-linux",
+	"oracle":    "oracle-linux",
+	"redhat":    "rhel",
+	"rhel":      "rhel",
+	"rocky":     "rocky-linux",
+	"opensuse":  "opensuse",
+	"sles":      "sles",
+	"ubuntu":    "ubuntu",
+}
+
+// Cycle is a single release cycle of an endoflife.date product
+type Cycle struct {
+	Cycle string `json:"cycle"`
+
+	// EOL is either the end-of-life date ("2006-01-02"), or a boolean when
+	// the date is not known
+	EOL json.RawMessage `json:"eol"`
+}
+
+// Status is the end-of-life status of a release
+type Status struct {
+	EOL     bool
+	Details string
+}
+
+type Client struct {
+	BaseURL  string
+	CacheDir string
+
+	httpClient *http.Client
+	cycles     map[string][]Cycle
+}
+
+func NewClient(cacheDir string) *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		CacheDir:   cacheDir,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		cycles:     map[string][]Cycle{},
+	}
+}
+
+// DefaultCacheDir returns the directory used to cache endoflife.date
+// responses when none is provided explicitly
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "rumble", "eol")
+}
+
+// Check returns the end-of-life status of a distro release, or nil if
+// endoflife.date doesn't track the distro or release
+func (c *Client) Check(osName string, osVersion string, now time.Time) (*Status, error) {
+	product, ok := Products[strings.ToLower(osName)]
+	if !ok || osVersion == "" {
+		return nil, nil
+	}
+	cycles, err := c.Cycles(product)
+	if err != nil {
+		return nil, err
+	}
+	cycle := MatchCycle(cycles, osVersion)
+	if cycle == nil {
+		return nil, nil
+	}
+	return cycle.Status(product, now)
+}
+
+// Cycles returns all release cycles of an endoflife.date product
+func (c *Client) Cycles(product string) ([]Cycle, error) {
+	if cycles, ok := c.cycles[product]; ok {
+		return cycles, nil
+	}
+	b, err := c.readCache(product)
+	if err != nil {
+		b, err = c.fetch(product)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.writeCache(product, b); err != nil {
+			fmt.Printf("WARNING: could not cache endoflife.date response for %s: %s\n", product, err.Error())
+		}
+	}
+	var cycles []Cycle
+	if err := json.Unmarshal(b, &cycles); err != nil {
+		return nil, fmt.Errorf("parsing endoflife.date response for %s: %w", product, err)
+	}
+	c.cycles[product] = cycles
+	return cycles, nil
+}
+
+// MatchCycle returns the cycle a version belongs to, i.e. the longest cycle
+// that is equal to the version or a prefix of it (so "3.19.1" is in "3.19")
+func MatchCycle(cycles []Cycle, version string) *Cycle {
+	var match *Cycle
+	for i, cycle := range cycles {
+		if version != cycle.Cycle && !strings.HasPrefix(version, cycle.Cycle+".") {
+			continue
+		}
+		if match == nil || len(cycle.Cycle) > len(match.Cycle) {
+			match = &cycles[i]
+		}
+	}
+	return match
+}
+
+// Status returns whether the cycle is past its end-of-life date
+func (cycle *Cycle) Status(product string, now time.Time) (*Status, error) {
+	var eol bool
+	if err := json.Unmarshal(cycle.EOL, &eol); err == nil {
+		status := &Status{EOL: eol}
+		if eol {
+			status.Details = fmt.Sprintf("%s %s has reached end of life", product, cycle.Cycle)
+		}
+		return status, nil
+	}
+	var s string
+	if err := json.Unmarshal(cycle.EOL, &s); err != nil {
+		return nil, fmt.Errorf("invalid eol value for %s %s: %s", product, cycle.Cycle, cycle.EOL)
+	}
+	date, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid eol date for %s %s: %w", product, cycle.Cycle, err)
+	}
+	if now.Before(date) {
+		return &Status{EOL: false}, nil
+	}
+	return &Status{EOL: true, Details: fmt.Sprintf("%s %s reached end of life on %s", product, cycle.Cycle, s)}, nil
+}
+
+func (c *Client) fetch(product string) ([]byte, error) {
+	resp, err := c.httpClient.Get(c.BaseURL + "/" + product + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s from endoflife.date: %w", product, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s from endoflife.date: unexpected status %s", product, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (c *Client) cachePath(product string) string {
+	return filepath.Join(c.CacheDir, product+".json")
+}
+
+func (c *Client) readCache(product string) ([]byte, error) {
+	if c.CacheDir == "" {
+		return nil, os.ErrNotExist
+	}
+	info, err := os.Stat(c.cachePath(product))
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(info.ModTime()) > cacheTTL {
+		return nil, fmt.Errorf("cache entry for %s is stale", product)
+	}
+	return os.ReadFile(c.cachePath(product))
+}
+
+func (c *Client) writeCache(product string, b []byte) error {
+	if c.CacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.CacheDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.cachePath(product), b, 0644)
+}
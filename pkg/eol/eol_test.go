@@ -0,0 +1,45 @@
+package eol
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMatchCycle(t *testing.T) {
+	var cycles []Cycle
+	if err := json.Unmarshal([]byte(`[
+		{"cycle": "3.19", "eol": "2025-11-01"},
+		{"cycle": "3.1", "eol": "2016-05-01"},
+		{"cycle": "3.16", "eol": "2024-05-23"},
+		{"cycle": "edge", "eol": false}
+	]`), &cycles); err != nil {
+		t.Fatalf("expected no error on json.Unmarshal(), got %v", err)
+	}
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]bool{
+		"3.19.1": false,
+		"3.19":   false,
+		"3.16.9": true,
+		"3.1.4":  true,
+		"edge":   false,
+	}
+	for version, expected := range tests {
+		cycle := MatchCycle(cycles, version)
+		if cycle == nil {
+			t.Errorf("expected a cycle for %s, got nil", version)
+			continue
+		}
+		status, err := cycle.Status("alpine", now)
+		if err != nil {
+			t.Errorf("expected no error on Status() for %s, got %v", version, err)
+			continue
+		}
+		if status.EOL != expected {
+			t.Errorf("EOL for %s is %t, wanted %t", version, status.EOL, expected)
+		}
+	}
+	if cycle := MatchCycle(cycles, "3.2.0"); cycle != nil {
+		t.Errorf("expected no cycle for 3.2.0, got %s", cycle.Cycle)
+	}
+}
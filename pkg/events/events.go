@@ -0,0 +1,91 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// Event is the compact scan-completed event published after each scan, for
+// consumers that react to new results without querying the tables
+type Event struct {
+	ScanID  string `json:"scan_id"`
+	Image   string `json:"image"`
+	Digest  string `json:"digest"`
+	Scanner string `json:"scanner"`
+	Time    string `json:"time"`
+	Success bool   `json:"success"`
+
+	Severities Severities `json:"severities"`
+	Policy     Policy     `json:"policy"`
+}
+
+// Severities are the (deduplicated) CVE counts of a scan by severity
+type Severities struct {
+	Critical   int `json:"critical"`
+	High       int `json:"high"`
+	Medium     int `json:"medium"`
+	Low        int `json:"low"`
+	Negligible int `json:"negligible"`
+	Unknown    int `json:"unknown"`
+	Total      int `json:"total"`
+}
+
+// Policy holds the outcome of the checks rumble performs on an image
+// besides scanning it. Checks that weren't enabled are left at their zero
+// values.
+type Policy struct {
+	SignatureVerified       bool   `json:"signature_verified"`
+	AttestationVerification string `json:"attestation_verification,omitempty"`
+	EOL                     bool   `json:"eol"`
+}
+
+// Publisher publishes events, e.g. to a Pub/Sub or Kafka topic
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+	Close() error
+}
+
+// New returns the event for a scan summary. The summary's ID must already
+// be set.
+func New(summary *types.ImageScanSummary) *Event {
+	return &Event{
+		ScanID:  summary.ID,
+		Image:   summary.Image,
+		Digest:  summary.Digest,
+		Scanner: summary.Scanner,
+		Time:    summary.Time,
+		Success: summary.Success,
+		Severities: Severities{
+			Critical:   summary.CritCveCount,
+			High:       summary.HighCveCount,
+			Medium:     summary.MedCveCount,
+			Low:        summary.LowCveCount,
+			Negligible: summary.NegligibleCveCount,
+			Unknown:    summary.UnknownCveCount,
+			Total:      summary.TotCveCount,
+		},
+		Policy: Policy{
+			SignatureVerified:       summary.SignatureVerified,
+			AttestationVerification: summary.AttestationVerification,
+			EOL:                     summary.EOL,
+		},
+	}
+}
+
+// Marshal returns the JSON message body of an event
+func (event *Event) Marshal() ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// Attributes returns the metadata sent alongside the message body (Pub/Sub
+// attributes or Kafka headers), so subscribers can filter without decoding it
+func (event *Event) Attributes() map[string]string {
+	return map[string]string{
+		"type":    "scan-completed",
+		"image":   event.Image,
+		"scanner": event.Scanner,
+		"scan_id": event.ScanID,
+	}
+}
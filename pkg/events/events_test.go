@@ -0,0 +1,57 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestNew(t *testing.T) {
+	summary := &types.ImageScanSummary{
+		ID:                      "testing123",
+		Image:                   "cgr.dev/chainguard/static:latest",
+		Digest:                  "sha256:abc",
+		Scanner:                 "grype",
+		Time:                    "2023-06-22T02:38:46Z",
+		Success:                 true,
+		CritCveCount:            1,
+		HighCveCount:            2,
+		TotCveCount:             3,
+		SignatureVerified:       true,
+		AttestationVerification: "verified",
+	}
+	b, err := New(summary).Marshal()
+	if err != nil {
+		t.Fatalf("expected no error on Marshal(), got %v", err)
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(b, &event); err != nil {
+		t.Fatalf("expected no error on json.Unmarshal(), got %v", err)
+	}
+	if event["scan_id"] != "testing123" || event["digest"] != "sha256:abc" {
+		t.Errorf("expected the scan ID and digest, got %v", event)
+	}
+	severities, ok := event["severities"].(map[string]interface{})
+	if !ok || severities["critical"] != float64(1) || severities["high"] != float64(2) || severities["total"] != float64(3) {
+		t.Errorf("expected severity counts, got %v", event["severities"])
+	}
+	policy, ok := event["policy"].(map[string]interface{})
+	if !ok || policy["signature_verified"] != true || policy["attestation_verification"] != "verified" || policy["eol"] != false {
+		t.Errorf("expected policy results, got %v", event["policy"])
+	}
+}
+
+func TestMessage(t *testing.T) {
+	event := New(&types.ImageScanSummary{ID: "testing123", Image: "cgr.dev/chainguard/static:latest", Scanner: "trivy"})
+	msg, err := Message(event)
+	if err != nil {
+		t.Fatalf("expected no error on Message(), got %v", err)
+	}
+	if string(msg.Key) != "cgr.dev/chainguard/static:latest" {
+		t.Errorf("message key is %s, wanted the image", msg.Key)
+	}
+	if len(msg.Headers) != 4 || msg.Headers[0].Key != "image" || msg.Headers[3].Key != "type" || string(msg.Headers[3].Value) != "scan-completed" {
+		t.Errorf("expected sorted image, scan_id, scanner and type headers, got %v", msg.Headers)
+	}
+}
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka publishes events to a Kafka topic. Messages are keyed by image, so
+// the events for an image land in the same partition, in order.
+type Kafka struct {
+	writer *kafka.Writer
+}
+
+// NewKafka returns a publisher for a topic on the given brokers
+func NewKafka(brokers []string, topic string) (*Kafka, error) {
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("at least one Kafka broker is required")
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("a Kafka topic is required")
+	}
+	return &Kafka{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}}, nil
+}
+
+// Message returns the Kafka message for an event
+func Message(event *Event) (kafka.Message, error) {
+	data, err := event.Marshal()
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	attributes := event.Attributes()
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	headers := make([]kafka.Header, len(keys))
+	for i, key := range keys {
+		headers[i] = kafka.Header{Key: key, Value: []byte(attributes[key])}
+	}
+	return kafka.Message{Key: []byte(event.Image), Value: data, Headers: headers}, nil
+}
+
+func (k *Kafka) Publish(ctx context.Context, event *Event) error {
+	msg, err := Message(event)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Publishing scan-completed event to Kafka topic \"%s\" (scan_id=\"%s\")\n", k.writer.Topic, event.ScanID)
+	return k.writer.WriteMessages(ctx, msg)
+}
+
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
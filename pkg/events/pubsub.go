@@ -0,0 +1,50 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// PubSub publishes events to a Pub/Sub topic
+type PubSub struct {
+	client *pubsub.Client
+	topic  *pubsub.Topic
+}
+
+// NewPubSub returns a publisher for a topic, given either as a topic ID in
+// the client's project or as projects/<project>/topics/<topic>
+func NewPubSub(client *pubsub.Client, topic string) (*PubSub, error) {
+	if strings.HasPrefix(topic, "projects/") {
+		parts := strings.Split(topic, "/")
+		if len(parts) != 4 || parts[2] != "topics" || parts[1] == "" || parts[3] == "" {
+			return nil, fmt.Errorf("invalid Pub/Sub topic %q, expected projects/<project>/topics/<topic>", topic)
+		}
+		return &PubSub{client: client, topic: client.TopicInProject(parts[3], parts[1])}, nil
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("a Pub/Sub topic is required")
+	}
+	return &PubSub{client: client, topic: client.Topic(topic)}, nil
+}
+
+func (p *PubSub) Publish(ctx context.Context, event *Event) error {
+	data, err := event.Marshal()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Publishing scan-completed event to Pub/Sub topic \"%s\" (scan_id=\"%s\")\n", p.topic.String(), event.ScanID)
+	id, err := p.topic.Publish(ctx, &pubsub.Message{Data: data, Attributes: event.Attributes()}).Get(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Published message %s\n", id)
+	return nil
+}
+
+func (p *PubSub) Close() error {
+	p.topic.Stop()
+	return p.client.Close()
+}
@@ -0,0 +1,428 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/diff"
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/report"
+	"github.com/chainguard-dev/rumble/pkg/types"
+	"github.com/graphql-go/graphql"
+)
+
+// Source reads the recorded results the schema serves, as the query
+// commands do
+type Source struct {
+	Summaries func(ctx context.Context, filter query.Filter) ([]*types.ImageScanSummary, error)
+	ScanVulns func(ctx context.Context, scanID string) ([]*types.Vuln, error)
+	Search    func(ctx context.Context, search query.Search) ([]*query.SearchResult, error)
+}
+
+// image is an image/scanner pair, with its latest scan
+type image struct {
+	latest *types.ImageScanSummary
+}
+
+// diffResult is a diff between two scans
+type diffResult struct {
+	from, to       *types.ImageScanSummary
+	added, removed []diff.Entry
+}
+
+// NewSchema returns the GraphQL schema over the scans of src:
+//
+//	images(scanner): the latest scan of each image/scanner pair
+//	scans(image, scanner, since, severity, limit): scans, most recent first
+//	scan(id): a single scan, with its vulns and a diff against another scan
+//	affected(vulnerability): the images whose latest scan has a vuln
+//	diff(from, to): the vulns added and removed between two scans
+func NewSchema(src Source) (graphql.Schema, error) {
+	r := &resolver{src: src}
+
+	vulnType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Vuln",
+		Description: "A vulnerability found in a package by a scan",
+		Fields: graphql.Fields{
+			"id":            vulnField(graphql.ID, func(v *types.Vuln) interface{} { return v.ID }),
+			"scanId":        vulnField(graphql.ID, func(v *types.Vuln) interface{} { return v.ScanID }),
+			"vulnerability": vulnField(graphql.String, func(v *types.Vuln) interface{} { return v.Vulnerability }),
+			"package":       vulnField(graphql.String, func(v *types.Vuln) interface{} { return v.Name }),
+			"installed":     vulnField(graphql.String, func(v *types.Vuln) interface{} { return v.Installed }),
+			"fixedIn":       vulnField(graphql.String, func(v *types.Vuln) interface{} { return v.FixedIn }),
+			"type":          vulnField(graphql.String, func(v *types.Vuln) interface{} { return v.Type }),
+			"severity": vulnField(graphql.String, func(v *types.Vuln) interface{} {
+				return report.Severities[report.SeverityRank(v)]
+			}),
+			"scannerSeverity": vulnField(graphql.String, func(v *types.Vuln) interface{} { return v.Severity }),
+			"nvdSeverity":     vulnField(graphql.String, func(v *types.Vuln) interface{} { return v.NvdSeverity }),
+			"nvdCvssScore":    vulnField(graphql.Float, func(v *types.Vuln) interface{} { return v.NvdCvssScore }),
+			"description":     vulnField(graphql.String, func(v *types.Vuln) interface{} { return v.Description }),
+			"dataSource":      vulnField(graphql.String, func(v *types.Vuln) interface{} { return v.DataSource }),
+			"target":          vulnField(graphql.String, func(v *types.Vuln) interface{} { return v.Target }),
+			"jiraIssue":       vulnField(graphql.String, func(v *types.Vuln) interface{} { return v.JiraIssue }),
+		},
+	})
+
+	entryType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "DiffEntry",
+		Description: "A vulnerability added or removed between two scans",
+		Fields: graphql.Fields{
+			"vulnerability": entryField(func(e diff.Entry) string { return e.Vulnerability }),
+			"package":       entryField(func(e diff.Entry) string { return e.Package }),
+			"installed":     entryField(func(e diff.Entry) string { return e.Installed }),
+			"fixedIn":       entryField(func(e diff.Entry) string { return e.FixedIn }),
+			"type":          entryField(func(e diff.Entry) string { return e.Type }),
+			"severity":      entryField(func(e diff.Entry) string { return e.Severity }),
+		},
+	})
+
+	var scanType *graphql.Object
+	diffType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Diff",
+		Description: "The vulnerabilities added and removed between two scans",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"from": &graphql.Field{Type: graphql.NewNonNull(scanType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*diffResult).from, nil
+				}},
+				"to": &graphql.Field{Type: graphql.NewNonNull(scanType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*diffResult).to, nil
+				}},
+				"added": &graphql.Field{Type: list(entryType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*diffResult).added, nil
+				}},
+				"removed": &graphql.Field{Type: list(entryType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*diffResult).removed, nil
+				}},
+			}
+		}),
+	})
+
+	scanType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Scan",
+		Description: "A scan of an image, as recorded in the summary table",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":               scanField(graphql.ID, func(s *types.ImageScanSummary) interface{} { return s.ID }),
+				"image":            scanField(graphql.String, func(s *types.ImageScanSummary) interface{} { return s.Image }),
+				"digest":           scanField(graphql.String, func(s *types.ImageScanSummary) interface{} { return s.Digest }),
+				"repository":       scanField(graphql.String, func(s *types.ImageScanSummary) interface{} { return s.Repository }),
+				"tag":              scanField(graphql.String, func(s *types.ImageScanSummary) interface{} { return s.Tag }),
+				"scanner":          scanField(graphql.String, func(s *types.ImageScanSummary) interface{} { return s.Scanner }),
+				"scannerVersion":   scanField(graphql.String, func(s *types.ImageScanSummary) interface{} { return s.ScannerVersion }),
+				"scannerDbVersion": scanField(graphql.String, func(s *types.ImageScanSummary) interface{} { return s.ScannerDbVersion }),
+				"time":             scanField(graphql.String, func(s *types.ImageScanSummary) interface{} { return s.Time }),
+				"created":          scanField(graphql.String, func(s *types.ImageScanSummary) interface{} { return s.Created }),
+				"success":          scanField(graphql.Boolean, func(s *types.ImageScanSummary) interface{} { return s.Success }),
+				"osName":           scanField(graphql.String, func(s *types.ImageScanSummary) interface{} { return s.OsName }),
+				"osVersion":        scanField(graphql.String, func(s *types.ImageScanSummary) interface{} { return s.OsVersion }),
+				"critical":         scanField(graphql.Int, func(s *types.ImageScanSummary) interface{} { return s.CritCveCount }),
+				"high":             scanField(graphql.Int, func(s *types.ImageScanSummary) interface{} { return s.HighCveCount }),
+				"medium":           scanField(graphql.Int, func(s *types.ImageScanSummary) interface{} { return s.MedCveCount }),
+				"low":              scanField(graphql.Int, func(s *types.ImageScanSummary) interface{} { return s.LowCveCount }),
+				"negligible":       scanField(graphql.Int, func(s *types.ImageScanSummary) interface{} { return s.NegligibleCveCount }),
+				"unknown":          scanField(graphql.Int, func(s *types.ImageScanSummary) interface{} { return s.UnknownCveCount }),
+				"total":            scanField(graphql.Int, func(s *types.ImageScanSummary) interface{} { return s.TotCveCount }),
+				"vulns": &graphql.Field{
+					Type:        list(vulnType),
+					Description: "The vulns found by the scan, most severe first",
+					Args: graphql.FieldConfigArgument{
+						"severity": {Type: graphql.String, Description: "Only return vulns at this severity or above"},
+					},
+					Resolve: r.vulns,
+				},
+				"previous": &graphql.Field{
+					Type:        scanType,
+					Description: "The scan of the same image and scanner before this one",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.previous(p.Context, p.Source.(*types.ImageScanSummary))
+					},
+				},
+				"diff": &graphql.Field{
+					Type:        diffType,
+					Description: "The vulns added and removed since another scan, by default the previous one",
+					Args: graphql.FieldConfigArgument{
+						"against": {Type: graphql.ID, Description: "The ID of the scan to compare against"},
+					},
+					Resolve: r.scanDiff,
+				},
+			}
+		}),
+	})
+
+	imageType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Image",
+		Description: "An image scanned by a scanner",
+		Fields: graphql.Fields{
+			"image": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*image).latest.Image, nil
+			}},
+			"scanner": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*image).latest.Scanner, nil
+			}},
+			"latest": &graphql.Field{Type: graphql.NewNonNull(scanType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*image).latest, nil
+			}},
+			"scans": &graphql.Field{
+				Type:        list(scanType),
+				Description: "The scans of the image, most recent first",
+				Args: graphql.FieldConfigArgument{
+					"limit": {Type: graphql.Int, DefaultValue: 10},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					latest := p.Source.(*image).latest
+					return r.src.Summaries(p.Context, query.Filter{Image: latest.Image, Scanner: latest.Scanner, Limit: p.Args["limit"].(int)})
+				},
+			},
+		},
+	})
+
+	affectedType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Affected",
+		Description: "A package with a vulnerability in the latest scan of an image",
+		Fields: graphql.Fields{
+			"image":   resultField(func(s *query.SearchResult) string { return s.Image }),
+			"scanner": resultField(func(s *query.SearchResult) string { return s.Scanner }),
+			"digest":  resultField(func(s *query.SearchResult) string { return s.Digest }),
+			"time":    resultField(func(s *query.SearchResult) string { return s.Time }),
+			"package": resultField(func(s *query.SearchResult) string { return s.Package }),
+			"version": resultField(func(s *query.SearchResult) string { return s.Version }),
+			"type":    resultField(func(s *query.SearchResult) string { return s.Type }),
+			"fixedIn": resultField(func(s *query.SearchResult) string { return s.FixedIn }),
+			"scan": &graphql.Field{Type: scanType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return r.scan(p.Context, p.Source.(*query.SearchResult).ScanID)
+			}},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"images": &graphql.Field{
+					Type:        list(imageType),
+					Description: "The latest scan of each image/scanner pair",
+					Args: graphql.FieldConfigArgument{
+						"scanner": {Type: graphql.String},
+					},
+					Resolve: r.images,
+				},
+				"scans": &graphql.Field{
+					Type:        list(scanType),
+					Description: "Scans, most recent first",
+					Args: graphql.FieldConfigArgument{
+						"image":    {Type: graphql.String},
+						"scanner":  {Type: graphql.String},
+						"since":    {Type: graphql.String, Description: "An age (e.g. \"30d\") or date (e.g. \"2006-01-02\")"},
+						"severity": {Type: graphql.String, Description: "Only scans with a CVE at this severity or above"},
+						"limit":    {Type: graphql.Int, DefaultValue: 100},
+					},
+					Resolve: r.scans,
+				},
+				"scan": &graphql.Field{
+					Type: scanType,
+					Args: graphql.FieldConfigArgument{
+						"id": {Type: graphql.NewNonNull(graphql.ID)},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.scan(p.Context, p.Args["id"].(string))
+					},
+				},
+				"affected": &graphql.Field{
+					Type:        list(affectedType),
+					Description: "The images whose latest scan has a vulnerability",
+					Args: graphql.FieldConfigArgument{
+						"vulnerability": {Type: graphql.NewNonNull(graphql.String)},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.src.Search(p.Context, query.Search{CVE: p.Args["vulnerability"].(string)})
+					},
+				},
+				"diff": &graphql.Field{
+					Type: diffType,
+					Args: graphql.FieldConfigArgument{
+						"from": {Type: graphql.NewNonNull(graphql.ID)},
+						"to":   {Type: graphql.NewNonNull(graphql.ID)},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						from, err := r.scan(p.Context, p.Args["from"].(string))
+						if err != nil || from == nil {
+							return nil, err
+						}
+						return r.diff(p.Context, from, p.Args["to"].(string))
+					},
+				},
+			},
+		}),
+	})
+}
+
+type resolver struct {
+	src Source
+}
+
+func (r *resolver) images(p graphql.ResolveParams) (interface{}, error) {
+	scanner, _ := p.Args["scanner"].(string)
+	scans, err := r.src.Summaries(p.Context, query.Filter{LatestOnly: true, Scanner: scanner})
+	if err != nil {
+		return nil, err
+	}
+	images := make([]*image, len(scans))
+	for i, scan := range scans {
+		images[i] = &image{latest: scan}
+	}
+	return images, nil
+}
+
+func (r *resolver) scans(p graphql.ResolveParams) (interface{}, error) {
+	filter := query.Filter{Limit: p.Args["limit"].(int)}
+	filter.Image, _ = p.Args["image"].(string)
+	filter.Scanner, _ = p.Args["scanner"].(string)
+	if since, ok := p.Args["since"].(string); ok {
+		t, err := query.ParseSince(since, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		filter.Since = t
+	}
+	if severity, ok := p.Args["severity"].(string); ok {
+		if _, err := severityRank(severity); err != nil {
+			return nil, err
+		}
+		filter.Severity = severity
+	}
+	return r.src.Summaries(p.Context, filter)
+}
+
+// scan returns the scan with an ID, or nil if there is none
+func (r *resolver) scan(ctx context.Context, id string) (*types.ImageScanSummary, error) {
+	scans, err := r.src.Summaries(ctx, query.Filter{ID: id})
+	if err != nil || len(scans) == 0 {
+		return nil, err
+	}
+	return scans[0], nil
+}
+
+func (r *resolver) vulns(p graphql.ResolveParams) (interface{}, error) {
+	rank := len(report.Severities) - 1
+	if severity, ok := p.Args["severity"].(string); ok {
+		var err error
+		if rank, err = severityRank(severity); err != nil {
+			return nil, err
+		}
+	}
+	vulns, err := r.src.ScanVulns(p.Context, p.Source.(*types.ImageScanSummary).ID)
+	if err != nil {
+		return nil, err
+	}
+	matching := []*types.Vuln{}
+	for _, vuln := range vulns {
+		if report.SeverityRank(vuln) <= rank {
+			matching = append(matching, vuln)
+		}
+	}
+	sortVulns(matching)
+	return matching, nil
+}
+
+// previous returns the scan of the same image and scanner before a scan, or
+// nil if there is none
+func (r *resolver) previous(ctx context.Context, scan *types.ImageScanSummary) (*types.ImageScanSummary, error) {
+	scans, err := r.src.Summaries(ctx, query.Filter{Image: scan.Image, Scanner: scan.Scanner})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range scans {
+		if s.ID != scan.ID && s.Time < scan.Time {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *resolver) scanDiff(p graphql.ResolveParams) (interface{}, error) {
+	scan := p.Source.(*types.ImageScanSummary)
+	if against, ok := p.Args["against"].(string); ok {
+		from, err := r.scan(p.Context, against)
+		if err != nil || from == nil {
+			return nil, err
+		}
+		return r.diff(p.Context, from, scan.ID)
+	}
+	previous, err := r.previous(p.Context, scan)
+	if err != nil || previous == nil {
+		return nil, err
+	}
+	return r.diff(p.Context, previous, scan.ID)
+}
+
+// diff compares a scan with the scan with ID to, returning nil if there is
+// none
+func (r *resolver) diff(ctx context.Context, from *types.ImageScanSummary, to string) (*diffResult, error) {
+	toScan, err := r.scan(ctx, to)
+	if err != nil || toScan == nil {
+		return nil, err
+	}
+	before, err := r.src.ScanVulns(ctx, from.ID)
+	if err != nil {
+		return nil, err
+	}
+	after, err := r.src.ScanVulns(ctx, toScan.ID)
+	if err != nil {
+		return nil, err
+	}
+	added, removed := diff.Vulns(before, after)
+	return &diffResult{from: from, to: toScan, added: added, removed: removed}, nil
+}
+
+// severityRank returns the index of a severity in report.Severities
+func severityRank(severity string) (int, error) {
+	for i, s := range report.Severities {
+		if strings.EqualFold(s, severity) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid severity %q, expected one of: %s", severity, strings.Join(report.Severities, ", "))
+}
+
+func list(t graphql.Type) graphql.Output {
+	return graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(t)))
+}
+
+func scanField(t graphql.Output, get func(*types.ImageScanSummary) interface{}) *graphql.Field {
+	return &graphql.Field{Type: graphql.NewNonNull(t), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(*types.ImageScanSummary)), nil
+	}}
+}
+
+func vulnField(t graphql.Output, get func(*types.Vuln) interface{}) *graphql.Field {
+	return &graphql.Field{Type: graphql.NewNonNull(t), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(*types.Vuln)), nil
+	}}
+}
+
+func entryField(get func(diff.Entry) string) *graphql.Field {
+	return &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(diff.Entry)), nil
+	}}
+}
+
+func resultField(get func(*query.SearchResult) string) *graphql.Field {
+	return &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return get(p.Source.(*query.SearchResult)), nil
+	}}
+}
+
+// sortVulns orders vulns most severe first
+func sortVulns(vulns []*types.Vuln) {
+	sort.SliceStable(vulns, func(i, j int) bool {
+		if ri, rj := report.SeverityRank(vulns[i]), report.SeverityRank(vulns[j]); ri != rj {
+			return ri < rj
+		}
+		return vulns[i].Vulnerability < vulns[j].Vulnerability
+	})
+}
@@ -0,0 +1,131 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/types"
+	"github.com/graphql-go/graphql"
+)
+
+func testSchema(t *testing.T) graphql.Schema {
+	scans := []*types.ImageScanSummary{
+		{ID: "scan-2", Image: "cgr.dev/chainguard/static:latest", Scanner: "grype", Time: "2023-06-23T02:38:46Z", CritCveCount: 1, TotCveCount: 2},
+		{ID: "scan-1", Image: "cgr.dev/chainguard/static:latest", Scanner: "grype", Time: "2023-06-22T02:38:46Z", CritCveCount: 1, TotCveCount: 2},
+	}
+	vulns := map[string][]*types.Vuln{
+		"scan-1": {
+			{ScanID: "scan-1", Vulnerability: "CVE-2023-0003", Name: "busybox", Severity: "Medium"},
+			{ScanID: "scan-1", Vulnerability: "CVE-2023-0001", Name: "openssl", Severity: "Critical"},
+		},
+		"scan-2": {
+			{ScanID: "scan-2", Vulnerability: "CVE-2023-0002", Name: "zlib", Severity: "Low"},
+			{ScanID: "scan-2", Vulnerability: "CVE-2023-0001", Name: "openssl", Severity: "Critical"},
+		},
+	}
+	schema, err := NewSchema(Source{
+		Summaries: func(ctx context.Context, filter query.Filter) ([]*types.ImageScanSummary, error) {
+			matching := []*types.ImageScanSummary{}
+			for _, scan := range scans {
+				if (filter.ID != "" && scan.ID != filter.ID) || (filter.Image != "" && scan.Image != filter.Image) {
+					continue
+				}
+				matching = append(matching, scan)
+				if filter.LatestOnly || len(matching) == filter.Limit {
+					break
+				}
+			}
+			return matching, nil
+		},
+		ScanVulns: func(ctx context.Context, scanID string) ([]*types.Vuln, error) {
+			return vulns[scanID], nil
+		},
+		Search: func(ctx context.Context, search query.Search) ([]*query.SearchResult, error) {
+			return []*query.SearchResult{{Image: "cgr.dev/chainguard/static:latest", Scanner: "grype", ScanID: "scan-2", Package: "openssl", Vulnerability: search.CVE}}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected no error on NewSchema(), got %v", err)
+	}
+	return schema
+}
+
+func TestSchema(t *testing.T) {
+	schema := testSchema(t)
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{{
+		query: `{ images { image scanner latest { id critical } scans(limit: 1) { id } } }`,
+		want:  `{"images":[{"image":"cgr.dev/chainguard/static:latest","latest":{"critical":1,"id":"scan-2"},"scanner":"grype","scans":[{"id":"scan-2"}]}]}`,
+	}, {
+		query: `{ scan(id: "scan-2") { vulns { vulnerability severity } previous { id } } }`,
+		want:  `{"scan":{"previous":{"id":"scan-1"},"vulns":[{"severity":"Critical","vulnerability":"CVE-2023-0001"},{"severity":"Low","vulnerability":"CVE-2023-0002"}]}}`,
+	}, {
+		query: `{ scan(id: "scan-2") { vulns(severity: "high") { vulnerability } } }`,
+		want:  `{"scan":{"vulns":[{"vulnerability":"CVE-2023-0001"}]}}`,
+	}, {
+		query: `{ scan(id: "scan-2") { diff { from { id } added { vulnerability } removed { vulnerability package } } } }`,
+		want:  `{"scan":{"diff":{"added":[{"vulnerability":"CVE-2023-0002"}],"from":{"id":"scan-1"},"removed":[{"package":"busybox","vulnerability":"CVE-2023-0003"}]}}}`,
+	}, {
+		query: `{ scan(id: "scan-1") { previous { id } diff { from { id } } } missing: scan(id: "missing") { id } }`,
+		want:  `{"missing":null,"scan":{"diff":null,"previous":null}}`,
+	}, {
+		query: `{ affected(vulnerability: "CVE-2023-0001") { image package scan { id } } }`,
+		want:  `{"affected":[{"image":"cgr.dev/chainguard/static:latest","package":"openssl","scan":{"id":"scan-2"}}]}`,
+	}} {
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: tc.query, Context: context.Background()})
+		if len(result.Errors) > 0 {
+			t.Errorf("expected no errors for %s, got %v", tc.query, result.Errors)
+			continue
+		}
+		b, _ := json.Marshal(result.Data)
+		if string(b) != tc.want {
+			t.Errorf("got %s for %s, wanted %s", b, tc.query, tc.want)
+		}
+	}
+
+	result := graphql.Do(graphql.Params{Schema: schema, RequestString: `{ scans(severity: "severe") { id } }`, Context: context.Background()})
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "invalid severity") {
+		t.Errorf("expected an invalid severity error, got %v", result.Errors)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(Handler(testSchema(t)))
+	defer srv.Close()
+
+	body := `{"query": "query($id: ID!) { scan(id: $id) { image } }", "variables": {"id": "scan-1"}}`
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("expected no error on POST, got %v", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Data struct {
+			Scan struct {
+				Image string `json:"image"`
+			} `json:"scan"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("expected no error decoding the response, got %v", err)
+	}
+	if result.Data.Scan.Image != "cgr.dev/chainguard/static:latest" {
+		t.Errorf("got %+v, wanted the image of scan-1", result)
+	}
+
+	resp, err = http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected no error on GET, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 without a query, got %d", resp.StatusCode)
+	}
+}
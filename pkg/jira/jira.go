@@ -0,0 +1,207 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// searchPath is Jira Cloud's search API, and legacySearchPath the one
+	// older Jira Data Center releases have instead
+	searchPath       = "/rest/api/2/search/jql"
+	legacySearchPath = "/rest/api/2/search"
+)
+
+// Issue is a Jira issue, with only the fields rumble reads
+type Issue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary string   `json:"summary"`
+		Labels  []string `json:"labels"`
+	} `json:"fields"`
+}
+
+// NewIssue is an issue to create
+type NewIssue struct {
+	Project     string
+	IssueType   string
+	Summary     string
+	Description string
+	Labels      []string
+}
+
+// Client calls the Jira REST API (version 2, which both Jira Cloud and
+// Data Center serve). With a username the token is sent with basic auth,
+// as Jira Cloud API tokens are, and otherwise as a bearer token, as Data
+// Center personal access tokens are.
+type Client struct {
+	BaseURL  string
+	Username string
+	Token    string
+
+	httpClient *http.Client
+	searchPath string
+}
+
+func NewClient(baseURL string, username string, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Username:   username,
+		Token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		searchPath: searchPath,
+	}
+}
+
+// Search returns the issues matching a JQL query, going through every page
+func (c *Client) Search(ctx context.Context, jql string) ([]*Issue, error) {
+	issues := []*Issue{}
+	request := map[string]interface{}{"jql": jql, "fields": []string{"summary", "labels"}, "maxResults": 100}
+	for {
+		var page struct {
+			Issues        []*Issue `json:"issues"`
+			StartAt       int      `json:"startAt"`
+			Total         int      `json:"total"`
+			NextPageToken string   `json:"nextPageToken"`
+		}
+		err := c.do(ctx, http.MethodPost, c.searchPath, request, &page)
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound && c.searchPath == searchPath {
+			c.searchPath = legacySearchPath
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, page.Issues...)
+		// The new search API pages with a token, and the legacy one by offset
+		switch {
+		case page.NextPageToken != "":
+			request["nextPageToken"] = page.NextPageToken
+		case c.searchPath == legacySearchPath && len(page.Issues) > 0 && page.StartAt+len(page.Issues) < page.Total:
+			request["startAt"] = page.StartAt + len(page.Issues)
+		default:
+			return issues, nil
+		}
+	}
+}
+
+// Create creates an issue, returning its key
+func (c *Client) Create(ctx context.Context, issue *NewIssue) (string, error) {
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": issue.Project},
+		"issuetype":   map[string]string{"name": issue.IssueType},
+		"summary":     issue.Summary,
+		"description": issue.Description,
+		"labels":      issue.Labels,
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/rest/api/2/issue", map[string]interface{}{"fields": fields}, &created); err != nil {
+		return "", err
+	}
+	return created.Key, nil
+}
+
+// Comment adds a comment to an issue
+func (c *Client) Comment(ctx context.Context, key string, body string) error {
+	return c.do(ctx, http.MethodPost, "/rest/api/2/issue/"+url.PathEscape(key)+"/comment", map[string]string{"body": body}, nil)
+}
+
+// UpdateLabels adds and removes labels of an issue, leaving the others
+func (c *Client) UpdateLabels(ctx context.Context, key string, add []string, remove []string) error {
+	ops := []map[string]string{}
+	for _, label := range add {
+		ops = append(ops, map[string]string{"add": label})
+	}
+	for _, label := range remove {
+		ops = append(ops, map[string]string{"remove": label})
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	return c.do(ctx, http.MethodPut, "/rest/api/2/issue/"+url.PathEscape(key), map[string]interface{}{"update": map[string]interface{}{"labels": ops}}, nil)
+}
+
+// Transition moves an issue through the transition with a name (e.g.
+// "Done"), which depends on the project's workflow
+func (c *Client) Transition(ctx context.Context, key string, name string) error {
+	path := "/rest/api/2/issue/" + url.PathEscape(key) + "/transitions"
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &available); err != nil {
+		return err
+	}
+	names := []string{}
+	for _, transition := range available.Transitions {
+		if strings.EqualFold(transition.Name, name) {
+			return c.do(ctx, http.MethodPost, path, map[string]interface{}{"transition": map[string]string{"id": transition.ID}}, nil)
+		}
+		names = append(names, transition.Name)
+	}
+	return fmt.Errorf("%s has no %q transition, only: %s", key, name, strings.Join(names, ", "))
+}
+
+// Error is an unexpected response from Jira
+type Error struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("unexpected status %s: %s", e.Status, e.Body)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, request interface{}, response interface{}) error {
+	var body io.Reader
+	if request != nil {
+		b, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Token)
+	} else if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling Jira: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %w", method, path, &Error{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(b))})
+	}
+	if response == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("%s %s: parsing response: %w", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+type fakeIssue struct {
+	labels   []string
+	comments []string
+	closed   bool
+}
+
+// fakeJira serves enough of the Jira API for a Tracker, matching the label
+// clauses of searches. With legacy set, it only has the older search API.
+type fakeJira struct {
+	mu     sync.Mutex
+	legacy bool
+	issues map[string]*fakeIssue
+	keys   []string
+}
+
+var (
+	labelEquals = regexp.MustCompile(`labels = "([^"]+)"`)
+	labelsIn    = regexp.MustCompile(`labels in \(([^)]+)\)`)
+)
+
+func (f *fakeJira) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	path := strings.TrimPrefix(r.URL.Path, "/rest/api/2/")
+	switch {
+	case (path == "search/jql" && !f.legacy) || (path == "search" && f.legacy):
+		jql := body["jql"].(string)
+		issues := []map[string]interface{}{}
+		for _, key := range f.keys {
+			issue := f.issues[key]
+			if !issue.closed && f.matches(issue, jql) {
+				issues = append(issues, map[string]interface{}{"key": key, "fields": map[string]interface{}{"labels": issue.labels}})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"issues": issues, "total": len(issues)})
+	case path == "issue" && r.Method == http.MethodPost:
+		key := fmt.Sprintf("SEC-%d", len(f.keys)+1)
+		labels := []string{}
+		for _, l := range body["fields"].(map[string]interface{})["labels"].([]interface{}) {
+			labels = append(labels, l.(string))
+		}
+		f.issues[key] = &fakeIssue{labels: labels}
+		f.keys = append(f.keys, key)
+		json.NewEncoder(w).Encode(map[string]string{"key": key})
+	case strings.HasSuffix(path, "/comment"):
+		issue := f.issues[strings.TrimSuffix(strings.TrimPrefix(path, "issue/"), "/comment")]
+		issue.comments = append(issue.comments, body["body"].(string))
+	case strings.HasSuffix(path, "/transitions") && r.Method == http.MethodGet:
+		fmt.Fprint(w, `{"transitions": [{"id": "11", "name": "In Progress"}, {"id": "31", "name": "Done"}]}`)
+	case strings.HasSuffix(path, "/transitions"):
+		if body["transition"].(map[string]interface{})["id"] == "31" {
+			f.issues[strings.TrimSuffix(strings.TrimPrefix(path, "issue/"), "/transitions")].closed = true
+		}
+	case r.Method == http.MethodPut:
+		issue := f.issues[strings.TrimPrefix(path, "issue/")]
+		for _, op := range body["update"].(map[string]interface{})["labels"].([]interface{}) {
+			op := op.(map[string]interface{})
+			if add, ok := op["add"]; ok {
+				issue.labels = append(issue.labels, add.(string))
+			}
+			if remove, ok := op["remove"]; ok {
+				kept := []string{}
+				for _, l := range issue.labels {
+					if l != remove {
+						kept = append(kept, l)
+					}
+				}
+				issue.labels = kept
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// matches reports whether an issue has every "labels =" label of a query,
+// and any of its "labels in" labels
+func (f *fakeJira) matches(issue *fakeIssue, jql string) bool {
+	has := func(l string) bool {
+		for _, have := range issue.labels {
+			if have == l {
+				return true
+			}
+		}
+		return false
+	}
+	for _, m := range labelEquals.FindAllStringSubmatch(jql, -1) {
+		if !has(m[1]) {
+			return false
+		}
+	}
+	if m := labelsIn.FindStringSubmatch(jql); m != nil {
+		for _, l := range strings.Split(m[1], ", ") {
+			if has(strings.Trim(l, `"`)) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func newTracker(t *testing.T, groupBy string, legacy bool) (*Tracker, *fakeJira) {
+	fake := &fakeJira{legacy: legacy, issues: map[string]*fakeIssue{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	return &Tracker{
+		Client:          NewClient(srv.URL, "rumble@example.com", "token"),
+		Project:         "SEC",
+		IssueType:       "Bug",
+		GroupBy:         groupBy,
+		CloseTransition: "done",
+	}, fake
+}
+
+func scan(image string, cves ...string) (*types.ImageScanSummary, []*types.Vuln) {
+	vulns := []*types.Vuln{{Vulnerability: "CVE-2024-9999", Name: "zlib", Severity: "Low"}}
+	for _, cve := range cves {
+		vulns = append(vulns, &types.Vuln{Vulnerability: cve, Name: "openssl", Installed: "3.1.0", Severity: "High"})
+	}
+	return &types.ImageScanSummary{ID: "scan-" + image, Image: image, Scanner: "grype"}, vulns
+}
+
+func TestTrackImage(t *testing.T) {
+	tracker, fake := newTracker(t, GroupByImage, true)
+	ctx := context.Background()
+
+	summary, vulns := scan("cgr.dev/chainguard/static:latest", "CVE-2024-0001", "CVE-2024-0002")
+	if err := tracker.Track(ctx, summary, vulns); err != nil {
+		t.Fatalf("expected no error on Track(), got %v", err)
+	}
+	if len(fake.keys) != 1 || vulns[1].JiraIssue != "SEC-1" || vulns[2].JiraIssue != "SEC-1" || vulns[0].JiraIssue != "" {
+		t.Fatalf("expected a single issue for the high vulns, got %v issue(s) and %+v", fake.keys, vulns)
+	}
+
+	// A rescan with a new CVE (and without another) updates the issue
+	summary, vulns = scan("cgr.dev/chainguard/static:latest", "CVE-2024-0002", "CVE-2024-0003")
+	if err := tracker.Track(ctx, summary, vulns); err != nil {
+		t.Fatalf("expected no error on Track(), got %v", err)
+	}
+	issue := fake.issues["SEC-1"]
+	if len(fake.keys) != 1 || len(issue.comments) != 1 || !strings.Contains(issue.comments[0], "1 no longer found: CVE-2024-0001") {
+		t.Errorf("expected the issue to be updated, got %v issue(s) and comments %v", fake.keys, issue.comments)
+	}
+	if labels := strings.Join(issue.labels, ","); !strings.Contains(labels, "rumble-cve-CVE-2024-0003") || strings.Contains(labels, "rumble-cve-CVE-2024-0001") {
+		t.Errorf("expected the issue's CVE labels to be updated, got %s", labels)
+	}
+
+	// It is closed once the image has no high or critical CVEs left
+	summary, vulns = scan("cgr.dev/chainguard/static:latest")
+	if err := tracker.Track(ctx, summary, vulns); err != nil {
+		t.Fatalf("expected no error on Track(), got %v", err)
+	}
+	if !issue.closed {
+		t.Errorf("expected the issue to be closed")
+	}
+}
+
+func TestTrackCVEs(t *testing.T) {
+	tracker, fake := newTracker(t, GroupByCVE, false)
+	ctx := context.Background()
+	for _, image := range []string{"cgr.dev/chainguard/static:latest", "cgr.dev/chainguard/go:latest"} {
+		summary, vulns := scan(image, "CVE-2024-0001")
+		if err := tracker.Track(ctx, summary, vulns); err != nil {
+			t.Fatalf("expected no error on Track(), got %v", err)
+		}
+		if vulns[1].JiraIssue != "SEC-1" {
+			t.Errorf("expected the vuln in %s to be tracked by SEC-1, got %q", image, vulns[1].JiraIssue)
+		}
+	}
+	issue := fake.issues["SEC-1"]
+	if len(fake.keys) != 1 || len(issue.comments) != 1 || !strings.HasPrefix(issue.comments[0], "Also found in cgr.dev/chainguard/go:latest") {
+		t.Fatalf("expected a single issue for both images, got %v issue(s) and comments %v", fake.keys, issue.comments)
+	}
+
+	// The issue is only closed once neither image has the CVE
+	summary, vulns := scan("cgr.dev/chainguard/static:latest")
+	if err := tracker.Track(ctx, summary, vulns); err != nil {
+		t.Fatalf("expected no error on Track(), got %v", err)
+	}
+	if issue.closed {
+		t.Errorf("expected the issue to be open while the CVE is in an image")
+	}
+	summary, vulns = scan("cgr.dev/chainguard/go:latest")
+	if err := tracker.Track(ctx, summary, vulns); err != nil {
+		t.Fatalf("expected no error on Track(), got %v", err)
+	}
+	if !issue.closed {
+		t.Errorf("expected the issue to be closed")
+	}
+}
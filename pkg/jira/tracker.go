@@ -0,0 +1,338 @@
+package jira
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/report"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// How a Tracker groups findings into issues
+const (
+	// GroupByImage files an issue per image (and scanner) for all of its
+	// high and critical CVEs
+	GroupByImage = "image"
+
+	// GroupByCVE files an issue per CVE for all the images it is found in
+	GroupByCVE = "cve"
+)
+
+// Every issue filed by rumble has this label, along with labels that
+// identify what it tracks
+const (
+	label            = "rumble"
+	imageLabelPrefix = "rumble-image-"
+	inLabelPrefix    = "rumble-in-"
+	cveLabelPrefix   = "rumble-cve-"
+)
+
+// Tracker files Jira issues for a scan's high and critical CVEs, keeps them
+// up to date as later scans find more or fewer, and closes them with
+// CloseTransition once they are no longer found. Issues are found again by
+// their labels, so they are left alone once closed; a CVE found again after
+// that gets a new issue.
+type Tracker struct {
+	Client          *Client
+	Project         string
+	IssueType       string
+	GroupBy         string
+	CloseTransition string
+
+	// Labels are added to the issues filed, besides rumble's own
+	Labels []string
+}
+
+// finding is a high or critical CVE, in one or more packages
+type finding struct {
+	id       string
+	severity string
+	vulns    []*types.Vuln
+}
+
+// findings returns the high and critical CVEs, most severe first
+func findings(vulns []*types.Vuln) []*finding {
+	byID := map[string]*finding{}
+	found := []*finding{}
+	for _, vuln := range vulns {
+		if report.SeverityRank(vuln) > 1 {
+			continue
+		}
+		f, ok := byID[vuln.Vulnerability]
+		if !ok {
+			f = &finding{id: vuln.Vulnerability, severity: report.Severities[report.SeverityRank(vuln)]}
+			byID[vuln.Vulnerability] = f
+			found = append(found, f)
+		}
+		// The finding is as severe as its most severe package
+		if report.SeverityRank(vuln) == 0 {
+			f.severity = report.Severities[0]
+		}
+		f.vulns = append(f.vulns, vuln)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].severity != found[j].severity {
+			return found[i].severity == report.Severities[0]
+		}
+		return found[i].id < found[j].id
+	})
+	return found
+}
+
+// packages lists the packages the CVE is found in
+func (f *finding) packages() string {
+	names := []string{}
+	for _, vuln := range f.vulns {
+		name := vuln.Name + " " + vuln.Installed
+		if vuln.FixedIn != "" {
+			name += " (fixed in " + vuln.FixedIn + ")"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// scanKey identifies an image and scanner in labels, as image references
+// can't be labels themselves
+func scanKey(summary *types.ImageScanSummary) string {
+	sum := sha256.Sum256([]byte(summary.Image + " " + summary.Scanner))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// describe names the scan in issue descriptions and comments
+func describe(summary *types.ImageScanSummary) string {
+	s := summary.Image
+	if summary.Digest != "" {
+		s += " (" + summary.Digest + ")"
+	}
+	return fmt.Sprintf("%s, scanned with %s (scan_id=%s)", s, summary.Scanner, summary.ID)
+}
+
+// Track files or updates the issues for a scan, setting the JiraIssue of
+// each high and critical vuln to the key of the issue tracking it
+func (t *Tracker) Track(ctx context.Context, summary *types.ImageScanSummary, vulns []*types.Vuln) error {
+	found := findings(vulns)
+	if t.GroupBy == GroupByCVE {
+		return t.trackCVEs(ctx, summary, found)
+	}
+	return t.trackImage(ctx, summary, found)
+}
+
+// open returns the open issues of the project matching a JQL clause
+func (t *Tracker) open(ctx context.Context, clause string) ([]*Issue, error) {
+	return t.Client.Search(ctx, fmt.Sprintf("project = %q AND %s AND statusCategory != Done", t.Project, clause))
+}
+
+func (t *Tracker) trackImage(ctx context.Context, summary *types.ImageScanSummary, found []*finding) error {
+	key := imageLabelPrefix + scanKey(summary)
+	issues, err := t.open(ctx, fmt.Sprintf("labels = %q", key))
+	if err != nil {
+		return err
+	}
+	cves := map[string]*finding{}
+	for _, f := range found {
+		cves[cveLabelPrefix+f.id] = f
+	}
+
+	if len(issues) == 0 {
+		if len(found) == 0 {
+			return nil
+		}
+		description := []string{fmt.Sprintf("High and critical CVEs found in %s:", describe(summary)), ""}
+		labels := append([]string{label, key}, t.Labels...)
+		for _, f := range found {
+			description = append(description, fmt.Sprintf("* %s (%s) in %s", f.id, f.severity, f.packages()))
+			labels = append(labels, cveLabelPrefix+f.id)
+		}
+		issueKey, err := t.Client.Create(ctx, &NewIssue{
+			Project:     t.Project,
+			IssueType:   t.IssueType,
+			Summary:     fmt.Sprintf("%s has %d high or critical CVE(s) (%s)", summary.Image, len(found), summary.Scanner),
+			Description: strings.Join(description, "\n"),
+			Labels:      labels,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Filed Jira issue %s for %d CVE(s) in %s\n", issueKey, len(found), summary.Image)
+		setIssue(found, issueKey)
+		return nil
+	}
+
+	issue := issues[0]
+	if len(found) == 0 {
+		return t.close(ctx, issue, fmt.Sprintf("No high or critical CVEs are left in %s.", describe(summary)))
+	}
+	added, fixed := []string{}, []string{}
+	tracked := map[string]bool{}
+	for _, l := range issue.Fields.Labels {
+		if strings.HasPrefix(l, cveLabelPrefix) {
+			tracked[l] = true
+			if cves[l] == nil {
+				fixed = append(fixed, l)
+			}
+		}
+	}
+	comment := []string{}
+	for _, f := range found {
+		if !tracked[cveLabelPrefix+f.id] {
+			added = append(added, cveLabelPrefix+f.id)
+			comment = append(comment, fmt.Sprintf("* %s (%s) in %s", f.id, f.severity, f.packages()))
+		}
+	}
+	if len(added) > 0 || len(fixed) > 0 {
+		sort.Strings(fixed)
+		header := fmt.Sprintf("%d new CVE(s) found in %s", len(added), describe(summary))
+		if len(fixed) > 0 {
+			ids := make([]string, len(fixed))
+			for i, l := range fixed {
+				ids[i] = strings.TrimPrefix(l, cveLabelPrefix)
+			}
+			header += fmt.Sprintf(", and %d no longer found: %s", len(fixed), strings.Join(ids, ", "))
+		}
+		if err := t.Client.UpdateLabels(ctx, issue.Key, added, fixed); err != nil {
+			return err
+		}
+		if err := t.Client.Comment(ctx, issue.Key, strings.Join(append([]string{header + ".", ""}, comment...), "\n")); err != nil {
+			return err
+		}
+		fmt.Printf("Updated Jira issue %s with %d new and %d fixed CVE(s)\n", issue.Key, len(added), len(fixed))
+	}
+	setIssue(found, issue.Key)
+	return nil
+}
+
+func (t *Tracker) trackCVEs(ctx context.Context, summary *types.ImageScanSummary, found []*finding) error {
+	in := inLabelPrefix + scanKey(summary)
+
+	// Find the open issues for the scan's CVEs, a few at a time to keep
+	// each query short
+	byLabel := map[string]*Issue{}
+	for start := 0; start < len(found); start += 50 {
+		quoted := []string{}
+		for _, f := range found[start:min(start+50, len(found))] {
+			quoted = append(quoted, fmt.Sprintf("%q", cveLabelPrefix+f.id))
+		}
+		issues, err := t.open(ctx, fmt.Sprintf("labels in (%s)", strings.Join(quoted, ", ")))
+		if err != nil {
+			return err
+		}
+		for _, issue := range issues {
+			for _, l := range issue.Fields.Labels {
+				if strings.HasPrefix(l, cveLabelPrefix) && byLabel[l] == nil {
+					byLabel[l] = issue
+				}
+			}
+		}
+	}
+
+	current := map[string]bool{}
+	for _, f := range found {
+		cve := cveLabelPrefix + f.id
+		current[cve] = true
+		issue := byLabel[cve]
+		if issue == nil {
+			key, err := t.Client.Create(ctx, &NewIssue{
+				Project:     t.Project,
+				IssueType:   t.IssueType,
+				Summary:     fmt.Sprintf("%s (%s) in %s", f.id, f.severity, f.vulns[0].Name),
+				Description: fmt.Sprintf("%s (%s) is found in:\n\n* %s, in %s", f.id, f.severity, describe(summary), f.packages()),
+				Labels:      append([]string{label, cve, in}, t.Labels...),
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Filed Jira issue %s for %s\n", key, f.id)
+			setIssue([]*finding{f}, key)
+			continue
+		}
+		if !hasLabel(issue, in) {
+			if err := t.Client.UpdateLabels(ctx, issue.Key, []string{in}, nil); err != nil {
+				return err
+			}
+			if err := t.Client.Comment(ctx, issue.Key, fmt.Sprintf("Also found in %s, in %s.", describe(summary), f.packages())); err != nil {
+				return err
+			}
+			fmt.Printf("Added %s to Jira issue %s\n", summary.Image, issue.Key)
+		}
+		setIssue([]*finding{f}, issue.Key)
+	}
+
+	// The CVEs no longer found in the image are closed once no other image
+	// has them
+	issues, err := t.open(ctx, fmt.Sprintf("labels = %q", in))
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		tracking := false
+		for _, l := range issue.Fields.Labels {
+			if current[l] {
+				tracking = true
+			}
+		}
+		if tracking {
+			continue
+		}
+		if err := t.Client.UpdateLabels(ctx, issue.Key, nil, []string{in}); err != nil {
+			return err
+		}
+		others := false
+		for _, l := range issue.Fields.Labels {
+			if strings.HasPrefix(l, inLabelPrefix) && l != in {
+				others = true
+			}
+		}
+		note := fmt.Sprintf("No longer found in %s.", describe(summary))
+		if others {
+			if err := t.Client.Comment(ctx, issue.Key, note); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := t.close(ctx, issue, note+" It isn't found in any other image."); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close comments on an issue and closes it
+func (t *Tracker) close(ctx context.Context, issue *Issue, comment string) error {
+	if err := t.Client.Comment(ctx, issue.Key, comment); err != nil {
+		return err
+	}
+	if err := t.Client.Transition(ctx, issue.Key, t.CloseTransition); err != nil {
+		return err
+	}
+	fmt.Printf("Closed Jira issue %s\n", issue.Key)
+	return nil
+}
+
+func hasLabel(issue *Issue, l string) bool {
+	for _, have := range issue.Fields.Labels {
+		if have == l {
+			return true
+		}
+	}
+	return false
+}
+
+func setIssue(found []*finding, key string) {
+	for _, f := range found {
+		for _, vuln := range f.vulns {
+			vuln.JiraIssue = key
+		}
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
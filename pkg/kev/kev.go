@@ -0,0 +1,144 @@
+package kev
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	DefaultURL = "https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json"
+
+	// A cached catalog older than this is refreshed
+	cacheTTL = 24 * time.Hour
+
+	cacheFile = "known_exploited_vulnerabilities.json"
+)
+
+// Entry is a single vuln in CISA's Known Exploited Vulnerabilities catalog
+type Entry struct {
+	CveID                      string `json:"cveID"`
+	VendorProject              string `json:"vendorProject"`
+	Product                    string `json:"product"`
+	VulnerabilityName          string `json:"vulnerabilityName"`
+	DateAdded                  string `json:"dateAdded"`
+	RequiredAction             string `json:"requiredAction"`
+	DueDate                    string `json:"dueDate"`
+	KnownRansomwareCampaignUse string `json:"knownRansomwareCampaignUse"`
+}
+
+// Catalog is the KEV catalog, as published by CISA
+type Catalog struct {
+	CatalogVersion  string  `json:"catalogVersion"`
+	DateReleased    string  `json:"dateReleased"`
+	Vulnerabilities []Entry `json:"vulnerabilities"`
+
+	byID map[string]*Entry
+}
+
+// Lookup returns the catalog's entry for a CVE, or nil if it isn't known to
+// be exploited
+func (c *Catalog) Lookup(cveID string) *Entry {
+	if c.byID == nil {
+		c.byID = make(map[string]*Entry, len(c.Vulnerabilities))
+		for i, entry := range c.Vulnerabilities {
+			c.byID[entry.CveID] = &c.Vulnerabilities[i]
+		}
+	}
+	return c.byID[cveID]
+}
+
+type Client struct {
+	URL      string
+	CacheDir string
+
+	httpClient *http.Client
+	catalog    *Catalog
+}
+
+func NewClient(cacheDir string) *Client {
+	return &Client{
+		URL:        DefaultURL,
+		CacheDir:   cacheDir,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// DefaultCacheDir returns the directory used to cache the KEV catalog when
+// none is provided explicitly
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "rumble", "kev")
+}
+
+// Catalog returns the KEV catalog, fetching it at most once a day
+func (c *Client) Catalog(ctx context.Context) (*Catalog, error) {
+	if c.catalog != nil {
+		return c.catalog, nil
+	}
+	b, err := c.readCache()
+	if err != nil {
+		b, err = c.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.writeCache(b); err != nil {
+			fmt.Printf("WARNING: could not cache the KEV catalog: %s\n", err.Error())
+		}
+	}
+	catalog := &Catalog{}
+	if err := json.Unmarshal(b, catalog); err != nil {
+		return nil, fmt.Errorf("parsing the KEV catalog: %w", err)
+	}
+	c.catalog = catalog
+	return catalog, nil
+}
+
+func (c *Client) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching the KEV catalog: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching the KEV catalog: unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (c *Client) readCache() ([]byte, error) {
+	if c.CacheDir == "" {
+		return nil, os.ErrNotExist
+	}
+	path := filepath.Join(c.CacheDir, cacheFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(info.ModTime()) > cacheTTL {
+		return nil, fmt.Errorf("cached KEV catalog is stale")
+	}
+	return os.ReadFile(path)
+}
+
+func (c *Client) writeCache(b []byte) error {
+	if c.CacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.CacheDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.CacheDir, cacheFile), b, 0644)
+}
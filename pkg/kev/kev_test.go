@@ -0,0 +1,44 @@
+package kev
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{
+			"catalogVersion": "2024.06.01",
+			"vulnerabilities": [
+				{"cveID": "CVE-2023-4863", "vendorProject": "Google", "product": "Chromium WebP", "dateAdded": "2023-09-13", "knownRansomwareCampaignUse": "Unknown"},
+				{"cveID": "CVE-2024-3094", "vendorProject": "XZ Utils", "product": "XZ Utils", "dateAdded": "2024-03-29"}
+			]
+		}`)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		// A new client reads the catalog back from the cache
+		c := NewClient(dir)
+		c.URL = srv.URL
+		catalog, err := c.Catalog(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error on Catalog(), got %v", err)
+		}
+		if entry := catalog.Lookup("CVE-2024-3094"); entry == nil || entry.Product != "XZ Utils" {
+			t.Errorf("expected CVE-2024-3094 to be in the catalog, got %+v", entry)
+		}
+		if entry := catalog.Lookup("CVE-2024-0001"); entry != nil {
+			t.Errorf("expected CVE-2024-0001 not to be in the catalog, got %+v", entry)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the catalog to be fetched once, got %d requests", requests)
+	}
+}
@@ -0,0 +1,205 @@
+package mirror
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/types"
+
+	// Pure Go SQLite driver, registered as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// Table names in the local mirror database
+const (
+	SummaryTable = "summaries"
+	VulnsTable   = "vulns"
+)
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS summaries (
+		id TEXT PRIMARY KEY,
+		image TEXT NOT NULL,
+		digest TEXT NOT NULL,
+		scanner TEXT NOT NULL,
+		scanner_version TEXT NOT NULL,
+		scanner_db_version TEXT NOT NULL,
+		time TEXT NOT NULL,
+		created TEXT NOT NULL,
+		low_cve_count INTEGER NOT NULL,
+		med_cve_count INTEGER NOT NULL,
+		high_cve_count INTEGER NOT NULL,
+		crit_cve_count INTEGER NOT NULL,
+		negligible_cve_count INTEGER NOT NULL,
+		unknown_cve_count INTEGER NOT NULL,
+		tot_cve_count INTEGER NOT NULL,
+		success INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS summaries_image_time ON summaries (image, time)`,
+	`CREATE TABLE IF NOT EXISTS vulns (
+		id TEXT NOT NULL,
+		scan_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		installed TEXT NOT NULL,
+		fixed_in TEXT NOT NULL,
+		type TEXT NOT NULL,
+		vulnerability TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		time TEXT NOT NULL,
+		PRIMARY KEY (scan_id, id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS vulns_vulnerability ON vulns (vulnerability)`,
+}
+
+// Open opens (creating if needed) a local mirror database at path
+func Open(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("creating mirror schema in %s: %w", path, err)
+		}
+	}
+	return db, nil
+}
+
+// PutSummaries inserts summaries into the mirror, replacing existing rows with the same ID
+func PutSummaries(ctx context.Context, db *sql.DB, summaries []*types.ImageScanSummary) error {
+	rows := make([][]interface{}, len(summaries))
+	for i, s := range summaries {
+		rows[i] = values(query.SummaryFields(s))
+	}
+	return put(ctx, db, SummaryTable, query.SummaryColumns, rows)
+}
+
+// PutVulns inserts vulns into the mirror, replacing existing rows with the same scan and ID
+func PutVulns(ctx context.Context, db *sql.DB, vulns []*types.Vuln) error {
+	rows := make([][]interface{}, len(vulns))
+	for i, v := range vulns {
+		rows[i] = values(query.VulnFields(v))
+	}
+	return put(ctx, db, VulnsTable, query.VulnColumns, rows)
+}
+
+// values dereferences the pointers returned by query.SummaryFields and query.VulnFields
+func values(ptrs []interface{}) []interface{} {
+	vals := make([]interface{}, len(ptrs))
+	for i, ptr := range ptrs {
+		switch p := ptr.(type) {
+		case *string:
+			vals[i] = *p
+		case *int:
+			vals[i] = *p
+		case *bool:
+			vals[i] = *p
+		}
+	}
+	return vals
+}
+
+func put(ctx context.Context, db *sql.DB, table string, columns []string, rows [][]interface{}) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	placeholders := "?" + strings.Repeat(", ?", len(columns)-1)
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), placeholders))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Summaries runs a summary query against the mirror. The same filters as
+// query.Summaries are supported, other than IncludeMatches since the raw
+// scanner output is not mirrored.
+func Summaries(ctx context.Context, db *sql.DB, filter query.Filter) ([]*types.ImageScanSummary, error) {
+	if filter.IncludeMatches {
+		return nil, fmt.Errorf("matches are not available from the local mirror")
+	}
+	stmt, params, err := query.SummarySQL(SummaryTable, filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, stmt, namedArgs(params)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	summaries := []*types.ImageScanSummary{}
+	for rows.Next() {
+		var summary types.ImageScanSummary
+		if err := rows.Scan(query.SummaryFields(&summary)...); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, &summary)
+	}
+	return summaries, rows.Err()
+}
+
+// ScanVulns returns the mirrored vulns of a single scan
+func ScanVulns(ctx context.Context, db *sql.DB, scanID string) ([]*types.Vuln, error) {
+	stmt, params := query.ScanVulnsSQL(VulnsTable, scanID)
+	rows, err := db.QueryContext(ctx, stmt, namedArgs(params)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	vulns := []*types.Vuln{}
+	for rows.Next() {
+		var vuln types.Vuln
+		if err := rows.Scan(query.VulnFields(&vuln)...); err != nil {
+			return nil, err
+		}
+		vulns = append(vulns, &vuln)
+	}
+	return vulns, rows.Err()
+}
+
+func namedArgs(params []bigquery.QueryParameter) []interface{} {
+	args := make([]interface{}, len(params))
+	for i, param := range params {
+		args[i] = sql.Named(param.Name, param.Value)
+	}
+	return args
+}
+
+// Search runs a search of the mirrored vulns table. Packages tables are not
+// mirrored, so only vulnerable packages can be found.
+func Search(ctx context.Context, db *sql.DB, search query.Search) ([]*query.SearchResult, error) {
+	if search.Packages {
+		return nil, fmt.Errorf("packages are not available from the local mirror")
+	}
+	stmt, params, err := query.SearchSQL(SummaryTable, VulnsTable, search)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, stmt, namedArgs(params)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []*query.SearchResult{}
+	for rows.Next() {
+		var r query.SearchResult
+		if err := rows.Scan(&r.Image, &r.Scanner, &r.Digest, &r.Time, &r.ScanID, &r.Package, &r.Version, &r.Type, &r.Vulnerability, &r.FixedIn); err != nil {
+			return nil, err
+		}
+		results = append(results, &r)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,123 @@
+package mirror
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestSummaries(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(filepath.Join(t.TempDir(), "rumble.db"))
+	if err != nil {
+		t.Fatalf("expected no error on Open(), got %v", err)
+	}
+	defer db.Close()
+
+	summaries := []*types.ImageScanSummary{
+		{ID: "1", Image: "a", Scanner: "grype", Time: "2023-06-20T00:00:00Z", CritCveCount: 3, TotCveCount: 3, Success: true},
+		{ID: "2", Image: "a", Scanner: "grype", Time: "2023-06-21T00:00:00Z", CritCveCount: 1, TotCveCount: 1, Success: true},
+		{ID: "3", Image: "b", Scanner: "grype", Time: "2023-06-21T00:00:00Z", CritCveCount: 2, TotCveCount: 2, Success: true},
+	}
+	if err := PutSummaries(ctx, db, summaries); err != nil {
+		t.Fatalf("expected no error on PutSummaries(), got %v", err)
+	}
+	// Syncing the same rows again must not duplicate them
+	if err := PutSummaries(ctx, db, summaries); err != nil {
+		t.Fatalf("expected no error on PutSummaries(), got %v", err)
+	}
+
+	all, err := Summaries(ctx, db, query.Filter{})
+	if err != nil {
+		t.Fatalf("expected no error on Summaries(), got %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("got %d summaries, wanted 3", len(all))
+	}
+
+	latest, err := Summaries(ctx, db, query.Filter{LatestOnly: true, OrderBy: "critical"})
+	if err != nil {
+		t.Fatalf("expected no error on Summaries(), got %v", err)
+	}
+	if len(latest) != 2 {
+		t.Fatalf("got %d latest summaries, wanted 2", len(latest))
+	}
+	if latest[0].ID != "3" || latest[1].ID != "2" {
+		t.Errorf("got latest summaries %s, %s, wanted 3, 2", latest[0].ID, latest[1].ID)
+	}
+	if !latest[0].Success {
+		t.Errorf("expected success to round-trip through the mirror")
+	}
+
+	// Filters match the latest scans, not the latest of the matching scans
+	excluded, err := Summaries(ctx, db, query.Filter{LatestOnly: true, Image: "a", ID: "1"})
+	if err != nil {
+		t.Fatalf("expected no error on Summaries(), got %v", err)
+	}
+	if len(excluded) != 0 {
+		t.Errorf("got %d latest summaries with ID 1, which isn't the latest scan of a, wanted 0", len(excluded))
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(filepath.Join(t.TempDir(), "rumble.db"))
+	if err != nil {
+		t.Fatalf("expected no error on Open(), got %v", err)
+	}
+	defer db.Close()
+
+	summaries := []*types.ImageScanSummary{
+		{ID: "1", Image: "a", Scanner: "grype", Digest: "sha256:old", Time: "2023-06-20T00:00:00Z"},
+		{ID: "2", Image: "a", Scanner: "grype", Digest: "sha256:new", Time: "2023-06-21T00:00:00Z"},
+		{ID: "3", Image: "b", Scanner: "grype", Digest: "sha256:b", Time: "2023-06-21T00:00:00Z"},
+	}
+	if err := PutSummaries(ctx, db, summaries); err != nil {
+		t.Fatalf("expected no error on PutSummaries(), got %v", err)
+	}
+	vulns := []*types.Vuln{
+		{ID: "v1", ScanID: "1", Name: "openssl", Installed: "3.0.1", Vulnerability: "CVE-2024-1234"},
+		{ID: "v2", ScanID: "2", Name: "busybox", Installed: "1.36.0", Vulnerability: "CVE-2024-0001"},
+		{ID: "v3", ScanID: "3", Name: "openssl", Installed: "3.0.2", Vulnerability: "CVE-2024-1234"},
+	}
+	if err := PutVulns(ctx, db, vulns); err != nil {
+		t.Fatalf("expected no error on PutVulns(), got %v", err)
+	}
+
+	// Image a was fixed by its latest scan
+	results, err := Search(ctx, db, query.Search{CVE: "CVE-2024-1234"})
+	if err != nil {
+		t.Fatalf("expected no error on Search(), got %v", err)
+	}
+	if len(results) != 1 || results[0].Image != "b" || results[0].Digest != "sha256:b" || results[0].Version != "3.0.2" {
+		t.Errorf("got search results %+v, wanted only image b", results)
+	}
+}
+
+func TestScanVulns(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(filepath.Join(t.TempDir(), "rumble.db"))
+	if err != nil {
+		t.Fatalf("expected no error on Open(), got %v", err)
+	}
+	defer db.Close()
+
+	vulns := []*types.Vuln{
+		{ID: "v1", ScanID: "1", Name: "openssl", Installed: "3.0.1", Vulnerability: "CVE-2024-1234", Severity: "High"},
+		{ID: "v2", ScanID: "2", Name: "busybox", Installed: "1.36.0", Vulnerability: "CVE-2024-0001"},
+		{ID: "v3", ScanID: "1", Name: "busybox", Installed: "1.36.0", Vulnerability: "CVE-2024-0001"},
+	}
+	if err := PutVulns(ctx, db, vulns); err != nil {
+		t.Fatalf("expected no error on PutVulns(), got %v", err)
+	}
+	got, err := ScanVulns(ctx, db, "1")
+	if err != nil {
+		t.Fatalf("expected no error on ScanVulns(), got %v", err)
+	}
+	if len(got) != 2 || got[0].ID != "v3" || got[1].ID != "v1" || got[1].Severity != "High" {
+		t.Errorf("got vulns %+v, wanted v3 and v1", got)
+	}
+}
@@ -0,0 +1,271 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// How to secure the connection to an SMTP server
+const (
+	// TLSStartTLS upgrades the connection with STARTTLS, failing if the
+	// server doesn't support it
+	TLSStartTLS = "starttls"
+
+	// TLSImplicit connects with TLS from the start, usually on port 465
+	TLSImplicit = "tls"
+
+	// TLSNone sends mail in the clear, e.g. to a local relay
+	TLSNone = "none"
+)
+
+// DefaultEmailSubject and DefaultEmailTemplate are the subject and plain
+// text body of emails sent without templates of your own
+const (
+	DefaultEmailSubject  = `[rumble] {{ .Summary.Image }}: {{ .Summary.CritCveCount }} critical, {{ .Summary.HighCveCount }} high`
+	DefaultEmailTemplate = `{{ .Summary.Image }}{{ with .Summary.Digest }} ({{ . }}){{ end }} was scanned with {{ .Summary.Scanner }} at {{ .Summary.Time }} (scan_id={{ .Summary.ID }}):
+
+  Critical: {{ .Summary.CritCveCount }}
+  High:     {{ .Summary.HighCveCount }}
+  Medium:   {{ .Summary.MedCveCount }}
+  Low:      {{ .Summary.LowCveCount }}
+  Total:    {{ .Summary.TotCveCount }}
+{{ with .Diff }}
+{{ len .Added }} vuln(s) added and {{ len .Removed }} removed since {{ .Previous.Digest }}.
+{{ range .Added }}  + {{ .Vulnerability }} in {{ .Package }} {{ .Installed }}
+{{ end }}{{ end }}
+{{- with .TopFindings }}
+Most severe:
+{{ range . }}  {{ .Vulnerability }} ({{ severity . }}) in {{ .Name }} {{ .Installed }}{{ with .FixedIn }}, fixed in {{ . }}{{ end }}
+{{ end }}{{ end }}`
+)
+
+var (
+	defaultEmailSubject  = template.Must(ParseTemplate("email-subject", DefaultEmailSubject))
+	defaultEmailTemplate = template.Must(ParseTemplate("email", DefaultEmailTemplate))
+)
+
+// SMTP is the server emails are sent through. Username and Password, if
+// set, are used for PLAIN authentication, which net/smtp only allows over
+// TLS or to localhost.
+type SMTP struct {
+	// Addr is the server's host:port
+	Addr     string
+	TLS      string
+	Username string
+	Password string
+}
+
+// Email emails scans over Threshold to a list of recipients. The subject
+// and body templates default to DefaultEmailSubject and
+// DefaultEmailTemplate.
+type Email struct {
+	Server    *SMTP
+	From      string
+	To        []string
+	Subject   *template.Template
+	Template  *template.Template
+	Threshold Threshold
+}
+
+func (e *Email) Notify(ctx context.Context, msg *Message) error {
+	return e.NotifyDigest(ctx, []*Message{msg})
+}
+
+// NotifyDigest emails a single digest of the scans over Threshold, with the
+// body rendered for each in turn. Nothing is sent if none are over it.
+func (e *Email) NotifyDigest(ctx context.Context, msgs []*Message) error {
+	over := []*Message{}
+	for _, msg := range msgs {
+		if e.Threshold.Exceeded(msg.Summary) {
+			over = append(over, msg)
+		}
+	}
+	if len(over) == 0 {
+		return nil
+	}
+	subjectTmpl, bodyTmpl := e.Subject, e.Template
+	if subjectTmpl == nil {
+		subjectTmpl = defaultEmailSubject
+	}
+	if bodyTmpl == nil {
+		bodyTmpl = defaultEmailTemplate
+	}
+	subject := fmt.Sprintf("[rumble] %d scan(s) over the threshold", len(over))
+	if len(over) == 1 {
+		var err error
+		if subject, err = over[0].Render(subjectTmpl); err != nil {
+			return err
+		}
+	}
+	bodies := make([]string, len(over))
+	for i, msg := range over {
+		body, err := msg.Render(bodyTmpl)
+		if err != nil {
+			return err
+		}
+		bodies[i] = strings.TrimRight(body, "\n") + "\n"
+	}
+	return e.Server.send(ctx, e.From, e.To, formatEmail(e.From, e.To, subject, strings.Join(bodies, "\n"), time.Now()))
+}
+
+// formatEmail returns a plain text email. Line endings are left to the
+// SMTP client, which sends them as CRLF.
+func formatEmail(from string, to []string, subject string, body string, date time.Time) []byte {
+	// Newlines in the subject would start new headers
+	subject = strings.Join(strings.Fields(subject), " ")
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\n", from)
+	fmt.Fprintf(&b, "To: %s\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\n\n")
+	b.WriteString(body)
+	return []byte(b.String())
+}
+
+// send delivers an email, giving up when ctx is done
+func (s *SMTP) send(ctx context.Context, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP server %q: %w", s.Addr, err)
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	switch s.TLS {
+	case TLSImplicit:
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", s.Addr)
+	case TLSStartTLS, TLSNone:
+		conn, err = dialer.DialContext(ctx, "tcp", s.Addr)
+	default:
+		return fmt.Errorf("invalid SMTP TLS mode: %s", s.TLS)
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", s.Addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Abandon the conversation if ctx is done first
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if s.TLS == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s doesn't support STARTTLS", s.Addr)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS with %s: %w", s.Addr, err)
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return fmt.Errorf("authenticating to %s: %w", s.Addr, err)
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("recipient %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// Threshold is the counts at which a scan is notified about: a scan is over
+// it if any of the non-zero counts is reached. Every scan is over the zero
+// Threshold.
+type Threshold struct {
+	Critical int
+	High     int
+	Medium   int
+	Low      int
+	Total    int
+}
+
+// ParseThreshold parses comma-separated severity=count pairs, e.g.
+// "critical=1,high=5", with "total" for the total count
+func ParseThreshold(value string) (Threshold, error) {
+	t := Threshold{}
+	if value == "" {
+		return t, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		severity, count, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		n, err := strconv.Atoi(count)
+		if !ok || err != nil || n < 1 {
+			return t, fmt.Errorf("invalid threshold %q, expected severity=count with a count of at least 1", pair)
+		}
+		switch strings.ToLower(severity) {
+		case "critical":
+			t.Critical = n
+		case "high":
+			t.High = n
+		case "medium":
+			t.Medium = n
+		case "low":
+			t.Low = n
+		case "total":
+			t.Total = n
+		default:
+			return t, fmt.Errorf("invalid threshold severity: %s", severity)
+		}
+	}
+	return t, nil
+}
+
+// Exceeded reports whether a scan is over the threshold
+func (t Threshold) Exceeded(summary *types.ImageScanSummary) bool {
+	if t == (Threshold{}) {
+		return true
+	}
+	for _, check := range []struct{ threshold, count int }{
+		{t.Critical, summary.CritCveCount},
+		{t.High, summary.HighCveCount},
+		{t.Medium, summary.MedCveCount},
+		{t.Low, summary.LowCveCount},
+		{t.Total, summary.TotCveCount},
+	} {
+		if check.threshold > 0 && check.count >= check.threshold {
+			return true
+		}
+	}
+	return false
+}
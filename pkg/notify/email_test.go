@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// fakeSMTP accepts mail in the clear, sending each message received (with
+// its envelope recipients) on mails
+func fakeSMTP(t *testing.T) (string, chan string) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error on net.Listen(), got %v", err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	mails := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				fmt.Fprint(conn, "220 fake ESMTP\r\n")
+				var mail strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					cmd := strings.ToUpper(strings.TrimSpace(line))
+					switch {
+					case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+						fmt.Fprint(conn, "250 fake\r\n")
+					case strings.HasPrefix(cmd, "RCPT"):
+						mail.WriteString(strings.TrimSpace(line) + "\n")
+						fmt.Fprint(conn, "250 ok\r\n")
+					case cmd == "DATA":
+						fmt.Fprint(conn, "354 go ahead\r\n")
+						for {
+							line, err := r.ReadString('\n')
+							if err != nil {
+								return
+							}
+							if line == ".\r\n" {
+								break
+							}
+							mail.WriteString(line)
+						}
+						mails <- mail.String()
+						mail.Reset()
+						fmt.Fprint(conn, "250 queued\r\n")
+					case cmd == "QUIT":
+						fmt.Fprint(conn, "221 bye\r\n")
+						return
+					default:
+						fmt.Fprint(conn, "250 ok\r\n")
+					}
+				}
+			}(conn)
+		}
+	}()
+	return lis.Addr().String(), mails
+}
+
+func TestEmail(t *testing.T) {
+	addr, mails := fakeSMTP(t)
+	threshold, err := ParseThreshold("critical=1,high=5")
+	if err != nil {
+		t.Fatalf("expected no error on ParseThreshold(), got %v", err)
+	}
+	e := &Email{
+		Server:    &SMTP{Addr: addr, TLS: TLSNone},
+		From:      "rumble@example.com",
+		To:        []string{"security@example.com", "team@example.com"},
+		Threshold: threshold,
+	}
+	if err := e.Notify(context.Background(), testMessage()); err != nil {
+		t.Fatalf("expected no error on Notify(), got %v", err)
+	}
+	mail := <-mails
+	for _, expected := range []string{
+		"RCPT TO:<security@example.com>",
+		"RCPT TO:<team@example.com>",
+		"To: security@example.com, team@example.com\r\n",
+		"Subject: [rumble] cgr.dev/chainguard/static:latest: 1 critical, 1 high\r\n",
+		"  Critical: 1\r\n",
+		"1 vuln(s) added and 0 removed since sha256:def.\r\n",
+		"  CVE-2024-0002 (HIGH) in openssl 3.1.0, fixed in 3.1.1\r\n",
+	} {
+		if !strings.Contains(mail, expected) {
+			t.Errorf("expected the email to contain %q, got:\n%s", expected, mail)
+		}
+	}
+
+	// Scans under the threshold aren't emailed, and a digest only covers
+	// those over it
+	under := NewMessage(&types.ImageScanSummary{Image: "cgr.dev/chainguard/go:latest", HighCveCount: 4}, nil, nil)
+	if err := e.Notify(context.Background(), under); err != nil {
+		t.Fatalf("expected no error on Notify(), got %v", err)
+	}
+	if err := e.NotifyDigest(context.Background(), []*Message{testMessage(), under, testMessage()}); err != nil {
+		t.Fatalf("expected no error on NotifyDigest(), got %v", err)
+	}
+	mail = <-mails
+	if !strings.Contains(mail, "Subject: [rumble] 2 scan(s) over the threshold\r\n") || strings.Contains(mail, "chainguard/go") {
+		t.Errorf("expected a digest of the 2 scans over the threshold, got:\n%s", mail)
+	}
+	select {
+	case mail := <-mails:
+		t.Errorf("expected no email for the scan under the threshold, got:\n%s", mail)
+	default:
+	}
+}
+
+func TestParseThreshold(t *testing.T) {
+	if threshold, err := ParseThreshold("CRITICAL=1, total=50"); err != nil || threshold != (Threshold{Critical: 1, Total: 50}) {
+		t.Errorf("expected critical=1 and total=50, got %+v (%v)", threshold, err)
+	}
+	for _, value := range []string{"critical", "critical=0", "severe=1"} {
+		if _, err := ParseThreshold(value); err == nil {
+			t.Errorf("expected an error parsing %q", value)
+		}
+	}
+	if !(Threshold{}).Exceeded(&types.ImageScanSummary{}) {
+		t.Errorf("expected every scan to be over the zero threshold")
+	}
+}
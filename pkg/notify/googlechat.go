@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// GoogleChat posts messages to a Google Chat space's incoming webhook as a
+// card with the counts, top findings and a link to the scan. The template,
+// if set, renders the whole request body instead.
+type GoogleChat struct {
+	WebhookURL string
+	Template   *template.Template
+	HTTPClient *http.Client
+}
+
+func (g *GoogleChat) Notify(ctx context.Context, msg *Message) error {
+	body, err := renderBody(msg, g.Template, googleChatCard)
+	if err != nil {
+		return err
+	}
+	return post(ctx, g.HTTPClient, g.WebhookURL, "application/json; charset=UTF-8", body)
+}
+
+// googleChatCard returns the default Google Chat message for a scan, with
+// plain text for notifications and clients that don't show cards
+func googleChatCard(msg *Message) interface{} {
+	counts := []string{}
+	widgets := []interface{}{}
+	for _, count := range msg.Counts() {
+		counts = append(counts, fmt.Sprintf("%d %s", count.Count, strings.ToLower(count.Severity)))
+		widgets = append(widgets, map[string]interface{}{
+			"decoratedText": map[string]string{"topLabel": count.Severity, "text": strconv.Itoa(count.Count)},
+		})
+	}
+	sections := []interface{}{map[string]interface{}{"widgets": widgets}}
+	if text := changesText(msg); text != "" {
+		sections = append(sections, map[string]interface{}{
+			"widgets": []interface{}{map[string]interface{}{"textParagraph": map[string]string{"text": text}}},
+		})
+	}
+	if lines := findingLines(msg); len(lines) > 0 {
+		sections = append(sections, map[string]interface{}{
+			"header":  "Most severe",
+			"widgets": []interface{}{map[string]interface{}{"textParagraph": map[string]string{"text": strings.Join(lines, "\n")}}},
+		})
+	}
+	if msg.Link != "" {
+		sections = append(sections, map[string]interface{}{
+			"widgets": []interface{}{map[string]interface{}{"buttonList": map[string]interface{}{
+				"buttons": []interface{}{map[string]interface{}{
+					"text":    "View scan",
+					"onClick": map[string]interface{}{"openLink": map[string]string{"url": msg.Link}},
+				}},
+			}}},
+		})
+	}
+	subtitle := fmt.Sprintf("Scanned with %s (scan_id=%s)", msg.Summary.Scanner, msg.Summary.ID)
+	if msg.Summary.Digest != "" {
+		subtitle = msg.Summary.Digest + ", " + strings.ToLower(subtitle[:1]) + subtitle[1:]
+	}
+	return map[string]interface{}{
+		"text": fmt.Sprintf("%s: %s", msg.Summary.Image, strings.Join(counts, ", ")),
+		"cardsV2": []interface{}{map[string]interface{}{
+			"cardId": "rumble-" + msg.Summary.ID,
+			"card": map[string]interface{}{
+				"header":   map[string]string{"title": msg.Summary.Image, "subtitle": subtitle},
+				"sections": sections,
+			},
+		}},
+	}
+}
@@ -0,0 +1,225 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/kev"
+	"github.com/chainguard-dev/rumble/pkg/report"
+)
+
+const (
+	DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	DefaultOpsgenieURL  = "https://api.opsgenie.com/v2/alerts"
+)
+
+// Incident is a finding worth paging someone about: a CVE in CISA's Known
+// Exploited Vulnerabilities catalog, or a new critical with a fix available
+type Incident struct {
+	Image         string
+	Vulnerability string
+	Severity      string
+	Packages      []string
+	FixedIn       string
+
+	// KEV is the CVE's entry in the KEV catalog, if it is in it
+	KEV *kev.Entry
+}
+
+// DedupKey identifies the incident for the image and CVE, so rescans (even
+// of a new digest) update the open incident rather than open another one
+func (i *Incident) DedupKey() string {
+	return "rumble/" + i.Image + "/" + i.Vulnerability
+}
+
+// Summary is the incident's one line title
+func (i *Incident) Summary() string {
+	if i.KEV != nil {
+		return fmt.Sprintf("%s in %s is known to be exploited (%s)", i.Vulnerability, i.Image, strings.Join(i.Packages, ", "))
+	}
+	return fmt.Sprintf("%s in %s is critical with a fix available (%s)", i.Vulnerability, i.Image, strings.Join(i.Packages, ", "))
+}
+
+// details are the incident's fields for the body of an alert
+func (i *Incident) details(msg *Message) map[string]string {
+	details := map[string]string{
+		"image":         i.Image,
+		"digest":        msg.Summary.Digest,
+		"scanner":       msg.Summary.Scanner,
+		"scan_id":       msg.Summary.ID,
+		"vulnerability": i.Vulnerability,
+		"severity":      i.Severity,
+		"packages":      strings.Join(i.Packages, ", "),
+	}
+	if i.FixedIn != "" {
+		details["fixed_in"] = i.FixedIn
+	}
+	if i.KEV != nil {
+		details["kev_date_added"] = i.KEV.DateAdded
+		details["kev_due_date"] = i.KEV.DueDate
+		details["kev_ransomware_use"] = i.KEV.KnownRansomwareCampaignUse
+	}
+	return details
+}
+
+// Incidents returns a scan's incidents, one per CVE however many packages
+// it is found in. Criticals only count if they were added since the
+// previous scan, when that is known, so that a known critical doesn't page
+// again once its incident is resolved. catalog may be nil, e.g. if it
+// couldn't be fetched.
+func Incidents(msg *Message, catalog *kev.Catalog) []*Incident {
+	added := map[string]bool{}
+	if msg.Diff != nil {
+		for _, entry := range msg.Diff.Added {
+			added[entry.Vulnerability] = true
+		}
+	}
+	byID := map[string]*Incident{}
+	for _, vuln := range msg.Vulns {
+		var entry *kev.Entry
+		if catalog != nil {
+			entry = catalog.Lookup(vuln.Vulnerability)
+		}
+		severity := report.Severity(vuln)
+		critical := strings.EqualFold(severity, "critical") && vuln.FixedIn != "" && (msg.Diff == nil || added[vuln.Vulnerability])
+		if entry == nil && !critical {
+			continue
+		}
+		incident, ok := byID[vuln.Vulnerability]
+		if !ok {
+			incident = &Incident{Image: msg.Summary.Image, Vulnerability: vuln.Vulnerability, Severity: severity, KEV: entry}
+			byID[vuln.Vulnerability] = incident
+		}
+		incident.Packages = append(incident.Packages, vuln.Name+" "+vuln.Installed)
+		if incident.FixedIn == "" {
+			incident.FixedIn = vuln.FixedIn
+		}
+	}
+	incidents := make([]*Incident, 0, len(byID))
+	for _, incident := range byID {
+		incidents = append(incidents, incident)
+	}
+	sort.Slice(incidents, func(i, j int) bool { return incidents[i].Vulnerability < incidents[j].Vulnerability })
+	return incidents
+}
+
+// incidents returns the scan's incidents, only warning if the KEV catalog
+// can't be fetched
+func incidents(ctx context.Context, msg *Message, client *kev.Client) []*Incident {
+	var catalog *kev.Catalog
+	if client != nil {
+		var err error
+		if catalog, err = client.Catalog(ctx); err != nil {
+			fmt.Printf("WARNING: could not fetch the KEV catalog, only paging for criticals: %s\n", err.Error())
+		}
+	}
+	return Incidents(msg, catalog)
+}
+
+// PagerDuty triggers a PagerDuty incident through the Events API v2 for each
+// of a scan's incidents
+type PagerDuty struct {
+	RoutingKey string
+	URL        string
+	KEV        *kev.Client
+	HTTPClient *http.Client
+}
+
+// pagerDutyEvent is a PagerDuty Events API v2 event
+type pagerDutyEvent struct {
+	RoutingKey  string `json:"routing_key"`
+	EventAction string `json:"event_action"`
+	DedupKey    string `json:"dedup_key"`
+	Payload     struct {
+		Summary       string            `json:"summary"`
+		Source        string            `json:"source"`
+		Severity      string            `json:"severity"`
+		Component     string            `json:"component,omitempty"`
+		Class         string            `json:"class,omitempty"`
+		CustomDetails map[string]string `json:"custom_details"`
+	} `json:"payload"`
+}
+
+func (p *PagerDuty) Notify(ctx context.Context, msg *Message) error {
+	target := p.URL
+	if target == "" {
+		target = DefaultPagerDutyURL
+	}
+	for _, incident := range incidents(ctx, msg, p.KEV) {
+		event := pagerDutyEvent{RoutingKey: p.RoutingKey, EventAction: "trigger", DedupKey: incident.DedupKey()}
+		event.Payload.Summary = incident.Summary()
+		event.Payload.Source = incident.Image
+		event.Payload.Severity = "critical"
+		event.Payload.Component = strings.Join(incident.Packages, ", ")
+		event.Payload.Class = incident.Vulnerability
+		event.Payload.CustomDetails = incident.details(msg)
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err := post(ctx, p.HTTPClient, target, "application/json", body); err != nil {
+			return fmt.Errorf("triggering PagerDuty incident for %s: %w", incident.Vulnerability, err)
+		}
+	}
+	return nil
+}
+
+// Opsgenie creates an Opsgenie alert for each of a scan's incidents.
+// Opsgenie deduplicates open alerts with the same alias.
+type Opsgenie struct {
+	APIKey     string
+	URL        string
+	KEV        *kev.Client
+	HTTPClient *http.Client
+}
+
+// opsgenieAlert is the body of an Opsgenie create alert request
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description"`
+	Entity      string            `json:"entity"`
+	Source      string            `json:"source"`
+	Priority    string            `json:"priority"`
+	Tags        []string          `json:"tags"`
+	Details     map[string]string `json:"details"`
+}
+
+func (o *Opsgenie) Notify(ctx context.Context, msg *Message) error {
+	target := o.URL
+	if target == "" {
+		target = DefaultOpsgenieURL
+	}
+	header := http.Header{"Content-Type": {"application/json"}, "Authorization": {"GenieKey " + o.APIKey}}
+	for _, incident := range incidents(ctx, msg, o.KEV) {
+		alert := opsgenieAlert{
+			Message:     incident.Summary(),
+			Alias:       incident.DedupKey(),
+			Description: incident.Summary(),
+			Entity:      incident.Image,
+			Source:      "rumble",
+			Priority:    "P1",
+			Tags:        []string{"rumble", incident.Vulnerability},
+			Details:     incident.details(msg),
+		}
+		if incident.KEV != nil {
+			alert.Tags = append(alert.Tags, "kev")
+		}
+		// Opsgenie truncates messages after 130 characters
+		if len(alert.Message) > 130 {
+			alert.Message = alert.Message[:127] + "..."
+		}
+		body, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+		if err := postWithHeader(ctx, o.HTTPClient, target, header, body); err != nil {
+			return fmt.Errorf("creating Opsgenie alert for %s: %w", incident.Vulnerability, err)
+		}
+	}
+	return nil
+}
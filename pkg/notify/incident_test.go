@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/diff"
+	"github.com/chainguard-dev/rumble/pkg/kev"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func incidentMessage() *Message {
+	summary := &types.ImageScanSummary{ID: "testing123", Image: "cgr.dev/chainguard/static:latest", Scanner: "grype"}
+	vulns := []*types.Vuln{
+		{Vulnerability: "CVE-2024-3094", Name: "xz", Installed: "5.6.0", FixedIn: "5.6.2", Severity: "Critical"},
+		{Vulnerability: "CVE-2024-3094", Name: "xz-libs", Installed: "5.6.0", FixedIn: "5.6.2", Severity: "Critical"},
+		{Vulnerability: "CVE-2023-4863", Name: "libwebp", Installed: "1.3.1", Severity: "High"},
+		{Vulnerability: "CVE-2024-0001", Name: "glibc", Installed: "2.38", Severity: "Critical"},
+		{Vulnerability: "CVE-2024-0002", Name: "openssl", Installed: "3.1.0", FixedIn: "3.1.1", Severity: "Critical"},
+		{Vulnerability: "CVE-2024-0003", Name: "zlib", Installed: "1.3", FixedIn: "1.3.1", Severity: "Medium", NvdSeverity: "CRITICAL"},
+	}
+	d := &diff.Predicate{Added: []diff.Entry{diff.NewEntry(vulns[0]), diff.NewEntry(vulns[5])}}
+	return NewMessage(summary, vulns, d)
+}
+
+func TestIncidents(t *testing.T) {
+	catalog := &kev.Catalog{Vulnerabilities: []kev.Entry{{CveID: "CVE-2023-4863"}}}
+	ids := func(incidents []*Incident) string {
+		names := []string{}
+		for _, incident := range incidents {
+			names = append(names, incident.Vulnerability)
+		}
+		return strings.Join(names, ",")
+	}
+
+	// KEV entries of any severity, and new criticals with a fix (going by
+	// NVD's severity if known) on the scanner's
+	msg := incidentMessage()
+	incidents := Incidents(msg, catalog)
+	if expected := "CVE-2023-4863,CVE-2024-0003,CVE-2024-3094"; ids(incidents) != expected {
+		t.Errorf("expected incidents for %s, got %s", expected, ids(incidents))
+	}
+	if xz := incidents[2]; len(xz.Packages) != 2 || xz.DedupKey() != "rumble/cgr.dev/chainguard/static:latest/CVE-2024-3094" {
+		t.Errorf("expected a single incident for both xz packages, got %+v", xz)
+	}
+
+	// Without a diff, every critical with a fix counts
+	msg.Diff = nil
+	if expected := "CVE-2024-0002,CVE-2024-0003,CVE-2024-3094"; ids(Incidents(msg, nil)) != expected {
+		t.Errorf("expected incidents for %s, got %s", expected, ids(Incidents(msg, nil)))
+	}
+}
+
+func TestPagerDuty(t *testing.T) {
+	fake := &fakeWebhook{status: http.StatusAccepted}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	p := &PagerDuty{RoutingKey: "routing123", URL: srv.URL}
+	if err := p.Notify(context.Background(), incidentMessage()); err != nil {
+		t.Fatalf("expected no error on Notify(), got %v", err)
+	}
+	if len(fake.bodies) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(fake.bodies))
+	}
+	var event pagerDutyEvent
+	if err := json.Unmarshal([]byte(fake.bodies[1]), &event); err != nil {
+		t.Fatalf("expected a JSON event, got %v", err)
+	}
+	if event.RoutingKey != "routing123" || event.EventAction != "trigger" || event.DedupKey != "rumble/cgr.dev/chainguard/static:latest/CVE-2024-3094" {
+		t.Errorf("expected a trigger event deduplicated by image and CVE, got %+v", event)
+	}
+	if event.Payload.CustomDetails["packages"] != "xz 5.6.0, xz-libs 5.6.0" || event.Payload.CustomDetails["fixed_in"] != "5.6.2" {
+		t.Errorf("expected the packages and fix in the details, got %v", event.Payload.CustomDetails)
+	}
+}
+
+func TestOpsgenie(t *testing.T) {
+	auth := ""
+	fake := &fakeWebhook{status: http.StatusAccepted}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		fake.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	o := &Opsgenie{APIKey: "key123", URL: srv.URL}
+	msg := incidentMessage()
+	msg.Summary.Image = "cgr.dev/chainguard/" + strings.Repeat("a", 200) + ":latest"
+	if err := o.Notify(context.Background(), msg); err != nil {
+		t.Fatalf("expected no error on Notify(), got %v", err)
+	}
+	if auth != "GenieKey key123" {
+		t.Errorf("expected the API key in the Authorization header, got %q", auth)
+	}
+	var alert opsgenieAlert
+	if len(fake.bodies) != 2 || json.Unmarshal([]byte(fake.bodies[0]), &alert) != nil {
+		t.Fatalf("expected 2 JSON alerts, got %v", fake.bodies)
+	}
+	if len(alert.Message) != 130 || !strings.HasSuffix(alert.Alias, "/CVE-2024-0003") {
+		t.Errorf("expected a truncated message and an alias by image and CVE, got %+v", alert)
+	}
+}
@@ -0,0 +1,211 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/diff"
+	"github.com/chainguard-dev/rumble/pkg/report"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// MaxTopFindings is how many of a scan's most severe vulns a Message holds
+const MaxTopFindings = 10
+
+// Notifier sends a message about a scan, e.g. to a Slack channel
+type Notifier interface {
+	Notify(ctx context.Context, msg *Message) error
+}
+
+// Message is what is known about a scan when notifying about it, and what
+// notification templates are executed with
+type Message struct {
+	Summary *types.ImageScanSummary
+
+	// Diff holds the vulns added and removed since the previous scan of a
+	// different digest of the image, or is nil if there is none (or it
+	// isn't known, e.g. when the scan isn't recorded in BigQuery)
+	Diff *diff.Predicate
+
+	// TopFindings are the most severe vulns, at most MaxTopFindings, with
+	// the highest CVSS scores first within a severity
+	TopFindings []*types.Vuln
+
+	// Vulns are all of the scan's vulns
+	Vulns []*types.Vuln
+
+	// Link is where to find out more about the scan, e.g. its report or
+	// BigQuery table, if known
+	Link string
+}
+
+// NewMessage returns the message for a scan. The summary's ID must already
+// be set.
+func NewMessage(summary *types.ImageScanSummary, vulns []*types.Vuln, d *diff.Predicate) *Message {
+	top := append([]*types.Vuln{}, vulns...)
+	sort.SliceStable(top, func(i, j int) bool {
+		if ri, rj := report.SeverityRank(top[i]), report.SeverityRank(top[j]); ri != rj {
+			return ri < rj
+		}
+		if top[i].NvdCvssScore != top[j].NvdCvssScore {
+			return top[i].NvdCvssScore > top[j].NvdCvssScore
+		}
+		return top[i].Vulnerability < top[j].Vulnerability
+	})
+	if len(top) > MaxTopFindings {
+		top = top[:MaxTopFindings]
+	}
+	return &Message{Summary: summary, Diff: d, TopFindings: top, Vulns: vulns}
+}
+
+// Count is the number of a scan's vulns of a severity
+type Count struct {
+	Severity string
+	Count    int
+}
+
+// Counts returns the scan's counts by severity, most severe first
+func (msg *Message) Counts() []Count {
+	return []Count{
+		{"Critical", msg.Summary.CritCveCount},
+		{"High", msg.Summary.HighCveCount},
+		{"Medium", msg.Summary.MedCveCount},
+		{"Low", msg.Summary.LowCveCount},
+		{"Total", msg.Summary.TotCveCount},
+	}
+}
+
+// BigQueryLink returns a link to a BigQuery table in the Cloud console
+func BigQueryLink(project string, dataset string, table string) string {
+	return "https://console.cloud.google.com/bigquery?" + url.Values{
+		"project": {project},
+		"p":       {project},
+		"d":       {dataset},
+		"t":       {table},
+		"page":    {"table"},
+	}.Encode()
+}
+
+// Funcs are the functions available to notification templates besides the
+// text/template builtins
+var Funcs = template.FuncMap{
+	// severity is a vuln's severity, preferring NVD's
+	"severity": report.Severity,
+	// json encodes a value as JSON, e.g. for webhook bodies
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// ParseTemplate parses a notification template
+func ParseTemplate(name string, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(Funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// ReadTemplate parses the notification template in a file
+func ReadTemplate(path string) (*template.Template, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTemplate(path, string(b))
+}
+
+// Render executes a template with the message
+func (msg *Message) Render(tmpl *template.Template) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, msg); err != nil {
+		return "", fmt.Errorf("executing template %s: %w", tmpl.Name(), err)
+	}
+	return b.String(), nil
+}
+
+// renderBody returns the body of a card-based message: the template
+// rendered if there is one, and the default card as JSON otherwise
+func renderBody(msg *Message, tmpl *template.Template, card func(*Message) interface{}) ([]byte, error) {
+	if tmpl != nil {
+		text, err := msg.Render(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(text), nil
+	}
+	return json.Marshal(card(msg))
+}
+
+// changesText summarizes the diff with the previous scan, if there is one
+func changesText(msg *Message) string {
+	if msg.Diff == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d added and %d removed since %s", len(msg.Diff.Added), len(msg.Diff.Removed), msg.Diff.Previous.Digest)
+}
+
+// findingLines describes each of the top findings on a line of its own
+func findingLines(msg *Message) []string {
+	lines := []string{}
+	for _, vuln := range msg.TopFindings {
+		line := fmt.Sprintf("%s (%s) in %s %s", vuln.Vulnerability, report.Severity(vuln), vuln.Name, vuln.Installed)
+		if vuln.FixedIn != "" {
+			line += ", fixed in " + vuln.FixedIn
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// post sends a request body to a URL, failing unless the response is 2xx.
+// Errors only name the host, as webhook URLs often hold a secret.
+func post(ctx context.Context, client *http.Client, target string, contentType string, body []byte) error {
+	return postWithHeader(ctx, client, target, http.Header{"Content-Type": {contentType}}, body)
+}
+
+// postWithHeader is post with request headers of its own, e.g. to
+// authenticate
+func postWithHeader(ctx context.Context, client *http.Client, target string, header http.Header, body []byte) error {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid notification URL")
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("posting to %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("posting to %s: %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/diff"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// fakeWebhook records the bodies posted to it, answering with status
+type fakeWebhook struct {
+	status int
+	bodies []string
+}
+
+func (f *fakeWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	f.bodies = append(f.bodies, string(b))
+	w.WriteHeader(f.status)
+	fmt.Fprint(w, "no_service")
+}
+
+func testMessage() *Message {
+	summary := &types.ImageScanSummary{
+		ID:           "testing123",
+		Image:        "cgr.dev/chainguard/static:latest",
+		Digest:       "sha256:abc",
+		Scanner:      "grype",
+		CritCveCount: 1,
+		HighCveCount: 1,
+		TotCveCount:  3,
+	}
+	vulns := []*types.Vuln{
+		{Vulnerability: "CVE-2024-0003", Name: "zlib", Installed: "1.3", Severity: "Low"},
+		{Vulnerability: "CVE-2024-0002", Name: "openssl", Installed: "3.1.0", FixedIn: "3.1.1", Severity: "Medium", NvdSeverity: "HIGH"},
+		{Vulnerability: "CVE-2024-0001", Name: "glibc", Installed: "2.38", Severity: "Critical"},
+	}
+	d := &diff.Predicate{Previous: diff.Scan{Digest: "sha256:def"}, Added: []diff.Entry{diff.NewEntry(vulns[2])}}
+	return NewMessage(summary, vulns, d)
+}
+
+func TestNewMessage(t *testing.T) {
+	msg := testMessage()
+	ids := []string{}
+	for _, vuln := range msg.TopFindings {
+		ids = append(ids, vuln.Vulnerability)
+	}
+	if strings.Join(ids, ",") != "CVE-2024-0001,CVE-2024-0002,CVE-2024-0003" {
+		t.Errorf("expected the most severe vulns first (NVD's severity winning), got %v", ids)
+	}
+
+	vulns := make([]*types.Vuln, MaxTopFindings+5)
+	for i := range vulns {
+		vulns[i] = &types.Vuln{Vulnerability: fmt.Sprintf("CVE-2024-%04d", i)}
+	}
+	if msg := NewMessage(&types.ImageScanSummary{}, vulns, nil); len(msg.TopFindings) != MaxTopFindings {
+		t.Errorf("expected %d top findings, got %d", MaxTopFindings, len(msg.TopFindings))
+	}
+}
+
+func TestSlack(t *testing.T) {
+	fake := &fakeWebhook{status: http.StatusOK}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	s := &Slack{WebhookURL: srv.URL + "/services/T000/B000/secret"}
+	if err := s.Notify(context.Background(), testMessage()); err != nil {
+		t.Fatalf("expected no error on Notify(), got %v", err)
+	}
+	var body struct {
+		Text string `json:"text"`
+	}
+	if len(fake.bodies) != 1 || json.Unmarshal([]byte(fake.bodies[0]), &body) != nil {
+		t.Fatalf("expected a JSON message to be posted, got %v", fake.bodies)
+	}
+	for _, expected := range []string{
+		"*cgr.dev/chainguard/static:latest* (sha256:abc) scanned with grype: 1 critical, 1 high",
+		"1 added and 0 removed since sha256:def",
+		"• CVE-2024-0002 (HIGH) in openssl 3.1.0, fixed in 3.1.1",
+	} {
+		if !strings.Contains(body.Text, expected) {
+			t.Errorf("expected the message to contain %q, got:\n%s", expected, body.Text)
+		}
+	}
+
+	// The secret part of the URL is left out of errors
+	fake.status = http.StatusNotFound
+	err := s.Notify(context.Background(), testMessage())
+	if err == nil || !strings.Contains(err.Error(), "404") || strings.Contains(err.Error(), "secret") {
+		t.Errorf("expected a 404 error without the URL's path, got %v", err)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	fake := &fakeWebhook{status: http.StatusNoContent}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	// Without a template, the event is sent along with the top findings
+	w := &Webhook{URL: srv.URL}
+	if err := w.Notify(context.Background(), testMessage()); err != nil {
+		t.Fatalf("expected no error on Notify(), got %v", err)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(fake.bodies[0]), &payload); err != nil {
+		t.Fatalf("expected a JSON body, got %v", err)
+	}
+	if payload["scan_id"] != "testing123" || len(payload["top_findings"].([]interface{})) != 3 || len(payload["added"].([]interface{})) != 1 {
+		t.Errorf("expected the event with 3 top findings and 1 added, got %v", payload)
+	}
+
+	tmpl, err := ParseTemplate("custom", `{"image": {{ json .Summary.Image }}, "cves": {{ len .TopFindings }}, "first": "{{ (index .TopFindings 0).Vulnerability | lower }}"}`)
+	if err != nil {
+		t.Fatalf("expected no error on ParseTemplate(), got %v", err)
+	}
+	w.Template = tmpl
+	if err := w.Notify(context.Background(), testMessage()); err != nil {
+		t.Fatalf("expected no error on Notify(), got %v", err)
+	}
+	if expected := `{"image": "cgr.dev/chainguard/static:latest", "cves": 3, "first": "cve-2024-0001"}`; fake.bodies[1] != expected {
+		t.Errorf("expected the rendered template %s, got %s", expected, fake.bodies[1])
+	}
+
+	if _, err := ParseTemplate("broken", "{{ .Summary.Image "); err == nil {
+		t.Errorf("expected an error parsing a broken template")
+	}
+}
+
+func TestTeams(t *testing.T) {
+	fake := &fakeWebhook{status: http.StatusOK}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	msg := testMessage()
+	msg.Link = BigQueryLink("my-project", "rumble", "scans")
+	if err := (&Teams{WebhookURL: srv.URL}).Notify(context.Background(), msg); err != nil {
+		t.Fatalf("expected no error on Notify(), got %v", err)
+	}
+	var body struct {
+		Attachments []struct {
+			ContentType string       `json:"contentType"`
+			Content     adaptiveCard `json:"content"`
+		} `json:"attachments"`
+	}
+	if len(fake.bodies) != 1 || json.Unmarshal([]byte(fake.bodies[0]), &body) != nil || len(body.Attachments) != 1 {
+		t.Fatalf("expected a message with a card to be posted, got %v", fake.bodies)
+	}
+	card := body.Attachments[0].Content
+	for _, expected := range []string{
+		`{"title":"Critical","value":"1"}`,
+		`"text":"1 added and 0 removed since sha256:def"`,
+		`CVE-2024-0002 (HIGH) in openssl 3.1.0, fixed in 3.1.1`,
+	} {
+		if !strings.Contains(fake.bodies[0], expected) {
+			t.Errorf("expected the card to contain %s, got:\n%s", expected, fake.bodies[0])
+		}
+	}
+	if card.Type != "AdaptiveCard" || len(card.Actions) != 1 {
+		t.Fatalf("expected an Adaptive Card with a link to the scan, got %+v", card)
+	}
+	expected := "https://console.cloud.google.com/bigquery?d=rumble&p=my-project&page=table&project=my-project&t=scans"
+	if url := card.Actions[0].(map[string]interface{})["url"]; url != expected {
+		t.Errorf("expected a link to %s, got %v", expected, url)
+	}
+}
+
+func TestGoogleChat(t *testing.T) {
+	fake := &fakeWebhook{status: http.StatusOK}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	if err := (&GoogleChat{WebhookURL: srv.URL}).Notify(context.Background(), testMessage()); err != nil {
+		t.Fatalf("expected no error on Notify(), got %v", err)
+	}
+	var body struct {
+		Text    string `json:"text"`
+		CardsV2 []struct {
+			Card struct {
+				Header   map[string]string        `json:"header"`
+				Sections []map[string]interface{} `json:"sections"`
+			} `json:"card"`
+		} `json:"cardsV2"`
+	}
+	if len(fake.bodies) != 1 || json.Unmarshal([]byte(fake.bodies[0]), &body) != nil || len(body.CardsV2) != 1 {
+		t.Fatalf("expected a message with a card to be posted, got %v", fake.bodies)
+	}
+	if expected := "cgr.dev/chainguard/static:latest: 1 critical, 1 high, 0 medium, 0 low, 3 total"; body.Text != expected {
+		t.Errorf("expected the text %q, got %q", expected, body.Text)
+	}
+	card := body.CardsV2[0].Card
+	if card.Header["subtitle"] != "sha256:abc, scanned with grype (scan_id=testing123)" {
+		t.Errorf("expected the digest and scanner in the subtitle, got %v", card.Header)
+	}
+	// Counts, the diff and the top findings, without a link
+	if len(card.Sections) != 3 || card.Sections[2]["header"] != "Most severe" {
+		t.Errorf("expected 3 sections, got %v", card.Sections)
+	}
+}
@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"text/template"
+)
+
+// DefaultSlackTemplate is the Slack message (in mrkdwn) sent without a
+// template of your own
+const DefaultSlackTemplate = `*{{ .Summary.Image }}*{{ with .Summary.Digest }} ({{ . }}){{ end }} scanned with {{ .Summary.Scanner }}: ` +
+	`{{ .Summary.CritCveCount }} critical, {{ .Summary.HighCveCount }} high, {{ .Summary.MedCveCount }} medium, {{ .Summary.LowCveCount }} low ({{ .Summary.TotCveCount }} total)
+{{- with .Diff }}
+{{ len .Added }} added and {{ len .Removed }} removed since {{ .Previous.Digest }}
+{{- end }}
+{{- range .TopFindings }}
+• {{ .Vulnerability }} ({{ severity . }}) in {{ .Name }} {{ .Installed }}{{ with .FixedIn }}, fixed in {{ . }}{{ end }}
+{{- end }}
+`
+
+var defaultSlackTemplate = template.Must(ParseTemplate("slack", DefaultSlackTemplate))
+
+// Slack posts messages to a Slack incoming webhook. The template renders
+// the message text, and defaults to DefaultSlackTemplate.
+type Slack struct {
+	WebhookURL string
+	Template   *template.Template
+	HTTPClient *http.Client
+}
+
+func (s *Slack) Notify(ctx context.Context, msg *Message) error {
+	tmpl := s.Template
+	if tmpl == nil {
+		tmpl = defaultSlackTemplate
+	}
+	text, err := msg.Render(tmpl)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.HTTPClient, s.WebhookURL, "application/json", body)
+}
@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Teams posts messages to a Microsoft Teams incoming webhook (or a
+// Workflows webhook) as an Adaptive Card with the counts, top findings and
+// a link to the scan. The template, if set, renders the whole request body
+// instead.
+type Teams struct {
+	WebhookURL string
+	Template   *template.Template
+	HTTPClient *http.Client
+}
+
+// adaptiveCard is the subset of an Adaptive Card used for messages
+type adaptiveCard struct {
+	Schema  string        `json:"$schema"`
+	Type    string        `json:"type"`
+	Version string        `json:"version"`
+	Body    []interface{} `json:"body"`
+	Actions []interface{} `json:"actions,omitempty"`
+}
+
+func (t *Teams) Notify(ctx context.Context, msg *Message) error {
+	body, err := renderBody(msg, t.Template, teamsCard)
+	if err != nil {
+		return err
+	}
+	return post(ctx, t.HTTPClient, t.WebhookURL, "application/json", body)
+}
+
+// teamsCard returns the default Teams message for a scan
+func teamsCard(msg *Message) interface{} {
+	title := msg.Summary.Image
+	if msg.Summary.Digest != "" {
+		title += " (" + msg.Summary.Digest + ")"
+	}
+	facts := []map[string]string{}
+	for _, count := range msg.Counts() {
+		facts = append(facts, map[string]string{"title": count.Severity, "value": strconv.Itoa(count.Count)})
+	}
+	card := adaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body: []interface{}{
+			map[string]interface{}{"type": "TextBlock", "text": title, "weight": "Bolder", "size": "Medium", "wrap": true},
+			map[string]interface{}{"type": "TextBlock", "text": fmt.Sprintf("Scanned with %s (scan_id=%s)", msg.Summary.Scanner, msg.Summary.ID), "isSubtle": true, "spacing": "None", "wrap": true},
+			map[string]interface{}{"type": "FactSet", "facts": facts},
+		},
+	}
+	if text := changesText(msg); text != "" {
+		card.Body = append(card.Body, map[string]interface{}{"type": "TextBlock", "text": text, "wrap": true})
+	}
+	if lines := findingLines(msg); len(lines) > 0 {
+		card.Body = append(card.Body, map[string]interface{}{"type": "TextBlock", "text": "- " + strings.Join(lines, "\n- "), "wrap": true})
+	}
+	if msg.Link != "" {
+		card.Actions = []interface{}{map[string]string{"type": "Action.OpenUrl", "title": "View scan", "url": msg.Link}}
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{map[string]interface{}{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
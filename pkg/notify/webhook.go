@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"text/template"
+
+	"github.com/chainguard-dev/rumble/pkg/diff"
+	"github.com/chainguard-dev/rumble/pkg/events"
+)
+
+// Webhook posts messages to any URL. The template renders the request
+// body, sent as ContentType (defaulting to JSON); without one, the body is
+// the scan-completed event along with the diff and top findings.
+type Webhook struct {
+	URL         string
+	Template    *template.Template
+	ContentType string
+	HTTPClient  *http.Client
+}
+
+// webhookPayload is the default body of a webhook
+type webhookPayload struct {
+	*events.Event
+	Added       []diff.Entry `json:"added,omitempty"`
+	Removed     []diff.Entry `json:"removed,omitempty"`
+	TopFindings []diff.Entry `json:"top_findings"`
+}
+
+func (w *Webhook) Notify(ctx context.Context, msg *Message) error {
+	var body []byte
+	if w.Template != nil {
+		text, err := msg.Render(w.Template)
+		if err != nil {
+			return err
+		}
+		body = []byte(text)
+	} else {
+		payload := webhookPayload{Event: events.New(msg.Summary), TopFindings: []diff.Entry{}}
+		if msg.Diff != nil {
+			payload.Added, payload.Removed = msg.Diff.Added, msg.Diff.Removed
+		}
+		for _, vuln := range msg.TopFindings {
+			payload.TopFindings = append(payload.TopFindings, diff.NewEntry(vuln))
+		}
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+	contentType := w.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	return post(ctx, w.HTTPClient, w.URL, contentType, body)
+}
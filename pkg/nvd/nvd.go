@@ -0,0 +1,199 @@
+package nvd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+const (
+	DefaultBaseURL = "https://services.nvd.nist.gov/rest/json/cves/2.0"
+
+	// Cached NVD responses older than this are refreshed
+	cacheTTL = 7 * 24 * time.Hour
+
+	// The NVD API allows 5 requests per 30 seconds without an API key,
+	// and 50 requests per 30 seconds with one
+	requestInterval        = 6 * time.Second
+	requestIntervalWithKey = 600 * time.Millisecond
+)
+
+// CVSS is the NVD base score for a single CVE and the severity derived from it
+type CVSS struct {
+	Version  string
+	Score    float64
+	Severity string
+}
+
+type Client struct {
+	BaseURL  string
+	APIKey   string
+	CacheDir string
+
+	httpClient  *http.Client
+	lastRequest time.Time
+	scores      map[string]*CVSS
+}
+
+func NewClient(cacheDir string, apiKey string) *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		APIKey:     apiKey,
+		CacheDir:   cacheDir,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		scores:     map[string]*CVSS{},
+	}
+}
+
+// DefaultCacheDir returns the directory used to cache NVD responses when
+// none is provided explicitly
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "rumble", "nvd")
+}
+
+// Lookup returns the NVD CVSS score for a CVE, preferring CVSS v3.1, then
+// v3.0, then v2. A nil result with no error means NVD has no score for it.
+func (c *Client) Lookup(cveID string) (*CVSS, error) {
+	if !strings.HasPrefix(cveID, "CVE-") {
+		return nil, nil
+	}
+	if cvss, ok := c.scores[cveID]; ok {
+		return cvss, nil
+	}
+	b, err := c.readCache(cveID)
+	if err != nil {
+		b, err = c.fetch(cveID)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.writeCache(cveID, b); err != nil {
+			fmt.Printf("WARNING: could not cache NVD response for %s: %s\n", cveID, err.Error())
+		}
+	}
+	var output types.NvdCveOutput
+	if err := json.Unmarshal(b, &output); err != nil {
+		return nil, fmt.Errorf("parsing NVD response for %s: %w", cveID, err)
+	}
+	cvss := cvssFromOutput(&output)
+	c.scores[cveID] = cvss
+	return cvss, nil
+}
+
+func (c *Client) fetch(cveID string) ([]byte, error) {
+	interval := requestInterval
+	if c.APIKey != "" {
+		interval = requestIntervalWithKey
+	}
+	if wait := interval - time.Since(c.lastRequest); wait > 0 {
+		time.Sleep(wait)
+	}
+	c.lastRequest = time.Now()
+
+	req, err := http.NewRequest(http.MethodGet, c.BaseURL+"?cveId="+url.QueryEscape(cveID), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.APIKey != "" {
+		req.Header.Set("apiKey", c.APIKey)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s from NVD: %w", cveID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s from NVD: unexpected status %s", cveID, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (c *Client) cachePath(cveID string) string {
+	return filepath.Join(c.CacheDir, cveID+".json")
+}
+
+func (c *Client) readCache(cveID string) ([]byte, error) {
+	if c.CacheDir == "" {
+		return nil, os.ErrNotExist
+	}
+	info, err := os.Stat(c.cachePath(cveID))
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(info.ModTime()) > cacheTTL {
+		return nil, fmt.Errorf("cache entry for %s is stale", cveID)
+	}
+	return os.ReadFile(c.cachePath(cveID))
+}
+
+func (c *Client) writeCache(cveID string, b []byte) error {
+	if c.CacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.CacheDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.cachePath(cveID), b, 0644)
+}
+
+func cvssFromOutput(output *types.NvdCveOutput) *CVSS {
+	if len(output.Vulnerabilities) == 0 {
+		return nil
+	}
+	metrics := output.Vulnerabilities[0].Cve.Metrics
+	for _, candidates := range [][]types.NvdCveOutputCvssMetric{metrics.CvssMetricV31, metrics.CvssMetricV30, metrics.CvssMetricV2} {
+		metric := primaryMetric(candidates)
+		if metric == nil {
+			continue
+		}
+		return &CVSS{
+			Version:  metric.CvssData.Version,
+			Score:    metric.CvssData.BaseScore,
+			Severity: SeverityFromScore(metric.CvssData.BaseScore),
+		}
+	}
+	return nil
+}
+
+// primaryMetric prefers the score assigned by NVD itself over secondary
+// (e.g. CNA-provided) scores
+func primaryMetric(metrics []types.NvdCveOutputCvssMetric) *types.NvdCveOutputCvssMetric {
+	if len(metrics) == 0 {
+		return nil
+	}
+	for i, metric := range metrics {
+		if metric.Type == "Primary" {
+			return &metrics[i]
+		}
+	}
+	return &metrics[0]
+}
+
+// SeverityFromScore maps a CVSS base score to the qualitative severity
+// rating scale defined by the CVSS v3 specification, where 0 is "None"
+// (counted as negligible)
+func SeverityFromScore(score float64) string {
+	switch {
+	case score >= 9.0:
+		return "Critical"
+	case score >= 7.0:
+		return "High"
+	case score >= 4.0:
+		return "Medium"
+	case score > 0:
+		return "Low"
+	default:
+		return "None"
+	}
+}
@@ -0,0 +1,26 @@
+package nvd
+
+import (
+	"testing"
+)
+
+func TestSeverityFromScore(t *testing.T) {
+	for _, tc := range []struct {
+		score    float64
+		expected string
+	}{
+		{0, "None"},
+		{0.1, "Low"},
+		{3.9, "Low"},
+		{4.0, "Medium"},
+		{6.9, "Medium"},
+		{7.0, "High"},
+		{8.9, "High"},
+		{9.0, "Critical"},
+		{10.0, "Critical"},
+	} {
+		if got := SeverityFromScore(tc.score); got != tc.expected {
+			t.Errorf("SeverityFromScore(%.1f) is %q, wanted %q", tc.score, got, tc.expected)
+		}
+	}
+}
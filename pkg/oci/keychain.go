@@ -0,0 +1,133 @@
+package oci
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+
+	"github.com/chrismellard/docker-credential-acr-env/pkg/credhelper"
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+)
+
+// The amazon-ecr-credential-helper is run as a docker credential helper
+// rather than compiled in, and is only used when it is on PATH
+const ecrHelperProgram = "docker-credential-ecr-login"
+
+var ecrRegistryRE = regexp.MustCompile(`^\d+\.dkr\.ecr(-fips)?\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// Keychain returns the keychain used to authenticate to registries. With
+// cloud set, ambient Google (GCR/Artifact Registry), Amazon ECR and Azure
+// ACR credentials are tried after the docker config.
+func Keychain(cloud bool) authn.Keychain {
+	if !cloud {
+		return authn.DefaultKeychain
+	}
+	return authn.NewMultiKeychain(
+		authn.DefaultKeychain,
+		google.Keychain,
+		authn.NewKeychainFromHelper(ecrHelper{}),
+		authn.NewKeychainFromHelper(credhelper.NewACRCredentialsHelper()),
+	)
+}
+
+// WithCredentials returns a keychain that uses explicit credentials for the
+// registry of an image, and the given keychain for any other registry
+func WithCredentials(keychain authn.Keychain, imageRef string, cfg authn.AuthConfig) (authn.Keychain, error) {
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %w", imageRef, err)
+	}
+	return &staticKeychain{registry: ref.Context().RegistryStr(), auth: authn.FromConfig(cfg), fallback: keychain}, nil
+}
+
+type staticKeychain struct {
+	registry string
+	auth     authn.Authenticator
+	fallback authn.Keychain
+}
+
+func (k *staticKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if target.RegistryStr() == k.registry {
+		return k.auth, nil
+	}
+	return k.fallback.Resolve(target)
+}
+
+type ecrHelper struct{}
+
+func (ecrHelper) Get(serverURL string) (string, string, error) {
+	if !ecrRegistryRE.MatchString(serverURL) {
+		return "", "", fmt.Errorf("%s is not an ECR registry", serverURL)
+	}
+	if _, err := exec.LookPath(ecrHelperProgram); err != nil {
+		return "", "", err
+	}
+	creds, err := client.Get(client.NewShellProgramFunc(ecrHelperProgram), serverURL)
+	if err != nil {
+		return "", "", err
+	}
+	return creds.Username, creds.Secret, nil
+}
+
+// WriteDockerConfig resolves the credentials for an image's registry and
+// writes them to a docker config file in a new temporary directory, so they
+// can be passed to scanners (via DOCKER_CONFIG) that don't have access to
+// the keychain. An empty directory is returned if no credentials are needed.
+func WriteDockerConfig(imageRef string, keychain authn.Keychain) (string, error) {
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return "", fmt.Errorf("parsing reference %q: %w", imageRef, err)
+	}
+	auth, err := keychain.Resolve(ref.Context())
+	if err != nil {
+		return "", fmt.Errorf("resolving credentials for %q: %w", imageRef, err)
+	}
+	if auth == authn.Anonymous {
+		return "", nil
+	}
+	cfg, err := auth.Authorization()
+	if err != nil {
+		return "", fmt.Errorf("resolving credentials for %q: %w", imageRef, err)
+	}
+	return writeDockerConfig(ref.Context().RegistryStr(), cfg)
+}
+
+// dockerConfigAuth is an entry in the auths section of a docker config file
+type dockerConfigAuth struct {
+	Auth          string `json:"auth,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+	RegistryToken string `json:"registrytoken,omitempty"`
+}
+
+func writeDockerConfig(registry string, cfg *authn.AuthConfig) (string, error) {
+	// Docker Hub credentials are keyed by the legacy index URL
+	if registry == name.DefaultRegistry {
+		registry = "https://" + name.DefaultRegistry + "/v1/"
+	}
+	entry := dockerConfigAuth{Auth: cfg.Auth, IdentityToken: cfg.IdentityToken, RegistryToken: cfg.RegistryToken}
+	if cfg.Username != "" || cfg.Password != "" {
+		entry.Auth = base64.StdEncoding.EncodeToString([]byte(cfg.Username + ":" + cfg.Password))
+	}
+	b, err := json.Marshal(map[string]map[string]dockerConfigAuth{
+		"auths": {registry: entry},
+	})
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "rumble-docker-config-")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), b, 0600); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
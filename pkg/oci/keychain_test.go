@@ -0,0 +1,69 @@
+package oci
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+func TestWriteDockerConfig(t *testing.T) {
+	dir, err := writeDockerConfig("index.docker.io", &authn.AuthConfig{Username: "user", Password: "pass"})
+	if err != nil {
+		t.Fatalf("expected no error on writeDockerConfig(), got %v", err)
+	}
+	defer os.RemoveAll(dir)
+	b, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatalf("expected no error reading config.json, got %v", err)
+	}
+	expected := `{"auths":{"https://index.docker.io/v1/":{"auth":"dXNlcjpwYXNz"}}}`
+	if string(b) != expected {
+		t.Errorf("docker config is %s, wanted %s", b, expected)
+	}
+}
+
+func TestECRRegistry(t *testing.T) {
+	tests := map[string]bool{
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com":      true,
+		"123456789012.dkr.ecr-fips.us-east-1.amazonaws.com": true,
+		"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn":  true,
+		"gcr.io":          false,
+		"index.docker.io": false,
+	}
+	for registry, expected := range tests {
+		if actual := ecrRegistryRE.MatchString(registry); actual != expected {
+			t.Errorf("ecrRegistryRE.MatchString(%q) is %t, wanted %t", registry, actual, expected)
+		}
+	}
+}
+
+func TestWithCredentials(t *testing.T) {
+	keychain, err := WithCredentials(authn.NewMultiKeychain(), "registry.example.com/app:latest", authn.AuthConfig{RegistryToken: "token"})
+	if err != nil {
+		t.Fatalf("expected no error on WithCredentials(), got %v", err)
+	}
+	dir, err := WriteDockerConfig("registry.example.com/other:latest", keychain)
+	if err != nil {
+		t.Fatalf("expected no error on WriteDockerConfig(), got %v", err)
+	}
+	defer os.RemoveAll(dir)
+	b, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatalf("expected no error reading config.json, got %v", err)
+	}
+	expected := `{"auths":{"registry.example.com":{"registrytoken":"token"}}}`
+	if string(b) != expected {
+		t.Errorf("docker config is %s, wanted %s", b, expected)
+	}
+
+	// Other registries fall back to the original (here empty) keychain
+	dir, err = WriteDockerConfig("cgr.dev/chainguard/static:latest", keychain)
+	if err != nil {
+		t.Fatalf("expected no error on WriteDockerConfig(), got %v", err)
+	}
+	if dir != "" {
+		t.Errorf("expected no docker config for an anonymous registry, got %s", dir)
+	}
+}
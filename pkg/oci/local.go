@@ -0,0 +1,135 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// LocalDigest returns the digest of an image in an OCI layout directory
+// (which must hold a single image or index), or in a tarball as written by
+// "docker save" or "crane pull"
+func LocalDigest(path string) (v1.Hash, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	if info.IsDir() {
+		index, err := layout.ImageIndexFromPath(path)
+		if err != nil {
+			return v1.Hash{}, fmt.Errorf("reading OCI layout %s: %w", path, err)
+		}
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return v1.Hash{}, fmt.Errorf("reading OCI layout %s: %w", path, err)
+		}
+		if len(manifest.Manifests) != 1 {
+			return v1.Hash{}, fmt.Errorf("OCI layout %s has %d manifests, expected 1", path, len(manifest.Manifests))
+		}
+		return manifest.Manifests[0].Digest, nil
+	}
+	img, err := tarball.ImageFromPath(path, nil)
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("reading image tarball %s: %w", path, err)
+	}
+	return img.Digest()
+}
+
+// InspectLocal reads the config file and manifest of an image in an OCI
+// layout directory or a tarball, as for LocalDigest. A layout holding an
+// index can't be inspected, as it has no single config.
+func InspectLocal(path string) (*Config, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var img v1.Image
+	if info.IsDir() {
+		index, err := layout.ImageIndexFromPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading OCI layout %s: %w", path, err)
+		}
+		digest, err := LocalDigest(path)
+		if err != nil {
+			return nil, err
+		}
+		if img, err = index.Image(digest); err != nil {
+			return nil, fmt.Errorf("reading image %s from OCI layout %s: %w", digest, path, err)
+		}
+	} else if img, err = tarball.ImageFromPath(path, nil); err != nil {
+		return nil, fmt.Errorf("reading image tarball %s: %w", path, err)
+	}
+	config, err := InspectImage(img)
+	if err != nil {
+		return nil, fmt.Errorf("inspecting %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Digest returns imageRef pinned to the digest it currently resolves to. A
+// reference that is already pinned is returned as-is.
+func Digest(ctx context.Context, imageRef string, keychain authn.Keychain) (name.Digest, error) {
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("parsing reference %q: %w", imageRef, err)
+	}
+	if digest, ok := ref.(name.Digest); ok {
+		return digest, nil
+	}
+	desc, err := remote.Head(ref, remoteOptions(ctx, keychain)...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("remote.Head() %q: %w", imageRef, err)
+	}
+	return ref.Context().Digest(desc.Digest.String()), nil
+}
+
+// SubjectDigest checks that the pushed image at imageRef is the same image
+// as the local one at path, returning imageRef pinned to its digest
+func SubjectDigest(ctx context.Context, path string, imageRef string, keychain authn.Keychain) (string, error) {
+	local, err := LocalDigest(path)
+	if err != nil {
+		return "", err
+	}
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return "", fmt.Errorf("parsing reference %q: %w", imageRef, err)
+	}
+	desc, err := remote.Head(ref, remoteOptions(ctx, keychain)...)
+	if err != nil {
+		return "", fmt.Errorf("remote.Head() %q: %w", imageRef, err)
+	}
+	if desc.Digest != local {
+		return "", fmt.Errorf("%s has digest %s, but the scanned image %s has digest %s", imageRef, desc.Digest, path, local)
+	}
+	return ref.Context().Digest(desc.Digest.String()).String(), nil
+}
+
+// Pull writes the image at imageRef to a new OCI layout at path, so that
+// several scanners can scan it without each pulling it. An index is
+// resolved to its image for the platform.
+func Pull(ctx context.Context, imageRef string, path string, platform v1.Platform, keychain authn.Keychain) error {
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %w", imageRef, err)
+	}
+	img, err := remote.Image(ref, append(remoteOptions(ctx, keychain), remote.WithPlatform(platform))...)
+	if err != nil {
+		return fmt.Errorf("remote.Image() %q: %w", imageRef, err)
+	}
+	p, err := layout.Write(path, empty.Index)
+	if err != nil {
+		return fmt.Errorf("writing OCI layout %s: %w", path, err)
+	}
+	if err := p.AppendImage(img); err != nil {
+		return fmt.Errorf("writing %q to OCI layout %s: %w", imageRef, path, err)
+	}
+	return nil
+}
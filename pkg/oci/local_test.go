@@ -0,0 +1,99 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+func TestLocalDigest(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("expected no error on random.Image(), got %v", err)
+	}
+	expected, err := img.Digest()
+	if err != nil {
+		t.Fatalf("expected no error on img.Digest(), got %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "layout")
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		t.Fatalf("expected no error on layout.Write(), got %v", err)
+	}
+	if err := p.AppendImage(img); err != nil {
+		t.Fatalf("expected no error on AppendImage(), got %v", err)
+	}
+	if actual, err := LocalDigest(dir); err != nil || actual != expected {
+		t.Errorf("LocalDigest() of OCI layout is %s (err=%v), wanted %s", actual, err, expected)
+	}
+
+	tarPath := filepath.Join(t.TempDir(), "image.tar")
+	if err := tarball.WriteToFile(tarPath, name.MustParseReference("example.com/app:latest"), img); err != nil {
+		t.Fatalf("expected no error on tarball.WriteToFile(), got %v", err)
+	}
+	if actual, err := LocalDigest(tarPath); err != nil || actual != expected {
+		t.Errorf("LocalDigest() of tarball is %s (err=%v), wanted %s", actual, err, expected)
+	}
+
+	for _, path := range []string{dir, tarPath} {
+		config, err := InspectLocal(path)
+		if err != nil {
+			t.Fatalf("expected no error on InspectLocal(%s), got %v", path, err)
+		}
+		if len(config.Layers) != 1 {
+			t.Errorf("expected 1 layer in %s, got %v", path, config.Layers)
+		}
+	}
+}
+
+func TestPull(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatalf("expected no error on random.Image(), got %v", err)
+	}
+	imageRef := strings.TrimPrefix(srv.URL, "http://") + "/test/image:latest"
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		t.Fatalf("expected no error on name.ParseReference(), got %v", err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("expected no error on remote.Write(), got %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "layout")
+	if err := Pull(context.Background(), imageRef, dir, v1.Platform{OS: "linux", Architecture: "amd64"}, authn.DefaultKeychain); err != nil {
+		t.Fatalf("expected no error on Pull(), got %v", err)
+	}
+	expected, err := img.Digest()
+	if err != nil {
+		t.Fatalf("expected no error on img.Digest(), got %v", err)
+	}
+	if digest, err := LocalDigest(dir); err != nil || digest != expected {
+		t.Errorf("expected the layout to hold %s, got %s (%v)", expected, digest, err)
+	}
+
+	// Requests are abandoned once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Digest(ctx, imageRef, authn.DefaultKeychain); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected Digest() to be canceled, got %v", err)
+	}
+}
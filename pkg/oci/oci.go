@@ -1,26 +1,184 @@
 package oci
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 )
 
-func ImageBuildTime(imageRef string) (*time.Time, error) {
+// Config is what an image's config file and manifest say about it
+type Config struct {
+	// Created is nil if the image doesn't record when it was built, as with
+	// reproducible builds that set it to the zero time or the Unix epoch
+	Created *time.Time
+
+	// The platform the image is built for, with the Windows build for
+	// Windows images in OSVersion
+	OS           string
+	Architecture string
+	OSVersion    string
+
+	Entrypoint []string
+	Cmd        []string
+	Env        []string
+	User       string
+	Labels     map[string]string
+
+	// Layers are the digests of the image's layers, bottom first
+	Layers  []string
+	History []v1.History
+
+	// Annotations are those of the image manifest
+	Annotations map[string]string
+}
+
+// Epoch is recorded as the created time of images (and directories) that
+// don't have one
+const Epoch = "1970-01-01T00:00:00Z"
+
+// FormatCreated formats a created time as recorded, falling back to Epoch
+func FormatCreated(created *time.Time) string {
+	if created == nil {
+		return Epoch
+	}
+	return created.UTC().Format(time.RFC3339)
+}
+
+// Inspect fetches the config file and manifest of the image at imageRef. If
+// it is an index, the image for platform is inspected, defaulting to
+// linux/amd64 when platform is nil.
+func Inspect(ctx context.Context, imageRef string, platform *v1.Platform, keychain authn.Keychain) (*Config, error) {
 	ref, err := name.ParseReference(imageRef)
 	if err != nil {
 		return nil, fmt.Errorf("parsing reference %q: %w", imageRef, err)
 	}
-	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	options := remoteOptions(ctx, keychain)
+	if platform != nil {
+		options = append(options, remote.WithPlatform(*platform))
+	}
+	img, err := remote.Image(ref, options...)
 	if err != nil {
 		return nil, fmt.Errorf("remote.Image() %q: %w", imageRef, err)
 	}
-	config, err := img.ConfigFile()
+	config, err := InspectImage(img)
+	if err != nil {
+		return nil, fmt.Errorf("inspecting %q: %w", imageRef, err)
+	}
+	return config, nil
+}
+
+// InspectImage reads the config file and manifest of an image
+func InspectImage(img v1.Image) (*Config, error) {
+	file, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("img.ConfigFile(): %w", err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("img.Manifest(): %w", err)
+	}
+	config := &Config{
+		OS:           file.OS,
+		Architecture: file.Architecture,
+		OSVersion:    file.OSVersion,
+		Entrypoint:   file.Config.Entrypoint,
+		Cmd:          file.Config.Cmd,
+		Env:          file.Config.Env,
+		User:         file.Config.User,
+		Labels:       file.Config.Labels,
+		Layers:       make([]string, len(manifest.Layers)),
+		History:      file.History,
+		Annotations:  manifest.Annotations,
+	}
+	if created := file.Created.Time; !created.IsZero() && created.Unix() != 0 {
+		config.Created = &created
+	}
+	for i, layer := range manifest.Layers {
+		config.Layers[i] = layer.Digest.String()
+	}
+	return config, nil
+}
+
+// ImageBuildTime returns when the image at imageRef was built, or nil if
+// it doesn't record that
+func ImageBuildTime(ctx context.Context, imageRef string, keychain authn.Keychain) (*time.Time, error) {
+	config, err := Inspect(ctx, imageRef, nil, keychain)
 	if err != nil {
-		return nil, fmt.Errorf("img.ConfigFile() %q: %w", imageRef, err)
+		return nil, err
+	}
+	return config.Created, nil
+}
+
+// Standard annotations identifying the image a build started from
+const (
+	baseNameAnnotation   = "org.opencontainers.image.base.name"
+	baseDigestAnnotation = "org.opencontainers.image.base.digest"
+)
+
+// Standard annotations identifying the source code an image is built from
+const (
+	sourceAnnotation   = "org.opencontainers.image.source"
+	revisionAnnotation = "org.opencontainers.image.revision"
+)
+
+// BaseImage returns the name and digest of the image's base image, as
+// recorded in its manifest annotations or, failing that, its config labels.
+// Both are empty if the image doesn't record its base image. It's read from
+// the config already inspected for the scan, rather than fetched again.
+func (c *Config) BaseImage() (string, string) {
+	if c.Annotations[baseNameAnnotation] != "" {
+		return c.Annotations[baseNameAnnotation], c.Annotations[baseDigestAnnotation]
+	}
+	return c.Labels[baseNameAnnotation], c.Labels[baseDigestAnnotation]
+}
+
+// Source returns the URL of the repository the image was built from and
+// the revision (e.g. the git commit) built, each as recorded in its
+// manifest annotations or, failing that, its config labels. Both are empty
+// if the image doesn't record its source.
+func (c *Config) Source() (string, string) {
+	annotationOrLabel := func(key string) string {
+		if value := c.Annotations[key]; value != "" {
+			return value
+		}
+		return c.Labels[key]
+	}
+	return annotationOrLabel(sourceAnnotation), annotationOrLabel(revisionAnnotation)
+}
+
+// ImageTag returns the repository and tag an image reference is for, so that
+// scans of a tag can be grouped the same way whether the image was given by
+// tag or by digest. A digest reference takes its tag from tagHint or, failing
+// that, from a tag written before the digest (as in repo:tag@sha256:...), and
+// is otherwise left without one.
+func ImageTag(imageRef string, tagHint string) (string, string, error) {
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing reference %q: %w", imageRef, err)
+	}
+	repository := ref.Context().Name()
+	if tag, ok := ref.(name.Tag); ok {
+		if tagHint != "" && tagHint != tag.TagStr() {
+			return "", "", fmt.Errorf("tag hint %q doesn't match the tag of %q", tagHint, imageRef)
+		}
+		return repository, tag.TagStr(), nil
+	}
+	if tagHint != "" {
+		if _, err := name.NewTag(repository + ":" + tagHint); err != nil {
+			return "", "", fmt.Errorf("invalid tag hint %q: %w", tagHint, err)
+		}
+		return repository, tagHint, nil
+	}
+	if base, _, ok := strings.Cut(imageRef, "@"); ok {
+		if tag, err := name.NewTag(base, name.StrictValidation); err == nil {
+			return repository, tag.TagStr(), nil
+		}
 	}
-	return &config.Created.Time, nil
+	return repository, "", nil
 }
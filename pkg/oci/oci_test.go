@@ -0,0 +1,100 @@
+package oci
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestImageTag(t *testing.T) {
+	digest := "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	for _, tc := range []struct {
+		ref, tagHint, repository, tag string
+	}{
+		{"cgr.dev/chainguard/static:latest", "", "cgr.dev/chainguard/static", "latest"},
+		{"cgr.dev/chainguard/static:latest", "latest", "cgr.dev/chainguard/static", "latest"},
+		{"alpine", "", "index.docker.io/library/alpine", "latest"},
+		{"cgr.dev/chainguard/static@" + digest, "", "cgr.dev/chainguard/static", ""},
+		{"cgr.dev/chainguard/static@" + digest, "v1", "cgr.dev/chainguard/static", "v1"},
+		{"cgr.dev/chainguard/static:v2@" + digest, "", "cgr.dev/chainguard/static", "v2"},
+		{"cgr.dev/chainguard/static:v2@" + digest, "v1", "cgr.dev/chainguard/static", "v1"},
+	} {
+		repository, tag, err := ImageTag(tc.ref, tc.tagHint)
+		if err != nil {
+			t.Fatalf("expected no error on ImageTag(%q, %q), got %v", tc.ref, tc.tagHint, err)
+		}
+		if repository != tc.repository || tag != tc.tag {
+			t.Errorf("expected ImageTag(%q, %q) to be %q, %q, got %q, %q", tc.ref, tc.tagHint, tc.repository, tc.tag, repository, tag)
+		}
+	}
+
+	if _, _, err := ImageTag("cgr.dev/chainguard/static:latest", "v1"); err == nil {
+		t.Errorf("expected an error for a tag hint that doesn't match the tag")
+	}
+	if _, _, err := ImageTag("cgr.dev/chainguard/static@"+digest, "not a tag"); err == nil {
+		t.Errorf("expected an error for an invalid tag hint")
+	}
+}
+
+func TestInspectImage(t *testing.T) {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatalf("expected no error on random.Image(), got %v", err)
+	}
+	file, err := img.ConfigFile()
+	if err != nil {
+		t.Fatalf("expected no error on img.ConfigFile(), got %v", err)
+	}
+	file = file.DeepCopy()
+	file.Config.Entrypoint = []string{"/usr/bin/app"}
+	file.Config.Labels = map[string]string{
+		baseNameAnnotation: "cgr.dev/chainguard/static:latest",
+		sourceAnnotation:   "https://github.com/chainguard-dev/old",
+		revisionAnnotation: "3f1c2e0b9d8a7c6b5a4f3e2d1c0b9a8f7e6d5c4b",
+	}
+	file.Created = v1.Time{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if img, err = mutate.ConfigFile(img, file); err != nil {
+		t.Fatalf("expected no error on mutate.ConfigFile(), got %v", err)
+	}
+	img = mutate.Annotations(img, map[string]string{
+		baseDigestAnnotation: "sha256:abc",
+		sourceAnnotation:     "https://github.com/chainguard-dev/app",
+	}).(v1.Image)
+
+	config, err := InspectImage(img)
+	if err != nil {
+		t.Fatalf("expected no error on InspectImage(), got %v", err)
+	}
+	if len(config.Entrypoint) != 1 || config.Entrypoint[0] != "/usr/bin/app" {
+		t.Errorf("expected entrypoint /usr/bin/app, got %v", config.Entrypoint)
+	}
+	if len(config.Layers) != 2 {
+		t.Errorf("expected 2 layer digests, got %v", config.Layers)
+	}
+	if created := FormatCreated(config.Created); created != "2024-01-02T03:04:05Z" {
+		t.Errorf("expected created time 2024-01-02T03:04:05Z, got %s", created)
+	}
+	// Without a name in the manifest annotations, both come from the labels
+	if baseName, baseDigest := config.BaseImage(); baseName != "cgr.dev/chainguard/static:latest" || baseDigest != "" {
+		t.Errorf("expected the base image from the labels, got %q %q", baseName, baseDigest)
+	}
+	// Whereas the source and revision are looked up separately
+	if repo, revision := config.Source(); repo != "https://github.com/chainguard-dev/app" || revision != "3f1c2e0b9d8a7c6b5a4f3e2d1c0b9a8f7e6d5c4b" {
+		t.Errorf("expected the source from the annotations and the revision from the labels, got %q %q", repo, revision)
+	}
+
+	// Reproducible builds set the created time to the epoch
+	file.Created = v1.Time{Time: time.Unix(0, 0)}
+	if img, err = mutate.ConfigFile(img, file); err != nil {
+		t.Fatalf("expected no error on mutate.ConfigFile(), got %v", err)
+	}
+	if config, err = InspectImage(img); err != nil {
+		t.Fatalf("expected no error on InspectImage(), got %v", err)
+	}
+	if config.Created != nil || FormatCreated(config.Created) != Epoch {
+		t.Errorf("expected no created time, got %v", config.Created)
+	}
+}
@@ -0,0 +1,237 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Transport is used for every registry request made by this package. It
+// defaults to go-containerregistry's transport; see NewTransport for one
+// that limits concurrency and retries throttled requests.
+var Transport http.RoundTripper = remote.DefaultTransport
+
+func remoteOptions(ctx context.Context, keychain authn.Keychain) []remote.Option {
+	return []remote.Option{remote.WithContext(ctx), remote.WithAuthFromKeychain(keychain), remote.WithTransport(Transport)}
+}
+
+// Limiter caps the number of concurrent operations per registry, e.g.
+// "index.docker.io=2,*=8". Registries without a limit of their own use the
+// "*" limit, if any, and are otherwise unlimited.
+type Limiter struct {
+	limits map[string]int
+
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// ParseLimiter parses comma-separated registry=limit pairs. "docker.io" is
+// the same registry as "index.docker.io", as in image references.
+func ParseLimiter(limits string) (*Limiter, error) {
+	l := &Limiter{limits: map[string]int{}, slots: map[string]chan struct{}{}}
+	for _, pair := range strings.Split(limits, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		registry, value, ok := strings.Cut(pair, "=")
+		limit, err := strconv.Atoi(value)
+		if !ok || err != nil || limit < 1 || registry == "" {
+			return nil, fmt.Errorf("invalid registry limit %q, expected <registry>=<limit>", pair)
+		}
+		l.limits[registryName(registry)] = limit
+	}
+	return l, nil
+}
+
+func registryName(registry string) string {
+	if registry == "*" {
+		return registry
+	}
+	if reg, err := name.NewRegistry(registry); err == nil {
+		return reg.RegistryStr()
+	}
+	return registry
+}
+
+// Acquire waits for a slot for the registry, returning a function that
+// releases it
+func (l *Limiter) Acquire(ctx context.Context, registry string) (func(), error) {
+	slots := l.slotsFor(registryName(registry))
+	if slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// AcquireImage waits for a slot for the registry of an image reference
+func (l *Limiter) AcquireImage(ctx context.Context, imageRef string) (func(), error) {
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %w", imageRef, err)
+	}
+	return l.Acquire(ctx, ref.Context().RegistryStr())
+}
+
+func (l *Limiter) slotsFor(registry string) chan struct{} {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if slots, ok := l.slots[registry]; ok {
+		return slots
+	}
+	limit, ok := l.limits[registry]
+	if !ok {
+		limit, ok = l.limits["*"]
+	}
+	var slots chan struct{}
+	if ok {
+		slots = make(chan struct{}, limit)
+	}
+	l.slots[registry] = slots
+	return slots
+}
+
+// Backoff is how throttled registry requests (and scanner pulls) are retried
+type Backoff struct {
+	// Retries is how many times to retry, zero disabling retries
+	Retries int
+
+	// Base is the delay before the first retry, doubling after each one up
+	// to MaxBackoffDelay
+	Base time.Duration
+}
+
+// MaxBackoffDelay caps the delay between retries
+const MaxBackoffDelay = 2 * time.Minute
+
+// Delay returns how long to wait before the given retry (starting at 1).
+// A registry's Retry-After, if longer, takes precedence, up to
+// MaxBackoffDelay.
+func (b Backoff) Delay(retry int, retryAfter time.Duration) time.Duration {
+	delay := b.Base
+	for i := 1; i < retry && delay < MaxBackoffDelay; i++ {
+		delay *= 2
+	}
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	if delay > MaxBackoffDelay {
+		delay = MaxBackoffDelay
+	}
+	return delay
+}
+
+// Retryable returns whether a registry response status is worth retrying:
+// rate limiting (429) or a server error
+func Retryable(status int) bool {
+	return status == http.StatusTooManyRequests || (status >= 500 && status <= 599 && status != http.StatusNotImplemented)
+}
+
+// throttledRE matches scanner output reporting a throttled or failed pull
+var throttledRE = regexp.MustCompile(`(?i)too ?many ?requests|rate.?limit|internal server error|bad gateway|service unavailable|gateway time-?out`)
+
+// Throttled returns whether a scanner's output suggests its image pull was
+// throttled or hit a registry error, and so is worth retrying
+func Throttled(output string) bool {
+	return throttledRE.MatchString(output)
+}
+
+type transport struct {
+	base    http.RoundTripper
+	limiter *Limiter
+	backoff Backoff
+}
+
+// NewTransport returns a transport that limits concurrent requests per
+// registry and retries rate-limited (429) and server error responses
+func NewTransport(base http.RoundTripper, limiter *Limiter, backoff Backoff) http.RoundTripper {
+	return &transport{base: base, limiter: limiter, backoff: backoff}
+}
+
+// RoundTrip holds the registry's slot until the response body is closed,
+// since reading the body (e.g. a layer) is most of the request
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	release, err := t.limiter.Acquire(ctx, req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.roundTrip(ctx, req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+func (t *transport) roundTrip(ctx context.Context, req *http.Request) (*http.Response, error) {
+	// Only requests without a body can be sent again as they are
+	retries := t.backoff.Retries
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		retries = 0
+	}
+	for retry := 1; ; retry++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		if !Retryable(resp.StatusCode) || retry > retries {
+			return resp, err
+		}
+		delay := t.backoff.Delay(retry, retryAfter(resp))
+		resp.Body.Close()
+		fmt.Printf("WARNING: %s %s returned %s, retrying in %s (%d of %d)\n", req.Method, req.URL.Redacted(), resp.Status, delay, retry, retries)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// retryAfter returns the delay a response asks for with Retry-After, if it
+// is given in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// releaseBody releases a registry's slot once the response body is closed
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,158 @@
+package oci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	if _, err := ParseLimiter("docker.io"); err == nil {
+		t.Errorf("expected error on a limit without a value, got nil")
+	}
+	if _, err := ParseLimiter("docker.io=0"); err == nil {
+		t.Errorf("expected error on a zero limit, got nil")
+	}
+	l, err := ParseLimiter("docker.io=1, *=2")
+	if err != nil {
+		t.Fatalf("expected no error on ParseLimiter(), got %v", err)
+	}
+
+	// docker.io and index.docker.io share the one slot
+	ctx := context.Background()
+	release, err := l.AcquireImage(ctx, "alpine:latest")
+	if err != nil {
+		t.Fatalf("expected no error on AcquireImage(), got %v", err)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(timeout, "index.docker.io"); err == nil {
+		t.Errorf("expected Acquire() to time out while docker.io is at its limit")
+	}
+	release()
+	if release, err := l.Acquire(ctx, "docker.io"); err != nil {
+		t.Errorf("expected no error on Acquire() after release, got %v", err)
+	} else {
+		release()
+	}
+
+	// Other registries each get the default limit
+	for i := 0; i < 2; i++ {
+		if _, err := l.Acquire(ctx, "ghcr.io"); err != nil {
+			t.Fatalf("expected no error on Acquire(), got %v", err)
+		}
+	}
+	if _, err := l.Acquire(ctx, "cgr.dev"); err != nil {
+		t.Errorf("expected cgr.dev to have its own slots, got %v", err)
+	}
+
+	// A nil limiter doesn't limit anything
+	var unlimited *Limiter
+	if _, err := unlimited.Acquire(ctx, "docker.io"); err != nil {
+		t.Errorf("expected no error on a nil limiter, got %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Retries: 5, Base: time.Second}
+	for _, tc := range []struct {
+		retry      int
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{1, 0, time.Second},
+		{3, 0, 4 * time.Second},
+		{3, 30 * time.Second, 30 * time.Second},
+		{20, 0, MaxBackoffDelay},
+		{1, time.Hour, MaxBackoffDelay},
+	} {
+		if got := b.Delay(tc.retry, tc.retryAfter); got != tc.want {
+			t.Errorf("Delay(%d, %s) = %s, wanted %s", tc.retry, tc.retryAfter, got, tc.want)
+		}
+	}
+}
+
+func TestThrottled(t *testing.T) {
+	for output, want := range map[string]bool{
+		"toomanyrequests: You have reached your pull rate limit":   true,
+		"GET https://index.docker.io/v2/: 503 Service Unavailable": true,
+		"unexpected status code 429 Too Many Requests":             true,
+		"MANIFEST_UNKNOWN: manifest unknown":                       false,
+		"could not find 503 packages":                              false,
+	} {
+		if got := Throttled(output); got != want {
+			t.Errorf("Throttled(%q) = %v, wanted %v", output, got, want)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport, nil, Backoff{Retries: 2, Base: time.Millisecond})}
+	resp, err := client.Get(srv.URL + "/v2/")
+	if err != nil {
+		t.Fatalf("expected no error on Get(), got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || requests != 3 {
+		t.Errorf("got status %d after %d request(s), wanted 200 after 3", resp.StatusCode, requests)
+	}
+
+	// Once out of retries, the last response is returned
+	atomic.StoreInt32(&requests, 0)
+	client.Transport = NewTransport(http.DefaultTransport, nil, Backoff{Retries: 1, Base: time.Millisecond})
+	resp, err = client.Get(srv.URL + "/v2/")
+	if err != nil {
+		t.Fatalf("expected no error on Get(), got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("got status %d, wanted 502", resp.StatusCode)
+	}
+
+	// Requests are limited by the registry host
+	u, _ := url.Parse(srv.URL)
+	l, _ := ParseLimiter(u.Host + "=1")
+	release, _ := l.Acquire(context.Background(), u.Host)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v2/", nil)
+	if _, err := NewTransport(http.DefaultTransport, l, Backoff{}).RoundTrip(req); err == nil {
+		t.Errorf("expected the request to wait for the registry's slot and time out")
+	}
+	release()
+
+	// The slot is held until the response body is closed
+	resp, err = NewTransport(http.DefaultTransport, l, Backoff{}).RoundTrip(req.Clone(context.Background()))
+	if err != nil {
+		t.Fatalf("expected no error on RoundTrip(), got %v", err)
+	}
+	held, cancelHeld := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelHeld()
+	if _, err := l.Acquire(held, u.Host); err == nil {
+		t.Errorf("expected the registry's slot to be held until the response body is closed")
+	}
+	resp.Body.Close()
+	resp.Body.Close()
+	release, err = l.Acquire(context.Background(), u.Host)
+	if err != nil {
+		t.Fatalf("expected no error on Acquire() once the response body is closed, got %v", err)
+	}
+	release()
+}
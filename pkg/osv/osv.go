@@ -0,0 +1,96 @@
+package osv
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+const DefaultBaseURL = "https://api.osv.dev/v1"
+
+// Client looks up the packages affected by a vulnerability in the OSV
+// database (https://osv.dev)
+type Client struct {
+	BaseURL string
+
+	httpClient *http.Client
+}
+
+func NewClient() *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Packages returns the names of the packages affected by a vulnerability,
+// sorted. OSV records for CVEs often only list the affected upstream
+// repository, so the packages of the distro and ecosystem advisories
+// aliased to or related to it are included too.
+func (c *Client) Packages(id string) ([]string, error) {
+	vuln, err := c.Vuln(id)
+	if err != nil {
+		return nil, err
+	}
+	vulns := []*types.OsvVulnOutput{vuln}
+	for _, relatedID := range append(append([]string{}, vuln.Aliases...), vuln.Related...) {
+		related, err := c.Vuln(relatedID)
+		if err != nil {
+			// Not every alias is in OSV
+			fmt.Printf("WARNING: could not look up %s (related to %s) in OSV: %s\n", relatedID, id, err.Error())
+			continue
+		}
+		vulns = append(vulns, related)
+	}
+	return packageNames(vulns), nil
+}
+
+// Vuln fetches a single vulnerability record
+func (c *Client) Vuln(id string) (*types.OsvVulnOutput, error) {
+	resp, err := c.httpClient.Get(fmt.Sprintf("%s/vulns/%s", c.BaseURL, url.PathEscape(id)))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s from OSV: %w", id, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s from OSV: unexpected status %s", id, resp.Status)
+	}
+	var vuln types.OsvVulnOutput
+	if err := json.NewDecoder(resp.Body).Decode(&vuln); err != nil {
+		return nil, fmt.Errorf("parsing OSV response for %s: %w", id, err)
+	}
+	return &vuln, nil
+}
+
+// packageNames returns the distinct affected package names. Maven packages
+// are named "group:artifact" by trivy but just "artifact" by grype, so both
+// are returned.
+func packageNames(vulns []*types.OsvVulnOutput) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, vuln := range vulns {
+		for _, affected := range vuln.Affected {
+			candidates := []string{affected.Package.Name}
+			if affected.Package.Ecosystem == "Maven" {
+				if _, artifact, ok := strings.Cut(affected.Package.Name, ":"); ok {
+					candidates = append(candidates, artifact)
+				}
+			}
+			for _, name := range candidates {
+				if name == "" || seen[name] {
+					continue
+				}
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
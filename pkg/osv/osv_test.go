@@ -0,0 +1,46 @@
+package osv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestPackages(t *testing.T) {
+	vulns := map[string]types.OsvVulnOutput{
+		"CVE-2021-44228": {ID: "CVE-2021-44228", Aliases: []string{"GHSA-jfh8-c2jp-5v3q"}, Related: []string{"MISSING-1"}},
+		"GHSA-jfh8-c2jp-5v3q": {ID: "GHSA-jfh8-c2jp-5v3q", Affected: []types.OsvVulnOutputAffected{
+			{Package: types.OsvVulnOutputAffectedPackage{Ecosystem: "Maven", Name: "org.apache.logging.log4j:log4j-core"}},
+			{Package: types.OsvVulnOutputAffectedPackage{Ecosystem: "Debian:11", Name: "apache-log4j2"}},
+			{Package: types.OsvVulnOutputAffectedPackage{Ecosystem: "Debian:12", Name: "apache-log4j2"}},
+		}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vuln, ok := vulns[strings.TrimPrefix(r.URL.Path, "/vulns/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(vuln)
+	}))
+	defer server.Close()
+
+	c := NewClient()
+	c.BaseURL = server.URL
+	packages, err := c.Packages("CVE-2021-44228")
+	if err != nil {
+		t.Fatalf("expected no error on Packages(), got %v", err)
+	}
+	expected := []string{"apache-log4j2", "log4j-core", "org.apache.logging.log4j:log4j-core"}
+	if !reflect.DeepEqual(packages, expected) {
+		t.Errorf("got packages %v, wanted %v", packages, expected)
+	}
+	if _, err := c.Packages("MISSING-1"); err == nil {
+		t.Errorf("expected error on unknown vulnerability, got nil")
+	}
+}
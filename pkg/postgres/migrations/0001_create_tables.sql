@@ -0,0 +1,68 @@
+-- Columns queried by rumble are typed, and each row is also kept in full
+-- as "data" (keyed by BigQuery column name), so new columns don't need a
+-- migration until they are queried. Times are kept as the same strings as
+-- in BigQuery, which sort chronologically.
+CREATE TABLE scans (
+	id TEXT PRIMARY KEY,
+	image TEXT NOT NULL,
+	digest TEXT NOT NULL,
+	scanner TEXT NOT NULL,
+	scanner_version TEXT NOT NULL,
+	scanner_db_version TEXT NOT NULL,
+	time TEXT NOT NULL,
+	created TEXT NOT NULL,
+	low_cve_count INTEGER NOT NULL,
+	med_cve_count INTEGER NOT NULL,
+	high_cve_count INTEGER NOT NULL,
+	crit_cve_count INTEGER NOT NULL,
+	negligible_cve_count INTEGER NOT NULL,
+	unknown_cve_count INTEGER NOT NULL,
+	tot_cve_count INTEGER NOT NULL,
+	success BOOLEAN NOT NULL,
+	data JSONB NOT NULL
+);
+CREATE INDEX scans_image_time ON scans (image, time);
+
+CREATE TABLE vulns (
+	id TEXT NOT NULL,
+	scan_id TEXT NOT NULL REFERENCES scans (id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	installed TEXT NOT NULL,
+	fixed_in TEXT NOT NULL,
+	type TEXT NOT NULL,
+	vulnerability TEXT NOT NULL,
+	severity TEXT NOT NULL,
+	time TEXT NOT NULL,
+	data JSONB NOT NULL,
+	PRIMARY KEY (scan_id, id)
+);
+CREATE INDEX vulns_vulnerability ON vulns (vulnerability);
+CREATE INDEX vulns_name ON vulns (name);
+
+CREATE TABLE findings (
+	id TEXT NOT NULL,
+	scan_id TEXT NOT NULL REFERENCES scans (id) ON DELETE CASCADE,
+	time TEXT NOT NULL,
+	data JSONB NOT NULL,
+	PRIMARY KEY (scan_id, id)
+);
+
+CREATE TABLE licenses (
+	id TEXT NOT NULL,
+	scan_id TEXT NOT NULL REFERENCES scans (id) ON DELETE CASCADE,
+	time TEXT NOT NULL,
+	data JSONB NOT NULL,
+	PRIMARY KEY (scan_id, id)
+);
+
+CREATE TABLE packages (
+	id TEXT NOT NULL,
+	scan_id TEXT NOT NULL REFERENCES scans (id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	version TEXT NOT NULL,
+	type TEXT NOT NULL,
+	time TEXT NOT NULL,
+	data JSONB NOT NULL,
+	PRIMARY KEY (scan_id, id)
+);
+CREATE INDEX packages_name ON packages (name);
@@ -0,0 +1,16 @@
+-- The persistent job queue of "rumble serve". Unlike scan times, job times
+-- are compared against now(), so they're typed.
+CREATE TABLE jobs (
+	id TEXT PRIMARY KEY,
+	image TEXT NOT NULL,
+	scanner TEXT NOT NULL,
+	state TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	submitted TEXT NOT NULL,
+	scan_id TEXT NOT NULL DEFAULT '',
+	error TEXT NOT NULL DEFAULT '',
+	run_after TIMESTAMPTZ NOT NULL,
+	claimed_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX jobs_state_run_after ON jobs (state, run_after);
@@ -0,0 +1,3 @@
+ALTER TABLE jobs ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+DROP INDEX jobs_state_run_after;
+CREATE INDEX jobs_state_priority ON jobs (state, priority DESC, run_after);
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/chainguard-dev/rumble/pkg/api"
+	"github.com/chainguard-dev/rumble/pkg/query"
+)
+
+func TestRebind(t *testing.T) {
+	stmt, args := Rebind("SELECT id FROM `scans` WHERE image = @image AND time >= @since OR image = @image",
+		[]bigquery.QueryParameter{{Name: "since", Value: "2023-06-22T00:00:00Z"}, {Name: "image", Value: "a"}})
+	if stmt != `SELECT id FROM "scans" WHERE image = $1 AND time >= $2 OR image = $1` {
+		t.Errorf("got statement %s", stmt)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"a", "2023-06-22T00:00:00Z"}) {
+		t.Errorf("got args %v, wanted [a 2023-06-22T00:00:00Z]", args)
+	}
+
+	// Every summary query must only reference known parameters
+	sql, params, err := query.SummarySQL(SummaryTable, query.Filter{Image: "a", Scanner: "grype", LatestOnly: true, Severity: "high"})
+	if err != nil {
+		t.Fatalf("expected no error on SummarySQL(), got %v", err)
+	}
+	stmt, args = Rebind(sql, params)
+	if strings.ContainsAny(stmt, "@`") || len(args) != len(params) {
+		t.Errorf("statement %s was not fully rebound (args %v)", stmt, args)
+	}
+}
+
+func TestListMigrations(t *testing.T) {
+	list, err := listMigrations()
+	if err != nil {
+		t.Fatalf("expected no error on listMigrations(), got %v", err)
+	}
+	if len(list) == 0 || list[0].version != 1 {
+		t.Fatalf("got migrations %v, wanted the first to be version 1", list)
+	}
+	for i := 1; i < len(list); i++ {
+		if list[i].version <= list[i-1].version {
+			t.Errorf("migrations are out of order: %v", list)
+		}
+	}
+}
+
+func TestJobStates(t *testing.T) {
+	for state, name := range jobStates {
+		if jobState(name) != state {
+			t.Errorf("state %s was stored as %s, but read back as %s", state, name, jobState(name))
+		}
+	}
+	if state := jobState("paused"); state != api.JobState_JOB_STATE_UNSPECIFIED {
+		t.Errorf("got state %s for an unknown state, wanted unspecified", state)
+	}
+}
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/api"
+	"github.com/chainguard-dev/rumble/pkg/server"
+)
+
+// JobsTable holds the job queue of "rumble serve"
+const JobsTable = "jobs"
+
+// Queue is a job queue kept in PostgreSQL, so queued jobs survive restarts
+// and can be shared by several servers
+type Queue struct {
+	DB *sql.DB
+}
+
+// Queue returns the job queue in the store's database
+func (s *Store) Queue() *Queue {
+	return &Queue{DB: s.DB}
+}
+
+var _ server.Queue = &Queue{}
+
+// Job states as stored in the state column
+var jobStates = map[api.JobState]string{
+	api.JobState_JOB_STATE_QUEUED:    "queued",
+	api.JobState_JOB_STATE_RUNNING:   "running",
+	api.JobState_JOB_STATE_SUCCEEDED: "succeeded",
+	api.JobState_JOB_STATE_FAILED:    "failed",
+}
+
+func jobState(state string) api.JobState {
+	for s, name := range jobStates {
+		if name == state {
+			return s
+		}
+	}
+	return api.JobState_JOB_STATE_UNSPECIFIED
+}
+
+const jobColumns = "id, image, scanner, state, attempts, submitted, scan_id, error, priority"
+
+func scanJob(row interface{ Scan(...interface{}) error }) (*api.Job, error) {
+	job := &api.Job{}
+	var state string
+	if err := row.Scan(&job.Id, &job.Image, &job.Scanner, &state, &job.Attempts, &job.Submitted, &job.ScanId, &job.Error, &job.Priority); err != nil {
+		return nil, err
+	}
+	job.State = jobState(state)
+	return job, nil
+}
+
+func (q *Queue) Enqueue(ctx context.Context, job *api.Job) error {
+	_, err := q.DB.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (id, image, scanner, state, submitted, priority, run_after, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, now(), now())`, JobsTable),
+		job.Id, job.Image, job.Scanner, jobStates[job.State], job.Submitted, job.Priority)
+	return err
+}
+
+// Claim locks the next job with SKIP LOCKED, so concurrent workers (even on
+// other servers) never claim the same job
+func (q *Queue) Claim(ctx context.Context, now time.Time, leaseExpiry time.Time) (*api.Job, error) {
+	job, err := scanJob(q.DB.QueryRowContext(ctx, fmt.Sprintf(`UPDATE %s SET state = 'running', attempts = attempts + 1, claimed_at = $1, updated_at = $1
+		WHERE id = (
+			SELECT id FROM %s
+			WHERE (state = 'queued' AND run_after <= $1) OR (state = 'running' AND claimed_at < $2)
+			ORDER BY priority DESC, run_after, submitted
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING %s`, JobsTable, JobsTable, jobColumns), now, leaseExpiry))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+func (q *Queue) Update(ctx context.Context, job *api.Job, runAfter time.Time) error {
+	result, err := q.DB.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET state = $2, scan_id = $3, error = $4, run_after = $5, updated_at = now()
+		WHERE id = $1`, JobsTable),
+		job.Id, jobStates[job.State], job.ScanId, job.Error, runAfter)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("job %s not found", job.Id)
+	}
+	return nil
+}
+
+func (q *Queue) Get(ctx context.Context, id string) (*api.Job, error) {
+	job, err := scanJob(q.DB.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", jobColumns, JobsTable), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+func (q *Queue) Status(ctx context.Context) (*server.QueueStatus, error) {
+	status := &server.QueueStatus{DeadLetters: []*api.Job{}}
+	rows, err := q.DB.QueryContext(ctx, fmt.Sprintf("SELECT state, COUNT(*), MIN(submitted) FROM %s GROUP BY state", JobsTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var state, oldest string
+		var count int
+		if err := rows.Scan(&state, &count, &oldest); err != nil {
+			return nil, err
+		}
+		switch jobState(state) {
+		case api.JobState_JOB_STATE_QUEUED:
+			status.Queued = count
+			status.OldestQueued = oldest
+		case api.JobState_JOB_STATE_RUNNING:
+			status.Running = count
+		case api.JobState_JOB_STATE_SUCCEEDED:
+			status.Succeeded = count
+		case api.JobState_JOB_STATE_FAILED:
+			status.Failed = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.DB.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE state = 'failed' ORDER BY updated_at DESC LIMIT %d", jobColumns, JobsTable, server.MaxDeadLetters))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		status.DeadLetters = append(status.DeadLetters, job)
+	}
+	return status, rows.Err()
+}
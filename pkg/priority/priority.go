@@ -0,0 +1,140 @@
+package priority
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// Candidate is an image waiting to be scanned
+type Candidate struct {
+	Image   string
+	Scanner string
+
+	// Published is when the image was built, if known. A tag that was just
+	// pushed has a recent build time.
+	Published time.Time
+
+	// Latest is the most recent recorded scan of the image with the
+	// scanner, if any
+	Latest *types.ImageScanSummary
+}
+
+// Policy ranks candidates, so that when there isn't time to scan every
+// image the most important ones are scanned first
+type Policy interface {
+	// Score returns a candidate's score between 0 and 1, higher scores
+	// being scanned first
+	Score(c *Candidate, now time.Time) float64
+
+	// NeedsPublished returns whether Score uses Candidate.Published, which
+	// costs a registry request per image to look up
+	NeedsPublished() bool
+}
+
+var policies = map[string]Policy{}
+
+// Register makes a policy available to Parse (and so to --priority)
+func Register(name string, policy Policy) {
+	policies[name] = policy
+}
+
+// Names returns the names of the registered policies
+func Names() []string {
+	names := []string{}
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse returns the registered policy with the given name
+func Parse(name string) (Policy, error) {
+	policy, ok := policies[name]
+	if !ok {
+		return nil, fmt.Errorf("invalid priority policy %q, expected one of %s", name, strings.Join(Names(), ", "))
+	}
+	return policy, nil
+}
+
+// Sort orders candidates by their score, highest first. Candidates with the
+// same score keep their order.
+func Sort(candidates []*Candidate, policy Policy, now time.Time) {
+	scores := make(map[*Candidate]float64, len(candidates))
+	for _, c := range candidates {
+		scores[c] = policy.Score(c, now)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return scores[candidates[i]] > scores[candidates[j]]
+	})
+}
+
+// Built-in policies
+const (
+	FIFO     = "fifo"
+	Recent   = "recent"
+	Severity = "severity"
+	Balanced = "balanced"
+)
+
+func init() {
+	Register(FIFO, fifo{})
+	Register(Recent, recent{})
+	Register(Severity, severity{})
+	Register(Balanced, balanced{})
+}
+
+// fifo scores every candidate the same, keeping the order they came in
+type fifo struct{}
+
+func (fifo) Score(*Candidate, time.Time) float64 { return 0 }
+func (fifo) NeedsPublished() bool                { return false }
+
+// recentHalfLife is the age at which a build's recency score halves
+const recentHalfLife = 7 * 24 * time.Hour
+
+// recent scores newly built (or pushed) images highest, decaying with age
+type recent struct{}
+
+func (recent) Score(c *Candidate, now time.Time) float64 {
+	if c.Published.IsZero() {
+		return 0
+	}
+	age := now.Sub(c.Published)
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, float64(age)/float64(recentHalfLife))
+}
+
+func (recent) NeedsPublished() bool { return true }
+
+// severity scores images with critical (then high) CVEs in their latest
+// scan highest. Images that were never scanned score highest of all, since
+// nothing is known about them.
+type severity struct{}
+
+func (severity) Score(c *Candidate, now time.Time) float64 {
+	if c.Latest == nil {
+		return 1
+	}
+	weight := float64(c.Latest.CritCveCount) + float64(c.Latest.HighCveCount)/10
+	// Approaches (but never reaches) 1 as the weight grows
+	return 1 - 1/(1+weight)
+}
+
+func (severity) NeedsPublished() bool { return false }
+
+// balanced averages the recent and severity scores
+type balanced struct{}
+
+func (balanced) Score(c *Candidate, now time.Time) float64 {
+	return (recent{}.Score(c, now) + severity{}.Score(c, now)) / 2
+}
+
+func (balanced) NeedsPublished() bool { return true }
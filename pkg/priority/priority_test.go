@@ -0,0 +1,43 @@
+package priority
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestParse(t *testing.T) {
+	for _, name := range []string{FIFO, Recent, Severity, Balanced} {
+		if _, err := Parse(name); err != nil {
+			t.Errorf("expected no error on Parse(%q), got %v", name, err)
+		}
+	}
+	if _, err := Parse("random"); err == nil {
+		t.Errorf("expected error on an unknown policy, got nil")
+	}
+}
+
+func TestSort(t *testing.T) {
+	now := time.Date(2023, 6, 22, 0, 0, 0, 0, time.UTC)
+	old := &Candidate{Image: "old", Published: now.Add(-30 * 24 * time.Hour), Latest: &types.ImageScanSummary{CritCveCount: 5}}
+	fresh := &Candidate{Image: "fresh", Published: now.Add(-time.Hour), Latest: &types.ImageScanSummary{HighCveCount: 1}}
+	clean := &Candidate{Image: "clean", Published: now.Add(-10 * 24 * time.Hour), Latest: &types.ImageScanSummary{}}
+	unscanned := &Candidate{Image: "unscanned"}
+
+	for policy, want := range map[string][]string{
+		FIFO:     {"old", "fresh", "clean", "unscanned"},
+		Recent:   {"fresh", "clean", "old", "unscanned"},
+		Severity: {"unscanned", "old", "fresh", "clean"},
+		Balanced: {"fresh", "unscanned", "old", "clean"},
+	} {
+		p, _ := Parse(policy)
+		candidates := []*Candidate{old, fresh, clean, unscanned}
+		Sort(candidates, p, now)
+		for i, c := range candidates {
+			if c.Image != want[i] {
+				t.Errorf("%s: got order %s at %d, wanted %v", policy, c.Image, i, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/chainguard-dev/rumble/pkg/types"
+	"google.golang.org/api/iterator"
+)
+
+// How the vulns of a scan are recorded: a row for every vuln, or only for
+// those added or removed since its base scan
+const (
+	UploadModeFull  = "full"
+	UploadModeDelta = "delta"
+)
+
+// The changes recorded by the vuln rows of a delta scan
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+)
+
+// ScanState is the full set of vulns of a scan, however they were recorded
+type ScanState struct {
+	ScanID string
+	Vulns  []*types.Vuln
+
+	// Depth is how many delta scans lead back to a full one: 0 for a full
+	// scan, 1 for a delta against a full scan, and so on
+	Depth int
+}
+
+// deltaKey identifies a vuln across scans, including the installed
+// version, so an upgrade that doesn't fix a vuln is still recorded
+func deltaKey(vuln *types.Vuln) string {
+	return strings.Join([]string{vuln.Vulnerability, vuln.Name, vuln.Installed, vuln.Type}, "--")
+}
+
+// Delta returns the vuln rows of a delta scan against base: copies of the
+// vulns that are new, marked as added, and of those of base that are gone,
+// marked as removed and given the scan's ID and time
+func Delta(base []*types.Vuln, vulns []*types.Vuln, scanID string, time string) []*types.Vuln {
+	before := map[string]bool{}
+	for _, vuln := range base {
+		before[deltaKey(vuln)] = true
+	}
+	after := map[string]bool{}
+	rows := []*types.Vuln{}
+	for _, vuln := range vulns {
+		key := deltaKey(vuln)
+		if !before[key] && !after[key] {
+			added := *vuln
+			added.Change = ChangeAdded
+			rows = append(rows, &added)
+		}
+		after[key] = true
+	}
+	for _, vuln := range base {
+		key := deltaKey(vuln)
+		if after[key] {
+			continue
+		}
+		// Only recorded once if the base had duplicates
+		after[key] = true
+		removed := *vuln
+		removed.ScanID, removed.Time, removed.Change = scanID, time, ChangeRemoved
+		removed.SetID()
+		rows = append(rows, &removed)
+	}
+	return rows
+}
+
+// ApplyDelta returns the vulns of a delta scan, from those of its base scan
+// and its own rows
+func ApplyDelta(base []*types.Vuln, delta []*types.Vuln) []*types.Vuln {
+	removed := map[string]bool{}
+	for _, vuln := range delta {
+		if vuln.Change == ChangeRemoved {
+			removed[deltaKey(vuln)] = true
+		}
+	}
+	vulns := []*types.Vuln{}
+	for _, vuln := range base {
+		if !removed[deltaKey(vuln)] {
+			vulns = append(vulns, vuln)
+		}
+	}
+	for _, vuln := range delta {
+		if vuln.Change != ChangeRemoved {
+			// Part of the scan's state, no longer a change
+			added := *vuln
+			added.Change = ""
+			vulns = append(vulns, &added)
+		}
+	}
+	sort.SliceStable(vulns, func(i, j int) bool {
+		if vulns[i].Vulnerability != vulns[j].Vulnerability {
+			return vulns[i].Vulnerability < vulns[j].Vulnerability
+		}
+		return vulns[i].Name < vulns[j].Name
+	})
+	return vulns
+}
+
+// hasUploadMode reports whether a summary table has the upload_mode
+// column, which only tables used with delta uploads need
+func hasUploadMode(ctx context.Context, client *Client, table string) (bool, error) {
+	md, err := tableMetadata(ctx, client, table)
+	if err != nil {
+		return false, err
+	}
+	for _, field := range md.Schema {
+		if field.Name == "upload_mode" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// State returns every vuln of a scan, following the base scans of delta
+// scans back to the full scan they start from
+func State(ctx context.Context, client *Client, table string, vulnsTable string, scanID string) (*ScanState, error) {
+	delta, err := hasUploadMode(ctx, client, table)
+	if err != nil {
+		return nil, err
+	}
+	if !delta {
+		vulns, err := scanRows(ctx, client, vulnsTable, scanID, false)
+		if err != nil {
+			return nil, err
+		}
+		return &ScanState{ScanID: scanID, Vulns: vulns}, nil
+	}
+
+	// Find the chain of scans back to the full one, newest first
+	chain := []string{}
+	for id := scanID; ; {
+		q := newQuery(client, fmt.Sprintf("SELECT IFNULL(upload_mode, '') AS upload_mode, IFNULL(base_scan_id, '') AS base_scan_id FROM `%s` WHERE id = @id LIMIT 1", table))
+		q.Parameters = []bigquery.QueryParameter{{Name: "id", Value: id}}
+		it, err := read(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		var row struct {
+			UploadMode string `bigquery:"upload_mode"`
+			BaseScanID string `bigquery:"base_scan_id"`
+		}
+		if err := it.Next(&row); err == iterator.Done {
+			if id == scanID {
+				// Not recorded, e.g. a vuln table shared with another
+				// summary table, so its rows are all there is
+				break
+			}
+			return nil, fmt.Errorf("scan %s is a delta against scan %s, which is no longer recorded (e.g. pruned)", chain[len(chain)-1], id)
+		} else if err != nil {
+			return nil, err
+		}
+		chain = append(chain, id)
+		if row.UploadMode != UploadModeDelta {
+			break
+		}
+		if row.BaseScanID == "" {
+			return nil, fmt.Errorf("delta scan %s has no base scan", id)
+		}
+		id = row.BaseScanID
+	}
+	if len(chain) == 0 {
+		chain = []string{scanID}
+	}
+
+	state := &ScanState{ScanID: scanID, Depth: len(chain) - 1}
+	for i := len(chain) - 1; i >= 0; i-- {
+		rows, err := scanRows(ctx, client, vulnsTable, chain[i], i < len(chain)-1)
+		if err != nil {
+			return nil, err
+		}
+		if i == len(chain)-1 {
+			state.Vulns = rows
+		} else {
+			state.Vulns = ApplyDelta(state.Vulns, rows)
+		}
+	}
+	return state, nil
+}
+
+// stateSQL returns a WITH clause with two tables: "scans", selected from the
+// summary table by the scans query, and "scan_state", the vuln rows of each
+// of those scans as State rebuilds them. Delta scans are followed back to
+// the full scan they start from, and each vuln comes from the latest scan
+// of the chain with a row for it, unless that row records it as removed.
+// The rows have the scan_id of the scan they're part of the state of. The
+// vuln rows (as "r") can be narrowed down by where, if it's set, which must
+// only be on the columns vulns are matched across scans by (see deltaKey).
+// The SQL is kept to what BigQuery and SQLite support.
+func stateSQL(summaryTable string, vulnsTable string, scans string, where string) string {
+	if where != "" {
+		where = " WHERE " + where
+	}
+	columns := make([]string, len(VulnColumns))
+	for i, column := range VulnColumns {
+		columns[i] = "r." + column
+		if column == "scan_id" {
+			columns[i] = "c.scan_id"
+		}
+	}
+	return fmt.Sprintf("WITH RECURSIVE scans AS (%s), "+
+		"bases AS (SELECT DISTINCT id, IFNULL(upload_mode, '') AS upload_mode, IFNULL(base_scan_id, '') AS base_scan_id FROM `%s`), "+
+		"chain AS (SELECT b.id AS scan_id, b.id AS member_id, 0 AS depth, b.upload_mode, b.base_scan_id FROM bases b WHERE b.id IN (SELECT id FROM scans) "+
+		"UNION ALL SELECT c.scan_id, b.id, c.depth + 1, b.upload_mode, b.base_scan_id FROM chain c JOIN bases b ON b.id = c.base_scan_id WHERE c.upload_mode = '%s'), "+
+		"chain_rows AS (SELECT %s, c.depth, IFNULL(r.change, '') AS change, MIN(c.depth) OVER (PARTITION BY c.scan_id, r.vulnerability, r.name, r.installed, r.type) AS live_depth "+
+		"FROM chain c JOIN `%s` r ON r.scan_id = c.member_id%s), "+
+		"scan_state AS (SELECT %s FROM chain_rows WHERE depth = live_depth AND change != '%s') ",
+		scans, summaryTable, UploadModeDelta, strings.Join(columns, ", "), vulnsTable, where, strings.Join(VulnColumns, ", "), ChangeRemoved)
+}
+
+// scanRows reads the vuln rows of a scan, with their change if it's a
+// delta scan
+func scanRows(ctx context.Context, client *Client, table string, scanID string, delta bool) ([]*types.Vuln, error) {
+	stmt, params := ScanVulnsSQL(fmt.Sprintf("`%s`", table), scanID)
+	if delta {
+		stmt = strings.Replace(stmt, " FROM ", ", IFNULL(change, '') AS change FROM ", 1)
+	}
+	q := newQuery(client, stmt)
+	q.Parameters = params
+	return readVulns(ctx, q)
+}
@@ -0,0 +1,156 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// Export formats supported by BigQuery's EXPORT DATA statement
+var exportFormats = map[string]string{
+	"parquet": "PARQUET",
+	"csv":     "CSV",
+	"json":    "JSON",
+	"avro":    "AVRO",
+}
+
+// ExportSQL returns an EXPORT DATA statement writing rows of table with a
+// time at or after since to files matching uri (a gs:// URI containing a
+// single "*" wildcard). A nil columns slice exports every column.
+func ExportSQL(table string, columns []string, uri string, format string, since time.Time) (string, []bigquery.QueryParameter, error) {
+	exportFormat, err := checkExport(uri, format)
+	if err != nil {
+		return "", nil, err
+	}
+	params := []bigquery.QueryParameter{
+		{Name: "since", Value: since.UTC().Format("2006-01-02T15:04:05Z")},
+	}
+	return exportSQL(table, columns, uri, exportFormat, "time >= @since"), params, nil
+}
+
+// checkExport returns the EXPORT DATA format for a format, checking it and
+// the URI
+func checkExport(uri string, format string) (string, error) {
+	exportFormat, ok := exportFormats[strings.ToLower(format)]
+	if !ok {
+		return "", fmt.Errorf("invalid export format: %s", format)
+	}
+	if !strings.HasPrefix(uri, "gs://") || strings.Count(uri, "*") != 1 {
+		return "", fmt.Errorf("invalid export URI %q, expected gs://bucket/path with a single * wildcard", uri)
+	}
+	return exportFormat, nil
+}
+
+// anonymizedImage is the image reference without its registry hostname
+// (the first component, if it has a "." or ":" or is localhost, as Docker
+// tells them apart), so internal registries and mirrors aren't named
+const anonymizedImage = `REGEXP_REPLACE(image, r'^(localhost|[^/]*[.:][^/]*)/', '')`
+
+// AnonymizedExportSQL returns the EXPORT DATA statements for sharing scans
+// of public images outside: one of their CVE counts, and one of the CVEs
+// found, each with the image without its registry hostname. Neither has
+// scan IDs, labels, paths or the raw scanner output. Only scans of images
+// matching one of the glob patterns (see config.Match) since a time are
+// exported. The URIs are as for ExportSQL. With delta, as for summary
+// tables with delta uploads, the full vulns of each scan are exported (see
+// stateSQL) rather than its own rows of the vulns table.
+func AnonymizedExportSQL(summaryTable string, vulnsTable string, images []string, summaryURI string, vulnsURI string, format string, since time.Time, delta bool) (string, string, []bigquery.QueryParameter, error) {
+	if len(images) == 0 {
+		return "", "", nil, fmt.Errorf("the public images to export are required, so no others are shared")
+	}
+	exportFormat, err := checkExport(summaryURI, format)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if _, err := checkExport(vulnsURI, format); err != nil {
+		return "", "", nil, err
+	}
+	matches := make([]string, len(images))
+	for i, pattern := range images {
+		matches[i] = "image LIKE " + quoteString(likePattern(pattern))
+	}
+	scans := fmt.Sprintf("SELECT id, %s AS image, digest, scanner, scanner_version, scanner_db_version, time, %s, tot_cve_count FROM `%s` WHERE time >= @since AND success AND (%s)",
+		anonymizedImage, strings.Join(countColumns(), ", "), summaryTable, strings.Join(matches, " OR "))
+	options := "EXPORT DATA OPTIONS (uri = %s, format = '%s', overwrite = true) AS "
+	summarySQL := fmt.Sprintf(options+"SELECT * EXCEPT (id) FROM (%s) ORDER BY time", quoteString(summaryURI), exportFormat, scans)
+	vulnsColumns := "s.image, s.digest, s.scanner, s.time, v.vulnerability, v.severity, v.name, v.installed, v.fixed_in, v.type"
+	vulnsSQL := fmt.Sprintf(options+"SELECT %s FROM `%s` v JOIN (%s) s ON v.scan_id = s.id ORDER BY s.time, s.image, v.vulnerability",
+		quoteString(vulnsURI), exportFormat, vulnsColumns, vulnsTable, scans)
+	if delta {
+		vulnsSQL = fmt.Sprintf(options+"%sSELECT %s FROM scan_state v JOIN scans s ON v.scan_id = s.id ORDER BY s.time, s.image, v.vulnerability",
+			quoteString(vulnsURI), exportFormat, stateSQL(summaryTable, vulnsTable, scans, ""), vulnsColumns)
+	}
+	params := []bigquery.QueryParameter{
+		{Name: "since", Value: since.UTC().Format("2006-01-02T15:04:05Z")},
+	}
+	return summarySQL, vulnsSQL, params, nil
+}
+
+// AnonymizedExport runs the statements of AnonymizedExportSQL, exporting the
+// vulns unless vulnsURI is empty
+func AnonymizedExport(ctx context.Context, client *Client, summaryTable string, vulnsTable string, images []string, summaryURI string, vulnsURI string, format string, since time.Time) error {
+	uri := vulnsURI
+	if uri == "" {
+		// Only checked, not exported to
+		uri = summaryURI
+	}
+	delta, err := hasUploadMode(ctx, client, summaryTable)
+	if err != nil {
+		return err
+	}
+	summarySQL, vulnsSQL, params, err := AnonymizedExportSQL(summaryTable, vulnsTable, images, summaryURI, uri, format, since, delta)
+	if err != nil {
+		return err
+	}
+	if _, err := runStatement(ctx, client, summarySQL, params); err != nil {
+		return err
+	}
+	if vulnsURI == "" {
+		return nil
+	}
+	_, err = runStatement(ctx, client, vulnsSQL, params)
+	return err
+}
+
+func exportSQL(table string, columns []string, uri string, exportFormat string, where string) string {
+	selected := "*"
+	if columns != nil {
+		selected = strings.Join(columns, ", ")
+	}
+	// Options must be literals rather than query parameters
+	return fmt.Sprintf("EXPORT DATA OPTIONS (uri = %s, format = '%s', overwrite = true) AS SELECT %s FROM `%s` WHERE %s ORDER BY time",
+		quoteString(uri), exportFormat, selected, table, where)
+}
+
+// quoteString returns s as a GoogleSQL string literal
+func quoteString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// Export runs an EXPORT DATA statement (see ExportSQL) and waits for it to finish
+func Export(ctx context.Context, client *Client, table string, columns []string, uri string, format string, since time.Time) error {
+	sql, params, err := ExportSQL(table, columns, uri, format, since)
+	if err != nil {
+		return err
+	}
+	_, err = runStatement(ctx, client, sql, params)
+	return err
+}
+
+// runStatement runs a statement and waits for it to finish, returning the
+// number of rows affected for DML statements
+func runStatement(ctx context.Context, client *Client, sql string, params []bigquery.QueryParameter) (int64, error) {
+	q := newQuery(client, sql)
+	q.Parameters = params
+	_, status, err := run(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	if stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok {
+		return stats.NumDMLAffectedRows, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,48 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// tables caches the metadata of tables by their fully qualified name, for
+// the columns and partitioning queries depend on
+var tables sync.Map
+
+func tableMetadata(ctx context.Context, client *Client, table string) (*bigquery.TableMetadata, error) {
+	if md, ok := tables.Load(table); ok {
+		return md.(*bigquery.TableMetadata), nil
+	}
+	parts := strings.Split(table, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid table %q, expected project.dataset.table", table)
+	}
+	md, err := client.DatasetInProject(parts[0], parts[1]).Table(parts[2]).Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tables.Store(table, md)
+	return md, nil
+}
+
+// ingestionPartitioned reports whether a table is partitioned by the day
+// its rows were inserted, as CreateTables creates them. Rows are inserted
+// once their scan is done, so a scan is never in a partition before the
+// day of its time.
+func ingestionPartitioned(ctx context.Context, client *Client, table string) (bool, error) {
+	md, err := tableMetadata(ctx, client, table)
+	if err != nil {
+		return false, err
+	}
+	p := md.TimePartitioning
+	return p != nil && p.Field == "" && p.Type == bigquery.DayPartitioningType, nil
+}
+
+// partitionSince is a condition on an ingestion time partitioned table
+// limiting a query with a time >= @since condition to the partitions it
+// can match. Rows still in the streaming buffer have no partition yet.
+const partitionSince = "(_PARTITIONTIME IS NULL OR _PARTITIONTIME >= TIMESTAMP_TRUNC(TIMESTAMP(@since), DAY))"
@@ -0,0 +1,117 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// PruneOptions selects the rows removed by Prune
+type PruneOptions struct {
+	SummaryTable string
+	VulnsTable   string
+
+	// OlderThan is the cutoff; rows with an earlier time are pruned
+	OlderThan time.Time
+
+	// KeepLatest keeps the latest scan of each image/scanner pair (and its
+	// vulns) regardless of age
+	KeepLatest bool
+
+	// ArchivePrefix, if set, is a gs:// prefix that rows are exported to
+	// before they are deleted
+	ArchivePrefix string
+
+	// ArchiveFormat is the export format used when archiving (see ExportSQL)
+	ArchiveFormat string
+}
+
+// PruneResult reports how many rows were (or, for a dry run, would be) pruned
+type PruneResult struct {
+	Summaries int64
+	Vulns     int64
+}
+
+// pruneWhere returns the conditions selecting summary and vuln rows to prune
+func pruneWhere(opts PruneOptions) (string, string) {
+	summaryWhere := "time < @cutoff"
+	vulnsWhere := "time < @cutoff"
+	if opts.KeepLatest {
+		latest := fmt.Sprintf("SELECT id FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY image, scanner ORDER BY time DESC) AS row_num FROM `%s`) WHERE row_num = 1", opts.SummaryTable)
+		summaryWhere += fmt.Sprintf(" AND id NOT IN (%s)", latest)
+		vulnsWhere += fmt.Sprintf(" AND scan_id NOT IN (%s)", latest)
+	}
+	return summaryWhere, vulnsWhere
+}
+
+// PruneSQL returns the statements run by Prune, in order. Vulns are handled
+// before summaries, since the summary table decides which vulns are kept.
+func PruneSQL(opts PruneOptions) ([]string, []bigquery.QueryParameter, error) {
+	summaryWhere, vulnsWhere := pruneWhere(opts)
+	statements := []string{}
+	if opts.ArchivePrefix != "" {
+		exportFormat, ok := exportFormats[strings.ToLower(opts.ArchiveFormat)]
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid archive format: %s", opts.ArchiveFormat)
+		}
+		if !strings.HasPrefix(opts.ArchivePrefix, "gs://") {
+			return nil, nil, fmt.Errorf("invalid archive prefix %q, expected gs://bucket/path", opts.ArchivePrefix)
+		}
+		prefix := fmt.Sprintf("%s/pruned-%s", strings.TrimSuffix(opts.ArchivePrefix, "/"), opts.OlderThan.UTC().Format("20060102T150405Z"))
+		ext := strings.ToLower(opts.ArchiveFormat)
+		statements = append(statements,
+			exportSQL(opts.VulnsTable, nil, fmt.Sprintf("%s/vulns-*.%s", prefix, ext), exportFormat, vulnsWhere),
+			exportSQL(opts.SummaryTable, nil, fmt.Sprintf("%s/summaries-*.%s", prefix, ext), exportFormat, summaryWhere))
+	}
+	statements = append(statements,
+		fmt.Sprintf("DELETE FROM `%s` WHERE %s", opts.VulnsTable, vulnsWhere),
+		fmt.Sprintf("DELETE FROM `%s` WHERE %s", opts.SummaryTable, summaryWhere))
+	params := []bigquery.QueryParameter{
+		{Name: "cutoff", Value: opts.OlderThan.UTC().Format("2006-01-02T15:04:05Z")},
+	}
+	return statements, params, nil
+}
+
+// Prune archives (optionally) and deletes old summary and vuln rows
+func Prune(ctx context.Context, client *Client, opts PruneOptions) (*PruneResult, error) {
+	statements, params, err := PruneSQL(opts)
+	if err != nil {
+		return nil, err
+	}
+	affected := []int64{}
+	for _, statement := range statements {
+		n, err := runStatement(ctx, client, statement, params)
+		if err != nil {
+			return nil, err
+		}
+		affected = append(affected, n)
+	}
+	// The two DELETE statements come last
+	return &PruneResult{Vulns: affected[len(affected)-2], Summaries: affected[len(affected)-1]}, nil
+}
+
+// PruneCount returns how many rows Prune would delete, without deleting anything
+func PruneCount(ctx context.Context, client *Client, opts PruneOptions) (*PruneResult, error) {
+	summaryWhere, vulnsWhere := pruneWhere(opts)
+	sql := fmt.Sprintf("SELECT (SELECT COUNT(*) FROM `%s` WHERE %s) AS summaries, (SELECT COUNT(*) FROM `%s` WHERE %s) AS vulns",
+		opts.SummaryTable, summaryWhere, opts.VulnsTable, vulnsWhere)
+	q := newQuery(client, sql)
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "cutoff", Value: opts.OlderThan.UTC().Format("2006-01-02T15:04:05Z")},
+	}
+	it, err := read(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	var row struct {
+		Summaries int64 `bigquery:"summaries"`
+		Vulns     int64 `bigquery:"vulns"`
+	}
+	if err := it.Next(&row); err != nil {
+		return nil, err
+	}
+	return &PruneResult{Summaries: row.Summaries, Vulns: row.Vulns}, nil
+}
@@ -0,0 +1,448 @@
+package query
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/chainguard-dev/rumble/pkg/types"
+	"google.golang.org/api/iterator"
+)
+
+// SummaryColumns are the columns of the summary table returned by queries.
+// The raw scanner output is deliberately left out since it can be very large.
+var SummaryColumns = []string{
+	"id", "image", "digest", "scanner", "scanner_version", "scanner_db_version", "time", "created",
+	"low_cve_count", "med_cve_count", "high_cve_count", "crit_cve_count",
+	"negligible_cve_count", "unknown_cve_count", "tot_cve_count", "success",
+}
+
+// SummaryFields returns pointers to the fields of s in SummaryColumns order,
+// for scanning rows from other SQL databases (see pkg/mirror)
+func SummaryFields(s *types.ImageScanSummary) []interface{} {
+	return []interface{}{
+		&s.ID, &s.Image, &s.Digest, &s.Scanner, &s.ScannerVersion, &s.ScannerDbVersion, &s.Time, &s.Created,
+		&s.LowCveCount, &s.MedCveCount, &s.HighCveCount, &s.CritCveCount,
+		&s.NegligibleCveCount, &s.UnknownCveCount, &s.TotCveCount, &s.Success,
+	}
+}
+
+// Severity count columns, ordered from most to least severe
+var severityColumns = []struct {
+	severity string
+	column   string
+}{
+	{"critical", "crit_cve_count"},
+	{"high", "high_cve_count"},
+	{"medium", "med_cve_count"},
+	{"low", "low_cve_count"},
+	{"negligible", "negligible_cve_count"},
+	{"unknown", "unknown_cve_count"},
+}
+
+// Filter narrows down the scans returned from the summary table
+type Filter struct {
+	// ID, if set, only matches the scan with this scan ID
+	ID string
+
+	// Image, if set, only matches scans of this exact image reference
+	Image string
+
+	// Repository, if set, only matches scans of this repository with the
+	// tag Tag, which is empty for scans by digest without one (see
+	// types.ImageScanSummary). Unlike Image, it matches scans of the same
+	// tag whether they were by tag or by digest.
+	Repository string
+	Tag        string
+
+	// Scanner, if set, only matches scans by this scanner ("grype" or "trivy")
+	Scanner string
+
+	// ExcludeDigest, if set, skips scans of this digest
+	ExcludeDigest string
+
+	// Since, if set, only matches scans at or after this time
+	Since time.Time
+
+	// Severity, if set, only matches scans with at least one CVE at this
+	// severity or above
+	Severity string
+
+	// LatestOnly only matches the most recent scan of each image/scanner pair
+	LatestOnly bool
+
+	// OrderBy, if set, orders results by the count for this severity (or
+	// "total"), largest first, instead of by time
+	OrderBy string
+
+	// Limit, if non-zero, caps the number of results
+	Limit int
+
+	// IncludeMatches also fetches the raw grype output so each Result
+	// carries its parsed matches
+	IncludeMatches bool
+
+	// partitioned limits a Since query to the partitions it can match, for
+	// an ingestion time partitioned BigQuery table
+	partitioned bool
+}
+
+// Result is a single scan returned from the summary table
+type Result struct {
+	Summary *types.ImageScanSummary `json:"summary"`
+
+	// Matches is only populated when Filter.IncludeMatches is set. The raw
+	// JSON it was parsed from is not kept on Summary.
+	Matches []types.GrypeScanOutputMatches `json:"matches,omitempty"`
+}
+
+// SummarySQL returns the SQL and parameters for querying the summary table
+func SummarySQL(table string, filter Filter) (string, []bigquery.QueryParameter, error) {
+	// With LatestOnly, the scans are ranked before the filters in having
+	// are applied, so that they match the latest scan of each image rather
+	// than the latest scan that matches. Filters on the image and scanner,
+	// and Since, pick the same latest scans either way, and stay in where,
+	// where they can prune what's ranked.
+	where, having := []string{}, []string{}
+	params := []bigquery.QueryParameter{}
+	if filter.ID != "" {
+		having = append(having, "id = @id")
+		params = append(params, bigquery.QueryParameter{Name: "id", Value: filter.ID})
+	}
+	if filter.Image != "" {
+		where = append(where, "image = @image")
+		params = append(params, bigquery.QueryParameter{Name: "image", Value: filter.Image})
+	}
+	if filter.Repository != "" {
+		where = append(where, "repository = @repository", "IFNULL(tag, '') = @tag")
+		params = append(params, bigquery.QueryParameter{Name: "repository", Value: filter.Repository}, bigquery.QueryParameter{Name: "tag", Value: filter.Tag})
+	}
+	if filter.Scanner != "" {
+		where = append(where, "scanner = @scanner")
+		params = append(params, bigquery.QueryParameter{Name: "scanner", Value: filter.Scanner})
+	}
+	if filter.ExcludeDigest != "" {
+		having = append(having, "digest != @exclude_digest")
+		params = append(params, bigquery.QueryParameter{Name: "exclude_digest", Value: filter.ExcludeDigest})
+	}
+	if !filter.Since.IsZero() {
+		// The time column is a string, but its fixed format sorts chronologically
+		where = append(where, "time >= @since")
+		params = append(params, bigquery.QueryParameter{Name: "since", Value: filter.Since.UTC().Format("2006-01-02T15:04:05Z")})
+		if filter.partitioned {
+			where = append(where, partitionSince)
+		}
+	}
+	if filter.Severity != "" {
+		columns, err := severityColumnsAtOrAbove(filter.Severity)
+		if err != nil {
+			return "", nil, err
+		}
+		having = append(having, "("+strings.Join(columns, " + ")+") > 0")
+	}
+	if !filter.LatestOnly {
+		where, having = append(where, having...), nil
+	}
+
+	columns := SummaryColumns
+	if filter.IncludeMatches {
+		columns = append(append([]string{}, SummaryColumns...), "raw_grype_json")
+	}
+	// The SQL is kept to what BigQuery, SQLite and PostgreSQL support (see
+	// pkg/mirror and pkg/postgres), hence an aliased subquery rather than
+	// QUALIFY for LatestOnly
+	var sb strings.Builder
+	if filter.LatestOnly {
+		fmt.Fprintf(&sb, "SELECT %s FROM (SELECT %s, ROW_NUMBER() OVER (PARTITION BY image, scanner ORDER BY time DESC) AS row_num FROM `%s`",
+			strings.Join(columns, ", "), strings.Join(columns, ", "), table)
+	} else {
+		fmt.Fprintf(&sb, "SELECT %s FROM `%s`", strings.Join(columns, ", "), table)
+	}
+	if len(where) > 0 {
+		fmt.Fprintf(&sb, " WHERE %s", strings.Join(where, " AND "))
+	}
+	if filter.LatestOnly {
+		sb.WriteString(") latest WHERE " + strings.Join(append([]string{"row_num = 1"}, having...), " AND "))
+	}
+	if filter.OrderBy != "" {
+		column, err := countColumn(filter.OrderBy)
+		if err != nil {
+			return "", nil, err
+		}
+		fmt.Fprintf(&sb, " ORDER BY %s DESC, image", column)
+	} else {
+		sb.WriteString(" ORDER BY time DESC, image")
+	}
+	if filter.Limit > 0 {
+		fmt.Fprintf(&sb, " LIMIT %d", filter.Limit)
+	}
+	return sb.String(), params, nil
+}
+
+// countColumn returns the summary column holding the count for a severity
+func countColumn(severity string) (string, error) {
+	if strings.ToLower(severity) == "total" {
+		return "tot_cve_count", nil
+	}
+	for _, sc := range severityColumns {
+		if sc.severity == strings.ToLower(severity) {
+			return sc.column, nil
+		}
+	}
+	return "", fmt.Errorf("invalid severity: %s", severity)
+}
+
+func severityColumnsAtOrAbove(severity string) ([]string, error) {
+	columns := []string{}
+	for _, sc := range severityColumns {
+		columns = append(columns, sc.column)
+		if sc.severity == strings.ToLower(severity) {
+			return columns, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid severity: %s", severity)
+}
+
+// Iterator streams the results of a query against the summary table
+type Iterator struct {
+	it             *bigquery.RowIterator
+	job            *bigquery.Job
+	includeMatches bool
+	paged          bool
+	done           bool
+}
+
+// Page holds the options for reading a query's results a page at a time
+type Page struct {
+	// Size is the maximum number of results per page. Zero means results
+	// are not paged, and the iterator returns every result.
+	Size int
+
+	// Token resumes from the page after the one that returned it (see
+	// Iterator.NextPageToken). The query job from that call is reused
+	// rather than running the query again.
+	Token string
+}
+
+// pageToken identifies the query job along with the position in its results
+type pageToken struct {
+	JobID    string `json:"job"`
+	Location string `json:"location"`
+	Token    string `json:"token"`
+}
+
+// Query runs a query against the summary table, returning an iterator over
+// the matching scans. With a page token, results are read from the job that
+// produced the token instead of running the query again.
+func Query(ctx context.Context, client *Client, table string, filter Filter, page Page) (*Iterator, error) {
+	var job *bigquery.Job
+	var token pageToken
+	if page.Token != "" {
+		b, err := base64.RawURLEncoding.DecodeString(page.Token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page token: %w", err)
+		}
+		if err := json.Unmarshal(b, &token); err != nil {
+			return nil, fmt.Errorf("invalid page token: %w", err)
+		}
+		job, err = client.JobFromIDLocation(ctx, token.JobID, token.Location)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if !filter.Since.IsZero() {
+			partitioned, err := ingestionPartitioned(ctx, client, table)
+			if err != nil {
+				return nil, err
+			}
+			filter.partitioned = partitioned
+		}
+		sql, params, err := SummarySQL(table, filter)
+		if err != nil {
+			return nil, err
+		}
+		q := newQuery(client, sql)
+		q.Parameters = params
+		job, _, err = run(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+	}
+	it, err := job.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if page.Size > 0 {
+		it.PageInfo().MaxSize = page.Size
+		it.PageInfo().Token = token.Token
+	}
+	return &Iterator{it: it, job: job, includeMatches: filter.IncludeMatches, paged: page.Size > 0}, nil
+}
+
+// Next returns the next result, or iterator.Done when there are no more
+// (including at the end of the current page when paging)
+func (i *Iterator) Next() (*Result, error) {
+	if i.done {
+		return nil, iterator.Done
+	}
+	var summary types.ImageScanSummary
+	if err := i.it.Next(&summary); err != nil {
+		if err == iterator.Done {
+			i.done = true
+		}
+		return nil, err
+	}
+	// Stop once the page fetched from BigQuery has been used up, so that
+	// the page token lines up with the next unread row
+	if i.paged && i.it.PageInfo().Remaining() == 0 {
+		i.done = true
+	}
+	result := &Result{Summary: &summary}
+	if i.includeMatches && summary.RawGrypeJSON != "" {
+		var output types.GrypeScanOutput
+		if err := json.Unmarshal([]byte(summary.RawGrypeJSON), &output); err != nil {
+			return nil, fmt.Errorf("parsing raw grype output for scan %s: %w", summary.ID, err)
+		}
+		result.Matches = output.Matches
+		summary.RawGrypeJSON = ""
+	}
+	return result, nil
+}
+
+// NextPageToken returns the token for the page after the rows returned so
+// far, or an empty string if there are no more pages
+func (i *Iterator) NextPageToken() string {
+	if !i.paged || i.it.PageInfo().Token == "" {
+		return ""
+	}
+	b, _ := json.Marshal(pageToken{
+		JobID:    i.job.ID(),
+		Location: i.job.Location(),
+		Token:    i.it.PageInfo().Token,
+	})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Summaries runs a query against the summary table and returns all matching scans
+func Summaries(ctx context.Context, client *Client, table string, filter Filter) ([]*types.ImageScanSummary, error) {
+	it, err := Query(ctx, client, table, filter, Page{})
+	if err != nil {
+		return nil, err
+	}
+	summaries := []*types.ImageScanSummary{}
+	for {
+		result, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, result.Summary)
+	}
+	return summaries, nil
+}
+
+// VulnColumns are the columns of the vulns table returned by queries
+var VulnColumns = []string{"id", "scan_id", "name", "installed", "fixed_in", "type", "vulnerability", "severity", "time"}
+
+// VulnFields returns pointers to the fields of v in VulnColumns order
+func VulnFields(v *types.Vuln) []interface{} {
+	return []interface{}{
+		&v.ID, &v.ScanID, &v.Name, &v.Installed, &v.FixedIn, &v.Type, &v.Vulnerability, &v.Severity, &v.Time,
+	}
+}
+
+// Vulns returns all rows of the vulns table with a time at or after since.
+// If the summary table has delta uploads, it instead returns the full vulns
+// of each scan since then (see stateSQL), as if every scan was full.
+func Vulns(ctx context.Context, client *Client, table string, vulnsTable string, since time.Time) ([]*types.Vuln, error) {
+	delta, err := hasUploadMode(ctx, client, table)
+	if err != nil {
+		return nil, err
+	}
+	var stmt string
+	if delta {
+		// The rows of base scans may be from before since
+		scans := fmt.Sprintf("SELECT id FROM `%s` WHERE time >= @since", table)
+		stmt = stateSQL(table, vulnsTable, scans, "") + fmt.Sprintf("SELECT %s FROM scan_state", strings.Join(VulnColumns, ", "))
+	} else {
+		partitioned, err := ingestionPartitioned(ctx, client, vulnsTable)
+		if err != nil {
+			return nil, err
+		}
+		where := "time >= @since"
+		if partitioned {
+			where += " AND " + partitionSince
+		}
+		stmt = fmt.Sprintf("SELECT %s FROM `%s` WHERE %s", strings.Join(VulnColumns, ", "), vulnsTable, where)
+	}
+	q := newQuery(client, stmt)
+	q.Parameters = []bigquery.QueryParameter{{Name: "since", Value: since.UTC().Format("2006-01-02T15:04:05Z")}}
+	return readVulns(ctx, q)
+}
+
+// ScanVulns returns the vulns of a single scan, from the rows of the vulns
+// table for it and, if it was recorded as a delta, its base scans (see State)
+func ScanVulns(ctx context.Context, client *Client, table string, vulnsTable string, scanID string) ([]*types.Vuln, error) {
+	state, err := State(ctx, client, table, vulnsTable, scanID)
+	if err != nil {
+		return nil, err
+	}
+	return state.Vulns, nil
+}
+
+// ScanVulnsSQL returns the SQL and parameters for querying the rows of the
+// vulns table for a single scan, ordered by vulnerability and package
+func ScanVulnsSQL(table string, scanID string) (string, []bigquery.QueryParameter) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE scan_id = @scan_id ORDER BY vulnerability, name", strings.Join(VulnColumns, ", "), table)
+	return stmt, []bigquery.QueryParameter{{Name: "scan_id", Value: scanID}}
+}
+
+func readVulns(ctx context.Context, q *bigquery.Query) ([]*types.Vuln, error) {
+	it, err := read(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	vulns := []*types.Vuln{}
+	for {
+		var vuln types.Vuln
+		err := it.Next(&vuln)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		vulns = append(vulns, &vuln)
+	}
+	return vulns, nil
+}
+
+// ParseSince parses either a relative age (a Go duration such as "36h", or a
+// number of days such as "30d") or an absolute date/time ("2006-01-02" or
+// RFC 3339) into the earliest time to include
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a duration (e.g. \"30d\", \"12h\") or a date (e.g. \"2006-01-02\")", s)
+}
@@ -0,0 +1,463 @@
+package query
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"github.com/chainguard-dev/rumble/pkg/types"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2023, 6, 22, 2, 38, 46, 0, time.UTC)
+	tests := map[string]time.Time{
+		"":                     {},
+		"30d":                  now.AddDate(0, 0, -30),
+		"36h":                  now.Add(-36 * time.Hour),
+		"2023-01-02":           time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		"2023-01-02T03:04:05Z": time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	for input, expected := range tests {
+		actual, err := ParseSince(input, now)
+		if err != nil {
+			t.Errorf("expected no error on ParseSince(%q), got %v", input, err)
+		}
+		if !actual.Equal(expected) {
+			t.Errorf("ParseSince(%q) is %s, wanted %s", input, actual, expected)
+		}
+	}
+	if _, err := ParseSince("last tuesday", now); err == nil {
+		t.Errorf("expected error on ParseSince(\"last tuesday\"), got nil")
+	}
+}
+
+func TestSummarySQL(t *testing.T) {
+	sql, params, err := SummarySQL("p.d.t", Filter{
+		Image:      "cgr.dev/chainguard/static:latest",
+		Severity:   "high",
+		LatestOnly: true,
+	})
+	if err != nil {
+		t.Errorf("expected no error on SummarySQL(), got %v", err)
+	}
+	for _, expected := range []string{
+		"FROM `p.d.t`",
+		"image = @image",
+		"ROW_NUMBER() OVER (PARTITION BY image, scanner ORDER BY time DESC) AS row_num",
+		// The severity filter applies to the latest scans, after ranking
+		") latest WHERE row_num = 1 AND (crit_cve_count + high_cve_count) > 0",
+	} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("expected SQL to contain %q, got %s", expected, sql)
+		}
+	}
+	if len(params) != 1 {
+		t.Errorf("got %d params, wanted 1", len(params))
+	}
+	sql, _, err = SummarySQL("p.d.t", Filter{LatestOnly: true, OrderBy: "critical", Limit: 20})
+	if err != nil {
+		t.Errorf("expected no error on SummarySQL(), got %v", err)
+	}
+	if !strings.HasSuffix(sql, "ORDER BY crit_cve_count DESC, image LIMIT 20") {
+		t.Errorf("expected SQL to be ordered by critical count with a limit, got %s", sql)
+	}
+	sql, _, err = SummarySQL("p.d.t", Filter{Image: "cgr.dev/chainguard/static:latest", ExcludeDigest: "sha256:abc", Limit: 1})
+	if err != nil {
+		t.Errorf("expected no error on SummarySQL(), got %v", err)
+	}
+	if !strings.Contains(sql, "digest != @exclude_digest") || !strings.HasSuffix(sql, "ORDER BY time DESC, image LIMIT 1") {
+		t.Errorf("expected SQL to skip the digest and return the latest scan, got %s", sql)
+	}
+	sql, params, err = SummarySQL("p.d.t", Filter{Repository: "cgr.dev/chainguard/static", Tag: "", ExcludeDigest: "sha256:abc", Limit: 1})
+	if err != nil {
+		t.Errorf("expected no error on SummarySQL(), got %v", err)
+	}
+	if !strings.Contains(sql, "WHERE repository = @repository AND IFNULL(tag, '') = @tag AND digest != @exclude_digest") || len(params) != 3 {
+		t.Errorf("expected SQL to match the repository and (empty) tag, got %s", sql)
+	}
+	sql, params, err = SummarySQL("p.d.t", Filter{ID: "testing123"})
+	if err != nil {
+		t.Errorf("expected no error on SummarySQL(), got %v", err)
+	}
+	if !strings.Contains(sql, "WHERE id = @id") || len(params) != 1 || params[0].Value != "testing123" {
+		t.Errorf("expected SQL to match the scan ID, got %s", sql)
+	}
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	sql, _, err = SummarySQL("p.d.t", Filter{Since: since, partitioned: true})
+	if err != nil {
+		t.Errorf("expected no error on SummarySQL(), got %v", err)
+	}
+	if !strings.Contains(sql, "time >= @since AND "+partitionSince) {
+		t.Errorf("expected SQL to only read the partitions since then, got %s", sql)
+	}
+	if sql, _, _ = SummarySQL("p.d.t", Filter{Since: since}); strings.Contains(sql, "_PARTITIONTIME") {
+		t.Errorf("expected SQL of an unpartitioned table not to filter partitions, got %s", sql)
+	}
+	if _, _, err := SummarySQL("p.d.t", Filter{Severity: "severe"}); err == nil {
+		t.Errorf("expected error on invalid severity, got nil")
+	}
+}
+
+func TestExportSQL(t *testing.T) {
+	since := time.Date(2023, 6, 22, 0, 0, 0, 0, time.UTC)
+	sql, params, err := ExportSQL("p.d.t", []string{"id", "image"}, "gs://bucket/rumble/summaries-*.parquet", "parquet", since)
+	if err != nil {
+		t.Errorf("expected no error on ExportSQL(), got %v", err)
+	}
+	expected := "EXPORT DATA OPTIONS (uri = 'gs://bucket/rumble/summaries-*.parquet', format = 'PARQUET', overwrite = true) AS SELECT id, image FROM `p.d.t` WHERE time >= @since ORDER BY time"
+	if sql != expected {
+		t.Errorf("got SQL %s, wanted %s", sql, expected)
+	}
+	if len(params) != 1 {
+		t.Errorf("got %d params, wanted 1", len(params))
+	}
+	for _, uri := range []string{"/tmp/out-*.parquet", "gs://bucket/out.parquet"} {
+		if _, _, err := ExportSQL("p.d.t", nil, uri, "parquet", since); err == nil {
+			t.Errorf("expected error on ExportSQL() with URI %q, got nil", uri)
+		}
+	}
+	if _, _, err := ExportSQL("p.d.t", nil, "gs://bucket/out-*.xml", "xml", since); err == nil {
+		t.Errorf("expected error on ExportSQL() with format xml, got nil")
+	}
+}
+
+func TestAnonymizedExportSQL(t *testing.T) {
+	since := time.Date(2023, 6, 22, 0, 0, 0, 0, time.UTC)
+	summarySQL, vulnsSQL, params, err := AnonymizedExportSQL("p.d.summaries", "p.d.vulns", []string{"cgr.dev/chainguard/*"}, "gs://bucket/public-summaries-*.csv", "gs://bucket/public-vulns-*.csv", "csv", since, false)
+	if err != nil {
+		t.Fatalf("expected no error on AnonymizedExportSQL(), got %v", err)
+	}
+	for _, sql := range []string{summarySQL, vulnsSQL} {
+		if !strings.Contains(sql, anonymizedImage+" AS image") {
+			t.Errorf("expected the image's registry to be stripped, got SQL %s", sql)
+		}
+		if !strings.Contains(sql, "AND success AND (image LIKE 'cgr.dev/chainguard/%')") {
+			t.Errorf("expected only the public images, got SQL %s", sql)
+		}
+		for _, column := range []string{"labels", "raw_", "path", "s.id", "v.scan_id,"} {
+			if strings.Contains(strings.SplitN(sql, " WHERE ", 2)[0], column) {
+				t.Errorf("expected no %s in the exported columns, got SQL %s", column, sql)
+			}
+		}
+	}
+	if !strings.Contains(summarySQL, "SELECT * EXCEPT (id) FROM") {
+		t.Errorf("expected the scan IDs to be left out, got SQL %s", summarySQL)
+	}
+	if !strings.Contains(vulnsSQL, "FROM `p.d.vulns` v JOIN") {
+		t.Errorf("expected the vulns of the public scans, got SQL %s", vulnsSQL)
+	}
+	if len(params) != 1 {
+		t.Errorf("got %d params, wanted 1", len(params))
+	}
+	if _, _, _, err := AnonymizedExportSQL("p.d.summaries", "p.d.vulns", nil, "gs://bucket/public-summaries-*.csv", "", "csv", since, false); err == nil {
+		t.Errorf("expected error on AnonymizedExportSQL() without images, got nil")
+	}
+
+	// Delta scans only have rows for their changes, so the vulns are
+	// rebuilt from their base scans
+	_, vulnsSQL, _, err = AnonymizedExportSQL("p.d.summaries", "p.d.vulns", []string{"cgr.dev/chainguard/*"}, "gs://bucket/public-summaries-*.csv", "gs://bucket/public-vulns-*.csv", "csv", since, true)
+	if err != nil {
+		t.Fatalf("expected no error on AnonymizedExportSQL(), got %v", err)
+	}
+	for _, expected := range []string{
+		"overwrite = true) AS WITH RECURSIVE scans AS (SELECT id, " + anonymizedImage + " AS image",
+		"JOIN `p.d.vulns` r ON r.scan_id = c.member_id",
+		"change != 'removed'",
+		"FROM scan_state v JOIN scans s ON v.scan_id = s.id",
+	} {
+		if !strings.Contains(vulnsSQL, expected) {
+			t.Errorf("expected SQL to contain %q, got %s", expected, vulnsSQL)
+		}
+	}
+}
+
+func TestPruneSQL(t *testing.T) {
+	statements, params, err := PruneSQL(PruneOptions{
+		SummaryTable:  "p.d.summaries",
+		VulnsTable:    "p.d.vulns",
+		OlderThan:     time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeepLatest:    true,
+		ArchivePrefix: "gs://bucket/archive",
+		ArchiveFormat: "parquet",
+	})
+	if err != nil {
+		t.Errorf("expected no error on PruneSQL(), got %v", err)
+	}
+	if len(statements) != 4 {
+		t.Fatalf("got %d statements, wanted 4", len(statements))
+	}
+	for i, prefix := range []string{
+		"EXPORT DATA OPTIONS (uri = 'gs://bucket/archive/pruned-20230101T000000Z/vulns-*.parquet'",
+		"EXPORT DATA OPTIONS (uri = 'gs://bucket/archive/pruned-20230101T000000Z/summaries-*.parquet'",
+		"DELETE FROM `p.d.vulns` WHERE time < @cutoff AND scan_id NOT IN (",
+		"DELETE FROM `p.d.summaries` WHERE time < @cutoff AND id NOT IN (",
+	} {
+		if !strings.HasPrefix(statements[i], prefix) {
+			t.Errorf("expected statement %d to start with %q, got %s", i, prefix, statements[i])
+		}
+	}
+	if len(params) != 1 || params[0].Value != "2023-01-01T00:00:00Z" {
+		t.Errorf("got params %v, wanted a single cutoff param", params)
+	}
+}
+
+func TestSearchSQL(t *testing.T) {
+	sql, params, err := SearchSQL("p.d.t", "p.d.vulns", Search{CVE: "CVE-2024-1234"})
+	if err != nil {
+		t.Errorf("expected no error on SearchSQL(), got %v", err)
+	}
+	for _, expected := range []string{
+		"ROW_NUMBER() OVER (PARTITION BY image, scanner ORDER BY time DESC) AS row_num FROM `p.d.t`",
+		"JOIN `p.d.vulns` r ON r.scan_id = s.id",
+		"WHERE s.row_num = 1 AND r.vulnerability = @cve",
+	} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("expected SQL to contain %q, got %s", expected, sql)
+		}
+	}
+	if len(params) != 1 {
+		t.Errorf("got %d params, wanted 1", len(params))
+	}
+	sql, _, err = SearchSQL("p.d.t", "p.d.packages", Search{Package: "openssl", Packages: true})
+	if err != nil {
+		t.Errorf("expected no error on SearchSQL(), got %v", err)
+	}
+	if !strings.Contains(sql, "r.version") || !strings.Contains(sql, "r.name = @package") {
+		t.Errorf("expected SQL to search the packages table, got %s", sql)
+	}
+	if _, _, err := SearchSQL("p.d.t", "p.d.packages", Search{CVE: "CVE-2024-1234", Packages: true}); err == nil {
+		t.Errorf("expected error on CVE search of the packages table, got nil")
+	}
+	if _, _, err := SearchSQL("p.d.t", "p.d.vulns", Search{}); err == nil {
+		t.Errorf("expected error on empty search, got nil")
+	}
+}
+
+func TestSearchSQLDelta(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "rumble.db"))
+	if err != nil {
+		t.Fatalf("expected no error on sql.Open(), got %v", err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		"CREATE TABLE summaries (id TEXT, image TEXT, scanner TEXT, digest TEXT, time TEXT, upload_mode TEXT, base_scan_id TEXT)",
+		"CREATE TABLE vulns (id TEXT, scan_id TEXT, name TEXT, installed TEXT, fixed_in TEXT, type TEXT, vulnerability TEXT, severity TEXT, time TEXT, change TEXT)",
+		// Image a has a full scan and two deltas on top of it, and image b
+		// was scanned before delta uploads
+		`INSERT INTO summaries VALUES
+			('a1', 'a', 'grype', 'sha256:a1', '2024-01-01T00:00:00Z', 'full', ''),
+			('a2', 'a', 'grype', 'sha256:a2', '2024-01-02T00:00:00Z', 'delta', 'a1'),
+			('a3', 'a', 'grype', 'sha256:a3', '2024-01-03T00:00:00Z', 'delta', 'a2'),
+			('b1', 'b', 'grype', 'sha256:b1', '2024-01-01T00:00:00Z', NULL, NULL)`,
+		`INSERT INTO vulns VALUES
+			('v1', 'a1', 'openssl', '3.1.0', '', 'apk', 'CVE-2024-0001', 'High', '2024-01-01T00:00:00Z', NULL),
+			('v2', 'a1', 'busybox', '1.36.0', '', 'apk', 'CVE-2024-0002', 'Low', '2024-01-01T00:00:00Z', NULL),
+			('v3', 'a2', 'openssl', '3.1.0', '', 'apk', 'CVE-2024-0001', 'High', '2024-01-02T00:00:00Z', 'removed'),
+			('v4', 'a2', 'zlib', '1.3', '', 'apk', 'CVE-2024-0003', 'Medium', '2024-01-02T00:00:00Z', 'added'),
+			('v5', 'a3', 'openssl', '3.1.1', '', 'apk', 'CVE-2024-0004', 'Critical', '2024-01-03T00:00:00Z', 'added'),
+			('v6', 'b1', 'openssl', '3.0.0', '', 'apk', 'CVE-2024-0001', 'High', '2024-01-01T00:00:00Z', NULL)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("expected no error creating the tables, got %v", err)
+		}
+	}
+
+	search := func(s Search) []string {
+		stmt, params, err := searchSQL("summaries", "vulns", s, true)
+		if err != nil {
+			t.Fatalf("expected no error on searchSQL(), got %v", err)
+		}
+		args := make([]interface{}, len(params))
+		for i, param := range params {
+			args[i] = sql.Named(param.Name, param.Value)
+		}
+		rows, err := db.Query(stmt, args...)
+		if err != nil {
+			t.Fatalf("expected no error running %s, got %v", stmt, err)
+		}
+		defer rows.Close()
+		found := []string{}
+		for rows.Next() {
+			var r SearchResult
+			if err := rows.Scan(&r.Image, &r.Scanner, &r.Digest, &r.Time, &r.ScanID, &r.Package, &r.Version, &r.Type, &r.Vulnerability, &r.FixedIn); err != nil {
+				t.Fatalf("expected no error on rows.Scan(), got %v", err)
+			}
+			found = append(found, r.ScanID+" "+r.Vulnerability)
+		}
+		return found
+	}
+	for _, tc := range []struct {
+		search   Search
+		expected string
+	}{
+		// Removed by the first delta
+		{Search{CVE: "CVE-2024-0001"}, "b1 CVE-2024-0001"},
+		// Carried over from the full scan and the first delta
+		{Search{CVE: "CVE-2024-0002"}, "a3 CVE-2024-0002"},
+		{Search{CVE: "CVE-2024-0003"}, "a3 CVE-2024-0003"},
+		{Search{Package: "openssl"}, "a3 CVE-2024-0004, b1 CVE-2024-0001"},
+		{Search{Package: "busybox", Since: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}, "a3 CVE-2024-0002"},
+	} {
+		if found := strings.Join(search(tc.search), ", "); found != tc.expected {
+			t.Errorf("expected search %+v to find %s, got %s", tc.search, tc.expected, found)
+		}
+	}
+}
+
+func TestDelta(t *testing.T) {
+	vuln := func(cve string, installed string) *types.Vuln {
+		return &types.Vuln{ScanID: "base", Vulnerability: cve, Name: "openssl", Installed: installed, Type: "apk"}
+	}
+	base := []*types.Vuln{vuln("CVE-2024-0001", "3.1.0"), vuln("CVE-2024-0002", "3.1.0")}
+	vulns := []*types.Vuln{vuln("CVE-2024-0002", "3.1.0"), vuln("CVE-2024-0003", "3.1.0")}
+	for _, v := range vulns {
+		v.ScanID = "scan"
+	}
+
+	rows := Delta(base, vulns, "scan", "2024-01-02T00:00:00Z")
+	if len(rows) != 2 || rows[0].Vulnerability != "CVE-2024-0003" || rows[0].Change != ChangeAdded || rows[1].Vulnerability != "CVE-2024-0001" || rows[1].Change != ChangeRemoved {
+		t.Fatalf("expected CVE-2024-0003 added and CVE-2024-0001 removed, got %+v", rows)
+	}
+	if rows[1].ScanID != "scan" || rows[1].ID == "" || base[0].ScanID != "base" || vulns[1].Change != "" {
+		t.Errorf("expected the rows to be copies for the new scan, got %+v", rows[1])
+	}
+
+	// Applying the delta to the base gives back the scan's vulns
+	state := ApplyDelta(base, rows)
+	if len(state) != 2 || state[0].Vulnerability != "CVE-2024-0002" || state[1].Vulnerability != "CVE-2024-0003" || state[1].Change != "" {
+		t.Errorf("expected CVE-2024-0002 and CVE-2024-0003, got %+v", state)
+	}
+
+	// Upgrading a package without fixing the vuln is still a change
+	rows = Delta(base[:1], []*types.Vuln{vuln("CVE-2024-0001", "3.1.1")}, "scan", "2024-01-02T00:00:00Z")
+	if len(rows) != 2 {
+		t.Errorf("expected the upgrade to be recorded as a change, got %+v", rows)
+	}
+	if rows := Delta(base, base, "scan", "2024-01-02T00:00:00Z"); len(rows) != 0 {
+		t.Errorf("expected no changes, got %+v", rows)
+	}
+}
+
+func TestViews(t *testing.T) {
+	views := Views("p.d.t")
+	names := []string{}
+	for _, view := range views {
+		names = append(names, view.Name)
+		if !strings.Contains(view.SQL, "FROM `p.d.t`") {
+			t.Errorf("expected view %s to read the summary table, got %s", view.Name, view.SQL)
+		}
+		if strings.Contains(view.SQL, "raw_") || strings.Contains(view.SQL, "SELECT *") {
+			t.Errorf("expected view %s to only select known columns, got %s", view.Name, view.SQL)
+		}
+	}
+	if strings.Join(names, ",") != "scans,latest_scans,daily_severity_counts,latest_severity_counts" {
+		t.Errorf("got views %v", names)
+	}
+	if sql := views[3].SQL; !strings.Contains(sql, "WHERE row_num = 1") || !strings.Contains(sql, "STRUCT('critical' AS severity, crit_cve_count AS cve_count)") {
+		t.Errorf("expected the latest scans' counts by severity, got %s", sql)
+	}
+}
+
+func TestReaderEntry(t *testing.T) {
+	entry, err := readerEntry("serviceAccount:dash@p.iam.gserviceaccount.com")
+	if err != nil {
+		t.Fatalf("expected no error on readerEntry(), got %v", err)
+	}
+	if entry.Role != bigquery.ReaderRole || entry.EntityType != bigquery.UserEmailEntity || entry.Entity != "dash@p.iam.gserviceaccount.com" {
+		t.Errorf("expected read access for the service account, got %+v", entry)
+	}
+	if !hasAccess([]*bigquery.AccessEntry{{Role: bigquery.ReaderRole, EntityType: bigquery.UserEmailEntity, Entity: "Dash@p.iam.gserviceaccount.com"}}, entry) {
+		t.Errorf("expected an existing grant to be found")
+	}
+	for _, invalid := range []string{"dash@p.iam.gserviceaccount.com", "team:security", "group:"} {
+		if _, err := readerEntry(invalid); err == nil {
+			t.Errorf("expected error on readerEntry(%q), got nil", invalid)
+		}
+	}
+}
+
+func TestRollupSQL(t *testing.T) {
+	rollups := Rollups("scans_rollup_")
+	if len(rollups) != 3 || rollups[2].Table != "scans_rollup_teams" {
+		t.Fatalf("got rollups %+v", rollups)
+	}
+	teams := []config.Team{{Name: "platform", Images: []string{"cgr.dev/chainguard/static_*"}}}
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	create, merge, params := RollupSQL("p.d.scans", rollups[2], teams, since, true)
+	if !strings.HasPrefix(create, "CREATE TABLE IF NOT EXISTS `p.d.scans_rollup_teams` (day DATE, team STRING, scanner STRING, images INT64,") || !strings.HasSuffix(create, "PARTITION BY day CLUSTER BY team, scanner") {
+		t.Errorf("expected the rollup table to be created in the dataset, got %s", create)
+	}
+	for _, expected := range []string{
+		"MERGE `p.d.scans_rollup_teams` T USING",
+		`WHEN image LIKE 'cgr.dev/chainguard/static\\_%' THEN 'platform' ELSE '' END AS team`,
+		"FROM `p.d.scans` WHERE time >= @since AND success AND " + partitionSince,
+		"ON T.day = S.day AND T.team = S.team AND T.scanner = S.scanner",
+		"WHEN NOT MATCHED BY SOURCE AND T.day >= DATE(TIMESTAMP(@since)) THEN DELETE",
+	} {
+		if !strings.Contains(merge, expected) {
+			t.Errorf("expected MERGE to contain %q, got %s", expected, merge)
+		}
+	}
+	if len(params) != 1 || params[0].Value != "2024-01-02T00:00:00Z" {
+		t.Errorf("expected the whole first day to be recomputed, got %+v", params)
+	}
+}
+
+func TestMergeSchema(t *testing.T) {
+	existing := bigquery.Schema{
+		{Name: "id", Type: bigquery.StringFieldType, Required: true},
+		{Name: "secret_count", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "critical", Type: bigquery.IntegerFieldType, Required: true},
+		}},
+	}
+	wanted, err := bigquery.InferSchema(struct {
+		ID          string `bigquery:"id"`
+		NvdSeverity string `bigquery:"nvd_severity"`
+		SecretCount struct {
+			Critical int `bigquery:"critical"`
+			High     int `bigquery:"high"`
+		} `bigquery:"secret_count"`
+		Tags []string `bigquery:"tags"`
+	}{})
+	if err != nil {
+		t.Fatalf("expected no error on InferSchema(), got %v", err)
+	}
+	merged, added := mergeSchema(existing, wanted, "")
+	if strings.Join(added, ",") != "nvd_severity,secret_count.high,tags" {
+		t.Errorf("got added columns %v, wanted nvd_severity, secret_count.high and tags", added)
+	}
+	if len(merged) != 4 || merged[0].Name != "id" || !merged[0].Required {
+		t.Fatalf("expected the existing columns to be kept as they are, got %+v", merged)
+	}
+	if nested := merged[1].Schema; len(nested) != 2 || nested[1].Name != "high" || nested[1].Required {
+		t.Errorf("expected a NULLABLE high column added to secret_count, got %+v", nested)
+	}
+	if merged[2].Name != "nvd_severity" || merged[2].Required {
+		t.Errorf("expected a NULLABLE nvd_severity column, got %+v", merged[2])
+	}
+	if merged[3].Name != "tags" || !merged[3].Repeated {
+		t.Errorf("expected a REPEATED tags column, got %+v", merged[3])
+	}
+	if len(existing[1].Schema) != 1 {
+		t.Errorf("expected the existing schema not to be modified")
+	}
+	if _, added := mergeSchema(merged, wanted, ""); len(added) != 0 {
+		t.Errorf("expected nothing to add to the merged schema, got %v", added)
+	}
+}
+
+func TestNewQuery(t *testing.T) {
+	q := newQuery(&Client{Client: &bigquery.Client{}, MaxBytesBilled: 1 << 30}, "SELECT 1")
+	if q.MaxBytesBilled != 1<<30 {
+		t.Errorf("expected the query to be limited to the client's MaxBytesBilled, got %d", q.MaxBytesBilled)
+	}
+	if q := newQuery(&Client{Client: &bigquery.Client{}}, "SELECT 1"); q.MaxBytesBilled != 0 {
+		t.Errorf("expected no limit without MaxBytesBilled, got %d", q.MaxBytesBilled)
+	}
+}
@@ -0,0 +1,159 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/chainguard-dev/rumble/pkg/config"
+)
+
+// Rollup is a daily aggregate of the summary table, one row per day and
+// key, kept up to date by RollupSQL's MERGE statement
+type Rollup struct {
+	// Table is the rollup table's name within the summary table's dataset
+	Table string
+
+	// Keys are the columns identifying a row along with its day, and
+	// Columns the others, each with its type
+	Keys    []string
+	Columns []string
+	Types   map[string]string
+
+	// source selects the rollup's rows from the latest scan of each image
+	// by each scanner each day, which it reads FROM daily
+	source string
+}
+
+// Rollups returns the rollups maintained by "rumble rollup", as tables named
+// with a prefix: counts per image, per severity and per team
+func Rollups(prefix string) []Rollup {
+	types := map[string]string{"day": "DATE"}
+	counts := []string{}
+	sums := []string{}
+	unnest := []string{}
+	for _, severity := range severityColumns {
+		types[severity.column] = "INT64"
+		counts = append(counts, severity.column)
+		sums = append(sums, fmt.Sprintf("SUM(%s) AS %s", severity.column, severity.column))
+		unnest = append(unnest, fmt.Sprintf("STRUCT('%s' AS severity, %s AS cve_count)", severity.severity, severity.column))
+	}
+	for _, column := range []string{"image", "scanner", "team", "severity"} {
+		types[column] = "STRING"
+	}
+	for _, column := range []string{"scans", "images", "cve_count", "tot_cve_count"} {
+		types[column] = "INT64"
+	}
+	return []Rollup{
+		{
+			Table:   prefix + "images",
+			Keys:    []string{"image", "scanner"},
+			Columns: append(append([]string{"team", "scans"}, counts...), "tot_cve_count"),
+			Types:   types,
+			source:  fmt.Sprintf("SELECT day, image, scanner, team, scans, %s, tot_cve_count FROM daily", strings.Join(counts, ", ")),
+		},
+		{
+			Table:   prefix + "severities",
+			Keys:    []string{"scanner", "severity"},
+			Columns: []string{"cve_count", "images"},
+			Types:   types,
+			source: fmt.Sprintf("SELECT day, scanner, severity, SUM(cve_count) AS cve_count, COUNTIF(cve_count > 0) AS images FROM daily, UNNEST([%s]) GROUP BY day, scanner, severity",
+				strings.Join(unnest, ", ")),
+		},
+		{
+			Table:   prefix + "teams",
+			Keys:    []string{"team", "scanner"},
+			Columns: append(append([]string{"images", "scans"}, counts...), "tot_cve_count"),
+			Types:   types,
+			source: fmt.Sprintf("SELECT day, team, scanner, COUNT(*) AS images, SUM(scans) AS scans, %s, SUM(tot_cve_count) AS tot_cve_count FROM daily GROUP BY day, team, scanner",
+				strings.Join(sums, ", ")),
+		},
+	}
+}
+
+// RollupSQL returns the statements creating a rollup table (in the dataset
+// of the summary table, given as project.dataset.table) if it doesn't
+// exist, and recomputing its rows for the days since a time. Days without
+// scans any more lose their rows. Images are assigned to teams in the
+// order given, and those of no team have an empty team.
+func RollupSQL(table string, rollup Rollup, teams []config.Team, since time.Time, partitioned bool) (string, string, []bigquery.QueryParameter) {
+	target := rollup.Table
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		target = table[:i+1] + rollup.Table
+	}
+	keys := append([]string{"day"}, rollup.Keys...)
+	all := append(append([]string{}, keys...), rollup.Columns...)
+	definitions := make([]string, len(all))
+	for i, column := range all {
+		definitions[i] = column + " " + rollup.Types[column]
+	}
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s) PARTITION BY day CLUSTER BY %s",
+		target, strings.Join(definitions, ", "), strings.Join(rollup.Keys, ", "))
+
+	on := make([]string, len(keys))
+	for i, key := range keys {
+		on[i] = fmt.Sprintf("T.%s = S.%s", key, key)
+	}
+	set := make([]string, len(rollup.Columns))
+	for i, column := range rollup.Columns {
+		set[i] = fmt.Sprintf("%s = S.%s", column, column)
+	}
+	values := make([]string, len(all))
+	for i, column := range all {
+		values[i] = "S." + column
+	}
+	merge := fmt.Sprintf("MERGE `%s` T USING (%s) S ON %s WHEN MATCHED THEN UPDATE SET %s WHEN NOT MATCHED BY TARGET THEN INSERT (%s) VALUES (%s) WHEN NOT MATCHED BY SOURCE AND T.day >= DATE(TIMESTAMP(@since)) THEN DELETE",
+		target, strings.Replace(rollup.source, "FROM daily", "FROM ("+dailySQL(table, teams, partitioned)+") daily", 1),
+		strings.Join(on, " AND "), strings.Join(set, ", "), strings.Join(all, ", "), strings.Join(values, ", "))
+
+	day := since.UTC().Truncate(24 * time.Hour)
+	return create, merge, []bigquery.QueryParameter{{Name: "since", Value: day.Format("2006-01-02T15:04:05Z")}}
+}
+
+// dailySQL selects the latest successful scan of each image by each
+// scanner each day since @since, with its team and the day's number of
+// scans of it
+func dailySQL(table string, teams []config.Team, partitioned bool) string {
+	team := "''"
+	if len(teams) > 0 {
+		cases := []string{}
+		for _, t := range teams {
+			for _, pattern := range t.Images {
+				cases = append(cases, fmt.Sprintf("WHEN image LIKE %s THEN %s", quoteString(likePattern(pattern)), quoteString(t.Name)))
+			}
+		}
+		team = "CASE " + strings.Join(cases, " ") + " ELSE '' END"
+	}
+	counts := countColumns()
+	where := "time >= @since AND success"
+	if partitioned {
+		where += " AND " + partitionSince
+	}
+	return fmt.Sprintf("SELECT DATE(SUBSTR(time, 1, 10)) AS day, image, scanner, %s AS team, scans, %s, tot_cve_count FROM ("+
+		"SELECT image, scanner, time, %s, tot_cve_count, COUNT(*) OVER (PARTITION BY SUBSTR(time, 1, 10), image, scanner) AS scans, "+
+		"ROW_NUMBER() OVER (PARTITION BY SUBSTR(time, 1, 10), image, scanner ORDER BY time DESC) AS row_num FROM `%s` WHERE %s"+
+		") WHERE row_num = 1",
+		team, strings.Join(counts, ", "), strings.Join(counts, ", "), table, where)
+}
+
+// likePattern returns a LIKE pattern for a glob pattern (see config.Match)
+func likePattern(glob string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(glob)
+	return strings.ReplaceAll(escaped, "*", "%")
+}
+
+// UpdateRollup creates a rollup table if needed and recomputes its rows for
+// the days since a time, returning how many rows were changed
+func UpdateRollup(ctx context.Context, client *Client, table string, rollup Rollup, teams []config.Team, since time.Time) (int64, error) {
+	partitioned, err := ingestionPartitioned(ctx, client, table)
+	if err != nil {
+		return 0, err
+	}
+	create, merge, params := RollupSQL(table, rollup, teams, since, partitioned)
+	if _, err := runStatement(ctx, client, create, nil); err != nil {
+		return 0, fmt.Errorf("creating rollup table %s: %w", rollup.Table, err)
+	}
+	return runStatement(ctx, client, merge, params)
+}
@@ -0,0 +1,134 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// Search finds the images whose latest scan contains a vulnerability or a
+// package
+type Search struct {
+	// CVE, if set, matches rows of the vulns table with this vulnerability
+	CVE string
+
+	// Package, if set, matches rows with this package name
+	Package string
+
+	// Packages searches a packages table (see types.Package) rather than
+	// the vulns table, which only has vulnerable packages. CVE can't be
+	// used with it.
+	Packages bool
+
+	// Since, if set, only considers scans at or after this time
+	Since time.Time
+}
+
+// SearchResult is a package found in the latest scan of an image
+type SearchResult struct {
+	Image   string `bigquery:"image" json:"image"`
+	Scanner string `bigquery:"scanner" json:"scanner"`
+	Digest  string `bigquery:"digest" json:"digest"`
+	Time    string `bigquery:"time" json:"time"`
+	ScanID  string `bigquery:"scan_id" json:"scanId"`
+	Package string `bigquery:"package" json:"package"`
+	Version string `bigquery:"version" json:"version"`
+	Type    string `bigquery:"type" json:"type"`
+
+	// These are only set when searching the vulns table
+	Vulnerability string `bigquery:"vulnerability" json:"vulnerability,omitempty"`
+	FixedIn       string `bigquery:"fixed_in" json:"fixedIn,omitempty"`
+}
+
+// SearchSQL returns the SQL and parameters for a search, joining the
+// latest scan of each image/scanner pair in the summary table with the rows
+// of table (a vulns or packages table). The SQL is kept to what BigQuery,
+// SQLite and PostgreSQL support, like SummarySQL.
+func SearchSQL(summaryTable string, table string, search Search) (string, []bigquery.QueryParameter, error) {
+	return searchSQL(summaryTable, table, search, false)
+}
+
+// searchSQL is SearchSQL, matching the full state of each scan (see
+// stateSQL) rather than its own rows of the vulns table if delta is set, as
+// it must be for summary tables with delta uploads
+func searchSQL(summaryTable string, table string, search Search, delta bool) (string, []bigquery.QueryParameter, error) {
+	if search.CVE == "" && search.Package == "" {
+		return "", nil, fmt.Errorf("a CVE or package to search for is required")
+	}
+	if search.CVE != "" && search.Packages {
+		return "", nil, fmt.Errorf("CVEs can't be searched for in the packages table")
+	}
+	columns := "r.name AS package, r.installed AS version, r.type, r.vulnerability, r.fixed_in"
+	if search.Packages {
+		columns = "r.name AS package, r.version, r.type, '' AS vulnerability, '' AS fixed_in"
+	}
+
+	summaryWhere := ""
+	params := []bigquery.QueryParameter{}
+	if !search.Since.IsZero() {
+		summaryWhere = " WHERE time >= @since"
+		params = append(params, bigquery.QueryParameter{Name: "since", Value: search.Since.UTC().Format("2006-01-02T15:04:05Z")})
+	}
+	where := []string{}
+	if search.CVE != "" {
+		where = append(where, "r.vulnerability = @cve")
+		params = append(params, bigquery.QueryParameter{Name: "cve", Value: search.CVE})
+	}
+	if search.Package != "" {
+		where = append(where, "r.name = @package")
+		params = append(params, bigquery.QueryParameter{Name: "package", Value: search.Package})
+	}
+
+	if delta && !search.Packages {
+		latest := fmt.Sprintf("SELECT id, image, scanner, digest, time FROM "+
+			"(SELECT id, image, scanner, digest, time, ROW_NUMBER() OVER (PARTITION BY image, scanner ORDER BY time DESC) AS row_num FROM `%s`%s) latest WHERE row_num = 1",
+			summaryTable, summaryWhere)
+		return stateSQL(summaryTable, table, latest, strings.Join(where, " AND ")) +
+			fmt.Sprintf("SELECT s.image, s.scanner, s.digest, s.time, s.id AS scan_id, %s FROM scans s JOIN scan_state r ON r.scan_id = s.id ORDER BY s.image, s.scanner, package, version",
+				columns), params, nil
+	}
+	where = append([]string{"s.row_num = 1"}, where...)
+	return fmt.Sprintf("SELECT s.image, s.scanner, s.digest, s.time, s.id AS scan_id, %s FROM "+
+		"(SELECT id, image, scanner, digest, time, ROW_NUMBER() OVER (PARTITION BY image, scanner ORDER BY time DESC) AS row_num FROM `%s`%s) s "+
+		"JOIN `%s` r ON r.scan_id = s.id WHERE %s ORDER BY s.image, s.scanner, package, version",
+		columns, summaryTable, summaryWhere, table, strings.Join(where, " AND ")), params, nil
+}
+
+// SearchImages runs a search against BigQuery
+func SearchImages(ctx context.Context, client *Client, summaryTable string, table string, search Search) ([]*SearchResult, error) {
+	// Only the vulns table has delta uploads
+	delta := false
+	if !search.Packages {
+		var err error
+		if delta, err = hasUploadMode(ctx, client, summaryTable); err != nil {
+			return nil, err
+		}
+	}
+	stmt, params, err := searchSQL(summaryTable, table, search, delta)
+	if err != nil {
+		return nil, err
+	}
+	q := newQuery(client, stmt)
+	q.Parameters = params
+	it, err := read(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	results := []*SearchResult{}
+	for {
+		var result SearchResult
+		err := it.Next(&result)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &result)
+	}
+	return results, nil
+}
@@ -0,0 +1,140 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/chainguard-dev/rumble/pkg/types"
+	"google.golang.org/api/googleapi"
+)
+
+// Tables names the tables of a dataset. Tables for kinds of findings that
+// aren't recorded may be left empty.
+type Tables struct {
+	Summary  string
+	Vulns    string
+	Findings string
+	Licenses string
+	Packages string
+}
+
+// CreateTables creates the tables of a dataset that don't exist yet, each
+// partitioned by the day its rows are inserted (the time columns are
+// strings, which can't be partitioned on, and rows are inserted as each
+// scan finishes) and clustered on columns queries filter by. Tables that
+// exist already get the columns they're missing added, so that inserts of
+// rows with columns added since the table was created don't fail. It
+// returns the names of the tables created, and of the columns added to each
+// existing table.
+func CreateTables(ctx context.Context, dataset *bigquery.Dataset, tables Tables) ([]string, map[string][]string, error) {
+	created, added := []string{}, map[string][]string{}
+	for _, table := range []struct {
+		name       string
+		row        interface{}
+		clustering []string
+	}{
+		{tables.Summary, types.ImageScanSummary{}, []string{"image", "digest"}},
+		{tables.Vulns, types.Vuln{}, []string{"scan_id", "vulnerability"}},
+		{tables.Findings, types.Finding{}, []string{"scan_id"}},
+		{tables.Licenses, types.License{}, []string{"scan_id"}},
+		{tables.Packages, types.Package{}, []string{"scan_id"}},
+	} {
+		if table.name == "" {
+			continue
+		}
+		schema, err := bigquery.InferSchema(table.row)
+		if err != nil {
+			return created, added, err
+		}
+		md := &bigquery.TableMetadata{
+			Schema:           schema,
+			TimePartitioning: &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType},
+			Clustering:       &bigquery.Clustering{Fields: table.clustering},
+		}
+		err = dataset.Table(table.name).Create(ctx, md)
+		if alreadyExists(err) {
+			columns, err := addColumns(ctx, dataset.Table(table.name), schema)
+			if err != nil {
+				return created, added, fmt.Errorf("adding columns to table %s: %w", table.name, err)
+			}
+			if len(columns) > 0 {
+				added[table.name] = columns
+			}
+			continue
+		}
+		if err != nil {
+			return created, added, fmt.Errorf("creating table %s: %w", table.name, err)
+		}
+		created = append(created, table.name)
+	}
+	return created, added, nil
+}
+
+// addColumns adds the columns of schema that an existing table is missing,
+// returning their names
+func addColumns(ctx context.Context, table *bigquery.Table, schema bigquery.Schema) ([]string, error) {
+	md, err := table.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	merged, columns := mergeSchema(md.Schema, schema, "")
+	if len(columns) == 0 {
+		return nil, nil
+	}
+	// The ETag makes the update fail rather than drop columns added since
+	// the schema was read
+	if _, err := table.Update(ctx, bigquery.TableMetadataToUpdate{Schema: merged}, md.ETag); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// mergeSchema returns the existing schema with the fields of wanted it's
+// missing appended, including those of nested records, and their names
+// (with the names of the records they're in as a prefix). BigQuery only
+// allows adding columns that aren't REQUIRED, so added fields are NULLABLE
+// (or stay REPEATED).
+func mergeSchema(existing bigquery.Schema, wanted bigquery.Schema, prefix string) (bigquery.Schema, []string) {
+	merged := bigquery.Schema{}
+	names := []string{}
+	byName := map[string]*bigquery.FieldSchema{}
+	for _, field := range existing {
+		field := *field
+		byName[strings.ToLower(field.Name)] = &field
+		merged = append(merged, &field)
+	}
+	for _, field := range wanted {
+		if have, ok := byName[strings.ToLower(field.Name)]; ok {
+			if have.Type == bigquery.RecordFieldType && field.Type == bigquery.RecordFieldType {
+				var nested []string
+				have.Schema, nested = mergeSchema(have.Schema, field.Schema, prefix+field.Name+".")
+				names = append(names, nested...)
+			}
+			continue
+		}
+		merged = append(merged, nullable(field))
+		names = append(names, prefix+field.Name)
+	}
+	return merged, names
+}
+
+// nullable returns a copy of field (and of its nested fields) that isn't
+// REQUIRED
+func nullable(field *bigquery.FieldSchema) *bigquery.FieldSchema {
+	copied := *field
+	copied.Required = false
+	copied.Schema = nil
+	for _, nested := range field.Schema {
+		copied.Schema = append(copied.Schema, nullable(nested))
+	}
+	return &copied
+}
+
+func alreadyExists(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict
+}
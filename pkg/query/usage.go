@@ -0,0 +1,78 @@
+package query
+
+import (
+	"context"
+	"sync/atomic"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// Client is a BigQuery client, with the limits of the queries it runs
+type Client struct {
+	*bigquery.Client
+
+	// MaxBytesBilled, if non-zero, has BigQuery fail queries that would bill
+	// more bytes than this rather than run them (see --maximum-bytes-billed)
+	MaxBytesBilled int64
+}
+
+// Totals of the queries run so far, for attributing their cost
+var (
+	queries     int64
+	bytesBilled int64
+)
+
+// Usage is what the queries run so far used of BigQuery
+type Usage struct {
+	Queries     int64
+	BytesBilled int64
+}
+
+// TotalUsage returns what the queries run so far used of BigQuery
+func TotalUsage() Usage {
+	return Usage{Queries: atomic.LoadInt64(&queries), BytesBilled: atomic.LoadInt64(&bytesBilled)}
+}
+
+// record adds a finished query job to the totals
+func record(status *bigquery.JobStatus) {
+	atomic.AddInt64(&queries, 1)
+	if status.Statistics == nil {
+		return
+	}
+	if stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok {
+		atomic.AddInt64(&bytesBilled, stats.TotalBytesBilled)
+	}
+}
+
+// newQuery returns a query run with the client's MaxBytesBilled
+func newQuery(client *Client, sql string) *bigquery.Query {
+	q := client.Query(sql)
+	q.MaxBytesBilled = client.MaxBytesBilled
+	return q
+}
+
+// run runs a query and waits for it to finish, adding it to the totals
+func run(ctx context.Context, q *bigquery.Query) (*bigquery.Job, *bigquery.JobStatus, error) {
+	job, err := q.Run(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	record(status)
+	if err := status.Err(); err != nil {
+		return nil, nil, err
+	}
+	return job, status, nil
+}
+
+// read runs a query, adding it to the totals, and returns its rows
+func read(ctx context.Context, q *bigquery.Query) (*bigquery.RowIterator, error) {
+	job, _, err := run(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return job.Read(ctx)
+}
@@ -0,0 +1,171 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// View is a view of the summary table for read-only consumers, which only
+// has the columns in SummaryColumns, so never the raw scanner output
+type View struct {
+	Name        string
+	Description string
+	SQL         string
+}
+
+// countColumns returns the severity count columns, most severe first
+func countColumns() []string {
+	columns := make([]string, len(severityColumns))
+	for i, sc := range severityColumns {
+		columns[i] = sc.column
+	}
+	return columns
+}
+
+// Views returns the views of a summary table (given as project.dataset.table)
+func Views(table string) []View {
+	columns := strings.Join(SummaryColumns, ", ")
+	latest := fmt.Sprintf("SELECT %s FROM (SELECT %s, ROW_NUMBER() OVER (PARTITION BY image, scanner ORDER BY time DESC) AS row_num FROM `%s`) WHERE row_num = 1", columns, columns, table)
+
+	sums := []string{}
+	counts := []string{}
+	for _, severity := range severityColumns {
+		sums = append(sums, fmt.Sprintf("SUM(%s) AS %s", severity.column, severity.column))
+		counts = append(counts, fmt.Sprintf("STRUCT('%s' AS severity, %s AS cve_count)", severity.severity, severity.column))
+	}
+	return []View{
+		{
+			Name:        "scans",
+			Description: "Every scan recorded by rumble, without the raw scanner output",
+			SQL:         fmt.Sprintf("SELECT %s FROM `%s`", columns, table),
+		},
+		{
+			Name:        "latest_scans",
+			Description: "The latest scan of each image by each scanner",
+			SQL:         latest,
+		},
+		{
+			Name:        "daily_severity_counts",
+			Description: "The scans, images scanned and CVEs found each day (UTC) by each scanner, by severity",
+			SQL: fmt.Sprintf("SELECT SUBSTR(time, 1, 10) AS day, scanner, COUNT(*) AS scans, COUNT(DISTINCT image) AS images, %s, SUM(tot_cve_count) AS tot_cve_count FROM `%s` GROUP BY day, scanner",
+				strings.Join(sums, ", "), table),
+		},
+		{
+			Name:        "latest_severity_counts",
+			Description: "The CVEs of the latest scans by each scanner, by severity, along with how many images have any",
+			SQL: fmt.Sprintf("SELECT scanner, severity, SUM(cve_count) AS cve_count, COUNTIF(cve_count > 0) AS images FROM (%s), UNNEST([%s]) GROUP BY scanner, severity",
+				latest, strings.Join(counts, ", ")),
+		},
+	}
+}
+
+// CreateViews creates (or updates) views in their own dataset, authorizes
+// them to read the source dataset, and grants readers (e.g.
+// "group:security@example.com") read access to the views dataset but not
+// to the source, so they can't query the raw tables
+func CreateViews(ctx context.Context, source *bigquery.Dataset, dataset *bigquery.Dataset, views []View, readers []string) error {
+	if source.ProjectID == dataset.ProjectID && source.DatasetID == dataset.DatasetID {
+		return fmt.Errorf("views have to be in another dataset than %s, or their readers could read the raw tables too", source.DatasetID)
+	}
+	readerAccess := []*bigquery.AccessEntry{}
+	for _, reader := range readers {
+		entry, err := readerEntry(reader)
+		if err != nil {
+			return err
+		}
+		readerAccess = append(readerAccess, entry)
+	}
+
+	// Views can only read datasets in the same location
+	md, err := source.Metadata(ctx)
+	if err != nil {
+		return err
+	}
+	if err := dataset.Create(ctx, &bigquery.DatasetMetadata{Location: md.Location, Description: "Views of the rumble dataset " + source.DatasetID}); err != nil && !alreadyExists(err) {
+		return fmt.Errorf("creating dataset %s: %w", dataset.DatasetID, err)
+	}
+	viewAccess := []*bigquery.AccessEntry{}
+	for _, view := range views {
+		table := dataset.Table(view.Name)
+		err := table.Create(ctx, &bigquery.TableMetadata{Description: view.Description, ViewQuery: view.SQL})
+		if alreadyExists(err) {
+			_, err = table.Update(ctx, bigquery.TableMetadataToUpdate{Description: view.Description, ViewQuery: view.SQL}, "")
+		}
+		if err != nil {
+			return fmt.Errorf("creating view %s: %w", view.Name, err)
+		}
+		viewAccess = append(viewAccess, &bigquery.AccessEntry{EntityType: bigquery.ViewEntity, View: table})
+	}
+
+	if err := grant(ctx, source, viewAccess); err != nil {
+		return fmt.Errorf("authorizing the views on dataset %s: %w", source.DatasetID, err)
+	}
+	if err := grant(ctx, dataset, readerAccess); err != nil {
+		return fmt.Errorf("granting read access to dataset %s: %w", dataset.DatasetID, err)
+	}
+	return nil
+}
+
+// readerEntry returns the access entry granting read access to a member,
+// as "user:", "group:", "serviceAccount:" or "domain:" and its email or
+// domain
+func readerEntry(member string) (*bigquery.AccessEntry, error) {
+	kind, entity, ok := strings.Cut(member, ":")
+	if !ok || entity == "" {
+		return nil, fmt.Errorf("invalid reader %q, expected user:, group:, serviceAccount: or domain: and an email or domain", member)
+	}
+	entityTypes := map[string]bigquery.EntityType{
+		"user":           bigquery.UserEmailEntity,
+		"serviceAccount": bigquery.UserEmailEntity,
+		"group":          bigquery.GroupEmailEntity,
+		"domain":         bigquery.DomainEntity,
+	}
+	entityType, ok := entityTypes[kind]
+	if !ok {
+		return nil, fmt.Errorf("invalid reader %q, expected user:, group:, serviceAccount: or domain: and an email or domain", member)
+	}
+	return &bigquery.AccessEntry{Role: bigquery.ReaderRole, EntityType: entityType, Entity: entity}, nil
+}
+
+// grant adds access entries to a dataset, leaving those it has. The read
+// and update are tied by the dataset's etag, so grants made in between
+// aren't lost.
+func grant(ctx context.Context, dataset *bigquery.Dataset, entries []*bigquery.AccessEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	md, err := dataset.Metadata(ctx)
+	if err != nil {
+		return err
+	}
+	access := md.Access
+	for _, entry := range entries {
+		if !hasAccess(access, entry) {
+			access = append(access, entry)
+		}
+	}
+	if len(access) == len(md.Access) {
+		return nil
+	}
+	_, err = dataset.Update(ctx, bigquery.DatasetMetadataToUpdate{Access: access}, md.ETag)
+	return err
+}
+
+func hasAccess(access []*bigquery.AccessEntry, entry *bigquery.AccessEntry) bool {
+	for _, have := range access {
+		if have.EntityType != entry.EntityType {
+			continue
+		}
+		if entry.View != nil {
+			if have.View != nil && have.View.ProjectID == entry.View.ProjectID && have.View.DatasetID == entry.View.DatasetID && have.View.TableID == entry.View.TableID {
+				return true
+			}
+		} else if have.Role == entry.Role && strings.EqualFold(have.Entity, entry.Entity) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// Ways of mapping vulns to JUnit test cases
+const (
+	// ByVuln makes each vuln a failed test case
+	ByVuln = "vuln"
+
+	// BySeverity makes each severity a test case, failed if any vulns have it
+	BySeverity = "severity"
+)
+
+// Severities are the severity classes reported with BySeverity, in order
+var Severities = []string{"Critical", "High", "Medium", "Low", "Negligible", "Unknown"}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit returns a JUnit XML report of a scan, with its vulns mapped to test
+// cases by ByVuln or BySeverity, so that CI systems that only understand
+// test results can show them. A scan without vulns has a single passing
+// test case with ByVuln.
+func JUnit(summary *types.ImageScanSummary, vulns []*types.Vuln, by string) ([]byte, error) {
+	suite := junitTestSuite{
+		Name:      fmt.Sprintf("%s (%s)", summary.Image, summary.Scanner),
+		Timestamp: summary.Time,
+		Properties: []junitProperty{
+			{"scan_id", summary.ID},
+			{"digest", summary.Digest},
+			{"scanner_version", summary.ScannerVersion},
+			{"scanner_db_version", summary.ScannerDbVersion},
+		},
+	}
+	switch by {
+	case ByVuln:
+		for _, vuln := range vulns {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      fmt.Sprintf("%s in %s %s", vuln.Vulnerability, vuln.Name, vuln.Installed),
+				Classname: vuln.Name,
+				Failure:   vulnFailure(vuln),
+			})
+		}
+		if len(suite.Cases) == 0 {
+			suite.Cases = append(suite.Cases, junitTestCase{Name: "no vulnerabilities", Classname: summary.Image})
+		}
+	case BySeverity:
+		bySeverity := map[string][]*types.Vuln{}
+		for _, vuln := range vulns {
+			severity := normalizeSeverity(Severity(vuln))
+			bySeverity[severity] = append(bySeverity[severity], vuln)
+		}
+		for _, severity := range Severities {
+			c := junitTestCase{Name: "no " + strings.ToLower(severity) + " vulnerabilities", Classname: summary.Image}
+			if found := bySeverity[severity]; len(found) > 0 {
+				lines := make([]string, len(found))
+				for i, vuln := range found {
+					lines[i] = vulnLine(vuln)
+				}
+				c.Failure = &junitFailure{
+					Message: fmt.Sprintf("%d %s vulnerabilities", len(found), strings.ToLower(severity)),
+					Type:    severity,
+					Text:    strings.Join(lines, "\n"),
+				}
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+	default:
+		return nil, fmt.Errorf("invalid JUnit test cases: %s (expected %q or %q)", by, ByVuln, BySeverity)
+	}
+
+	suite.Tests = len(suite.Cases)
+	for _, c := range suite.Cases {
+		if c.Failure != nil {
+			suite.Failures++
+		}
+	}
+	b, err := xml.MarshalIndent(junitTestSuites{
+		Name:     "rumble",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+// Severity is the severity a vuln was counted with
+func Severity(vuln *types.Vuln) string {
+	if vuln.NvdSeverity != "" {
+		return vuln.NvdSeverity
+	}
+	return vuln.Severity
+}
+
+// SeverityRank is the index of a vuln's severity in Severities, so the most
+// severe vulns rank lowest
+func SeverityRank(vuln *types.Vuln) int {
+	severity := normalizeSeverity(Severity(vuln))
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(Severities) - 1
+}
+
+// normalizeSeverity returns the one of Severities matching severity
+// (e.g. "Critical" for trivy's "CRITICAL", or "Negligible" for CVSS's
+// "None"), or "Unknown"
+func normalizeSeverity(severity string) string {
+	for _, s := range Severities {
+		if strings.EqualFold(s, severity) || (s == "Medium" && strings.EqualFold(severity, "moderate")) || (s == "Negligible" && strings.EqualFold(severity, "none")) {
+			return s
+		}
+	}
+	return "Unknown"
+}
+
+func vulnFailure(vuln *types.Vuln) *junitFailure {
+	return &junitFailure{
+		Message: fmt.Sprintf("%s (%s)", vuln.Vulnerability, Severity(vuln)),
+		Type:    normalizeSeverity(Severity(vuln)),
+		Text:    strings.TrimSpace(vulnLine(vuln) + "\n" + vuln.Description),
+	}
+}
+
+func vulnLine(vuln *types.Vuln) string {
+	line := fmt.Sprintf("%s: %s %s", vuln.Vulnerability, vuln.Name, vuln.Installed)
+	if vuln.FixedIn != "" {
+		line += ", fixed in " + vuln.FixedIn
+	}
+	if vuln.DataSource != "" {
+		line += " (" + vuln.DataSource + ")"
+	}
+	return line
+}
@@ -0,0 +1,53 @@
+package report
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestJUnit(t *testing.T) {
+	summary := &types.ImageScanSummary{ID: "testing123", Image: "cgr.dev/chainguard/static:latest", Scanner: "trivy"}
+	vulns := []*types.Vuln{
+		{Name: "openssl", Installed: "3.2.0-r0", FixedIn: "3.2.1-r0", Vulnerability: "CVE-2024-0001", Severity: "CRITICAL"},
+		{Name: "busybox", Installed: "1.36.1-r1", Vulnerability: "CVE-2024-0002", Severity: "LOW"},
+		{Name: "zlib", Installed: "1.3-r0", Vulnerability: "CVE-2024-0003", Severity: "LOW", NvdSeverity: "HIGH"},
+	}
+	for _, tc := range []struct {
+		by              string
+		tests, failures int
+		contains        string
+	}{
+		{ByVuln, 3, 3, `<testcase name="CVE-2024-0001 in openssl 3.2.0-r0" classname="openssl">`},
+		{BySeverity, 6, 3, `<failure message="1 low vulnerabilities" type="Low">CVE-2024-0002: busybox 1.36.1-r1</failure>`},
+	} {
+		b, err := JUnit(summary, vulns, tc.by)
+		if err != nil {
+			t.Fatalf("expected no error on JUnit(%s), got %v", tc.by, err)
+		}
+		var report junitTestSuites
+		if err := xml.Unmarshal(b, &report); err != nil {
+			t.Fatalf("expected valid XML from JUnit(%s), got %v", tc.by, err)
+		}
+		if report.Tests != tc.tests || report.Failures != tc.failures {
+			t.Errorf("expected %d test(s) with %d failure(s) by %s, got %d with %d", tc.tests, tc.failures, tc.by, report.Tests, report.Failures)
+		}
+		if !strings.Contains(string(b), tc.contains) {
+			t.Errorf("expected the report by %s to contain %s, got:\n%s", tc.by, tc.contains, b)
+		}
+	}
+
+	// A clean scan still has a passing test case
+	b, err := JUnit(summary, nil, ByVuln)
+	if err != nil {
+		t.Fatalf("expected no error on JUnit(), got %v", err)
+	}
+	if !strings.Contains(string(b), `tests="1" failures="0"`) {
+		t.Errorf("expected 1 passing test case, got:\n%s", b)
+	}
+	if _, err := JUnit(summary, vulns, "package"); err == nil {
+		t.Errorf("expected an error for invalid test cases")
+	}
+}
@@ -0,0 +1,68 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Embedded holds the scanner binaries compiled into rumble with the
+// embedded_scanners build tag, each named after its scanner. It is nil in
+// regular builds. They're the release binaries, which still have to be
+// extracted to a writable directory and run as subprocesses, rather than
+// the scanners linked in as libraries.
+var Embedded fs.FS
+
+// InstallEmbedded writes the embedded binary of a scanner into its own
+// directory under dir, unless it is already there, and returns the
+// directory holding it. It returns "" if the scanner isn't embedded.
+func InstallEmbedded(dir string, name string) (string, error) {
+	if Embedded == nil {
+		return "", nil
+	}
+	if platform, err := fs.ReadFile(Embedded, "PLATFORM"); err == nil {
+		if p := strings.TrimSpace(string(platform)); p != runtime.GOOS+"/"+runtime.GOARCH {
+			return "", fmt.Errorf("the embedded scanners are for %s, not %s/%s", p, runtime.GOOS, runtime.GOARCH)
+		}
+	}
+	src, err := Embedded.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	release, ok := Releases[name]
+	if !ok {
+		return "", fmt.Errorf("invalid scanner: %s", name)
+	}
+	binDir := filepath.Join(dir, name+"-"+release.Version+"-embedded")
+	if _, err := os.Stat(filepath.Join(binDir, name)); err == nil {
+		return binDir, nil
+	}
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(binDir, ".extract-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(f.Name(), 0755); err != nil {
+		return "", err
+	}
+	return binDir, os.Rename(f.Name(), filepath.Join(binDir, name))
+}
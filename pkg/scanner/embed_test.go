@@ -0,0 +1,41 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+)
+
+func TestInstallEmbedded(t *testing.T) {
+	defer func() { Embedded = nil }()
+	dir := t.TempDir()
+	if binDir, err := InstallEmbedded(dir, Grype); err != nil || binDir != "" {
+		t.Errorf("expected nothing to install without embedded scanners, got %q (%v)", binDir, err)
+	}
+
+	Embedded = fstest.MapFS{
+		"grype":    {Data: []byte("#!/bin/sh\necho grype\n")},
+		"PLATFORM": {Data: []byte(runtime.GOOS + "/" + runtime.GOARCH + "\n")},
+	}
+	binDir, err := InstallEmbedded(dir, Grype)
+	if err != nil {
+		t.Fatalf("expected no error on InstallEmbedded(), got %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(binDir, Grype))
+	if err != nil || string(b) != "#!/bin/sh\necho grype\n" {
+		t.Errorf("expected the embedded grype to be extracted, got %q (%v)", string(b), err)
+	}
+	if binDir, err := InstallEmbedded(dir, Trivy); err != nil || binDir != "" {
+		t.Errorf("expected nothing to install for a scanner that isn't embedded, got %q (%v)", binDir, err)
+	}
+
+	Embedded = fstest.MapFS{
+		"grype":    {Data: []byte("grype")},
+		"PLATFORM": {Data: []byte("plan9/mips\n")},
+	}
+	if _, err := InstallEmbedded(t.TempDir(), Grype); err == nil {
+		t.Errorf("expected an error for scanners embedded for another platform")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build embedded_scanners
+
+package scanner
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// The binaries are put in place with "go run ./cmd/embedscanners" before
+// building with -tags embedded_scanners
+//
+//go:embed embedded
+var embedded embed.FS
+
+func init() {
+	sub, err := fs.Sub(embedded, "embedded")
+	if err != nil {
+		panic(err)
+	}
+	Embedded = sub
+}
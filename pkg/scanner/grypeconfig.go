@@ -0,0 +1,62 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// GrypeConfig returns a grype config with the ignore rules and VEX
+// documents added to those of base, the contents of a grype config file
+// (or nil), whose other settings are kept as they are
+func GrypeConfig(base []byte, ignore []config.IgnoreRule, vexDocuments []string) ([]byte, error) {
+	cfg := map[string]interface{}{}
+	if err := yaml.Unmarshal(base, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing grype config: %w", err)
+	}
+	if cfg == nil {
+		// An empty file
+		cfg = map[string]interface{}{}
+	}
+	if len(ignore) > 0 {
+		rules, err := list(cfg, "ignore")
+		if err != nil {
+			return nil, err
+		}
+		// The rules are already in grype's format, so a round trip through
+		// JSON turns them into the same generic values as the base's
+		b, err := json.Marshal(ignore)
+		if err != nil {
+			return nil, err
+		}
+		var added []interface{}
+		if err := json.Unmarshal(b, &added); err != nil {
+			return nil, err
+		}
+		cfg["ignore"] = append(rules, added...)
+	}
+	if len(vexDocuments) > 0 {
+		documents, err := list(cfg, "vex-documents")
+		if err != nil {
+			return nil, err
+		}
+		for _, document := range vexDocuments {
+			documents = append(documents, document)
+		}
+		cfg["vex-documents"] = documents
+	}
+	return yaml.Marshal(cfg)
+}
+
+func list(cfg map[string]interface{}, key string) ([]interface{}, error) {
+	switch value := cfg[key].(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("grype config has %s set to %v, expected a list", key, value)
+	}
+}
@@ -0,0 +1,48 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGrypeConfig(t *testing.T) {
+	base := []byte(`
+only-fixed: true
+ignore:
+  - vulnerability: CVE-2023-0001
+`)
+	ignore := []config.IgnoreRule{
+		{Vulnerability: "CVE-2024-0001", Reason: "not reachable"},
+		{FixState: "wont-fix", Package: &config.IgnorePackage{Name: "zlib"}},
+	}
+	b, err := GrypeConfig(base, ignore, []string{"openvex.json"})
+	if err != nil {
+		t.Fatalf("expected no error on GrypeConfig(), got %v", err)
+	}
+	var got map[string]interface{}
+	if err := yaml.Unmarshal(b, &got); err != nil {
+		t.Fatalf("expected the config to be valid YAML, got %v", err)
+	}
+	want := map[string]interface{}{
+		"only-fixed": true,
+		"ignore": []interface{}{
+			map[string]interface{}{"vulnerability": "CVE-2023-0001"},
+			map[string]interface{}{"vulnerability": "CVE-2024-0001", "reason": "not reachable"},
+			map[string]interface{}{"fix-state": "wont-fix", "package": map[string]interface{}{"name": "zlib"}},
+		},
+		"vex-documents": []interface{}{"openvex.json"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got grype config %v, wanted %v", got, want)
+	}
+
+	if _, err := GrypeConfig(nil, ignore, nil); err != nil {
+		t.Errorf("expected no error on GrypeConfig() without a base config, got %v", err)
+	}
+	if _, err := GrypeConfig([]byte("ignore: CVE-2024-0001"), ignore, nil); err == nil {
+		t.Errorf("expected error on GrypeConfig() with ignore set to a string, got nil")
+	}
+}
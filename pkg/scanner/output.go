@@ -0,0 +1,50 @@
+package scanner
+
+import (
+	"fmt"
+	"sync"
+)
+
+// OutputLimit is how much of a scanner's output is kept by default
+const OutputLimit = 64 << 10
+
+// Output keeps the end of what a scanner writes to stdout and stderr, up to
+// Limit bytes (OutputLimit if zero), as the end is where the reason for a
+// failure is. It is safe to write to from both at once.
+type Output struct {
+	Limit int
+
+	mu      sync.Mutex
+	buf     []byte
+	dropped int64
+}
+
+func (o *Output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	limit := o.Limit
+	if limit <= 0 {
+		limit = OutputLimit
+	}
+	n := len(p)
+	if len(p) > limit {
+		o.dropped += int64(len(p) - limit)
+		p = p[len(p)-limit:]
+	}
+	if over := len(o.buf) + len(p) - limit; over > 0 {
+		o.dropped += int64(over)
+		o.buf = append(o.buf[:0], o.buf[over:]...)
+	}
+	o.buf = append(o.buf, p...)
+	return n, nil
+}
+
+// String returns the output kept, noting how much was dropped before it
+func (o *Output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.dropped > 0 {
+		return fmt.Sprintf("[%d earlier bytes dropped]\n%s", o.dropped, o.buf)
+	}
+	return string(o.buf)
+}
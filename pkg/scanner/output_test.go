@@ -0,0 +1,25 @@
+package scanner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestOutput(t *testing.T) {
+	o := &Output{Limit: 16}
+	fmt.Fprint(o, "pulling image\n")
+	if got := o.String(); got != "pulling image\n" {
+		t.Errorf("expected the output to be kept as is, got %q", got)
+	}
+	fmt.Fprint(o, "failed: 401\n")
+	if got := o.String(); got != "[10 earlier bytes dropped]\nage\nfailed: 401\n" {
+		t.Errorf("expected the last 16 bytes to be kept, got %q", got)
+	}
+
+	// A write longer than the limit keeps its own end
+	fmt.Fprint(o, strings.Repeat("x", 20)+"end")
+	if got := o.String(); !strings.HasPrefix(got, "[33 earlier bytes dropped]\n") || !strings.HasSuffix(got, "xxxxxxxxxxxxxend") {
+		t.Errorf("expected the end of the long write to be kept, got %q", got)
+	}
+}
@@ -0,0 +1,224 @@
+package scanner
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Release is a pinned scanner release that can be installed when the
+// scanner isn't on the PATH. Archives are checked against the SHA-256
+// pinned for them here before anything is extracted, so an asset replaced
+// after the release was pinned is never installed.
+type Release struct {
+	Version string
+
+	// BaseURL is where the release's assets are downloaded from
+	BaseURL string
+
+	// Checksums is the name of the checksums file published with the
+	// release, which Pin reads the checksums of the assets from. It's only
+	// downloaded when pinning a release (see cmd/pinscanners), never when
+	// installing one.
+	Checksums string
+
+	// Assets are the archives of the release for each platform (e.g.
+	// "linux/amd64")
+	Assets map[string]Asset
+}
+
+// Asset is an archive of a release and the SHA-256 checksum it's pinned to
+type Asset struct {
+	Name   string
+	SHA256 string
+}
+
+// Releases are the releases installed with --ensure-scanners. The SHA-256
+// of each asset is filled in from the output of "go run ./cmd/pinscanners"
+// (after checking it against the release page), and assets without one are
+// refused.
+var Releases = map[string]*Release{
+	Grype: {
+		Version:   "0.74.7",
+		BaseURL:   "https://github.com/anchore/grype/releases/download/v0.74.7",
+		Checksums: "grype_0.74.7_checksums.txt",
+		Assets: map[string]Asset{
+			"linux/amd64":  {Name: "grype_0.74.7_linux_amd64.tar.gz"},
+			"linux/arm64":  {Name: "grype_0.74.7_linux_arm64.tar.gz"},
+			"darwin/amd64": {Name: "grype_0.74.7_darwin_amd64.tar.gz"},
+			"darwin/arm64": {Name: "grype_0.74.7_darwin_arm64.tar.gz"},
+		},
+	},
+	Trivy: {
+		Version:   "0.49.1",
+		BaseURL:   "https://github.com/aquasecurity/trivy/releases/download/v0.49.1",
+		Checksums: "trivy_0.49.1_checksums.txt",
+		Assets: map[string]Asset{
+			"linux/amd64":  {Name: "trivy_0.49.1_Linux-64bit.tar.gz"},
+			"linux/arm64":  {Name: "trivy_0.49.1_Linux-ARM64.tar.gz"},
+			"darwin/amd64": {Name: "trivy_0.49.1_macOS-64bit.tar.gz"},
+			"darwin/arm64": {Name: "trivy_0.49.1_macOS-ARM64.tar.gz"},
+		},
+	},
+}
+
+// DefaultDir returns the directory scanners are installed in by default
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "rumble", "bin")
+}
+
+// Install downloads the release of a scanner for this platform into its
+// own directory under dir, unless it is already there, and returns the
+// directory holding the binary
+func (r *Release) Install(ctx context.Context, client *http.Client, dir string, name string) (string, error) {
+	binDir := filepath.Join(dir, name+"-"+r.Version)
+	if _, err := os.Stat(filepath.Join(binDir, name)); err == nil {
+		return binDir, nil
+	}
+	if err := r.Download(ctx, client, runtime.GOOS+"/"+runtime.GOARCH, name, binDir); err != nil {
+		return "", err
+	}
+	return binDir, nil
+}
+
+// Download downloads the release of a scanner for a platform (e.g.
+// "linux/arm64") and extracts the binary into binDir
+func (r *Release) Download(ctx context.Context, client *http.Client, platform string, name string, binDir string) error {
+	asset, ok := r.Assets[platform]
+	if !ok {
+		return fmt.Errorf("no %s %s release for %s", name, r.Version, platform)
+	}
+	if asset.SHA256 == "" {
+		return fmt.Errorf("the %s %s release for %s has no pinned checksum, see cmd/pinscanners", name, r.Version, platform)
+	}
+
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return err
+	}
+	archive, err := os.CreateTemp(binDir, ".download-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	fmt.Printf("Downloading %s %s from %s/%s\n", name, r.Version, r.BaseURL, asset.Name)
+	body, err := r.get(ctx, client, asset.Name)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(archive, h), body); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != asset.SHA256 {
+		return fmt.Errorf("checksum of %s is %s, but %s is pinned", asset.Name, actual, asset.SHA256)
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := extract(archive, name, filepath.Join(binDir, name)); err != nil {
+		return fmt.Errorf("extracting %s from %s: %w", name, asset.Name, err)
+	}
+	return nil
+}
+
+// Pin sets the SHA-256 of each asset to the one listed in the release's
+// checksums file. That only vouches for the assets as they are now, so the
+// checksums should be checked against the release before being committed.
+func (r *Release) Pin(ctx context.Context, client *http.Client) error {
+	body, err := r.get(ctx, client, r.Checksums)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	listed := map[string]string{}
+	lines := bufio.NewScanner(body)
+	for lines.Scan() {
+		if fields := strings.Fields(lines.Text()); len(fields) == 2 {
+			listed[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return err
+	}
+	for platform, asset := range r.Assets {
+		checksum, ok := listed[asset.Name]
+		if !ok {
+			return fmt.Errorf("%s has no checksum for %s", r.Checksums, asset.Name)
+		}
+		asset.SHA256 = checksum
+		r.Assets[platform] = asset
+	}
+	return nil
+}
+
+func (r *Release) get(ctx context.Context, client *http.Client, asset string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.BaseURL+"/"+asset, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("downloading %s: %s", req.URL, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// extract writes the file called name at the top of a gzipped tarball to
+// dest, replacing it in one step so a partial binary is never left behind
+func extract(archive io.Reader, name string, dest string) error {
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("no %s in the archive", name)
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg || filepath.Clean(hdr.Name) != name {
+			continue
+		}
+		f, err := os.CreateTemp(filepath.Dir(dest), ".extract-")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		if err := os.Chmod(f.Name(), 0755); err != nil {
+			return err
+		}
+		return os.Rename(f.Name(), dest)
+	}
+}
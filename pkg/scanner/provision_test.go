@@ -0,0 +1,125 @@
+package scanner
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func testArchive(t *testing.T, name string, content string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range []struct{ name, content string }{{"README.md", "readme"}, {name, content}} {
+		if err := tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("expected no error on WriteHeader(), got %v", err)
+		}
+		if _, err := tw.Write([]byte(file.content)); err != nil {
+			t.Fatalf("expected no error on Write(), got %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("expected no error on tw.Close(), got %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("expected no error on gz.Close(), got %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestInstall(t *testing.T) {
+	archive := testArchive(t, "grype", "#!/bin/sh\necho grype\n")
+	checksums := fmt.Sprintf("%x  grype_1.0.0_test.tar.gz\n", sha256.Sum256(archive))
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/grype_1.0.0_checksums.txt":
+			w.Write([]byte(checksums))
+		case "/grype_1.0.0_test.tar.gz":
+			w.Write(archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	release := &Release{
+		Version:   "1.0.0",
+		BaseURL:   srv.URL,
+		Checksums: "grype_1.0.0_checksums.txt",
+		Assets:    map[string]Asset{platform: {Name: "grype_1.0.0_test.tar.gz"}},
+	}
+
+	// Nothing is installed before the release is pinned
+	if _, err := release.Install(context.Background(), srv.Client(), t.TempDir(), "grype"); err == nil {
+		t.Errorf("expected an error installing an asset without a pinned checksum")
+	}
+	if err := release.Pin(context.Background(), srv.Client()); err != nil {
+		t.Fatalf("expected no error on Pin(), got %v", err)
+	}
+	if checksum := fmt.Sprintf("%x", sha256.Sum256(archive)); release.Assets[platform].SHA256 != checksum {
+		t.Fatalf("expected the asset to be pinned to %s, got %+v", checksum, release.Assets[platform])
+	}
+
+	dir := t.TempDir()
+	binDir, err := release.Install(context.Background(), srv.Client(), dir, "grype")
+	if err != nil {
+		t.Fatalf("expected no error on Install(), got %v", err)
+	}
+	info, err := os.Stat(filepath.Join(binDir, "grype"))
+	if err != nil {
+		t.Fatalf("expected the binary to be installed, got %v", err)
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("expected the binary to be executable, got mode %s", info.Mode())
+	}
+
+	// Once installed, nothing is downloaded again
+	requests = 0
+	if _, err := release.Install(context.Background(), srv.Client(), dir, "grype"); err != nil || requests != 0 {
+		t.Errorf("expected the installed binary to be reused, got %d request(s) (%v)", requests, err)
+	}
+
+	// An archive that doesn't match its pinned checksum is never extracted,
+	// even if the checksums file was replaced along with it
+	release.Assets[platform] = Asset{Name: "grype_1.0.0_test.tar.gz", SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte("something else")))}
+	other := t.TempDir()
+	if _, err := release.Install(context.Background(), srv.Client(), other, "grype"); err == nil {
+		t.Errorf("expected an error for a checksum mismatch")
+	}
+	if _, err := os.Stat(filepath.Join(other, "grype-1.0.0", "grype")); !os.IsNotExist(err) {
+		t.Errorf("expected no binary after a checksum mismatch, got %v", err)
+	}
+}
+
+func TestReleases(t *testing.T) {
+	for name, release := range Releases {
+		if err := CheckVersion(name, release.Version); err != nil {
+			t.Errorf("expected the pinned %s release to be supported, got %v", name, err)
+		}
+		if len(release.Assets) == 0 || release.Checksums == "" {
+			t.Errorf("expected assets and a checksums file for %s", name)
+		}
+		for platform, asset := range release.Assets {
+			if asset.Name == "" {
+				t.Errorf("expected the %s asset for %s to be named, got %+v", name, platform, asset)
+			}
+			// Download refuses assets without a checksum, so every one
+			// has to be pinned (with "go run ./cmd/pinscanners")
+			if b, err := hex.DecodeString(asset.SHA256); err != nil || len(b) != sha256.Size {
+				t.Errorf("expected the %s asset for %s to be pinned to a SHA-256, got %q", name, platform, asset.SHA256)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package scanner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/version"
+)
+
+const (
+	Grype = "grype"
+	Trivy = "trivy"
+)
+
+// MinVersions are the oldest releases of each scanner whose JSON output and
+// flags rumble is known to work with
+var MinVersions = map[string]string{
+	Grype: "0.63.0",
+	Trivy: "0.37.0",
+}
+
+// SchemaVersions are the output schema versions rumble understands: the
+// vulnerability database schema reported by grype, and the report
+// SchemaVersion of trivy's JSON output
+var SchemaVersions = map[string][]int{
+	Grype: {5},
+	Trivy: {2},
+}
+
+// CheckVersion returns an error if a scanner version is older than the
+// minimum supported
+func CheckVersion(scanner string, v string) error {
+	min, ok := MinVersions[scanner]
+	if !ok {
+		return fmt.Errorf("invalid scanner: %s", scanner)
+	}
+	if v == "" {
+		return fmt.Errorf("could not tell which version of %s is installed, %s or newer is required", scanner, min)
+	}
+	if version.Compare(v, min) < 0 {
+		return fmt.Errorf("%s %s is older than the oldest supported version, %s", scanner, v, min)
+	}
+	return nil
+}
+
+// CheckSchema returns an error if a scanner's output uses a schema version
+// rumble doesn't understand, which could silently throw off the counts
+func CheckSchema(scanner string, schemaVersion int) error {
+	supported, ok := SchemaVersions[scanner]
+	if !ok {
+		return fmt.Errorf("invalid scanner: %s", scanner)
+	}
+	names := make([]string, len(supported))
+	for i, v := range supported {
+		if v == schemaVersion {
+			return nil
+		}
+		names[i] = fmt.Sprint(v)
+	}
+	return fmt.Errorf("%s output has schema version %d, but only version(s) %s are supported", scanner, schemaVersion, strings.Join(names, ", "))
+}
@@ -0,0 +1,38 @@
+package scanner
+
+import "testing"
+
+func TestCheckVersion(t *testing.T) {
+	for _, tc := range []struct {
+		scanner, version string
+		ok               bool
+	}{
+		{Grype, "0.63.0", true},
+		{Grype, "0.74.1", true},
+		{Grype, "v0.63.1", true},
+		{Grype, "0.62.3", false},
+		{Grype, "", false},
+		{Trivy, "0.50.0", true},
+		{Trivy, "0.36.1", false},
+		{"clair", "1.0.0", false},
+	} {
+		if err := CheckVersion(tc.scanner, tc.version); (err == nil) != tc.ok {
+			t.Errorf("CheckVersion(%q, %q) returned %v, wanted ok=%t", tc.scanner, tc.version, err, tc.ok)
+		}
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	if err := CheckSchema(Grype, 5); err != nil {
+		t.Errorf("expected grype schema 5 to be supported, got %v", err)
+	}
+	if err := CheckSchema(Trivy, 2); err != nil {
+		t.Errorf("expected trivy schema 2 to be supported, got %v", err)
+	}
+	if err := CheckSchema(Grype, 6); err == nil {
+		t.Errorf("expected an error for an unknown grype schema")
+	}
+	if err := CheckSchema(Trivy, 0); err == nil {
+		t.Errorf("expected an error for a trivy report without a schema version")
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ReadyTimeout bounds how long each readiness check may take
+const ReadyTimeout = 5 * time.Second
+
+// Check is something the server needs to be ready, e.g. its database
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// readiness is the body of GET /readyz
+type readiness struct {
+	Ready    bool              `json:"ready"`
+	Draining bool              `json:"draining,omitempty"`
+	Checks   map[string]string `json:"checks"`
+}
+
+// HealthHandler serves GET /healthz, which succeeds as long as the server
+// is up, e.g. for a liveness probe
+func (s *Server) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// ReadyHandler serves GET /readyz, e.g. for a readiness probe or a load
+// balancer's health check. It fails with 503 while the server is draining
+// or if any check fails, and returns each check's result as JSON.
+func (s *Server) ReadyHandler(checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := readiness{Draining: s.Draining(), Checks: map[string]string{}}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, check := range checks {
+			check := check
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(r.Context(), ReadyTimeout)
+				defer cancel()
+				result := "ok"
+				if err := check.Check(ctx); err != nil {
+					result = err.Error()
+				}
+				mu.Lock()
+				status.Checks[check.Name] = result
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+		status.Ready = !status.Draining
+		for _, result := range status.Checks {
+			if result != "ok" {
+				status.Ready = false
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !status.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+}
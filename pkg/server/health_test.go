@@ -0,0 +1,36 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyHandler(t *testing.T) {
+	s := New(nil, nil, Options{})
+	ok := Check{Name: "bigquery", Check: func(ctx context.Context) error { return nil }}
+	missing := Check{Name: "grype", Check: func(ctx context.Context) error { return fmt.Errorf("not found") }}
+	ready := func(checks ...Check) (int, readiness) {
+		w := httptest.NewRecorder()
+		s.ReadyHandler(checks...).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body readiness
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("expected no error decoding /readyz, got %v", err)
+		}
+		return w.Code, body
+	}
+
+	if code, body := ready(ok); code != http.StatusOK || !body.Ready || body.Checks["bigquery"] != "ok" {
+		t.Errorf("expected to be ready, got %d %+v", code, body)
+	}
+	if code, body := ready(ok, missing); code != http.StatusServiceUnavailable || body.Ready || body.Checks["grype"] != "not found" {
+		t.Errorf("expected not to be ready without grype, got %d %+v", code, body)
+	}
+	s.Drain(context.Background())
+	if code, body := ready(ok); code != http.StatusServiceUnavailable || !body.Draining {
+		t.Errorf("expected not to be ready while draining, got %d %+v", code, body)
+	}
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/api"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrQueueFull is returned by Enqueue when no more jobs can be queued
+var ErrQueueFull = errors.New("the queue is full")
+
+// Queue holds submitted jobs until a worker claims them, and keeps their
+// state afterwards. Implementations must be safe for concurrent use, and a
+// persistent one (see pkg/postgres) may be shared by several servers.
+type Queue interface {
+	// Enqueue adds a new job, ready to run now
+	Enqueue(ctx context.Context, job *api.Job) error
+
+	// Claim marks the next job that's ready to run (the highest priority,
+	// then the longest waiting) as running, counting the attempt, and
+	// returns it. Running jobs claimed before leaseExpiry are
+	// assumed to have been lost (e.g. with the server that ran them) and are
+	// claimed again. It returns nil if no job is ready.
+	Claim(ctx context.Context, now time.Time, leaseExpiry time.Time) (*api.Job, error)
+
+	// Update saves a job's state, scan ID and error. Queued jobs wait until
+	// runAfter before they can be claimed again.
+	Update(ctx context.Context, job *api.Job, runAfter time.Time) error
+
+	// Get returns a job, or nil if there is no such job
+	Get(ctx context.Context, id string) (*api.Job, error)
+
+	// Status summarizes the queue
+	Status(ctx context.Context) (*QueueStatus, error)
+}
+
+// QueueStatus is served by GET /queue
+type QueueStatus struct {
+	Queued    int `json:"queued"`
+	Running   int `json:"running"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+
+	// OldestQueued is when the longest-waiting queued job was submitted
+	OldestQueued string `json:"oldest_queued,omitempty"`
+
+	// DeadLetters are the most recently failed jobs, up to MaxDeadLetters
+	DeadLetters []*api.Job `json:"-"`
+}
+
+// MaxDeadLetters is the most failed jobs listed in a QueueStatus
+const MaxDeadLetters = 100
+
+// MarshalJSON encodes dead letters with the protobuf JSON mapping, so job
+// states are names rather than numbers
+func (status *QueueStatus) MarshalJSON() ([]byte, error) {
+	type counts QueueStatus
+	deadLetters := make([]json.RawMessage, len(status.DeadLetters))
+	for i, job := range status.DeadLetters {
+		b, err := protojson.Marshal(job)
+		if err != nil {
+			return nil, err
+		}
+		deadLetters[i] = b
+	}
+	return json.Marshal(struct {
+		*counts
+		DeadLetters []json.RawMessage `json:"dead_letters"`
+	}{(*counts)(status), deadLetters})
+}
+
+// memoryQueue keeps jobs in memory, so they're lost when the server stops.
+// Finished jobs are only kept until MaxFinishedJobs more have succeeded (or
+// MaxDeadLetters more have failed), so a long-running server doesn't grow
+// without bound, while QueueStatus still counts every job.
+type memoryQueue struct {
+	mu   sync.Mutex
+	jobs map[string]*memoryJob
+	size int
+
+	// active holds the queued and running jobs, the only ones Claim looks at
+	active map[string]*memoryJob
+	queued int
+
+	// succeeded and failed hold the finished jobs still kept, oldest first
+	succeeded, failed           []*memoryJob
+	succeededCount, failedCount int
+}
+
+// MaxFinishedJobs is the most succeeded jobs an in-memory queue keeps, for
+// StreamEvents to look up
+const MaxFinishedJobs = 1000
+
+type memoryJob struct {
+	job      *api.Job
+	runAfter time.Time
+	claimed  time.Time
+	updated  time.Time
+}
+
+// NewMemoryQueue returns a queue holding up to size queued jobs in memory
+func NewMemoryQueue(size int) Queue {
+	return &memoryQueue{jobs: map[string]*memoryJob{}, active: map[string]*memoryJob{}, size: size}
+}
+
+func (q *memoryQueue) Enqueue(ctx context.Context, job *api.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.queued >= q.size {
+		return ErrQueueFull
+	}
+	now := time.Now()
+	j := &memoryJob{job: proto.Clone(job).(*api.Job), runAfter: now, updated: now}
+	q.jobs[job.Id] = j
+	q.track(j)
+	return nil
+}
+
+// track counts a job that was added or changed state, and drops the oldest
+// finished jobs past the limits
+func (q *memoryQueue) track(j *memoryJob) {
+	switch j.job.State {
+	case api.JobState_JOB_STATE_QUEUED:
+		q.active[j.job.Id] = j
+		q.queued++
+	case api.JobState_JOB_STATE_RUNNING:
+		q.active[j.job.Id] = j
+	case api.JobState_JOB_STATE_SUCCEEDED:
+		q.succeededCount++
+		q.succeeded = q.evict(append(q.succeeded, j), MaxFinishedJobs)
+	case api.JobState_JOB_STATE_FAILED:
+		q.failedCount++
+		q.failed = q.evict(append(q.failed, j), MaxDeadLetters)
+	}
+}
+
+// untrack reverses track for a job about to change state, other than the
+// succeeded and failed counts, which stay cumulative
+func (q *memoryQueue) untrack(j *memoryJob) {
+	switch j.job.State {
+	case api.JobState_JOB_STATE_QUEUED:
+		delete(q.active, j.job.Id)
+		q.queued--
+	case api.JobState_JOB_STATE_RUNNING:
+		delete(q.active, j.job.Id)
+	case api.JobState_JOB_STATE_SUCCEEDED:
+		q.succeeded = remove(q.succeeded, j)
+	case api.JobState_JOB_STATE_FAILED:
+		q.failed = remove(q.failed, j)
+	}
+}
+
+// evict drops the oldest of finished past max
+func (q *memoryQueue) evict(finished []*memoryJob, max int) []*memoryJob {
+	for len(finished) > max {
+		delete(q.jobs, finished[0].job.Id)
+		finished = finished[1:]
+	}
+	return finished
+}
+
+func remove(finished []*memoryJob, j *memoryJob) []*memoryJob {
+	for i := range finished {
+		if finished[i] == j {
+			return append(finished[:i:i], finished[i+1:]...)
+		}
+	}
+	return finished
+}
+
+func (q *memoryQueue) Claim(ctx context.Context, now time.Time, leaseExpiry time.Time) (*api.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var next *memoryJob
+	for _, j := range q.active {
+		ready := j.job.State == api.JobState_JOB_STATE_QUEUED && !j.runAfter.After(now)
+		lost := j.job.State == api.JobState_JOB_STATE_RUNNING && j.claimed.Before(leaseExpiry)
+		if !ready && !lost {
+			continue
+		}
+		if next == nil || before(j, next) {
+			next = j
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	q.untrack(next)
+	next.job.State = api.JobState_JOB_STATE_RUNNING
+	next.job.Attempts++
+	next.claimed = now
+	next.updated = now
+	q.track(next)
+	return proto.Clone(next.job).(*api.Job), nil
+}
+
+// before returns whether job a should run before job b
+func before(a *memoryJob, b *memoryJob) bool {
+	if a.job.Priority != b.job.Priority {
+		return a.job.Priority > b.job.Priority
+	}
+	if !a.runAfter.Equal(b.runAfter) {
+		return a.runAfter.Before(b.runAfter)
+	}
+	return a.job.Submitted < b.job.Submitted
+}
+
+func (q *memoryQueue) Update(ctx context.Context, job *api.Job, runAfter time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[job.Id]
+	if !ok {
+		return fmt.Errorf("job %s not found", job.Id)
+	}
+	changed := j.job.State != job.State
+	if changed {
+		q.untrack(j)
+	}
+	j.job.State = job.State
+	j.job.ScanId = job.ScanId
+	j.job.Error = job.Error
+	j.runAfter = runAfter
+	j.updated = time.Now()
+	if changed {
+		q.track(j)
+	}
+	return nil
+}
+
+func (q *memoryQueue) Get(ctx context.Context, id string) (*api.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	return proto.Clone(j.job).(*api.Job), nil
+}
+
+func (q *memoryQueue) Status(ctx context.Context) (*QueueStatus, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	status := &QueueStatus{
+		Queued:      q.queued,
+		Running:     len(q.active) - q.queued,
+		Succeeded:   q.succeededCount,
+		Failed:      q.failedCount,
+		DeadLetters: []*api.Job{},
+	}
+	for _, j := range q.active {
+		if j.job.State == api.JobState_JOB_STATE_QUEUED && (status.OldestQueued == "" || j.job.Submitted < status.OldestQueued) {
+			status.OldestQueued = j.job.Submitted
+		}
+	}
+	// Most recently failed first
+	for i := len(q.failed) - 1; i >= 0; i-- {
+		status.DeadLetters = append(status.DeadLetters, proto.Clone(q.failed[i].job).(*api.Job))
+	}
+	return status, nil
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/api"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue(2)
+	for _, id := range []string{"a", "b"} {
+		if err := q.Enqueue(ctx, &api.Job{Id: id, State: api.JobState_JOB_STATE_QUEUED, Submitted: "2023-06-22T02:38:4" + id}); err != nil {
+			t.Fatalf("expected no error on Enqueue(), got %v", err)
+		}
+	}
+	if err := q.Enqueue(ctx, &api.Job{Id: "c", State: api.JobState_JOB_STATE_QUEUED}); err != ErrQueueFull {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+
+	now := time.Now()
+	lease := now.Add(-time.Hour)
+	job, err := q.Claim(ctx, now, lease)
+	if err != nil || job == nil || job.Id != "a" || job.State != api.JobState_JOB_STATE_RUNNING || job.Attempts != 1 {
+		t.Fatalf("expected to claim job a, running its first attempt, got %v (err %v)", job, err)
+	}
+
+	// A retried job waits until it's ready to run again
+	job.State = api.JobState_JOB_STATE_QUEUED
+	job.Error = "scan failed"
+	if err := q.Update(ctx, job, now.Add(time.Minute)); err != nil {
+		t.Fatalf("expected no error on Update(), got %v", err)
+	}
+	if job, _ := q.Claim(ctx, now, lease); job == nil || job.Id != "b" {
+		t.Fatalf("expected to claim job b, got %v", job)
+	}
+	if job, _ := q.Claim(ctx, now, lease); job != nil {
+		t.Fatalf("expected no job to be ready, got %v", job)
+	}
+	job, _ = q.Claim(ctx, now.Add(time.Minute), lease)
+	if job == nil || job.Id != "a" || job.Attempts != 2 || job.Error != "scan failed" {
+		t.Fatalf("expected to claim job a again, got %v", job)
+	}
+
+	// Jobs still running after their lease are claimed again
+	job, _ = q.Claim(ctx, now.Add(2*time.Hour), now.Add(time.Hour))
+	if job == nil || job.Id != "b" || job.Attempts != 2 {
+		t.Fatalf("expected to claim lost job b again, got %v", job)
+	}
+
+	job.State = api.JobState_JOB_STATE_FAILED
+	if err := q.Update(ctx, job, now); err != nil {
+		t.Fatalf("expected no error on Update(), got %v", err)
+	}
+	status, err := q.Status(ctx)
+	if err != nil {
+		t.Fatalf("expected no error on Status(), got %v", err)
+	}
+	if status.Running != 1 || status.Failed != 1 || len(status.DeadLetters) != 1 || status.DeadLetters[0].Id != "b" {
+		t.Errorf("expected one running job and job b dead-lettered, got %+v", status)
+	}
+	if job, _ := q.Get(ctx, "missing"); job != nil {
+		t.Errorf("expected no job, got %v", job)
+	}
+}
+
+func TestQueueHandler(t *testing.T) {
+	q := NewMemoryQueue(QueueSize)
+	ctx := context.Background()
+	q.Enqueue(ctx, &api.Job{Id: "a", State: api.JobState_JOB_STATE_QUEUED, Submitted: "2023-06-22T02:38:46Z"})
+	q.Enqueue(ctx, &api.Job{Id: "b", State: api.JobState_JOB_STATE_QUEUED})
+	q.Update(ctx, &api.Job{Id: "b", State: api.JobState_JOB_STATE_FAILED, Error: "scan failed"}, time.Now())
+	s := New(nil, nil, Options{Queue: q})
+
+	rec := httptest.NewRecorder()
+	s.QueueHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/queue", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, wanted 200", rec.Code)
+	}
+	var status struct {
+		Queued       int                      `json:"queued"`
+		Failed       int                      `json:"failed"`
+		OldestQueued string                   `json:"oldest_queued"`
+		DeadLetters  []map[string]interface{} `json:"dead_letters"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("expected no error on json.Unmarshal(), got %v", err)
+	}
+	if status.Queued != 1 || status.Failed != 1 || status.OldestQueued != "2023-06-22T02:38:46Z" {
+		t.Errorf("got status %s", rec.Body.String())
+	}
+	if len(status.DeadLetters) != 1 || status.DeadLetters[0]["state"] != "JOB_STATE_FAILED" || status.DeadLetters[0]["error"] != "scan failed" {
+		t.Errorf("expected job b in the dead letters, got %v", status.DeadLetters)
+	}
+
+	rec = httptest.NewRecorder()
+	s.QueueHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/queue", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d, wanted 405", rec.Code)
+	}
+}
+
+func TestMemoryQueuePriority(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue(QueueSize)
+	q.Enqueue(ctx, &api.Job{Id: "low", State: api.JobState_JOB_STATE_QUEUED, Submitted: "2023-06-22T02:38:46Z"})
+	q.Enqueue(ctx, &api.Job{Id: "high", State: api.JobState_JOB_STATE_QUEUED, Submitted: "2023-06-22T02:38:47Z", Priority: 900})
+	now := time.Now()
+	if job, _ := q.Claim(ctx, now, now.Add(-time.Hour)); job == nil || job.Id != "high" {
+		t.Errorf("expected the higher priority job first, got %v", job)
+	}
+}
+
+func TestMemoryQueueEviction(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue(1)
+	total := MaxDeadLetters + 5
+	for i := 0; i < total; i++ {
+		id := fmt.Sprintf("job-%03d", i)
+		if err := q.Enqueue(ctx, &api.Job{Id: id, State: api.JobState_JOB_STATE_QUEUED}); err != nil {
+			t.Fatalf("expected no error on Enqueue(), got %v", err)
+		}
+		if err := q.Update(ctx, &api.Job{Id: id, State: api.JobState_JOB_STATE_FAILED}, time.Now()); err != nil {
+			t.Fatalf("expected no error on Update(), got %v", err)
+		}
+	}
+
+	if job, err := q.Get(ctx, "job-000"); err != nil || job != nil {
+		t.Errorf("expected the oldest failed job to be evicted, got %v (err %v)", job, err)
+	}
+	last := fmt.Sprintf("job-%03d", total-1)
+	if job, err := q.Get(ctx, last); err != nil || job == nil {
+		t.Errorf("expected the latest failed job to be kept, got %v (err %v)", job, err)
+	}
+
+	status, err := q.Status(ctx)
+	if err != nil {
+		t.Fatalf("expected no error on Status(), got %v", err)
+	}
+	if status.Queued != 0 || status.Failed != total || len(status.DeadLetters) != MaxDeadLetters {
+		t.Errorf("expected %d failed jobs and %d dead letters, got %+v", total, MaxDeadLetters, status)
+	}
+	if status.DeadLetters[0].Id != last {
+		t.Errorf("expected %s as the first dead letter, got %s", last, status.DeadLetters[0].Id)
+	}
+}
@@ -0,0 +1,600 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/api"
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"github.com/chainguard-dev/rumble/pkg/oci"
+	"github.com/chainguard-dev/rumble/pkg/priority"
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// ScanFunc runs a scan of an image with a scanner, recording it, and
+// returns its summary
+type ScanFunc func(ctx context.Context, image string, scanner string) (*types.ImageScanSummary, error)
+
+// SummariesFunc reads recorded scans, e.g. from BigQuery or PostgreSQL
+type SummariesFunc func(ctx context.Context, filter query.Filter) ([]*types.ImageScanSummary, error)
+
+const (
+	// QueueSize is the most jobs that can be waiting to run at once in the
+	// default, in-memory queue
+	QueueSize = 1000
+
+	// DefaultListLimit and MaxListLimit bound the scans returned by ListScans
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+
+	// subscriberBuffer is how many events a slow StreamEvents client can
+	// fall behind by before events are dropped for it
+	subscriberBuffer = 64
+)
+
+// Options control how jobs are queued and retried
+type Options struct {
+	// Queue holds the jobs, defaulting to an in-memory queue of QueueSize
+	Queue Queue
+
+	// MaxAttempts is how many times a job is run before it fails,
+	// defaulting to 3
+	MaxAttempts int
+
+	// RetryDelay is how long a job waits after its first failed attempt,
+	// doubling after each attempt, defaulting to a minute
+	RetryDelay time.Duration
+
+	// Lease is how long a job may run for, after which it's assumed lost and
+	// run again, defaulting to an hour
+	Lease time.Duration
+
+	// PollInterval is how often idle workers check the queue for jobs
+	// submitted elsewhere or ready to retry, defaulting to 5 seconds
+	PollInterval time.Duration
+
+	// Limiter, if set, limits how many jobs scan images from the same
+	// registry at once
+	Limiter *oci.Limiter
+
+	// Policy, if set, scores submitted jobs so that the highest priority
+	// ones run first. Jobs otherwise run in the order they were submitted.
+	Policy priority.Policy
+
+	// Published looks up when an image was built, for policies that need it
+	Published func(ctx context.Context, image string) (time.Time, error)
+
+	// Digest, if set, resolves an image to its digest (as repo@digest), so
+	// submissions of the same digest and scanner while a job for it is
+	// queued or running are coalesced into that job. Without it, or if it
+	// fails, submissions are only coalesced by their image reference.
+	Digest func(ctx context.Context, image string) (string, error)
+
+	// Webhooks are posted to when jobs run here succeed or are
+	// dead-lettered
+	Webhooks []Webhook
+
+	// Images, if set, rejects submissions of the images it skips
+	Images *config.ImageFilter
+}
+
+// Server implements the Rumble gRPC service. Submitted scans are queued and
+// run by a fixed number of workers.
+type Server struct {
+	api.UnimplementedRumbleServer
+
+	scan      ScanFunc
+	summaries SummariesFunc
+	opts      Options
+	queue     Queue
+
+	// wake tells an idle worker a job was just submitted
+	wake chan struct{}
+
+	// draining is closed by Drain, workers counts the running workers and
+	// deliveries the webhook payloads still being posted
+	draining   chan struct{}
+	drainOnce  sync.Once
+	workers    sync.WaitGroup
+	deliveries sync.WaitGroup
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+
+	// inflight maps the digest and scanner of each job submitted here that
+	// may still be queued or running to its ID, and inflightKeys the other
+	// way, for coalescing submissions
+	inflightMu   sync.Mutex
+	inflight     map[string]string
+	inflightKeys map[string]string
+}
+
+type subscriber struct {
+	jobID  string
+	events chan *api.ScanEvent
+}
+
+// New returns a server running scans with scan and reading them back with
+// summaries. Jobs only run once Start is called.
+func New(scan ScanFunc, summaries SummariesFunc, opts Options) *Server {
+	if opts.Queue == nil {
+		opts.Queue = NewMemoryQueue(QueueSize)
+	}
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.RetryDelay == 0 {
+		opts.RetryDelay = time.Minute
+	}
+	if opts.Lease == 0 {
+		opts.Lease = time.Hour
+	}
+	if opts.PollInterval == 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	return &Server{
+		scan:         scan,
+		summaries:    summaries,
+		opts:         opts,
+		queue:        opts.Queue,
+		wake:         make(chan struct{}, 1),
+		draining:     make(chan struct{}),
+		subscribers:  map[*subscriber]struct{}{},
+		inflight:     map[string]string{},
+		inflightKeys: map[string]string{},
+	}
+}
+
+// Start runs queued jobs with the given number of workers, until ctx is done
+// or the server is drained. Jobs still running when ctx is done are
+// interrupted and requeued.
+func (s *Server) Start(ctx context.Context, workers int) {
+	for i := 0; i < workers; i++ {
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			s.work(ctx)
+		}()
+	}
+}
+
+// Drain stops workers from claiming more jobs and SubmitScan from accepting
+// them, then waits for the running jobs to finish and their webhooks to be
+// posted, or for ctx to be done
+func (s *Server) Drain(ctx context.Context) error {
+	s.drainOnce.Do(func() { close(s.draining) })
+	finished := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		s.deliveries.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Draining reports whether Drain was called
+func (s *Server) Draining() bool {
+	select {
+	case <-s.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Server) work(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+	for ctx.Err() == nil && !s.Draining() {
+		now := time.Now()
+		job, err := s.queue.Claim(ctx, now, now.Add(-s.opts.Lease))
+		if err != nil && ctx.Err() == nil {
+			fmt.Printf("WARNING: could not claim a job: %s\n", err.Error())
+		}
+		if job != nil {
+			s.run(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-s.draining:
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) run(ctx context.Context, job *api.Job) {
+	s.publish(&api.ScanEvent{Job: job})
+	var summary *types.ImageScanSummary
+	var err error
+	if job.Attempts > int32(s.opts.MaxAttempts) {
+		// A job claimed again after its lease expired may have already used
+		// all its attempts
+		err = fmt.Errorf("job was lost while running after %d attempt(s)", job.Attempts-1)
+	} else {
+		fmt.Printf("Running job %s (attempt %d of %d): scanning %s with %s\n", job.Id, job.Attempts, s.opts.MaxAttempts, job.Image, job.Scanner)
+		scanCtx, cancel := context.WithTimeout(ctx, s.opts.Lease)
+		var release func()
+		if release, err = s.opts.Limiter.AcquireImage(scanCtx, job.Image); err == nil {
+			summary, err = s.scan(scanCtx, job.Image, job.Scanner)
+			release()
+		}
+		cancel()
+	}
+
+	var scan *api.Scan
+	runAfter := time.Now()
+	switch {
+	case err == nil:
+		fmt.Printf("Job %s succeeded (scan_id=\"%s\")\n", job.Id, summary.ID)
+		job.State = api.JobState_JOB_STATE_SUCCEEDED
+		job.ScanId = summary.ID
+		job.Error = ""
+		scan = ScanProto(summary)
+	case ctx.Err() != nil:
+		// The server is stopping, so the job is left for the next one
+		fmt.Printf("WARNING: job %s was interrupted, requeueing it\n", job.Id)
+		job.State = api.JobState_JOB_STATE_QUEUED
+		job.Error = err.Error()
+	case job.Attempts >= int32(s.opts.MaxAttempts):
+		fmt.Printf("WARNING: job %s failed after %d attempt(s), dead-lettering it: %s\n", job.Id, job.Attempts, err.Error())
+		job.State = api.JobState_JOB_STATE_FAILED
+		job.Error = err.Error()
+	default:
+		delay := s.opts.RetryDelay << (job.Attempts - 1)
+		fmt.Printf("WARNING: job %s failed, retrying in %s: %s\n", job.Id, delay, err.Error())
+		job.State = api.JobState_JOB_STATE_QUEUED
+		job.Error = err.Error()
+		runAfter = runAfter.Add(delay)
+	}
+	// The update must be saved even if the server is stopping
+	if err := s.queue.Update(context.Background(), job, runAfter); err != nil {
+		fmt.Printf("WARNING: could not update job %s: %s\n", job.Id, err.Error())
+		return
+	}
+	if done(job) {
+		s.inflightMu.Lock()
+		if key, ok := s.inflightKeys[job.Id]; ok {
+			delete(s.inflight, key)
+			delete(s.inflightKeys, job.Id)
+		}
+		s.inflightMu.Unlock()
+		s.notify(job, summary)
+	}
+	s.publish(&api.ScanEvent{Job: job, Scan: scan})
+}
+
+// publish sends an event to subscribers, without waiting on slow ones. Each
+// server only publishes changes to the jobs it runs.
+func (s *Server) publish(event *api.ScanEvent) {
+	event = proto.Clone(event).(*api.ScanEvent)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subscribers {
+		if sub.jobID != "" && sub.jobID != event.Job.Id {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			fmt.Printf("WARNING: dropped event for job %s, a StreamEvents client is falling behind\n", event.Job.Id)
+		}
+	}
+}
+
+func (s *Server) SubmitScan(ctx context.Context, req *api.SubmitScanRequest) (*api.Job, error) {
+	if s.Draining() {
+		return nil, status.Error(codes.Unavailable, "the server is shutting down")
+	}
+	if req.Image == "" {
+		return nil, status.Error(codes.InvalidArgument, "image is required")
+	}
+	if _, err := name.ParseReference(req.Image); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid image: %s", err.Error())
+	}
+	if s.opts.Images != nil {
+		if reason := s.opts.Images.Skip(req.Image); reason != "" {
+			return nil, status.Errorf(codes.FailedPrecondition, "%s isn't scanned here, as %s", req.Image, reason)
+		}
+	}
+	scanner := req.Scanner
+	if scanner == "" {
+		scanner = "grype"
+	}
+	if scanner != "grype" && scanner != "trivy" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid scanner: %s", scanner)
+	}
+	id, err := newJobID()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// A submission for a digest being scanned already gets that job, so
+	// every caller follows the same scan. The digest and priority may need
+	// a registry, so the lock is only held to check for a job again and
+	// enqueue one.
+	key := s.digest(ctx, req.Image) + " " + scanner
+	if existing, err := s.coalesce(ctx, key, req.Image); err != nil || existing != nil {
+		return existing, err
+	}
+	job := &api.Job{
+		Id:        id,
+		Image:     req.Image,
+		Scanner:   scanner,
+		State:     api.JobState_JOB_STATE_QUEUED,
+		Submitted: time.Now().UTC().Format(time.RFC3339),
+		Priority:  s.priority(ctx, req.Image, scanner),
+	}
+	s.inflightMu.Lock()
+	defer s.inflightMu.Unlock()
+	if existing, err := s.coalesceLocked(ctx, key, req.Image); err != nil || existing != nil {
+		return existing, err
+	}
+
+	if err := s.queue.Enqueue(ctx, job); err != nil {
+		if errors.Is(err, ErrQueueFull) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.inflight[key] = id
+	s.inflightKeys[id] = key
+	fmt.Printf("Queued job %s: scanning %s with %s\n", id, job.Image, job.Scanner)
+	s.publish(&api.ScanEvent{Job: job})
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// digest returns the digest an image resolves to with Options.Digest, or
+// the image itself if that isn't set or fails
+func (s *Server) digest(ctx context.Context, image string) string {
+	if s.opts.Digest == nil {
+		return image
+	}
+	digest, err := s.opts.Digest(ctx, image)
+	if err != nil {
+		fmt.Printf("WARNING: could not resolve the digest of %s, coalescing it by reference: %s\n", image, err.Error())
+		return image
+	}
+	return digest
+}
+
+// coalesce returns the job for a key if it's still queued or running
+func (s *Server) coalesce(ctx context.Context, key string, image string) (*api.Job, error) {
+	s.inflightMu.Lock()
+	defer s.inflightMu.Unlock()
+	return s.coalesceLocked(ctx, key, image)
+}
+
+// coalesceLocked is coalesce with inflightMu held, forgetting the job for
+// the key once it's done
+func (s *Server) coalesceLocked(ctx context.Context, key string, image string) (*api.Job, error) {
+	id, ok := s.inflight[key]
+	if !ok {
+		return nil, nil
+	}
+	// The job may have been run by another server sharing the queue
+	job, err := s.queue.Get(ctx, id)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if job == nil || done(job) {
+		delete(s.inflight, key)
+		delete(s.inflightKeys, id)
+		return nil, nil
+	}
+	fmt.Printf("Coalesced the submission of %s with %s into job %s\n", image, job.Scanner, job.Id)
+	return job, nil
+}
+
+// priority scores a job with the priority policy, if any. Lookups that fail
+// are left out of the score rather than failing the submission. FIFO scores
+// every job the same, so it doesn't look anything up.
+func (s *Server) priority(ctx context.Context, image string, scanner string) int32 {
+	if fifo, _ := priority.Parse(priority.FIFO); s.opts.Policy == nil || s.opts.Policy == fifo {
+		return 0
+	}
+	candidate := &priority.Candidate{Image: image, Scanner: scanner}
+	latest, err := s.summaries(ctx, query.Filter{Image: image, Scanner: scanner, Limit: 1})
+	if err != nil {
+		fmt.Printf("WARNING: could not look up the latest scan of %s: %s\n", image, err.Error())
+	} else if len(latest) > 0 {
+		candidate.Latest = latest[0]
+	}
+	if s.opts.Policy.NeedsPublished() && s.opts.Published != nil {
+		if candidate.Published, err = s.opts.Published(ctx, image); err != nil {
+			fmt.Printf("WARNING: could not look up when %s was built: %s\n", image, err.Error())
+		}
+	}
+	return int32(math.Round(s.opts.Policy.Score(candidate, time.Now()) * PriorityScale))
+}
+
+// PriorityScale converts priority policy scores (from 0 to 1) to job
+// priorities
+const PriorityScale = 1000
+
+func (s *Server) GetScan(ctx context.Context, req *api.GetScanRequest) (*api.Scan, error) {
+	if req.ScanId == "" {
+		return nil, status.Error(codes.InvalidArgument, "scan_id is required")
+	}
+	summaries, err := s.summaries(ctx, query.Filter{ID: req.ScanId, Limit: 1})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if len(summaries) == 0 {
+		return nil, status.Errorf(codes.NotFound, "scan %s not found", req.ScanId)
+	}
+	return ScanProto(summaries[0]), nil
+}
+
+func (s *Server) ListScans(ctx context.Context, req *api.ListScansRequest) (*api.ListScansResponse, error) {
+	since, err := query.ParseSince(req.Since, time.Now())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+	filter := query.Filter{
+		Image:      req.Image,
+		Scanner:    req.Scanner,
+		Since:      since,
+		Severity:   req.Severity,
+		LatestOnly: req.LatestOnly,
+		Limit:      limit,
+	}
+	if _, _, err := query.SummarySQL("", filter); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	summaries, err := s.summaries(ctx, filter)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &api.ListScansResponse{Scans: make([]*api.Scan, len(summaries))}
+	for i, summary := range summaries {
+		resp.Scans[i] = ScanProto(summary)
+	}
+	return resp, nil
+}
+
+func (s *Server) StreamEvents(req *api.StreamEventsRequest, stream api.Rumble_StreamEventsServer) error {
+	sub := &subscriber{jobID: req.JobId, events: make(chan *api.ScanEvent, subscriberBuffer)}
+
+	// Subscribe before reading the job's current state, so no change is
+	// missed in between
+	s.mu.Lock()
+	s.subscribers[sub] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, sub)
+		s.mu.Unlock()
+	}()
+	var current *api.Job
+	if req.JobId != "" {
+		var err error
+		current, err = s.queue.Get(stream.Context(), req.JobId)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if current == nil {
+			return status.Errorf(codes.NotFound, "job %s not found", req.JobId)
+		}
+	}
+
+	if current != nil {
+		event := &api.ScanEvent{Job: current}
+		if current.State == api.JobState_JOB_STATE_SUCCEEDED {
+			// The scan itself is only sent with the change to succeeded, so
+			// look it up again
+			if scan, err := s.GetScan(stream.Context(), &api.GetScanRequest{ScanId: current.ScanId}); err == nil {
+				event.Scan = scan
+			}
+		}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+		if done(current) {
+			return nil
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-sub.events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+			if req.JobId != "" && done(event.Job) {
+				return nil
+			}
+		}
+	}
+}
+
+// done returns whether a job has finished, successfully or not
+func done(job *api.Job) bool {
+	return job.State == api.JobState_JOB_STATE_SUCCEEDED || job.State == api.JobState_JOB_STATE_FAILED
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ScanProto returns a scan summary as an API message
+func ScanProto(summary *types.ImageScanSummary) *api.Scan {
+	return &api.Scan{
+		Id:               summary.ID,
+		Image:            summary.Image,
+		Digest:           summary.Digest,
+		Scanner:          summary.Scanner,
+		ScannerVersion:   summary.ScannerVersion,
+		ScannerDbVersion: summary.ScannerDbVersion,
+		Time:             summary.Time,
+		Created:          summary.Created,
+		Success:          summary.Success,
+		Severities: &api.SeverityCounts{
+			Critical:   int32(summary.CritCveCount),
+			High:       int32(summary.HighCveCount),
+			Medium:     int32(summary.MedCveCount),
+			Low:        int32(summary.LowCveCount),
+			Negligible: int32(summary.NegligibleCveCount),
+			Unknown:    int32(summary.UnknownCveCount),
+			Total:      int32(summary.TotCveCount),
+		},
+		OsName:                  summary.OsName,
+		OsVersion:               summary.OsVersion,
+		SignatureVerified:       summary.SignatureVerified,
+		Attested:                summary.Attested,
+		AttestationVerification: summary.AttestationVerification,
+		Eol:                     summary.EOL,
+	}
+}
+
+// QueueHandler serves GET /queue, the queue's status as JSON
+func (s *Server) QueueHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		status, err := s.queue.Status(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	})
+}
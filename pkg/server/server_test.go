@@ -0,0 +1,324 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/api"
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"github.com/chainguard-dev/rumble/pkg/priority"
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testClient serves s over an in-memory connection
+func testClient(t *testing.T, s *Server) api.RumbleClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	api.RegisterRumbleServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("expected no error on grpc.Dial(), got %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return api.NewRumbleClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	recorded := []*types.ImageScanSummary{}
+	release := make(chan struct{})
+	scan := func(ctx context.Context, image string, scanner string) (*types.ImageScanSummary, error) {
+		<-release
+		if image == "cgr.dev/chainguard/broken:latest" {
+			return nil, fmt.Errorf("scan failed")
+		}
+		summary := &types.ImageScanSummary{Image: image, Scanner: scanner, Time: "2023-06-22T02:38:46Z", HighCveCount: 1, TotCveCount: 1, Success: true}
+		summary.SetID()
+		recorded = append(recorded, summary)
+		return summary, nil
+	}
+	summaries := func(ctx context.Context, filter query.Filter) ([]*types.ImageScanSummary, error) {
+		matching := []*types.ImageScanSummary{}
+		for _, summary := range recorded {
+			if filter.ID == "" || summary.ID == filter.ID {
+				matching = append(matching, summary)
+			}
+		}
+		return matching, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := New(scan, summaries, Options{MaxAttempts: 2, RetryDelay: time.Millisecond, PollInterval: 10 * time.Millisecond})
+	s.Start(ctx, 1)
+	client := testClient(t, s)
+
+	if _, err := client.SubmitScan(ctx, &api.SubmitScanRequest{Image: "cgr.dev/chainguard/static:latest", Scanner: "clair"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for an invalid scanner, got %v", err)
+	}
+	job, err := client.SubmitScan(ctx, &api.SubmitScanRequest{Image: "cgr.dev/chainguard/static:latest"})
+	if err != nil {
+		t.Fatalf("expected no error on SubmitScan(), got %v", err)
+	}
+	if job.State != api.JobState_JOB_STATE_QUEUED || job.Scanner != "grype" {
+		t.Errorf("expected a queued grype job, got %v", job)
+	}
+
+	// The stream starts with the job's current state and ends once it's done
+	stream, err := client.StreamEvents(ctx, &api.StreamEventsRequest{JobId: job.Id})
+	if err != nil {
+		t.Fatalf("expected no error on StreamEvents(), got %v", err)
+	}
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("expected no error on Recv(), got %v", err)
+	}
+	if event.Job.Id != job.Id {
+		t.Errorf("expected an event for job %s, got %v", job.Id, event)
+	}
+	close(release)
+	var last *api.ScanEvent
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		last = event
+	}
+	if last == nil || last.Job.State != api.JobState_JOB_STATE_SUCCEEDED || last.Scan == nil || last.Scan.Severities.High != 1 {
+		t.Fatalf("expected the stream to end with the succeeded scan, got %v", last)
+	}
+
+	scanResult, err := client.GetScan(ctx, &api.GetScanRequest{ScanId: last.Job.ScanId})
+	if err != nil {
+		t.Fatalf("expected no error on GetScan(), got %v", err)
+	}
+	if scanResult.Image != "cgr.dev/chainguard/static:latest" || scanResult.Id != last.Job.ScanId {
+		t.Errorf("expected the recorded scan, got %v", scanResult)
+	}
+	if _, err := client.GetScan(ctx, &api.GetScanRequest{ScanId: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for a missing scan, got %v", err)
+	}
+	list, err := client.ListScans(ctx, &api.ListScansRequest{})
+	if err != nil {
+		t.Fatalf("expected no error on ListScans(), got %v", err)
+	}
+	if len(list.Scans) != 1 {
+		t.Errorf("got %d scans, wanted 1", len(list.Scans))
+	}
+	if _, err := client.ListScans(ctx, &api.ListScansRequest{Severity: "severe"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for an invalid severity, got %v", err)
+	}
+
+	// Failed jobs are retried, then end the stream too, with the error
+	job, err = client.SubmitScan(ctx, &api.SubmitScanRequest{Image: "cgr.dev/chainguard/broken:latest", Scanner: "trivy"})
+	if err != nil {
+		t.Fatalf("expected no error on SubmitScan(), got %v", err)
+	}
+	stream, err = client.StreamEvents(ctx, &api.StreamEventsRequest{JobId: job.Id})
+	if err != nil {
+		t.Fatalf("expected no error on StreamEvents(), got %v", err)
+	}
+	last = nil
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		last = event
+	}
+	if last == nil || last.Job.State != api.JobState_JOB_STATE_FAILED || last.Job.Error != "scan failed" || last.Job.Attempts != 2 {
+		t.Errorf("expected the stream to end with the job failed after 2 attempts, got %v", last)
+	}
+}
+
+func TestSubmitScanPriority(t *testing.T) {
+	summaries := func(ctx context.Context, filter query.Filter) ([]*types.ImageScanSummary, error) {
+		if filter.Image == "cgr.dev/chainguard/vulnerable:latest" {
+			return []*types.ImageScanSummary{{Image: filter.Image, CritCveCount: 3}}, nil
+		}
+		return []*types.ImageScanSummary{{Image: filter.Image}}, nil
+	}
+	policy, _ := priority.Parse(priority.Severity)
+	s := New(nil, summaries, Options{Policy: policy})
+	ctx := context.Background()
+	vulnerable, err := s.SubmitScan(ctx, &api.SubmitScanRequest{Image: "cgr.dev/chainguard/vulnerable:latest"})
+	if err != nil {
+		t.Fatalf("expected no error on SubmitScan(), got %v", err)
+	}
+	clean, err := s.SubmitScan(ctx, &api.SubmitScanRequest{Image: "cgr.dev/chainguard/static:latest"})
+	if err != nil {
+		t.Fatalf("expected no error on SubmitScan(), got %v", err)
+	}
+	if vulnerable.Priority != 750 || clean.Priority != 0 {
+		t.Errorf("got priorities %d and %d, wanted 750 and 0", vulnerable.Priority, clean.Priority)
+	}
+}
+
+func TestSubmitScanFIFO(t *testing.T) {
+	summaries := func(ctx context.Context, filter query.Filter) ([]*types.ImageScanSummary, error) {
+		t.Errorf("expected no lookup of the latest scan of %s under FIFO", filter.Image)
+		return nil, nil
+	}
+	policy, _ := priority.Parse(priority.FIFO)
+	s := New(nil, summaries, Options{Policy: policy})
+	job, err := s.SubmitScan(context.Background(), &api.SubmitScanRequest{Image: "cgr.dev/chainguard/static:latest"})
+	if err != nil {
+		t.Fatalf("expected no error on SubmitScan(), got %v", err)
+	}
+	if job.Priority != 0 {
+		t.Errorf("got priority %d, wanted 0", job.Priority)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	scan := func(ctx context.Context, image string, scanner string) (*types.ImageScanSummary, error) {
+		close(started)
+		<-release
+		summary := &types.ImageScanSummary{Image: image, Scanner: scanner, Time: "2023-06-22T02:38:46Z", Success: true}
+		summary.SetID()
+		return summary, nil
+	}
+	summaries := func(ctx context.Context, filter query.Filter) ([]*types.ImageScanSummary, error) {
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := NewMemoryQueue(QueueSize)
+	s := New(scan, summaries, Options{Queue: queue, PollInterval: 10 * time.Millisecond})
+	s.Start(ctx, 1)
+	client := testClient(t, s)
+
+	job, err := client.SubmitScan(ctx, &api.SubmitScanRequest{Image: "cgr.dev/chainguard/static:latest"})
+	if err != nil {
+		t.Fatalf("expected no error on SubmitScan(), got %v", err)
+	}
+	<-started
+
+	// Draining waits for the running scan, and refuses new ones meanwhile
+	drained := make(chan error)
+	go func() { drained <- s.Drain(ctx) }()
+	for !s.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := client.SubmitScan(ctx, &api.SubmitScanRequest{Image: "cgr.dev/chainguard/go:latest"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable while draining, got %v", err)
+	}
+	select {
+	case err := <-drained:
+		t.Fatalf("expected Drain() to wait for the running scan, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-drained; err != nil {
+		t.Fatalf("expected no error on Drain(), got %v", err)
+	}
+	finished, err := queue.Get(ctx, job.Id)
+	if err != nil {
+		t.Fatalf("expected no error on Get(), got %v", err)
+	}
+	if finished.State != api.JobState_JOB_STATE_SUCCEEDED {
+		t.Errorf("expected the running job to finish, got %v", finished)
+	}
+}
+
+func TestSubmitScanCoalesce(t *testing.T) {
+	var mu sync.Mutex
+	scans := 0
+	release := make(chan struct{})
+	scan := func(ctx context.Context, image string, scanner string) (*types.ImageScanSummary, error) {
+		mu.Lock()
+		scans++
+		mu.Unlock()
+		<-release
+		summary := &types.ImageScanSummary{Image: image, Scanner: scanner, Time: "2023-06-22T02:38:46Z", Success: true}
+		summary.SetID()
+		return summary, nil
+	}
+	summaries := func(ctx context.Context, filter query.Filter) ([]*types.ImageScanSummary, error) {
+		return nil, nil
+	}
+	digest := func(ctx context.Context, image string) (string, error) {
+		// Both tags are the same image
+		return "cgr.dev/chainguard/static@sha256:0d5a9a0e0f3e4d3b5e3c0e2f0f1d0b1a0c3e1f4f3e2d1c0b9a8f7e6d5c4b3a29", nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := New(scan, summaries, Options{PollInterval: 10 * time.Millisecond, Digest: digest})
+	s.Start(ctx, 2)
+	client := testClient(t, s)
+
+	first, err := client.SubmitScan(ctx, &api.SubmitScanRequest{Image: "cgr.dev/chainguard/static:latest"})
+	if err != nil {
+		t.Fatalf("expected no error on SubmitScan(), got %v", err)
+	}
+	for _, image := range []string{"cgr.dev/chainguard/static:latest", "cgr.dev/chainguard/static:v1"} {
+		job, err := client.SubmitScan(ctx, &api.SubmitScanRequest{Image: image})
+		if err != nil {
+			t.Fatalf("expected no error on SubmitScan(), got %v", err)
+		}
+		if job.Id != first.Id {
+			t.Errorf("expected the submission of %s to be coalesced into job %s, got job %s", image, first.Id, job.Id)
+		}
+	}
+	// Another scanner is a different scan
+	trivy, err := client.SubmitScan(ctx, &api.SubmitScanRequest{Image: "cgr.dev/chainguard/static:latest", Scanner: "trivy"})
+	if err != nil {
+		t.Fatalf("expected no error on SubmitScan(), got %v", err)
+	}
+	if trivy.Id == first.Id {
+		t.Errorf("expected a separate job for trivy")
+	}
+
+	stream, err := client.StreamEvents(ctx, &api.StreamEventsRequest{JobId: first.Id})
+	if err != nil {
+		t.Fatalf("expected no error on StreamEvents(), got %v", err)
+	}
+	close(release)
+	for {
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+	mu.Lock()
+	if scans != 2 {
+		t.Errorf("expected 2 scans, one per scanner, got %d", scans)
+	}
+	mu.Unlock()
+
+	// Once the scan is done, the digest is scanned again
+	again, err := client.SubmitScan(ctx, &api.SubmitScanRequest{Image: "cgr.dev/chainguard/static:latest"})
+	if err != nil {
+		t.Fatalf("expected no error on SubmitScan(), got %v", err)
+	}
+	if again.Id == first.Id {
+		t.Errorf("expected a new job after the first one finished")
+	}
+}
+
+func TestSubmitScanSkipped(t *testing.T) {
+	scan := func(ctx context.Context, image string, scanner string) (*types.ImageScanSummary, error) {
+		return nil, fmt.Errorf("not run")
+	}
+	s := New(scan, nil, Options{Images: &config.ImageFilter{Exclude: []string{"*-internal:*"}}})
+	ctx := context.Background()
+	if _, err := s.SubmitScan(ctx, &api.SubmitScanRequest{Image: "cgr.dev/chainguard/app-internal:latest"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected FailedPrecondition for an excluded image, got %v", err)
+	}
+	if _, err := s.SubmitScan(ctx, &api.SubmitScanRequest{Image: "cgr.dev/chainguard/static:latest"}); err != nil {
+		t.Errorf("expected no error on SubmitScan(), got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/api"
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"github.com/chainguard-dev/rumble/pkg/events"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// The types of webhook payloads, also sent as the X-Rumble-Event header
+const (
+	WebhookScanCompleted = "scan.completed"
+	WebhookScanFailed    = "scan.failed"
+)
+
+// webhookAttempts is how many times a payload is posted before giving up
+// on it, waiting webhookRetryDelay after the first failure and doubling
+// after each one
+const webhookAttempts = 3
+
+var (
+	webhookRetryDelay = 5 * time.Second
+	webhookClient     = &http.Client{Timeout: 30 * time.Second}
+)
+
+// Webhook is a URL posted to when a job for a matching image succeeds or
+// is dead-lettered
+type Webhook struct {
+	Name string
+	URL  string
+
+	// Secret, if set, signs each request (see Sign)
+	Secret string
+
+	// Images are glob patterns (see config.Match) of the images to post
+	// about, or empty for every image
+	Images []string
+}
+
+// WebhookPayload is the JSON body posted to webhooks
+type WebhookPayload struct {
+	Type string     `json:"type"`
+	Job  WebhookJob `json:"job"`
+
+	// Scan is the scan-completed event of the recorded scan, or nil if the
+	// job failed
+	Scan *events.Event `json:"scan,omitempty"`
+}
+
+// WebhookJob is the job of a webhook payload
+type WebhookJob struct {
+	ID        string `json:"id"`
+	Image     string `json:"image"`
+	Scanner   string `json:"scanner"`
+	State     string `json:"state"`
+	Submitted string `json:"submitted"`
+	Attempts  int32  `json:"attempts"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Sign returns the X-Rumble-Signature-256 header of a request: the
+// HMAC-SHA256 of its X-Rumble-Timestamp header, a ".", and its body, keyed
+// with the webhook's secret
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *Webhook) matches(image string) bool {
+	if len(w.Images) == 0 {
+		return true
+	}
+	for _, pattern := range w.Images {
+		if config.Match(pattern, image) {
+			return true
+		}
+	}
+	return false
+}
+
+// notify posts a finished job (and its summary, if it succeeded) to the
+// matching webhooks, in the background
+func (s *Server) notify(job *api.Job, summary *types.ImageScanSummary) {
+	payload := &WebhookPayload{
+		Type: WebhookScanFailed,
+		Job: WebhookJob{
+			ID:        job.Id,
+			Image:     job.Image,
+			Scanner:   job.Scanner,
+			State:     strings.ToLower(strings.TrimPrefix(job.State.String(), "JOB_STATE_")),
+			Submitted: job.Submitted,
+			Attempts:  job.Attempts,
+			Error:     job.Error,
+		},
+	}
+	if job.State == api.JobState_JOB_STATE_SUCCEEDED {
+		payload.Type = WebhookScanCompleted
+		payload.Scan = events.New(summary)
+	}
+	var body []byte
+	for i := range s.opts.Webhooks {
+		webhook := &s.opts.Webhooks[i]
+		if !webhook.matches(job.Image) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(payload); err != nil {
+				fmt.Printf("WARNING: could not encode the webhook payload of job %s: %s\n", job.Id, err.Error())
+				return
+			}
+		}
+		s.deliveries.Add(1)
+		go func() {
+			defer s.deliveries.Done()
+			if err := webhook.deliver(payload.Type, body); err != nil {
+				fmt.Printf("WARNING: could not post job %s to webhook %s: %s\n", job.Id, webhook.Name, err.Error())
+			}
+		}()
+	}
+}
+
+// deliver posts a payload, retrying failures. Every attempt of a delivery
+// has the same X-Rumble-Delivery ID, so receivers can ignore repeats.
+func (w *Webhook) deliver(event string, body []byte) error {
+	id, err := newJobID()
+	if err != nil {
+		return err
+	}
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		err = w.post(id, event, body)
+		if err == nil || attempt == webhookAttempts {
+			return err
+		}
+		fmt.Printf("WARNING: posting to webhook %s failed, retrying in %s: %s\n", w.Name, delay, err.Error())
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (w *Webhook) post(id string, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rumble")
+	req.Header.Set("X-Rumble-Event", event)
+	req.Header.Set("X-Rumble-Delivery", id)
+	req.Header.Set("X-Rumble-Timestamp", timestamp)
+	if w.Secret != "" {
+		req.Header.Set("X-Rumble-Signature-256", Sign(w.Secret, timestamp, body))
+	}
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/api"
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestSign(t *testing.T) {
+	// As computed by: printf '1687401526.{}' | openssl dgst -sha256 -hmac s3cret
+	expected := "sha256=4a1a18bf27190174c27ee8d9ca172d84f48c148dd29d9d88833af0215465d4b9"
+	if got := Sign("s3cret", "1687401526", []byte("{}")); got != expected {
+		t.Errorf("got signature %s, wanted %s", got, expected)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	webhookRetryDelay = time.Millisecond
+	var mu sync.Mutex
+	received := []*http.Request{}
+	payloads := []*WebhookPayload{}
+	failures := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		// The first delivery fails, and is retried
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Rumble-Signature-256") != Sign("s3cret", r.Header.Get("X-Rumble-Timestamp"), body) {
+			t.Errorf("expected the request to be signed, got headers %v", r.Header)
+		}
+		payload := &WebhookPayload{}
+		if err := json.Unmarshal(body, payload); err != nil {
+			t.Errorf("expected no error decoding the payload, got %v", err)
+		}
+		received = append(received, r)
+		payloads = append(payloads, payload)
+	}))
+	defer srv.Close()
+
+	scan := func(ctx context.Context, image string, scanner string) (*types.ImageScanSummary, error) {
+		if image == "cgr.dev/chainguard/broken:latest" {
+			return nil, fmt.Errorf("scan failed")
+		}
+		summary := &types.ImageScanSummary{Image: image, Scanner: scanner, Time: "2023-06-22T02:38:46Z", HighCveCount: 1, TotCveCount: 1, Success: true}
+		summary.SetID()
+		return summary, nil
+	}
+	summaries := func(ctx context.Context, filter query.Filter) ([]*types.ImageScanSummary, error) {
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := New(scan, summaries, Options{
+		MaxAttempts:  1,
+		PollInterval: 10 * time.Millisecond,
+		Webhooks: []Webhook{
+			{Name: "automation", URL: srv.URL, Secret: "s3cret", Images: []string{"cgr.dev/chainguard/*"}},
+		},
+	})
+	for _, image := range []string{"cgr.dev/chainguard/static:latest", "cgr.dev/chainguard/broken:latest", "example.com/app:latest"} {
+		if _, err := s.SubmitScan(ctx, &api.SubmitScanRequest{Image: image}); err != nil {
+			t.Fatalf("expected no error on SubmitScan(), got %v", err)
+		}
+	}
+	s.Start(ctx, 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := s.queue.Status(ctx)
+		if err != nil {
+			t.Fatalf("expected no error on Status(), got %v", err)
+		}
+		if status.Succeeded+status.Failed == 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.Drain(ctx); err != nil {
+		t.Fatalf("expected no error on Drain(), got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(payloads) != 2 {
+		t.Fatalf("expected payloads for the two matching jobs, got %d", len(payloads))
+	}
+	// Deliveries are posted in the background, so may arrive in any order
+	completed, failed := payloads[0], payloads[1]
+	if completed.Type != WebhookScanCompleted {
+		completed, failed = failed, completed
+	}
+	if completed.Type != WebhookScanCompleted || completed.Job.State != "succeeded" || completed.Scan == nil || completed.Scan.Severities.High != 1 {
+		t.Errorf("expected the completed scan, got %+v", completed)
+	}
+	if failed.Type != WebhookScanFailed || failed.Job.State != "failed" || failed.Job.Error == "" || failed.Scan != nil {
+		t.Errorf("expected the failed job, got %+v", failed)
+	}
+	for i, r := range received {
+		if event := r.Header.Get("X-Rumble-Event"); event != payloads[i].Type {
+			t.Errorf("expected the %s event header, got %q", payloads[i].Type, event)
+		}
+	}
+}
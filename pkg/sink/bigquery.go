@@ -0,0 +1,379 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// BigQuery streams scans into the tables of a BigQuery dataset. Tables for
+// kinds of findings that aren't recorded may be left empty.
+//
+// Inserts into separate tables can't be made atomic, so the other rows are
+// inserted before the summary row: a scan whose summary can be found has all
+// of its rows recorded. With StagingDir set, each scan is first written
+// there along with the tables it has been inserted into so far, and a scan
+// that fails partway stays staged for Reconcile to finish on a later run.
+type BigQuery struct {
+	Dataset *bigquery.Dataset
+
+	Table         string
+	VulnsTable    string
+	FindingsTable string
+	LicensesTable string
+	PackagesTable string
+
+	StagingDir string
+
+	// Retries is how many times rows that BigQuery rejected individually
+	// are inserted again, without the rows that went in
+	Retries int
+
+	// RowsInserted and BytesInserted count the rows that went into any
+	// table, including those of scans finished by Reconcile, and the
+	// bytes they are billed as (see RowBytes)
+	RowsInserted  int64
+	BytesInserted int64
+}
+
+// retryDelay is how long to wait before inserting failed rows again, which
+// grows with each retry
+var retryDelay = time.Second
+
+// DefaultStagingDir returns the directory scans are staged in by default
+func DefaultStagingDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "rumble", "staging")
+}
+
+// staged is a scan in the staging directory, with where it is to be
+// recorded. It's written once, since the scan can be large (e.g. with the
+// raw scanner output), and its progress is kept in a file of its own.
+type staged struct {
+	Project       string `json:"project"`
+	Dataset       string `json:"dataset"`
+	Table         string `json:"table"`
+	VulnsTable    string `json:"vulns_table"`
+	FindingsTable string `json:"findings_table"`
+	LicensesTable string `json:"licenses_table"`
+	PackagesTable string `json:"packages_table"`
+	Scan          *Scan  `json:"scan"`
+
+	progress `json:"-"`
+}
+
+// progress is the kinds of rows of a staged scan ("vulns", "findings",
+// "licenses", "packages" and "summary") already inserted. Remaining lists
+// the IDs of the rows still to insert for a kind of which only some rows
+// went in.
+type progress struct {
+	Done      []string            `json:"done"`
+	Remaining map[string][]string `json:"remaining,omitempty"`
+}
+
+func (p *staged) done(kind string) bool {
+	for _, done := range p.Done {
+		if done == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *BigQuery) Put(ctx context.Context, scan *Scan) error {
+	p := &staged{
+		Project:       s.Dataset.ProjectID,
+		Dataset:       s.Dataset.DatasetID,
+		Table:         s.Table,
+		VulnsTable:    s.VulnsTable,
+		FindingsTable: s.FindingsTable,
+		LicensesTable: s.LicensesTable,
+		PackagesTable: s.PackagesTable,
+		Scan:          scan,
+	}
+	if err := s.stage(p); err != nil {
+		return err
+	}
+	if err := s.insert(ctx, p); err != nil {
+		if s.StagingDir != "" {
+			return fmt.Errorf("%w (the scan is staged in %s, to be finished on the next run)", err, s.stagedFile(scan.Summary.ID))
+		}
+		return err
+	}
+	return s.unstage(p)
+}
+
+// Reconcile finishes recording the scans left in StagingDir by earlier runs
+// that failed partway, returning how many were finished. Only scans staged
+// for this dataset are retried; any others are left for runs recording there.
+func (s *BigQuery) Reconcile(ctx context.Context) (int, error) {
+	if s.StagingDir == "" {
+		return 0, nil
+	}
+	files, err := filepath.Glob(filepath.Join(s.StagingDir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	finished := 0
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return finished, err
+		}
+		p := &staged{}
+		if err := json.Unmarshal(b, p); err != nil {
+			return finished, fmt.Errorf("reading staged scan %s: %w", file, err)
+		}
+		if p.Project != s.Dataset.ProjectID || p.Dataset != s.Dataset.DatasetID || p.Scan == nil || p.Scan.Summary == nil {
+			continue
+		}
+		// Without a progress file, nothing was inserted yet
+		if b, err := os.ReadFile(s.progressFile(p.Scan.Summary.ID)); err == nil {
+			if err := json.Unmarshal(b, &p.progress); err != nil {
+				return finished, fmt.Errorf("reading the progress of staged scan %s: %w", file, err)
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return finished, err
+		}
+		fmt.Printf("Finishing staged scan %s (already inserted: %s)\n", p.Scan.Summary.ID, strings.Join(p.Done, ", "))
+		if err := s.insert(ctx, p); err != nil {
+			return finished, fmt.Errorf("finishing staged scan %s: %w", p.Scan.Summary.ID, err)
+		}
+		if err := s.unstage(p); err != nil {
+			return finished, err
+		}
+		finished++
+	}
+	return finished, nil
+}
+
+// row is a row to insert, with its ID and how to describe it if it is dropped
+type row struct {
+	id    string
+	label string
+	value interface{}
+}
+
+// insert adds the rows of each kind not yet inserted, summary last, keeping
+// the staged scan up to date as each is done
+func (s *BigQuery) insert(ctx context.Context, p *staged) error {
+	scan := p.Scan
+	vulns := make([]row, len(scan.Vulns))
+	for i, vuln := range scan.Vulns {
+		vulns[i] = row{vuln.ID, fmt.Sprintf("%s in %s %s", vuln.Vulnerability, vuln.Name, vuln.Installed), vuln}
+	}
+	findings := make([]row, len(scan.Findings))
+	for i, finding := range scan.Findings {
+		findings[i] = row{finding.ID, fmt.Sprintf("%s %s in %s", finding.Kind, finding.RuleID, finding.Target), finding}
+	}
+	licenses := make([]row, len(scan.Licenses))
+	for i, license := range scan.Licenses {
+		licenses[i] = row{license.ID, fmt.Sprintf("%s of %s", license.Name, license.Package), license}
+	}
+	packages := make([]row, len(scan.Packages))
+	for i, pkg := range scan.Packages {
+		packages[i] = row{pkg.ID, fmt.Sprintf("%s %s", pkg.Name, pkg.Version), pkg}
+	}
+	for _, rows := range []struct {
+		kind  string
+		table string
+		rows  []row
+	}{
+		{"vulns", p.VulnsTable, vulns},
+		{"findings", p.FindingsTable, findings},
+		{"licenses", p.LicensesTable, licenses},
+		{"packages", p.PackagesTable, packages},
+		{"summary", p.Table, []row{{scan.Summary.ID, "summary", scan.Summary}}},
+	} {
+		if len(rows.rows) == 0 || p.done(rows.kind) {
+			continue
+		}
+		pending := rows.rows
+		if remaining, ok := p.Remaining[rows.kind]; ok {
+			pending = only(pending, remaining)
+		}
+		if rows.kind == "summary" {
+			fmt.Printf("Adding 1 row to table \"%s\" (scan_id=\"%s\")\n", rows.table, scan.Summary.ID)
+		} else {
+			fmt.Printf("Adding %d row(s) to table \"%s\"\n", len(pending), rows.table)
+		}
+		failed, err := s.put(ctx, rows.table, pending)
+		if len(failed) > 0 && len(failed) < len(pending) {
+			// Only the rows that didn't go in are left for the next run
+			if p.Remaining == nil {
+				p.Remaining = map[string][]string{}
+			}
+			p.Remaining[rows.kind] = []string{}
+			for _, r := range failed {
+				p.Remaining[rows.kind] = append(p.Remaining[rows.kind], r.id)
+			}
+			if err := s.saveProgress(p); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return err
+		}
+		delete(p.Remaining, rows.kind)
+		p.Done = append(p.Done, rows.kind)
+		if err := s.saveProgress(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// put inserts rows into a table. Rows that BigQuery rejects individually are
+// inserted again up to Retries times, and those that still fail are listed
+// and returned along with the error.
+func (s *BigQuery) put(ctx context.Context, table string, rows []row) ([]row, error) {
+	inserter := s.Dataset.Table(table).Inserter()
+	total := len(rows)
+	for retry := 0; ; retry++ {
+		values := make([]interface{}, len(rows))
+		for i, r := range rows {
+			values[i] = r.value
+		}
+		err := inserter.Put(ctx, values)
+		var multi bigquery.PutMultiError
+		if !errors.As(err, &multi) {
+			if err != nil {
+				return rows, err
+			}
+			s.inserted(rows, nil)
+			return nil, nil
+		}
+		failed := make([]row, 0, len(multi))
+		reasons := make([]string, 0, len(multi))
+		for _, rowErr := range multi {
+			if rowErr.RowIndex < 0 || rowErr.RowIndex >= len(rows) {
+				return rows, err
+			}
+			failed = append(failed, rows[rowErr.RowIndex])
+			reasons = append(reasons, rowErr.Errors.Error())
+		}
+		s.inserted(rows, failed)
+		if retry == s.Retries {
+			for i, r := range failed {
+				fmt.Printf("WARNING: could not insert %s (id=\"%s\") into table \"%s\": %s\n", r.label, r.id, table, reasons[i])
+			}
+			return failed, fmt.Errorf("%d of %d row(s) could not be inserted into table %q", len(failed), total, table)
+		}
+		fmt.Printf("WARNING: %d of %d row(s) were not inserted into table \"%s\", retrying them\n", len(failed), len(rows), table)
+		select {
+		case <-ctx.Done():
+			return failed, ctx.Err()
+		case <-time.After(retryDelay * time.Duration(retry+1)):
+		}
+		rows = failed
+	}
+}
+
+// inserted counts the rows that went in, those of rows not in failed
+func (s *BigQuery) inserted(rows []row, failed []row) {
+	rejected := map[string]bool{}
+	for _, r := range failed {
+		rejected[r.id] = true
+	}
+	for _, r := range rows {
+		if rejected[r.id] {
+			continue
+		}
+		s.RowsInserted++
+		// Rows were already saved by the inserter, so this can't fail
+		n, _ := RowBytes(r.value)
+		s.BytesInserted += n
+	}
+}
+
+// only returns the rows with the given IDs
+func only(rows []row, ids []string) []row {
+	include := map[string]bool{}
+	for _, id := range ids {
+		include[id] = true
+	}
+	filtered := []row{}
+	for _, r := range rows {
+		if include[r.id] {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+func (s *BigQuery) stagedFile(id string) string {
+	return filepath.Join(s.StagingDir, id+".json")
+}
+
+// progressFile doesn't end in .json, so Reconcile doesn't take it for a
+// staged scan
+func (s *BigQuery) progressFile(id string) string {
+	return filepath.Join(s.StagingDir, id+".progress")
+}
+
+// stage writes the staged scan, without its progress
+func (s *BigQuery) stage(p *staged) error {
+	if s.StagingDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(s.StagingDir, 0755); err != nil {
+		return err
+	}
+	return s.write(s.stagedFile(p.Scan.Summary.ID), p)
+}
+
+// saveProgress writes the progress of the staged scan, after each kind of
+// rows is inserted
+func (s *BigQuery) saveProgress(p *staged) error {
+	if s.StagingDir == "" {
+		return nil
+	}
+	return s.write(s.progressFile(p.Scan.Summary.ID), &p.progress)
+}
+
+// write encodes v to filename, replacing any earlier copy in one step so
+// that a crash never leaves it truncated
+func (s *BigQuery) write(filename string, v interface{}) error {
+	f, err := os.CreateTemp(s.StagingDir, ".staging-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	w := bufio.NewWriter(f)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// unstage removes the staged scan, and then its progress
+func (s *BigQuery) unstage(p *staged) error {
+	if s.StagingDir == "" {
+		return nil
+	}
+	for _, filename := range []string{s.stagedFile(p.Scan.Summary.ID), s.progressFile(p.Scan.Summary.ID)} {
+		if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/chainguard-dev/rumble/pkg/types"
+	"google.golang.org/api/option"
+)
+
+// fakeBigQuery answers streaming inserts, failing every row inserted into a
+// table listed in fail and rows with an ID in failIDs (as many times as
+// given), and counts the rows inserted into each table
+type fakeBigQuery struct {
+	fail     map[string]bool
+	failIDs  map[string]int
+	inserted map[string]int
+}
+
+func (f *fakeBigQuery) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 2 || parts[len(parts)-1] != "insertAll" {
+		http.NotFound(w, r)
+		return
+	}
+	table := parts[len(parts)-2]
+	var req struct {
+		Rows []struct {
+			JSON struct {
+				ID string `json:"id"`
+			} `json:"json"`
+		} `json:"rows"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	type insertError struct {
+		Index  int                 `json:"index"`
+		Errors []map[string]string `json:"errors"`
+	}
+	resp := struct {
+		InsertErrors []insertError `json:"insertErrors,omitempty"`
+	}{}
+	for i, row := range req.Rows {
+		if f.failIDs[row.JSON.ID] > 0 {
+			f.failIDs[row.JSON.ID]--
+			resp.InsertErrors = append(resp.InsertErrors, insertError{Index: i, Errors: []map[string]string{{"reason": "invalid", "message": "rejected"}}})
+		} else if f.fail[table] {
+			resp.InsertErrors = append(resp.InsertErrors, insertError{Index: i, Errors: []map[string]string{{"reason": "backendError", "message": "unavailable"}}})
+		} else {
+			f.inserted[table]++
+		}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func newFakeBigQuery(t *testing.T, fake *fakeBigQuery) *bigquery.Client {
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	client, err := bigquery.NewClient(context.Background(), "project", option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication(), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("expected no error on bigquery.NewClient(), got %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestBigQueryStaging(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeBigQuery{fail: map[string]bool{"scans": true}, inserted: map[string]int{}}
+	client := newFakeBigQuery(t, fake)
+	s := &BigQuery{
+		Dataset:    client.Dataset("dataset"),
+		Table:      "scans",
+		VulnsTable: "vulns",
+		StagingDir: t.TempDir(),
+	}
+	scan := &Scan{
+		Summary: &types.ImageScanSummary{ID: "testing123", Image: "cgr.dev/chainguard/static:latest"},
+		Vulns: []*types.Vuln{
+			{ID: "v1", ScanID: "testing123", Vulnerability: "CVE-2024-0001"},
+			{ID: "v2", ScanID: "testing123", Vulnerability: "CVE-2024-0002"},
+		},
+	}
+
+	// The vulns go in, but the summary doesn't, so the scan stays staged
+	if err := s.Put(ctx, scan); err == nil {
+		t.Fatalf("expected an error when the summary insert fails")
+	}
+	if fake.inserted["vulns"] != 2 || fake.inserted["scans"] != 0 {
+		t.Errorf("expected only the vulns to be inserted, got %v", fake.inserted)
+	}
+	if _, err := os.Stat(filepath.Join(s.StagingDir, "testing123.json")); err != nil {
+		t.Fatalf("expected the scan to be staged, got %v", err)
+	}
+
+	// Scans staged for other datasets are left alone
+	other := &BigQuery{Dataset: client.Dataset("other"), StagingDir: s.StagingDir}
+	if finished, err := other.Reconcile(ctx); err != nil || finished != 0 {
+		t.Errorf("expected no staged scans for another dataset, got %d (%v)", finished, err)
+	}
+
+	// The next run only inserts the summary
+	fake.fail["scans"] = false
+	finished, err := s.Reconcile(ctx)
+	if err != nil {
+		t.Fatalf("expected no error on Reconcile(), got %v", err)
+	}
+	if finished != 1 || fake.inserted["vulns"] != 2 || fake.inserted["scans"] != 1 {
+		t.Errorf("expected 1 scan to be finished with only its summary inserted, got %d and %v", finished, fake.inserted)
+	}
+	if _, err := os.Stat(filepath.Join(s.StagingDir, "testing123.json")); !os.IsNotExist(err) {
+		t.Errorf("expected the staged scan to be removed, got %v", err)
+	}
+}
+
+func TestBigQueryPartialFailure(t *testing.T) {
+	retryDelay = 0
+	ctx := context.Background()
+	fake := &fakeBigQuery{failIDs: map[string]int{"v2": 1, "v3": 3}, inserted: map[string]int{}}
+	client := newFakeBigQuery(t, fake)
+	s := &BigQuery{
+		Dataset:    client.Dataset("dataset"),
+		Table:      "scans",
+		VulnsTable: "vulns",
+		StagingDir: t.TempDir(),
+		Retries:    1,
+	}
+	scan := &Scan{
+		Summary: &types.ImageScanSummary{ID: "testing123"},
+		Vulns: []*types.Vuln{
+			{ID: "v1", ScanID: "testing123"},
+			{ID: "v2", ScanID: "testing123"},
+			{ID: "v3", ScanID: "testing123"},
+		},
+	}
+
+	// v2 goes in on the retry, but v3 is still rejected, so the summary
+	// isn't inserted and only v3 is left staged
+	err := s.Put(ctx, scan)
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 row(s)") {
+		t.Fatalf("expected an error for the one row dropped, got %v", err)
+	}
+	if fake.inserted["vulns"] != 2 || fake.inserted["scans"] != 0 {
+		t.Errorf("expected only 2 vulns to be inserted, got %v", fake.inserted)
+	}
+	if _, err := os.Stat(filepath.Join(s.StagingDir, "testing123.json")); err != nil {
+		t.Fatalf("expected the scan to be staged, got %v", err)
+	}
+	// The progress is kept apart from the scan, which is only written once
+	b, err := os.ReadFile(filepath.Join(s.StagingDir, "testing123.progress"))
+	if err != nil {
+		t.Fatalf("expected the progress of the scan to be staged, got %v", err)
+	}
+	var p progress
+	if err := json.Unmarshal(b, &p); err != nil {
+		t.Fatalf("expected no error on json.Unmarshal(), got %v", err)
+	}
+	if remaining := p.Remaining["vulns"]; len(remaining) != 1 || remaining[0] != "v3" {
+		t.Errorf("expected only v3 to remain, got %v", p.Remaining)
+	}
+
+	// The next run only inserts v3 and the summary
+	finished, err := s.Reconcile(ctx)
+	if err != nil || finished != 1 {
+		t.Fatalf("expected 1 scan to be finished, got %d (%v)", finished, err)
+	}
+	if fake.inserted["vulns"] != 3 || fake.inserted["scans"] != 1 {
+		t.Errorf("expected each row to be inserted once, got %v", fake.inserted)
+	}
+	if files, _ := filepath.Glob(filepath.Join(s.StagingDir, "*")); len(files) != 0 {
+		t.Errorf("expected the staged scan and its progress to be removed, got %v", files)
+	}
+}
+
+func TestRowBytes(t *testing.T) {
+	// Small rows are billed as 1 KB
+	n, err := RowBytes(&types.Vuln{ID: "v1", ScanID: "testing123"})
+	if err != nil {
+		t.Fatalf("expected no error on RowBytes(), got %v", err)
+	}
+	if n != minRowBytes {
+		t.Errorf("expected a small row to be billed as %d bytes, got %d", minRowBytes, n)
+	}
+	vuln := &types.Vuln{ID: "v1", ScanID: "testing123", Description: strings.Repeat("x", 2000)}
+	if n, _ = RowBytes(vuln); n < 2002 || n > 2002+2*1024 {
+		t.Errorf("expected a row with a 2000 byte description to be billed as a little over 2000 bytes, got %d", n)
+	}
+}
+
+func TestBigQueryInserted(t *testing.T) {
+	retryDelay = 0
+	ctx := context.Background()
+	fake := &fakeBigQuery{failIDs: map[string]int{"v2": 1}, inserted: map[string]int{}}
+	client := newFakeBigQuery(t, fake)
+	s := &BigQuery{Dataset: client.Dataset("dataset"), Table: "scans", VulnsTable: "vulns", Retries: 1}
+	scan := &Scan{
+		Summary: &types.ImageScanSummary{ID: "testing123"},
+		Vulns:   []*types.Vuln{{ID: "v1", ScanID: "testing123"}, {ID: "v2", ScanID: "testing123"}},
+	}
+	if err := s.Put(ctx, scan); err != nil {
+		t.Fatalf("expected no error on Put(), got %v", err)
+	}
+	// The retried row is only counted once it goes in
+	if s.RowsInserted != 3 || s.BytesInserted != 3*minRowBytes {
+		t.Errorf("expected 3 rows of 1 KB to be counted, got %d rows and %d bytes", s.RowsInserted, s.BytesInserted)
+	}
+}
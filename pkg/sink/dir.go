@@ -0,0 +1,107 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chainguard-dev/rumble/pkg/dsse"
+)
+
+// Dir writes each scan to its own directory, <path>/<scan_id>, as
+// summary.json plus vulns.ndjson (and findings.ndjson, licenses.ndjson and
+// packages.ndjson when those were collected), one row per line. Rows use the
+// BigQuery column names, so the files can be loaded with "bq load" once
+// they've been synced out of an air-gapped environment. With a signer,
+// summary.json is signed, with its DSSE envelope written alongside it as
+// summary.json.dsse.
+type Dir struct {
+	Path   string
+	Signer *dsse.Signer
+}
+
+func (s *Dir) Put(ctx context.Context, scan *Scan) error {
+	dir := filepath.Join(s.Path, scan.Summary.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	fmt.Printf("Writing scan to directory \"%s\" (scan_id=\"%s\")\n", dir, scan.Summary.ID)
+	summary, err := Values(scan.Summary)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(summary, "", "    ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if err := os.WriteFile(filepath.Join(dir, "summary.json"), b, 0644); err != nil {
+		return err
+	}
+	if s.Signer != nil {
+		env, err := envelope(s.Signer, SummaryPayloadType, b)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "summary.json"+EnvelopeSuffix), env, 0644); err != nil {
+			return err
+		}
+	}
+
+	vulns := make([]interface{}, len(scan.Vulns))
+	for i, vuln := range scan.Vulns {
+		vulns[i] = vuln
+	}
+	// vulns.ndjson is always written, even if empty, so that a missing
+	// file means an incomplete scan
+	if err := writeNDJSON(filepath.Join(dir, "vulns.ndjson"), vulns); err != nil {
+		return err
+	}
+	findings := make([]interface{}, len(scan.Findings))
+	for i, finding := range scan.Findings {
+		findings[i] = finding
+	}
+	licenses := make([]interface{}, len(scan.Licenses))
+	for i, license := range scan.Licenses {
+		licenses[i] = license
+	}
+	packages := make([]interface{}, len(scan.Packages))
+	for i, pkg := range scan.Packages {
+		packages[i] = pkg
+	}
+	for name, rows := range map[string][]interface{}{"findings.ndjson": findings, "licenses.ndjson": licenses, "packages.ndjson": packages} {
+		if len(rows) == 0 {
+			continue
+		}
+		if err := writeNDJSON(filepath.Join(dir, name), rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeNDJSON(filename string, rows []interface{}) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, row := range rows {
+		values, err := Values(row)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(values); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,89 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/dsse"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestDir(t *testing.T) {
+	s := &Dir{Path: t.TempDir()}
+	scan := &Scan{
+		Summary: &types.ImageScanSummary{ID: "testing123", Image: "cgr.dev/chainguard/static:latest"},
+		Vulns: []*types.Vuln{
+			{ID: "v1", ScanID: "testing123", Vulnerability: "CVE-2024-0001"},
+			{ID: "v2", ScanID: "testing123", Vulnerability: "CVE-2024-0002"},
+		},
+	}
+	if err := s.Put(context.Background(), scan); err != nil {
+		t.Fatalf("expected no error on Put(), got %v", err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(s.Path, "testing123", "summary.json"))
+	if err != nil {
+		t.Fatalf("expected no error reading summary.json, got %v", err)
+	}
+	var summary map[string]interface{}
+	if err := json.Unmarshal(b, &summary); err != nil || summary["image"] != "cgr.dev/chainguard/static:latest" {
+		t.Errorf("unexpected summary.json %s (%v)", string(b), err)
+	}
+
+	f, err := os.Open(filepath.Join(s.Path, "testing123", "vulns.ndjson"))
+	if err != nil {
+		t.Fatalf("expected no error opening vulns.ndjson, got %v", err)
+	}
+	defer f.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); lines++ {
+		var vuln map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &vuln); err != nil || vuln["scan_id"] != "testing123" {
+			t.Errorf("unexpected vuln row %s (%v)", scanner.Text(), err)
+		}
+	}
+	if lines != 2 {
+		t.Errorf("got %d vuln rows, wanted 2", lines)
+	}
+	if _, err := os.Stat(filepath.Join(s.Path, "testing123", "findings.ndjson")); !os.IsNotExist(err) {
+		t.Errorf("expected no findings.ndjson without findings, got %v", err)
+	}
+}
+
+func TestDirSigned(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer, err := dsse.NewSigner(key)
+	if err != nil {
+		t.Fatalf("expected no error on NewSigner(), got %v", err)
+	}
+	s := &Dir{Path: t.TempDir(), Signer: signer}
+	scan := &Scan{Summary: &types.ImageScanSummary{ID: "testing123", Image: "cgr.dev/chainguard/static:latest"}}
+	if err := s.Put(context.Background(), scan); err != nil {
+		t.Fatalf("expected no error on Put(), got %v", err)
+	}
+	summary, err := os.ReadFile(filepath.Join(s.Path, "testing123", "summary.json"))
+	if err != nil {
+		t.Fatalf("expected no error reading summary.json, got %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(s.Path, "testing123", "summary.json"+EnvelopeSuffix))
+	if err != nil {
+		t.Fatalf("expected no error reading the envelope, got %v", err)
+	}
+	var env types.DSSEEnvelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		t.Fatalf("expected no error on json.Unmarshal(), got %v", err)
+	}
+	if env.PayloadType != SummaryPayloadType || string(env.Payload) != string(summary) {
+		t.Errorf("expected the envelope to hold summary.json, got %s", string(b))
+	}
+	if err := dsse.Verify(&env, key.Public()); err != nil {
+		t.Errorf("expected no error on Verify(), got %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// Elasticsearch indexes scans into Elasticsearch or OpenSearch with the bulk
+// API, one document per row, in the indices <prefix>-scans, <prefix>-vulns,
+// <prefix>-findings, <prefix>-licenses and <prefix>-packages. Documents use
+// the row's ID, so recording the same scan twice doesn't duplicate it.
+// Rows other than the summary also get the image, scanner and digest of
+// their scan, since dashboards can't join indices. The summary leaves out
+// the raw scanner output, and is indexed last, so that a scan whose summary
+// can be found has all of its rows indexed.
+type Elasticsearch struct {
+	URL         string
+	IndexPrefix string
+
+	// MaxBulkBytes caps the size of each bulk request, with the scan split
+	// across as many as needed. A document larger than that is sent in a
+	// request of its own.
+	MaxBulkBytes int
+
+	// Username and Password are used for basic auth, or APIKey for an
+	// Elasticsearch API key (base64 "id:key")
+	Username string
+	Password string
+	APIKey   string
+
+	httpClient *http.Client
+}
+
+func NewElasticsearch(url string, indexPrefix string) *Elasticsearch {
+	return &Elasticsearch{
+		URL:          strings.TrimSuffix(url, "/"),
+		IndexPrefix:  indexPrefix,
+		MaxBulkBytes: DefaultMaxBulkBytes,
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// DefaultMaxBulkBytes is well under the 100 MB default of Elasticsearch's
+// http.max_content_length, which it rejects larger requests over
+const DefaultMaxBulkBytes = 10 << 20
+
+func (s *Elasticsearch) Put(ctx context.Context, scan *Scan) error {
+	bodies, err := s.bulkBodies(scan)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Indexing scan into \"%s-*\" at %s in %d request(s) (scan_id=\"%s\")\n", s.IndexPrefix, s.URL, len(bodies), scan.Summary.ID)
+	for _, body := range bodies {
+		if err := s.bulk(ctx, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bulk sends a bulk request, failing if any of its documents wasn't indexed
+func (s *Elasticsearch) bulk(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL+"/_bulk", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+s.APIKey)
+	} else if s.Username != "" {
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("indexing scan: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("indexing scan: unexpected status %s: %s", resp.Status, string(b))
+	}
+	// The bulk API reports per-document failures in the response body
+	var result bulkResponse
+	if err := json.Unmarshal(b, &result); err != nil {
+		return fmt.Errorf("parsing bulk response: %w", err)
+	}
+	if result.Errors {
+		for _, item := range result.Items {
+			for _, op := range item {
+				if op.Error != nil {
+					return fmt.Errorf("indexing document %s into %s: %s", op.ID, op.Index, string(op.Error))
+				}
+			}
+		}
+		return fmt.Errorf("indexing scan: bulk request reported errors")
+	}
+	return nil
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Index string          `json:"_index"`
+		ID    string          `json:"_id"`
+		Error json.RawMessage `json:"error,omitempty"`
+	} `json:"items"`
+}
+
+type bulkRow struct {
+	index string
+	id    string
+	row   interface{}
+}
+
+// bulkBodies returns the NDJSON bodies of the bulk requests indexing every
+// row of a scan, each at most MaxBulkBytes (if set) unless it's a single
+// document, with the summary last
+func (s *Elasticsearch) bulkBodies(scan *Scan) ([][]byte, error) {
+	bodies := [][]byte{}
+	var buf bytes.Buffer
+	add := func(index string, id string, doc map[string]bigquery.Value) error {
+		var entry bytes.Buffer
+		if err := writeBulkDoc(&entry, index, id, doc); err != nil {
+			return err
+		}
+		if s.MaxBulkBytes > 0 && buf.Len() > 0 && buf.Len()+entry.Len() > s.MaxBulkBytes {
+			bodies = append(bodies, append([]byte{}, buf.Bytes()...))
+			buf.Reset()
+		}
+		buf.Write(entry.Bytes())
+		return nil
+	}
+
+	rows := []bulkRow{}
+	for _, vuln := range scan.Vulns {
+		rows = append(rows, bulkRow{"vulns", vuln.ID, vuln})
+	}
+	for _, finding := range scan.Findings {
+		rows = append(rows, bulkRow{"findings", finding.ID, finding})
+	}
+	for _, license := range scan.Licenses {
+		rows = append(rows, bulkRow{"licenses", license.ID, license})
+	}
+	for _, pkg := range scan.Packages {
+		rows = append(rows, bulkRow{"packages", pkg.ID, pkg})
+	}
+	for _, r := range rows {
+		doc, err := Values(r.row)
+		if err != nil {
+			return nil, err
+		}
+		doc["image"] = scan.Summary.Image
+		doc["scanner"] = scan.Summary.Scanner
+		doc["digest"] = scan.Summary.Digest
+		if err := add(s.IndexPrefix+"-"+r.index, r.id, doc); err != nil {
+			return nil, err
+		}
+	}
+
+	summary, err := Values(scan.Summary)
+	if err != nil {
+		return nil, err
+	}
+	// The raw scanner output can be hundreds of megabytes, and dashboards
+	// have the rows
+	for column := range summary {
+		if strings.HasPrefix(column, "raw_") && strings.HasSuffix(column, "_json") {
+			delete(summary, column)
+		}
+	}
+	if err := add(s.IndexPrefix+"-scans", scan.Summary.ID, summary); err != nil {
+		return nil, err
+	}
+	return append(bodies, buf.Bytes()), nil
+}
+
+func writeBulkDoc(buf *bytes.Buffer, index string, id string, doc map[string]bigquery.Value) error {
+	action, err := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": index, "_id": id}})
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	buf.Write(action)
+	buf.WriteByte('\n')
+	buf.Write(b)
+	buf.WriteByte('\n')
+	return nil
+}
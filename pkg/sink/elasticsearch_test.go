@@ -0,0 +1,106 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestElasticsearch(t *testing.T) {
+	docs := map[string]map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Authorization") != "ApiKey secret" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var action map[string]map[string]string
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			scanner.Scan()
+			var doc map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			docs[action["index"]["_index"]+"/"+action["index"]["_id"]] = doc
+		}
+		w.Write([]byte(`{"errors": false, "items": []}`))
+	}))
+	defer server.Close()
+
+	s := NewElasticsearch(server.URL+"/", "rumble")
+	s.APIKey = "secret"
+	scan := &Scan{
+		Summary: &types.ImageScanSummary{ID: "testing123", Image: "cgr.dev/chainguard/static:latest", Scanner: "grype", Digest: "sha256:abc"},
+		Vulns:   []*types.Vuln{{ID: "v1", ScanID: "testing123", Name: "openssl", Vulnerability: "CVE-2024-1234"}},
+	}
+	if err := s.Put(context.Background(), scan); err != nil {
+		t.Fatalf("expected no error on Put(), got %v", err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("got %d documents, wanted 2", len(docs))
+	}
+	if docs["rumble-scans/testing123"]["image"] != "cgr.dev/chainguard/static:latest" {
+		t.Errorf("unexpected summary document %v", docs["rumble-scans/testing123"])
+	}
+	vuln := docs["rumble-vulns/v1"]
+	if vuln["vulnerability"] != "CVE-2024-1234" || vuln["digest"] != "sha256:abc" {
+		t.Errorf("expected vuln document with its scan's digest, got %v", vuln)
+	}
+}
+
+func TestElasticsearchBulkBodies(t *testing.T) {
+	s := NewElasticsearch("https://es.example.com", "rumble")
+	s.MaxBulkBytes = 1024
+	scan := &Scan{
+		Summary: &types.ImageScanSummary{ID: "testing123", RawGrypeJSON: strings.Repeat("x", 4096)},
+	}
+	for i := 0; i < 20; i++ {
+		scan.Vulns = append(scan.Vulns, &types.Vuln{ID: fmt.Sprintf("v%d", i), ScanID: "testing123", Vulnerability: "CVE-2024-1234"})
+	}
+	bodies, err := s.bulkBodies(scan)
+	if err != nil {
+		t.Fatalf("expected no error on bulkBodies(), got %v", err)
+	}
+	if len(bodies) < 2 {
+		t.Fatalf("expected the scan to be split across bulk requests, got %d", len(bodies))
+	}
+	docs := 0
+	for _, body := range bodies {
+		n := strings.Count(string(body), "\n") / 2
+		if len(body) > s.MaxBulkBytes && n > 1 {
+			t.Errorf("got a %d byte bulk request of %d documents, wanted at most %d bytes", len(body), n, s.MaxBulkBytes)
+		}
+		docs += n
+	}
+	if docs != 21 {
+		t.Errorf("got %d documents, wanted 21", docs)
+	}
+	last := string(bodies[len(bodies)-1])
+	if !strings.Contains(last, `"_index":"rumble-scans"`) || strings.Contains(last, "raw_grype_json") {
+		t.Errorf("expected the summary last, without the raw grype output, got %s", last)
+	}
+}
+
+func TestElasticsearchErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors": true, "items": [{"index": {"_index": "rumble-scans", "_id": "testing123", "error": {"type": "mapper_parsing_exception"}}}]}`))
+	}))
+	defer server.Close()
+
+	s := NewElasticsearch(server.URL, "rumble")
+	if err := s.Put(context.Background(), &Scan{Summary: &types.ImageScanSummary{ID: "testing123"}}); err == nil {
+		t.Errorf("expected error on failed bulk request, got nil")
+	}
+}
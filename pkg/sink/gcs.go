@@ -0,0 +1,115 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/storage"
+	"github.com/chainguard-dev/rumble/pkg/dsse"
+)
+
+// GCS writes each scan as a single JSON object in a Cloud Storage bucket,
+// for environments that can't stream into BigQuery. Objects are named
+// <prefix>/dt=<date>/<scan_id>.json, so the bucket can be queried as a
+// Hive-partitioned BigQuery external table, or loaded later. With a
+// signer, each object is signed, with its DSSE envelope written alongside
+// it as <scan_id>.json.dsse.
+type GCS struct {
+	Bucket *storage.BucketHandle
+	Prefix string
+	Signer *dsse.Signer
+}
+
+// NewGCS returns a sink writing to a gs://bucket/path prefix
+func NewGCS(client *storage.Client, prefix string) (*GCS, error) {
+	if !strings.HasPrefix(prefix, "gs://") {
+		return nil, fmt.Errorf("invalid Cloud Storage prefix %q, expected gs://bucket/path", prefix)
+	}
+	bucket, objectPrefix, _ := strings.Cut(strings.TrimPrefix(prefix, "gs://"), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid Cloud Storage prefix %q, expected gs://bucket/path", prefix)
+	}
+	return &GCS{Bucket: client.Bucket(bucket), Prefix: strings.TrimSuffix(objectPrefix, "/")}, nil
+}
+
+// ObjectName returns the name of the object a scan is written to
+func (s *GCS) ObjectName(scan *Scan) string {
+	date := scan.Summary.Time
+	if len(date) >= len("2006-01-02") {
+		date = date[:len("2006-01-02")]
+	}
+	return path.Join(s.Prefix, "dt="+date, scan.Summary.ID+".json")
+}
+
+func (s *GCS) Put(ctx context.Context, scan *Scan) error {
+	b, err := Document(scan)
+	if err != nil {
+		return err
+	}
+	name := s.ObjectName(scan)
+	fmt.Printf("Writing scan to object \"%s\" (scan_id=\"%s\")\n", name, scan.Summary.ID)
+	if err := s.write(ctx, name, b); err != nil {
+		return err
+	}
+	if s.Signer == nil {
+		return nil
+	}
+	env, err := envelope(s.Signer, ScanPayloadType, b)
+	if err != nil {
+		return err
+	}
+	return s.write(ctx, name+EnvelopeSuffix, env)
+}
+
+func (s *GCS) write(ctx context.Context, name string, b []byte) error {
+	w := s.Bucket.Object(name).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(b); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Document returns a scan as a single line of JSON: the summary columns,
+// with the vulns, findings, licenses and packages as nested arrays of rows
+// under those keys
+func Document(scan *Scan) ([]byte, error) {
+	doc, err := Values(scan.Summary)
+	if err != nil {
+		return nil, err
+	}
+	rows := map[string][]interface{}{"vulns": {}, "findings": {}, "licenses": {}, "packages": {}}
+	for _, vuln := range scan.Vulns {
+		rows["vulns"] = append(rows["vulns"], vuln)
+	}
+	for _, finding := range scan.Findings {
+		rows["findings"] = append(rows["findings"], finding)
+	}
+	for _, license := range scan.Licenses {
+		rows["licenses"] = append(rows["licenses"], license)
+	}
+	for _, pkg := range scan.Packages {
+		rows["packages"] = append(rows["packages"], pkg)
+	}
+	for key, keyRows := range rows {
+		values := []map[string]bigquery.Value{}
+		for _, row := range keyRows {
+			v, err := Values(row)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		doc[key] = values
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,41 @@
+package sink
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestDocument(t *testing.T) {
+	scan := &Scan{
+		Summary: &types.ImageScanSummary{ID: "testing123", Image: "cgr.dev/chainguard/static:latest", Time: "2023-06-22T02:38:46Z", TotCveCount: 1, SecretCount: types.FindingCounts{High: 1, Total: 1}},
+		Vulns:   []*types.Vuln{{ID: "v1", ScanID: "testing123", Name: "openssl", Vulnerability: "CVE-2024-1234"}},
+	}
+	b, err := Document(scan)
+	if err != nil {
+		t.Fatalf("expected no error on Document(), got %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("expected no error on json.Unmarshal(), got %v", err)
+	}
+	if doc["id"] != "testing123" || doc["tot_cve_count"] != float64(1) {
+		t.Errorf("expected summary columns at the top level, got %v", doc)
+	}
+	if counts, ok := doc["secret_count"].(map[string]interface{}); !ok || counts["high"] != float64(1) {
+		t.Errorf("expected nested secret counts, got %v", doc["secret_count"])
+	}
+	vulns, ok := doc["vulns"].([]interface{})
+	if !ok || len(vulns) != 1 || vulns[0].(map[string]interface{})["vulnerability"] != "CVE-2024-1234" {
+		t.Errorf("expected one nested vuln row, got %v", doc["vulns"])
+	}
+	if findings, ok := doc["findings"].([]interface{}); !ok || len(findings) != 0 {
+		t.Errorf("expected an empty findings array, got %v", doc["findings"])
+	}
+
+	s := &GCS{Prefix: "rumble"}
+	if name := s.ObjectName(scan); name != "rumble/dt=2023-06-22/testing123.json" {
+		t.Errorf("object name is %s, wanted rumble/dt=2023-06-22/testing123.json", name)
+	}
+}
@@ -0,0 +1,31 @@
+package sink
+
+import (
+	"encoding/json"
+
+	"github.com/chainguard-dev/rumble/pkg/dsse"
+)
+
+// The payload types of the DSSE envelopes sinks write with a signer: the
+// summary.json of Dir, and the objects of GCS
+const (
+	SummaryPayloadType = "application/vnd.rumble.summary+json"
+	ScanPayloadType    = "application/vnd.rumble.scan+json"
+)
+
+// EnvelopeSuffix is added to the name of a signed file or object to name
+// its envelope, which holds a copy of the file as its payload
+const EnvelopeSuffix = ".dsse"
+
+// envelope signs a file's contents, returning its envelope as JSON
+func envelope(signer *dsse.Signer, payloadType string, payload []byte) ([]byte, error) {
+	env, err := signer.Sign(payloadType, payload)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.MarshalIndent(env, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
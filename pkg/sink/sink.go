@@ -0,0 +1,70 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// Scan holds the rows recorded for a single scan. Slices for kinds of
+// findings that weren't scanned for are left empty.
+type Scan struct {
+	Summary  *types.ImageScanSummary
+	Vulns    []*types.Vuln
+	Findings []*types.Finding
+	Licenses []*types.License
+	Packages []*types.Package
+}
+
+// Check returns an error unless the summary has an ID and every other row
+// refers to it, so no row can be recorded without its scan
+func (scan *Scan) Check() error {
+	if scan.Summary.ID == "" {
+		return fmt.Errorf("scan summary has no ID")
+	}
+	check := func(kind string, id string, scanID string) error {
+		if scanID != scan.Summary.ID {
+			return fmt.Errorf("%s %s has scan ID %q, expected %q", kind, id, scanID, scan.Summary.ID)
+		}
+		return nil
+	}
+	for _, vuln := range scan.Vulns {
+		if err := check("vuln", vuln.ID, vuln.ScanID); err != nil {
+			return err
+		}
+	}
+	for _, finding := range scan.Findings {
+		if err := check("finding", finding.ID, finding.ScanID); err != nil {
+			return err
+		}
+	}
+	for _, license := range scan.Licenses {
+		if err := check("license", license.ID, license.ScanID); err != nil {
+			return err
+		}
+	}
+	for _, pkg := range scan.Packages {
+		if err := check("package", pkg.ID, pkg.ScanID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sink records scans, e.g. in BigQuery tables or as objects in Cloud Storage
+type Sink interface {
+	Put(ctx context.Context, scan *Scan) error
+}
+
+// Values returns a row as a map of BigQuery column names to values, the
+// same as it would be inserted into a table
+func Values(row interface{}) (map[string]bigquery.Value, error) {
+	schema, err := bigquery.InferSchema(row)
+	if err != nil {
+		return nil, err
+	}
+	values, _, err := (&bigquery.StructSaver{Struct: row, Schema: schema}).Save()
+	return values, err
+}
@@ -0,0 +1,28 @@
+package sink
+
+import (
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func TestCheck(t *testing.T) {
+	scan := &Scan{
+		Summary:  &types.ImageScanSummary{ID: "testing123"},
+		Vulns:    []*types.Vuln{{ID: "v1", ScanID: "testing123"}},
+		Packages: []*types.Package{{ID: "p1", ScanID: "testing123"}},
+	}
+	if err := scan.Check(); err != nil {
+		t.Errorf("expected no error on Check(), got %v", err)
+	}
+
+	scan.Vulns = append(scan.Vulns, &types.Vuln{ID: "v2", ScanID: "other"})
+	if err := scan.Check(); err == nil {
+		t.Errorf("expected an error for a vuln of another scan")
+	}
+
+	scan = &Scan{Summary: &types.ImageScanSummary{}}
+	if err := scan.Check(); err == nil {
+		t.Errorf("expected an error for a summary without an ID")
+	}
+}
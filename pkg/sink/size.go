@@ -0,0 +1,58 @@
+package sink
+
+import (
+	"time"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// minRowBytes is the least a streamed row is billed as
+const minRowBytes = 1024
+
+// RowBytes returns how many bytes BigQuery bills for streaming a row into a
+// table: the size of its values by BigQuery's data size rules, and at least
+// 1 KB
+func RowBytes(row interface{}) (int64, error) {
+	values, err := Values(row)
+	if err != nil {
+		return 0, err
+	}
+	n := valueBytes(values)
+	if n < minRowBytes {
+		n = minRowBytes
+	}
+	return n, nil
+}
+
+// valueBytes returns the logical size of a value in BigQuery, where each
+// string or bytes value takes 2 bytes more than its length, a NULL none,
+// and records and repeated values the sum of what they hold
+func valueBytes(value interface{}) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return 2 + int64(len(v))
+	case []byte:
+		return 2 + int64(len(v))
+	case bool:
+		return 1
+	case int, int8, int16, int32, int64, uint8, uint16, uint32, float32, float64, time.Time:
+		return 8
+	case map[string]bigquery.Value:
+		var n int64
+		for _, field := range v {
+			n += valueBytes(field)
+		}
+		return n
+	case []bigquery.Value:
+		var n int64
+		for _, element := range v {
+			n += valueBytes(element)
+		}
+		return n
+	default:
+		// Other types (dates, numerics) are at most 16 bytes
+		return 16
+	}
+}
@@ -6,10 +6,11 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
 type ImageScanSummary struct {
-	ID string `bigquery:"id"` // This is faux primary key, the shas256sum of (image + "--" + scanner + "--" + time)
+	ID string `bigquery:"id"` // This is faux primary key, see SetID
 
 	Image            string `bigquery:"image"`
 	Digest           string `bigquery:"digest"`
@@ -23,6 +24,23 @@ type ImageScanSummary struct {
 	HighCveCount     int    `bigquery:"high_cve_count"`
 	CritCveCount     int    `bigquery:"crit_cve_count"`
 
+	// DigestSource is where Digest came from: "scanner" for the repo digest
+	// the scanner reported, "registry" or "local" when rumble computed it for
+	// an image the scanner reported none for, or "manifest" for the manifest
+	// digest reported by grype when neither worked. It's empty with no digest.
+	DigestSource string `bigquery:"digest_source"`
+
+	// Repository and Tag are Image split into the canonical repository name
+	// and its tag, which for images scanned by digest comes from --tag-hint
+	// (if given). Group by these rather than Image to combine scans by tag
+	// and by digest.
+	Repository string `bigquery:"repository"`
+	Tag        string `bigquery:"tag"`
+
+	// ScannerSchemaVersion is the schema version of the scanner output: the
+	// database schema for grype, or the report schema for trivy
+	ScannerSchemaVersion int `bigquery:"scanner_schema_version"`
+
 	// NegligibleCveCount is a grype specific field
 	NegligibleCveCount int `bigquery:"negligible_cve_count"`
 
@@ -31,29 +49,438 @@ type ImageScanSummary struct {
 	Success         bool `bigquery:"success"`
 
 	RawGrypeJSON string `bigquery:"raw_grype_json"`
+
+	// grypeOutput is RawGrypeJSON already parsed, if available
+	grypeOutput *GrypeScanOutput
+
+	// SeveritySource is where the severities used for the CVE counts came from ("scanner" or "nvd")
+	SeveritySource string `bigquery:"severity_source"`
+
+	// RawCveCount is the number of findings before deduplication, using the
+	// key recorded in DedupKey ("none", "package" or "path"). TotCveCount and
+	// the per-severity counts are always after deduplication.
+	RawCveCount int    `bigquery:"raw_cve_count"`
+	DedupKey    string `bigquery:"dedup_key"`
+
+	// ExcludedCpeMatches is the number of grype matches only found via CPE
+	// heuristics that were dropped (before counting) with --exclude-cpe-matches
+	ExcludedCpeMatches int `bigquery:"excluded_cpe_matches"`
+
+	// IgnoredCount is the number of grype matches suppressed by its ignore
+	// rules, which aren't counted, and IgnoredMatches what each was and why
+	// it was ignored, so suppressed findings can still be audited
+	IgnoredCount   int            `bigquery:"ignored_count"`
+	IgnoredMatches []IgnoredMatch `bigquery:"ignored_matches"`
+
+	// The distro detected by the scanner (e.g. name "alpine" with version
+	// "3.19"), or "windows" with its build for Windows images
+	OsName    string `bigquery:"os_name"`
+	OsVersion string `bigquery:"os_version"`
+
+	// Platform is the os/arch of the image scanned, from its config
+	Platform string `bigquery:"platform"`
+
+	// Wall-clock duration of the scan, plus CPU time and peak RSS of the scanner subprocess
+	ScanDurationSeconds float64 `bigquery:"scan_duration_seconds"`
+	ScannerCPUSeconds   float64 `bigquery:"scanner_cpu_seconds"`
+	ScannerMaxRssBytes  int64   `bigquery:"scanner_max_rss_bytes"`
+
+	// CacheHit is whether the scanner output was reused from the local cache
+	// (see --cache-dir) rather than scanning again
+	CacheHit bool `bigquery:"cache_hit"`
+
+	// DbSnapshot is the ID of the database snapshot scanned with (see
+	// --db-dir), or empty when the scanner used its own database
+	DbSnapshot string `bigquery:"db_snapshot"`
+
+	// DbBuiltAt is when the scanner's vulnerability database was built
+	// (RFC 3339), if the scanner reported it
+	DbBuiltAt string `bigquery:"db_built_at"`
+
+	// UploadMode is how the scan's vulns were recorded: "full", or "delta"
+	// for only the changes since BaseScanID, DeltaDepth deltas after the
+	// last full scan. It's empty for scans recorded before it existed,
+	// which are full.
+	UploadMode string `bigquery:"upload_mode"`
+	BaseScanID string `bigquery:"base_scan_id"`
+	DeltaDepth int    `bigquery:"delta_depth"`
+
+	// Offline is whether the scanner ran without database updates or other
+	// network lookups (see --offline)
+	Offline bool `bigquery:"offline"`
+
+	// The CI run the scan was invoked from, as in the invocation of its
+	// attestation (see --invocation-uri), and the CI system detected from
+	// the environment (see --detect-ci)
+	CIProvider          string `bigquery:"ci_provider"`
+	InvocationURI       string `bigquery:"invocation_uri"`
+	InvocationEventID   string `bigquery:"invocation_event_id"`
+	InvocationBuilderID string `bigquery:"invocation_builder_id"`
+
+	// Whether the image signature was verified (with --verify-signature)
+	// before scanning, and the keyless signing identity if there was one
+	SignatureVerified bool   `bigquery:"signature_verified"`
+	SignatureIdentity string `bigquery:"signature_identity"`
+
+	// The image this one was built from, if recorded in its annotations or labels
+	BaseImage       string `bigquery:"base_image"`
+	BaseImageDigest string `bigquery:"base_image_digest"`
+
+	// The repository the image was built from and the revision built, as
+	// passed in --source-repo and --source-revision or recorded in its
+	// annotations or labels
+	SourceRepo     string `bigquery:"source_repo"`
+	SourceRevision string `bigquery:"source_revision"`
+
+	// From the image config and manifest: how the image runs, the digests of
+	// its layers (bottom first), and its labels and manifest annotations.
+	// The environment isn't recorded, as it may hold credentials.
+	Entrypoint   []string   `bigquery:"entrypoint"`
+	Cmd          []string   `bigquery:"cmd"`
+	User         string     `bigquery:"user"`
+	LayerDigests []string   `bigquery:"layer_digests"`
+	Labels       []KeyValue `bigquery:"labels"`
+	Annotations  []KeyValue `bigquery:"annotations"`
+
+	// ApkoConfigDigest is the SHA-256 digest of the apko config the image
+	// was built from, if given with --apko-config
+	ApkoConfigDigest string `bigquery:"apko_config_digest"`
+
+	// SourceType is what was scanned: "image", "fs" for a local directory, or
+	// "apk-package" for APK packages (in which case Image holds the path)
+	SourceType string `bigquery:"source_type"`
+
+	// Secrets and failed misconfiguration checks found by trivy (with
+	// --scan-types), each listed individually in the findings table
+	SecretCount    FindingCounts `bigquery:"secret_count"`
+	MisconfigCount FindingCounts `bigquery:"misconfig_count"`
+
+	// CopyleftCount is the number of packages or files with a copyleft
+	// license (trivy's "forbidden", "restricted" and "reciprocal" categories),
+	// when collecting licenses with --licenses
+	CopyleftCount int `bigquery:"copyleft_count"`
+
+	// EOL is set when the detected OS release is past end-of-life according
+	// to endoflife.date (with --eol), with EOLDetails saying since when
+	EOL        bool   `bigquery:"eol"`
+	EOLDetails string `bigquery:"eol_details"`
+
+	// AttestationVerification is the outcome of verifying the attestation
+	// attached after the scan ("verified", "failed" or "skipped"), or empty
+	// if the scan wasn't attested with cosign
+	AttestationVerification string `bigquery:"attestation_verification"`
+
+	// Details of the attestation attached with cosign. RekorLogIndex is zero
+	// when no transparency log entry was created, and CertificateIdentity is
+	// only known for keyless signatures that were verified.
+	Attested                 bool   `bigquery:"attested"`
+	AttestationPredicateType string `bigquery:"attestation_predicate_type"`
+	RekorLogIndex            int64  `bigquery:"rekor_log_index"`
+	CertificateIdentity      string `bigquery:"certificate_identity"`
+
+	// EcosystemCounts breaks TotCveCount down by the type of the vulnerable
+	// package, as named by the scanner (e.g. "apk" or "go-module" for grype,
+	// "alpine" or "gomod" for trivy), sorted by type
+	EcosystemCounts []EcosystemCount `bigquery:"ecosystem_counts"`
+
+	// trivyOutput is the parsed trivy output, if this was a trivy scan
+	trivyOutput *TrivyScanOutput
+
+	// counted is how many matches of each vuln were counted by CountCve,
+	// for RecountCves
+	counted map[string]int
+}
+
+// FindingCounts counts non-vulnerability findings by severity
+type FindingCounts struct {
+	Critical int `bigquery:"critical"`
+	High     int `bigquery:"high"`
+	Medium   int `bigquery:"medium"`
+	Low      int `bigquery:"low"`
+	Unknown  int `bigquery:"unknown"`
+	Total    int `bigquery:"total"`
+}
+
+// Add counts a finding, treating unrecognized severities as unknown
+func (counts *FindingCounts) Add(severity string) {
+	switch strings.ToLower(severity) {
+	case "critical":
+		counts.Critical++
+	case "high":
+		counts.High++
+	case "medium":
+		counts.Medium++
+	case "low":
+		counts.Low++
+	default:
+		counts.Unknown++
+	}
+	counts.Total++
+}
+
+// IgnoredMatch is a finding the scanner suppressed, with the reason (or the
+// rules) it was suppressed for
+type IgnoredMatch struct {
+	Vulnerability string `bigquery:"vulnerability"`
+	Name          string `bigquery:"name"`
+	Version       string `bigquery:"version"`
+	Type          string `bigquery:"type"`
+	Severity      string `bigquery:"severity"`
+	Reason        string `bigquery:"reason"`
+}
+
+// KeyValue is one entry of a map of labels or annotations
+type KeyValue struct {
+	Key   string `bigquery:"key"`
+	Value string `bigquery:"value"`
+}
+
+// KeyValues returns the entries of a map sorted by key
+func KeyValues(m map[string]string) []KeyValue {
+	entries := make([]KeyValue, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, KeyValue{Key: key, Value: value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
 }
 
+// EcosystemCount is the number of vulns found in packages of one type
+type EcosystemCount struct {
+	Type  string `bigquery:"type"`
+	Count int    `bigquery:"count"`
+}
+
+// AddEcosystemCount counts a vuln in a package of the given type, with an
+// empty type counted as "unknown"
+func (row *ImageScanSummary) AddEcosystemCount(packageType string) {
+	if packageType == "" {
+		packageType = "unknown"
+	}
+	i := sort.Search(len(row.EcosystemCounts), func(i int) bool {
+		return row.EcosystemCounts[i].Type >= packageType
+	})
+	if i == len(row.EcosystemCounts) || row.EcosystemCounts[i].Type != packageType {
+		row.EcosystemCounts = append(row.EcosystemCounts, EcosystemCount{})
+		copy(row.EcosystemCounts[i+1:], row.EcosystemCounts[i:])
+		row.EcosystemCounts[i] = EcosystemCount{Type: packageType}
+	}
+	row.EcosystemCounts[i].Count++
+}
+
+// SetID sets the scan ID, unless one was already given (e.g. with
+// --scan-id). The ID is the sha256sum of (image + "--" + digest + "--" +
+// scanner + "--" + scanner_db_version + "--" + time), so recording the same
+// scan again gives the same ID, while scans of different digests or with a
+// different database in the same second don't collide.
 func (row *ImageScanSummary) SetID() {
-	row.ID = sha256Sum(row.id())
+	if row.ID == "" {
+		row.ID = sha256Sum(row.id())
+	}
 }
 
 func (row *ImageScanSummary) id() string {
-	return strings.Join([]string{row.Image, row.Scanner, row.Time}, "--")
+	return strings.Join([]string{row.Image, row.Digest, row.Scanner, row.ScannerDbVersion, row.Time}, "--")
+}
+
+// maxScanIDLength is the longest scan ID accepted from outside rumble
+const maxScanIDLength = 128
+
+// CheckScanID returns an error if an externally supplied scan ID can't be
+// used, as it has to be safe in object names and document IDs
+func CheckScanID(id string) error {
+	if id == "" || len(id) > maxScanIDLength {
+		return fmt.Errorf("scan ID must be 1 to %d characters, got %d", maxScanIDLength, len(id))
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return fmt.Errorf("invalid character %q in scan ID %q", c, id)
+		}
+	}
+	return nil
+}
+
+// AddCveCount increments the counter for the given severity (case-insensitive),
+// returning false if the severity is not recognized
+func (row *ImageScanSummary) AddCveCount(severity string) bool {
+	switch strings.ToLower(severity) {
+	case "low":
+		row.LowCveCount++
+	case "medium":
+		row.MedCveCount++
+	case "high":
+		row.HighCveCount++
+	case "critical":
+		row.CritCveCount++
+	case "negligible", "none": // "None" is CVSS's rating for a score of 0
+		row.NegligibleCveCount++
+	case "unknown":
+		row.UnknownCveCount++
+	default:
+		return false
+	}
+	return true
+}
+
+// CountCve counts a match of a vuln under the given severity, like
+// AddCveCount, remembering it so RecountCves can count it again
+func (row *ImageScanSummary) CountCve(name string, installed string, vulnerability string, typ string, severity string) bool {
+	if row.counted == nil {
+		row.counted = map[string]int{}
+	}
+	row.counted[countedKey(name, installed, vulnerability, typ)]++
+	return row.AddCveCount(severity)
+}
+
+// RecountCves counts the matches counted by CountCve again, under the
+// severities of their vulns once enriched (the NVD severity, if it has one),
+// so the counts agree with the vulns recorded. Severities that aren't
+// recognized are left out, as in AddCveCount.
+func (row *ImageScanSummary) RecountCves(vulns []*Vuln) {
+	if row.counted == nil {
+		return
+	}
+	row.CritCveCount, row.HighCveCount, row.MedCveCount, row.LowCveCount, row.NegligibleCveCount, row.UnknownCveCount = 0, 0, 0, 0, 0, 0
+	for _, vuln := range vulns {
+		severity := vuln.Severity
+		if vuln.NvdSeverity != "" {
+			severity = vuln.NvdSeverity
+		}
+		for i := 0; i < row.counted[countedKey(vuln.Name, vuln.Installed, vuln.Vulnerability, vuln.Type)]; i++ {
+			row.AddCveCount(severity)
+		}
+	}
+}
+
+func countedKey(name string, installed string, vulnerability string, typ string) string {
+	return strings.Join([]string{name, installed, vulnerability, typ}, "--")
+}
+
+// SetRawGrypeJSON sets the raw Grype output along with its parsed form, so
+// that ExtractVulns does not need to unmarshal it again
+func (row *ImageScanSummary) SetRawGrypeJSON(raw string, output *GrypeScanOutput) {
+	row.RawGrypeJSON = raw
+	row.grypeOutput = output
+}
+
+// SetTrivyOutput keeps the parsed trivy output, for ExtractVulns and
+// ExtractFindings
+func (row *ImageScanSummary) SetTrivyOutput(output *TrivyScanOutput) {
+	row.trivyOutput = output
+}
+
+// ExtractFindings returns a row for each secret and failed misconfiguration
+// check in the trivy output. Only kinds listed are included ("secret" and
+// "misconfig"), since trivy may report secrets even when not asked to.
+func (row *ImageScanSummary) ExtractFindings(kinds ...string) []*Finding {
+	findings := []*Finding{}
+	if row.trivyOutput == nil {
+		return findings
+	}
+	if row.ID == "" {
+		row.SetID()
+	}
+	include := map[string]bool{}
+	for _, kind := range kinds {
+		include[kind] = true
+	}
+	for _, result := range row.trivyOutput.Results {
+		if include[FindingKindSecret] {
+			for _, secret := range result.Secrets {
+				findings = append(findings, &Finding{
+					Kind:     FindingKindSecret,
+					RuleID:   secret.RuleID,
+					Title:    secret.Title,
+					Severity: secret.Severity,
+					Target:   result.Target,
+					Location: fmt.Sprintf("%d-%d", secret.StartLine, secret.EndLine),
+				})
+			}
+		}
+		if include[FindingKindMisconfig] {
+			for _, misconfig := range result.Misconfigurations {
+				if misconfig.Status != "" && misconfig.Status != "FAIL" {
+					continue
+				}
+				findings = append(findings, &Finding{
+					Kind:     FindingKindMisconfig,
+					RuleID:   misconfig.ID,
+					Title:    misconfig.Title,
+					Severity: misconfig.Severity,
+					Target:   result.Target,
+					Location: misconfig.Type,
+				})
+			}
+		}
+	}
+	for _, finding := range findings {
+		finding.ScanID = row.ID
+		finding.Time = row.Time
+		finding.SetID()
+	}
+	return findings
 }
 
+// Trivy license categories considered copyleft
+var copyleftCategories = map[string]bool{
+	"forbidden":  true,
+	"restricted": true,
+	"reciprocal": true,
+}
+
+// IsCopyleft reports whether a trivy license category is a copyleft one
+func IsCopyleft(category string) bool {
+	return copyleftCategories[strings.ToLower(category)]
+}
+
+// ExtractLicenses returns a row for each license detected by trivy
+func (row *ImageScanSummary) ExtractLicenses() []*License {
+	licenses := []*License{}
+	if row.trivyOutput == nil {
+		return licenses
+	}
+	if row.ID == "" {
+		row.SetID()
+	}
+	for _, result := range row.trivyOutput.Results {
+		for _, license := range result.Licenses {
+			l := License{
+				ScanID:   row.ID,
+				Package:  license.PkgName,
+				FilePath: license.FilePath,
+				Name:     license.Name,
+				Category: strings.ToLower(license.Category),
+				Severity: license.Severity,
+				Time:     row.Time,
+			}
+			l.SetID()
+			licenses = append(licenses, &l)
+		}
+	}
+	return licenses
+}
+
+// ExtractVulns returns a row for each vuln found, from the grype output or,
+// for trivy scans, the parsed trivy output
 func (row *ImageScanSummary) ExtractVulns() ([]*Vuln, error) {
-	// No Grype data present which we rely on for this info
-	if row.RawGrypeJSON == "" {
+	if row.RawGrypeJSON == "" && row.trivyOutput == nil {
 		return []*Vuln{}, nil
 	}
 	if row.ID == "" {
 		row.SetID()
 	}
-	var output GrypeScanOutput
-	if err := json.Unmarshal([]byte(row.RawGrypeJSON), &output); err != nil {
-		return nil, err
+	if row.RawGrypeJSON == "" {
+		return row.extractTrivyVulns(), nil
+	}
+	output := row.grypeOutput
+	if output == nil {
+		output = &GrypeScanOutput{}
+		if err := json.Unmarshal([]byte(row.RawGrypeJSON), output); err != nil {
+			return nil, err
+		}
 	}
 	uniqueVulns := map[string]*Vuln{}
+	searchedBy := map[string][]json.RawMessage{}
 	for _, match := range output.Matches {
 		v := Vuln{
 			ScanID:        row.ID,
@@ -63,13 +490,34 @@ func (row *ImageScanSummary) ExtractVulns() ([]*Vuln, error) {
 			Type:          match.Artifact.Type,
 			Vulnerability: match.Vulnerability.ID,
 			Severity:      match.Vulnerability.Severity,
+			DataSource:    match.Vulnerability.DataSource,
+			Description:   snippet(match.Vulnerability.Description),
 			Time:          row.Time,
 		}
 		v.SetID()
+		// The same vuln may be matched more than once, e.g. both directly
+		// and via its upstream source package, so keep every match detail
+		if existing, ok := uniqueVulns[v.ID]; ok {
+			v = *existing
+		}
+		for _, details := range match.MatchDetails {
+			v.MatchType = appendUnique(v.MatchType, details.Type)
+			v.Matcher = appendUnique(v.Matcher, details.Matcher)
+			if len(details.SearchedBy) > 0 {
+				searchedBy[v.ID] = append(searchedBy[v.ID], details.SearchedBy)
+			}
+		}
 		uniqueVulns[v.ID] = &v
 	}
 	vulns := []*Vuln{}
 	for _, vuln := range uniqueVulns {
+		if s, ok := searchedBy[vuln.ID]; ok {
+			b, err := json.Marshal(s)
+			if err != nil {
+				return nil, err
+			}
+			vuln.SearchedBy = string(b)
+		}
 		vulns = append(vulns, vuln)
 	}
 	sort.Slice(vulns, func(i, j int) bool {
@@ -78,8 +526,49 @@ func (row *ImageScanSummary) ExtractVulns() ([]*Vuln, error) {
 	return vulns, nil
 }
 
+// extractTrivyVulns returns a row for each vuln in the trivy output. As with
+// grype, the same vuln found in more than one place (e.g. a jar vendored
+// twice) is a single row, listing every target it was found in.
+func (row *ImageScanSummary) extractTrivyVulns() []*Vuln {
+	uniqueVulns := map[string]*Vuln{}
+	for _, result := range row.trivyOutput.Results {
+		for _, vuln := range result.Vulnerabilities {
+			v := Vuln{
+				ScanID:        row.ID,
+				Name:          vuln.PkgName,
+				Installed:     vuln.InstalledVersion,
+				FixedIn:       vuln.FixedVersion,
+				Type:          result.Type,
+				Vulnerability: vuln.VulnerabilityID,
+				Severity:      vuln.Severity,
+				DataSource:    vuln.PrimaryURL,
+				Description:   snippet(vuln.Description),
+				Time:          row.Time,
+			}
+			v.SetID()
+			if existing, ok := uniqueVulns[v.ID]; ok {
+				v = *existing
+			}
+			target := vuln.PkgPath
+			if target == "" {
+				target = result.Target
+			}
+			v.Target = appendUnique(v.Target, target)
+			uniqueVulns[v.ID] = &v
+		}
+	}
+	vulns := []*Vuln{}
+	for _, vuln := range uniqueVulns {
+		vulns = append(vulns, vuln)
+	}
+	sort.Slice(vulns, func(i, j int) bool {
+		return vulns[i].id() < vulns[j].id()
+	})
+	return vulns
+}
+
 type Vuln struct {
-	ID            string `bigquery:"id"`      // This is faux primary key, the shas256sum of (name + "--" + installed + "--" + vulnerability + "--" + type + "--" + time)
+	ID            string `bigquery:"id"`      // This is faux primary key, the shas256sum of (scan_id + "--" + name + "--" + installed + "--" + vulnerability + "--" + type + "--" + time)
 	ScanID        string `bigquery:"scan_id"` // This is faux foreign key to the table above
 	Name          string `bigquery:"name"`
 	Installed     string `bigquery:"installed"`
@@ -88,6 +577,74 @@ type Vuln struct {
 	Vulnerability string `bigquery:"vulnerability"`
 	Severity      string `bigquery:"severity"`
 	Time          string `bigquery:"time"`
+
+	// How grype matched the vuln, as comma-separated match types (e.g.
+	// "exact-direct-match" or "cpe-match") and matchers, along with a JSON
+	// array of what it searched by
+	MatchType  string `bigquery:"match_type"`
+	Matcher    string `bigquery:"matcher"`
+	SearchedBy string `bigquery:"searched_by"`
+
+	// DataSource is the URL of the advisory the scanner matched, and
+	// Description the start of its description (see MaxDescriptionLength)
+	DataSource  string `bigquery:"data_source"`
+	Description string `bigquery:"description"`
+
+	// Target is where trivy found the package, as comma-separated paths for
+	// language packages or result targets (e.g. "alpine:3.19 (alpine 3.19.1)")
+	// for OS packages. It is empty for grype.
+	Target string `bigquery:"target"`
+
+	// These are only populated when using --severity-source=nvd
+	NvdSeverity  string  `bigquery:"nvd_severity"`
+	NvdCvssScore float64 `bigquery:"nvd_cvss_score"`
+
+	// JiraIssue is the key of the Jira issue tracking the vuln, when filing
+	// issues with --jira-url
+	JiraIssue string `bigquery:"jira_issue"`
+
+	// Change is "added" or "removed" for the rows of a scan uploaded with
+	// --upload-mode=delta, which only records what changed since its base
+	// scan, and empty otherwise
+	Change string `bigquery:"change"`
+}
+
+// MaxDescriptionLength is the most bytes of a vuln's description recorded
+// in its row, as the full text is in the scanner output
+const MaxDescriptionLength = 256
+
+// snippet returns the start of a description, whitespace collapsed, cut at
+// a word boundary if it is longer than MaxDescriptionLength
+func snippet(description string) string {
+	s := strings.Join(strings.Fields(description), " ")
+	if len(s) <= MaxDescriptionLength {
+		return s
+	}
+	cut := strings.LastIndex(s[:MaxDescriptionLength-len("...")+1], " ")
+	if cut <= 0 {
+		cut = MaxDescriptionLength - len("...")
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+	}
+	return s[:cut] + "..."
+}
+
+// appendUnique appends value to a comma-separated list, unless it is empty
+// or already present
+func appendUnique(list string, value string) string {
+	if value == "" {
+		return list
+	}
+	if list == "" {
+		return value
+	}
+	for _, v := range strings.Split(list, ",") {
+		if v == value {
+			return list
+		}
+	}
+	return list + "," + value
 }
 
 func (row *Vuln) SetID() {
@@ -95,7 +652,95 @@ func (row *Vuln) SetID() {
 }
 
 func (row *Vuln) id() string {
-	return strings.Join([]string{row.Name, row.Installed, row.Vulnerability, row.Type, row.Time}, "--")
+	return strings.Join([]string{row.ScanID, row.Name, row.Installed, row.Vulnerability, row.Type, row.Time}, "--")
+}
+
+const (
+	FindingKindSecret    = "secret"
+	FindingKindMisconfig = "misconfig"
+)
+
+// Finding is a secret or misconfiguration found in a single scan
+type Finding struct {
+	ID       string `bigquery:"id"`      // This is faux primary key, the shas256sum of (scan_id + "--" + kind + "--" + rule_id + "--" + target + "--" + location)
+	ScanID   string `bigquery:"scan_id"` // This is faux foreign key to the summary table
+	Kind     string `bigquery:"kind"`    // "secret" or "misconfig"
+	RuleID   string `bigquery:"rule_id"`
+	Title    string `bigquery:"title"`
+	Severity string `bigquery:"severity"`
+	Target   string `bigquery:"target"`
+
+	// Location is the line range for secrets, or the config type (e.g.
+	// "dockerfile") for misconfigurations
+	Location string `bigquery:"location"`
+	Time     string `bigquery:"time"`
+}
+
+func (row *Finding) SetID() {
+	row.ID = sha256Sum(strings.Join([]string{row.ScanID, row.Kind, row.RuleID, row.Target, row.Location}, "--"))
+}
+
+// License is a license detected on a package (or, with trivy's
+// --license-full, in a file) in a single scan
+type License struct {
+	ID       string `bigquery:"id"`      // This is faux primary key, the shas256sum of (scan_id + "--" + package + "--" + file_path + "--" + name)
+	ScanID   string `bigquery:"scan_id"` // This is faux foreign key to the summary table
+	Package  string `bigquery:"package"`
+	FilePath string `bigquery:"file_path"`
+	Name     string `bigquery:"name"`     // SPDX license identifier where trivy recognizes it
+	Category string `bigquery:"category"` // e.g. "restricted", "reciprocal", "notice"
+	Severity string `bigquery:"severity"`
+	Time     string `bigquery:"time"`
+}
+
+func (row *License) SetID() {
+	row.ID = sha256Sum(strings.Join([]string{row.ScanID, row.Package, row.FilePath, row.Name}, "--"))
+}
+
+// ExtractPackages returns a row for each package trivy found, vulnerable or not
+func (row *ImageScanSummary) ExtractPackages() []*Package {
+	packages := []*Package{}
+	if row.trivyOutput == nil {
+		return packages
+	}
+	if row.ID == "" {
+		row.SetID()
+	}
+	for _, result := range row.trivyOutput.Results {
+		for _, pkg := range result.Packages {
+			p := Package{
+				ScanID:  row.ID,
+				Name:    pkg.Name,
+				Version: pkg.Version,
+				Type:    result.Type,
+				PURL:    pkg.Identifier.PURL,
+				Target:  result.Target,
+				Layer:   pkg.Layer.DiffID,
+				Time:    row.Time,
+			}
+			p.SetID()
+			packages = append(packages, &p)
+		}
+	}
+	return packages
+}
+
+// Package is a package found in a single scan, whether or not it has any
+// known vulnerabilities
+type Package struct {
+	ID      string `bigquery:"id"`      // This is faux primary key, the shas256sum of (scan_id + "--" + target + "--" + type + "--" + name + "--" + version + "--" + layer)
+	ScanID  string `bigquery:"scan_id"` // This is faux foreign key to the summary table
+	Name    string `bigquery:"name"`
+	Version string `bigquery:"version"`
+	Type    string `bigquery:"type"` // e.g. "alpine", "gomod" or "jar"
+	PURL    string `bigquery:"purl"`
+	Target  string `bigquery:"target"`
+	Layer   string `bigquery:"layer"` // diff ID of the layer that added the package, if known
+	Time    string `bigquery:"time"`
+}
+
+func (row *Package) SetID() {
+	row.ID = sha256Sum(strings.Join([]string{row.ScanID, row.Target, row.Type, row.Name, row.Version, row.Layer}, "--"))
 }
 
 func sha256Sum(s string) string {
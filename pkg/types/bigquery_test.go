@@ -2,7 +2,9 @@ package types
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 const (
@@ -12,6 +14,8 @@ const (
 	testScanID = "testing123"
 
 	expectedVulnCount = 37
+
+	expectedCPEMatchCount = 16
 )
 
 var (
@@ -57,11 +61,31 @@ func TestVulnExtraction(t *testing.T) {
 				t.Errorf("got empty value for required field %s", vuln.id())
 			}
 		}
+		if vuln.MatchType == "" || vuln.Matcher == "" || vuln.SearchedBy == "" {
+			t.Errorf("got empty match details for %s", vuln.id())
+		}
+		if !strings.HasPrefix(vuln.DataSource, "http") {
+			t.Errorf("got data source %q for %s, wanted a URL", vuln.DataSource, vuln.id())
+		}
+		if len(vuln.Description) > MaxDescriptionLength {
+			t.Errorf("got a %d byte description for %s", len(vuln.Description), vuln.id())
+		}
 		if _, ok := actualVulnCountsByType[vuln.Type]; !ok {
 			actualVulnCountsByType[vuln.Type] = 0
 		}
 		actualVulnCountsByType[vuln.Type]++
 	}
+	// Repeated matches of the same vuln keep all of their match types
+	actualMatchTypes := map[string]int{}
+	for _, vuln := range vulns {
+		actualMatchTypes[vuln.MatchType]++
+	}
+	if actualMatchTypes["cpe-match"] != expectedCPEMatchCount {
+		t.Errorf("got %d CPE-only vulns, wanted %d", actualMatchTypes["cpe-match"], expectedCPEMatchCount)
+	}
+	if actualMatchTypes["exact-direct-match,exact-indirect-match"]+actualMatchTypes["exact-indirect-match,exact-direct-match"] == 0 {
+		t.Errorf("expected vulns matched both directly and indirectly, got %v", actualMatchTypes)
+	}
 	for k, expected := range expectedVulnCountsByType {
 		actual, ok := actualVulnCountsByType[k]
 		if !ok {
@@ -72,3 +96,236 @@ func TestVulnExtraction(t *testing.T) {
 		}
 	}
 }
+
+func TestTrivyVulnExtraction(t *testing.T) {
+	summary := ImageScanSummary{Time: testTime, ID: testScanID}
+	summary.SetTrivyOutput(&TrivyScanOutput{
+		Results: []TrivyScanOutputResult{
+			{
+				Target: "alpine:3.19 (alpine 3.19.1)",
+				Type:   "alpine",
+				Vulnerabilities: []TrivyScanOutputResultVulnerability{
+					{VulnerabilityID: "CVE-2024-0001", PkgName: "openssl", InstalledVersion: "3.1.4-r5", FixedVersion: "3.1.4-r6", Severity: "HIGH", PrimaryURL: "https://avd.aquasec.com/nvd/cve-2024-0001", Description: "A flaw\nin openssl."},
+				},
+			},
+			{
+				Target: "Java",
+				Type:   "jar",
+				Vulnerabilities: []TrivyScanOutputResultVulnerability{
+					{VulnerabilityID: "CVE-2021-44228", PkgName: "log4j-core", PkgPath: "app/a.jar", InstalledVersion: "2.14.1", FixedVersion: "2.15.0", Severity: "CRITICAL"},
+					{VulnerabilityID: "CVE-2021-44228", PkgName: "log4j-core", PkgPath: "app/b.jar", InstalledVersion: "2.14.1", FixedVersion: "2.15.0", Severity: "CRITICAL"},
+				},
+			},
+		},
+	})
+
+	vulns, err := summary.ExtractVulns()
+	if err != nil {
+		t.Fatalf("expected no error on summary.ExtractVulns(), got %v", err)
+	}
+	if len(vulns) != 2 {
+		t.Fatalf("got %d vulns, wanted 2", len(vulns))
+	}
+	byName := map[string]*Vuln{}
+	for _, vuln := range vulns {
+		if vuln.ScanID != testScanID || vuln.Time != testTime || vuln.ID == "" {
+			t.Errorf("expected the scan ID and time to be passed down, got %+v", vuln)
+		}
+		byName[vuln.Name] = vuln
+	}
+	if v := byName["openssl"]; v == nil || v.FixedIn != "3.1.4-r6" || v.Type != "alpine" || v.Severity != "HIGH" || v.Target != "alpine:3.19 (alpine 3.19.1)" ||
+		v.DataSource != "https://avd.aquasec.com/nvd/cve-2024-0001" || v.Description != "A flaw in openssl." {
+		t.Errorf("unexpected openssl vuln %+v", v)
+	}
+	if v := byName["log4j-core"]; v == nil || v.Vulnerability != "CVE-2021-44228" || v.Target != "app/a.jar,app/b.jar" {
+		t.Errorf("expected log4j-core found in both jars, got %+v", v)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	if s := snippet("  short\n description "); s != "short description" {
+		t.Errorf("got snippet %q, wanted \"short description\"", s)
+	}
+	long := strings.Repeat("word ", 100)
+	s := snippet(long)
+	if len(s) > MaxDescriptionLength || !strings.HasSuffix(s, "word...") {
+		t.Errorf("expected a snippet cut after a word, got %q", s)
+	}
+	s = snippet(strings.Repeat("é", 200))
+	if len(s) > MaxDescriptionLength || !utf8.ValidString(s) {
+		t.Errorf("expected a valid UTF-8 snippet, got %q", s)
+	}
+}
+
+func TestFindingExtraction(t *testing.T) {
+	summary := ImageScanSummary{Time: testTime, ID: testScanID}
+	summary.SetTrivyOutput(&TrivyScanOutput{
+		Results: []TrivyScanOutputResult{
+			{
+				Target:  "/app/config.env",
+				Secrets: []TrivyScanOutputResultSecret{{RuleID: "aws-access-key-id", Severity: "CRITICAL", StartLine: 3, EndLine: 3}},
+			},
+			{
+				Target: "Dockerfile",
+				Misconfigurations: []TrivyScanOutputResultMisconfiguration{
+					{Type: "dockerfile", ID: "DS002", Severity: "HIGH", Status: "FAIL"},
+					{Type: "dockerfile", ID: "DS005", Severity: "LOW", Status: "PASS"},
+				},
+			},
+		},
+	})
+
+	if findings := summary.ExtractFindings(FindingKindMisconfig); len(findings) != 1 || findings[0].RuleID != "DS002" {
+		t.Errorf("expected only the failed DS002 misconfiguration, got %+v", findings)
+	}
+	findings := summary.ExtractFindings(FindingKindSecret, FindingKindMisconfig)
+	if len(findings) != 2 {
+		t.Fatalf("got %d findings, wanted 2", len(findings))
+	}
+	for _, finding := range findings {
+		if finding.ScanID != testScanID {
+			t.Errorf("finding.ScanID is %s, wanted %s", finding.ScanID, testScanID)
+		}
+		if finding.ID == "" {
+			t.Errorf("got empty ID for finding %s", finding.RuleID)
+		}
+	}
+	if findings[0].Location != "3-3" {
+		t.Errorf("secret location is %s, wanted 3-3", findings[0].Location)
+	}
+}
+
+func TestFindingCounts(t *testing.T) {
+	var counts FindingCounts
+	for _, severity := range []string{"CRITICAL", "high", "HIGH", "bogus"} {
+		counts.Add(severity)
+	}
+	expected := FindingCounts{Critical: 1, High: 2, Unknown: 1, Total: 4}
+	if counts != expected {
+		t.Errorf("counts are %+v, wanted %+v", counts, expected)
+	}
+}
+
+func TestRecountCves(t *testing.T) {
+	summary := &ImageScanSummary{}
+	summary.CountCve("openssl", "3.1.0", "CVE-2023-0001", "apk", "Medium")
+	summary.CountCve("openssl", "3.1.0", "CVE-2023-0001", "apk", "Medium")
+	summary.CountCve("busybox", "1.36.0", "CVE-2023-0002", "apk", "Low")
+	if summary.MedCveCount != 2 || summary.LowCveCount != 1 {
+		t.Fatalf("expected 2 medium and 1 low CVE counted, got %+v", summary)
+	}
+
+	summary.RecountCves([]*Vuln{
+		{Name: "openssl", Installed: "3.1.0", Vulnerability: "CVE-2023-0001", Type: "apk", Severity: "Medium", NvdSeverity: "CRITICAL"},
+		{Name: "busybox", Installed: "1.36.0", Vulnerability: "CVE-2023-0002", Type: "apk", Severity: "Low"},
+	})
+	if summary.CritCveCount != 2 || summary.MedCveCount != 0 || summary.LowCveCount != 1 {
+		t.Errorf("expected the openssl matches recounted as critical, got %+v", summary)
+	}
+}
+
+func TestLicenseExtraction(t *testing.T) {
+	summary := ImageScanSummary{Time: testTime, ID: testScanID}
+	summary.SetTrivyOutput(&TrivyScanOutput{
+		Results: []TrivyScanOutputResult{{
+			Target: "OS Packages",
+			Licenses: []TrivyScanOutputResultLicense{
+				{PkgName: "busybox", Name: "GPL-2.0-only", Category: "restricted", Severity: "HIGH"},
+				{PkgName: "zlib", Name: "Zlib", Category: "notice", Severity: "LOW"},
+			},
+		}},
+	})
+	licenses := summary.ExtractLicenses()
+	if len(licenses) != 2 {
+		t.Fatalf("got %d licenses, wanted 2", len(licenses))
+	}
+	if licenses[0].ScanID != testScanID || licenses[0].Package != "busybox" || licenses[0].ID == "" {
+		t.Errorf("unexpected license row %+v", licenses[0])
+	}
+	if !IsCopyleft(licenses[0].Category) || IsCopyleft(licenses[1].Category) {
+		t.Errorf("expected only %s to be copyleft", licenses[0].Name)
+	}
+}
+
+func TestEcosystemCounts(t *testing.T) {
+	var summary ImageScanSummary
+	for _, packageType := range []string{"go-module", "apk", "go-module", "", "python", "apk", "go-module"} {
+		summary.AddEcosystemCount(packageType)
+	}
+	expected := []EcosystemCount{{"apk", 2}, {"go-module", 3}, {"python", 1}, {"unknown", 1}}
+	if len(summary.EcosystemCounts) != len(expected) {
+		t.Fatalf("got ecosystem counts %v, wanted %v", summary.EcosystemCounts, expected)
+	}
+	for i, count := range expected {
+		if summary.EcosystemCounts[i] != count {
+			t.Errorf("got ecosystem counts %v, wanted %v", summary.EcosystemCounts, expected)
+			break
+		}
+	}
+}
+
+func TestPackageExtraction(t *testing.T) {
+	summary := ImageScanSummary{Time: testTime, ID: testScanID}
+	summary.SetTrivyOutput(&TrivyScanOutput{
+		Results: []TrivyScanOutputResult{{
+			Target: "app.jar",
+			Type:   "jar",
+			Packages: []TrivyScanOutputResultPackage{{
+				Name:       "org.apache.logging.log4j:log4j-core",
+				Version:    "2.14.1",
+				Identifier: TrivyScanOutputResultPackageIdentifier{PURL: "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"},
+				Layer:      TrivyScanOutputResultPackageLayer{DiffID: "sha256:abc"},
+			}},
+		}},
+	})
+	packages := summary.ExtractPackages()
+	if len(packages) != 1 {
+		t.Fatalf("got %d packages, wanted 1", len(packages))
+	}
+	pkg := packages[0]
+	if pkg.ScanID != testScanID || pkg.Type != "jar" || pkg.PURL == "" || pkg.Layer != "sha256:abc" || pkg.ID == "" {
+		t.Errorf("unexpected package row %+v", pkg)
+	}
+}
+
+func TestSetID(t *testing.T) {
+	summary := ImageScanSummary{Image: "cgr.dev/chainguard/static:latest", Digest: "sha256:abc", Scanner: "grype", ScannerDbVersion: "5", Time: testTime}
+	summary.SetID()
+	again := summary
+	again.ID = ""
+	again.SetID()
+	if summary.ID == "" || summary.ID != again.ID {
+		t.Errorf("expected the same scan to get the same ID, got %q and %q", summary.ID, again.ID)
+	}
+
+	// Another digest or database in the same second is another scan
+	for _, other := range []ImageScanSummary{
+		{Image: summary.Image, Digest: "sha256:def", Scanner: summary.Scanner, ScannerDbVersion: summary.ScannerDbVersion, Time: testTime},
+		{Image: summary.Image, Digest: summary.Digest, Scanner: summary.Scanner, ScannerDbVersion: "6", Time: testTime},
+	} {
+		other.SetID()
+		if other.ID == summary.ID {
+			t.Errorf("expected a different ID for %+v", other)
+		}
+	}
+
+	// An ID given up front is kept
+	given := ImageScanSummary{ID: testScanID, Image: summary.Image, Time: testTime}
+	given.SetID()
+	if given.ID != testScanID {
+		t.Errorf("expected ID %q to be kept, got %q", testScanID, given.ID)
+	}
+}
+
+func TestCheckScanID(t *testing.T) {
+	for _, id := range []string{testScanID, "job-42", "2023-06-22T02.38.46Z_grype"} {
+		if err := CheckScanID(id); err != nil {
+			t.Errorf("expected no error on CheckScanID(%q), got %v", id, err)
+		}
+	}
+	for _, id := range []string{"", "a/b", "with space", string(make([]byte, maxScanIDLength+1))} {
+		if err := CheckScanID(id); err == nil {
+			t.Errorf("expected an error on CheckScanID(%q)", id)
+		}
+	}
+}
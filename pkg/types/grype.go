@@ -1,17 +1,132 @@
 package types
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 type GrypeScanOutput struct {
 	Matches    []GrypeScanOutputMatches  `json:"matches"`
 	Source     GrypeScanOutputSource     `json:"source"`
+	Distro     GrypeScanOutputDistro     `json:"distro"`
 	Descriptor GrypeScanOutputDescriptor `json:"descriptor"`
+
+	// IgnoredMatches are the matches grype's ignore rules suppressed, which
+	// it only reports when it has some (e.g. from a .grype.yaml)
+	IgnoredMatches []GrypeScanOutputIgnoredMatch `json:"ignoredMatches"`
+}
+
+// GrypeScanOutputIgnoredMatch is a match with the ignore rules it matched
+type GrypeScanOutputIgnoredMatch struct {
+	GrypeScanOutputMatches
+	AppliedIgnoreRules []GrypeIgnoreRule `json:"appliedIgnoreRules"`
+}
+
+// GrypeIgnoreRule is an ignore rule of grype's config, of which only the
+// fields set are matched
+type GrypeIgnoreRule struct {
+	Vulnerability    string `json:"vulnerability,omitempty"`
+	Reason           string `json:"reason,omitempty"`
+	Namespace        string `json:"namespace,omitempty"`
+	FixState         string `json:"fix-state,omitempty"`
+	VexStatus        string `json:"vex-status,omitempty"`
+	VexJustification string `json:"vex-justification,omitempty"`
+	MatchType        string `json:"match-type,omitempty"`
+
+	Package *GrypeIgnoreRulePackage `json:"package,omitempty"`
+}
+
+// GrypeIgnoreRulePackage matches the package of an ignore rule
+type GrypeIgnoreRulePackage struct {
+	Name     string `json:"name,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+// Describe returns the rule's reason, or without one what it matched on
+func (rule *GrypeIgnoreRule) Describe() string {
+	if rule.Reason != "" {
+		return rule.Reason
+	}
+	parts := []string{}
+	for _, field := range []struct{ name, value string }{
+		{"vulnerability", rule.Vulnerability},
+		{"namespace", rule.Namespace},
+		{"fix-state", rule.FixState},
+		{"vex-status", rule.VexStatus},
+		{"vex-justification", rule.VexJustification},
+		{"match-type", rule.MatchType},
+	} {
+		if field.value != "" {
+			parts = append(parts, fmt.Sprintf("%s=%s", field.name, field.value))
+		}
+	}
+	if pkg := rule.Package; pkg != nil {
+		for _, field := range []struct{ name, value string }{
+			{"package.name", pkg.Name},
+			{"package.version", pkg.Version},
+			{"package.type", pkg.Type},
+			{"package.location", pkg.Location},
+		} {
+			if field.value != "" {
+				parts = append(parts, fmt.Sprintf("%s=%s", field.name, field.value))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Ignored returns the matches grype ignored, with the reasons of the rules
+// each matched
+func (output *GrypeScanOutput) Ignored() []IgnoredMatch {
+	ignored := make([]IgnoredMatch, 0, len(output.IgnoredMatches))
+	for _, match := range output.IgnoredMatches {
+		reasons := []string{}
+		for _, rule := range match.AppliedIgnoreRules {
+			reasons = append(reasons, rule.Describe())
+		}
+		ignored = append(ignored, IgnoredMatch{
+			Vulnerability: match.Vulnerability.ID,
+			Name:          match.Artifact.Name,
+			Version:       match.Artifact.Version,
+			Type:          match.Artifact.Type,
+			Severity:      match.Vulnerability.Severity,
+			Reason:        strings.Join(reasons, "; "),
+		})
+	}
+	return ignored
+}
+
+type GrypeScanOutputDistro struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
 }
 
 type GrypeScanOutputSource struct {
+	Type   string                      `json:"type"`
 	Target GrypeScanOutputSourceTarget `json:"target"`
 }
 
 type GrypeScanOutputSourceTarget struct {
 	RepoDigests []string `json:"repoDigests"`
+
+	// ManifestDigest is the digest of the image manifest scanned, which for
+	// an index differs from its repo digests
+	ManifestDigest string `json:"manifestDigest"`
+
+	// Path is set instead for directory and file sources, where the
+	// target is just the path that was scanned
+	Path string `json:"-"`
+}
+
+func (target *GrypeScanOutputSourceTarget) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &target.Path)
+	}
+	type plain GrypeScanOutputSourceTarget
+	return json.Unmarshal(b, (*plain)(target))
 }
 
 type GrypeScanOutputDescriptor struct {
@@ -20,24 +135,70 @@ type GrypeScanOutputDescriptor struct {
 }
 
 type GrypeScanOutputDescriptorDb struct {
-	Checksum string `json:"checksum"`
+	Checksum      string `json:"checksum"`
+	Built         string `json:"built"`
+	SchemaVersion int    `json:"schemaVersion"`
 }
 
 type GrypeScanOutputMatches struct {
 	Vulnerability GrypeScanOutputMatchesVulnerability  `json:"vulnerability"`
 	Artifact      GrypeScanOutputMatchesArtifact       `json:"artifact"`
+	MatchDetails  []GrypeScanOutputMatchesMatchDetails `json:"matchDetails"`
+}
+
+// CPEOnly reports whether a match was only found via CPE heuristics, which
+// are much more prone to false positives than exact package matches
+func (match *GrypeScanOutputMatches) CPEOnly() bool {
+	for _, details := range match.MatchDetails {
+		if details.Type != GrypeMatchTypeCPE {
+			return false
+		}
+	}
+	return len(match.MatchDetails) > 0
+}
+
+// ExcludeCPEMatches drops the matches only found via CPE heuristics,
+// returning how many were dropped
+func (output *GrypeScanOutput) ExcludeCPEMatches() int {
+	matches := []GrypeScanOutputMatches{}
+	for _, match := range output.Matches {
+		if !match.CPEOnly() {
+			matches = append(matches, match)
+		}
+	}
+	excluded := len(output.Matches) - len(matches)
+	output.Matches = matches
+	return excluded
+}
+
+const GrypeMatchTypeCPE = "cpe-match"
+
+// GrypeScanOutputMatchesMatchDetails describes how grype matched a
+// vulnerability, e.g. "exact-direct-match" on the package vs "cpe-match"
+type GrypeScanOutputMatchesMatchDetails struct {
+	Type       string          `json:"type"`
+	Matcher    string          `json:"matcher"`
+	SearchedBy json.RawMessage `json:"searchedBy"`
 }
 
 type GrypeScanOutputMatchesArtifact struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
-	Type    string `json:"type"`
+	Name      string                                   `json:"name"`
+	Version   string                                   `json:"version"`
+	Type      string                                   `json:"type"`
+	Locations []GrypeScanOutputMatchesArtifactLocation `json:"locations"`
+}
+
+type GrypeScanOutputMatchesArtifactLocation struct {
+	Path    string `json:"path"`
+	LayerID string `json:"layerID"`
 }
 
 type GrypeScanOutputMatchesVulnerability struct {
-	ID       string                                 `json:"id"`
-	Severity string                                 `json:"severity"`
-	Fix      GrypeScanOutputMatchesVulnerabilityFix `json:"fix"`
+	ID          string                                 `json:"id"`
+	DataSource  string                                 `json:"dataSource"`
+	Severity    string                                 `json:"severity"`
+	Description string                                 `json:"description"`
+	Fix         GrypeScanOutputMatchesVulnerabilityFix `json:"fix"`
 }
 
 type GrypeScanOutputMatchesVulnerabilityFix struct {
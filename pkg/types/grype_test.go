@@ -0,0 +1,69 @@
+package types
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestGrypeSourceTarget(t *testing.T) {
+	var image GrypeScanOutputSource
+	if err := json.Unmarshal([]byte(`{"type": "image", "target": {"repoDigests": ["cgr.dev/chainguard/static@sha256:abc"]}}`), &image); err != nil {
+		t.Errorf("expected no error on json.Unmarshal() of an image source, got %v", err)
+	}
+	if len(image.Target.RepoDigests) != 1 || image.Target.RepoDigests[0] != "cgr.dev/chainguard/static@sha256:abc" {
+		t.Errorf("image.Target.RepoDigests is %v, wanted [cgr.dev/chainguard/static@sha256:abc]", image.Target.RepoDigests)
+	}
+
+	var dir GrypeScanOutputSource
+	if err := json.Unmarshal([]byte(`{"type": "directory", "target": "/src"}`), &dir); err != nil {
+		t.Errorf("expected no error on json.Unmarshal() of a directory source, got %v", err)
+	}
+	if dir.Target.Path != "/src" {
+		t.Errorf("dir.Target.Path is %s, wanted /src", dir.Target.Path)
+	}
+}
+
+func TestExcludeCPEMatches(t *testing.T) {
+	b, err := os.ReadFile(testGrypeScan)
+	if err != nil {
+		t.Fatalf("expected no error on os.ReadFile(), got %v", err)
+	}
+	var output GrypeScanOutput
+	if err := json.Unmarshal(b, &output); err != nil {
+		t.Fatalf("expected no error on json.Unmarshal(), got %v", err)
+	}
+	if excluded := output.ExcludeCPEMatches(); excluded != 20 {
+		t.Errorf("excluded %d matches, wanted 20", excluded)
+	}
+	if len(output.Matches) != 37 {
+		t.Errorf("got %d matches left, wanted 37", len(output.Matches))
+	}
+	for _, match := range output.Matches {
+		if match.CPEOnly() {
+			t.Errorf("CPE-only match of %s in %s was not excluded", match.Vulnerability.ID, match.Artifact.Name)
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	var output GrypeScanOutput
+	if err := json.Unmarshal([]byte(`{"matches": [], "ignoredMatches": [
+		{"vulnerability": {"id": "CVE-2024-0001", "severity": "High"}, "artifact": {"name": "openssl", "version": "3.1.0", "type": "apk"},
+		 "appliedIgnoreRules": [{"vulnerability": "CVE-2024-0001", "reason": "not reachable"}]},
+		{"vulnerability": {"id": "CVE-2024-0002", "severity": "Low"}, "artifact": {"name": "zlib", "version": "1.3", "type": "apk"},
+		 "appliedIgnoreRules": [{"fix-state": "wont-fix"}, {"vex-status": "not_affected", "package": {"name": "zlib"}}]}
+	]}`), &output); err != nil {
+		t.Fatalf("expected no error on json.Unmarshal(), got %v", err)
+	}
+	ignored := output.Ignored()
+	if len(ignored) != 2 {
+		t.Fatalf("got %d ignored matches, wanted 2", len(ignored))
+	}
+	if got := ignored[0]; got.Vulnerability != "CVE-2024-0001" || got.Name != "openssl" || got.Severity != "High" || got.Reason != "not reachable" {
+		t.Errorf("got ignored match %+v, wanted CVE-2024-0001 in openssl, ignored as not reachable", got)
+	}
+	if got := ignored[1].Reason; got != "fix-state=wont-fix; vex-status=not_affected package.name=zlib" {
+		t.Errorf("got reason %q, wanted the rules described", got)
+	}
+}
@@ -1,5 +1,7 @@
 package types
 
+import "encoding/json"
+
 type InTotoStatement struct {
 	Invocation InTotoStatementInvocation `json:"invocation"`
 	Scanner    InTotoStatementScanner    `json:"scanner"`
@@ -14,12 +16,42 @@ type InTotoStatementInvocation struct {
 }
 
 type InTotoStatementScanner struct {
-	URI     string                 `json:"uri"`
-	Version string                 `json:"version"`
-	Result  map[string]interface{} `json:"result"`
+	URI     string          `json:"uri"`
+	Version string          `json:"version"`
+	Result  json.RawMessage `json:"result"`
 }
 
 type InTotoStatementMetadata struct {
 	ScanStartedOn  string `json:"scanStartedOn"`
 	ScanFinishedOn string `json:"scanFinishedOn"`
+
+	// DbBuiltOn is when the scanner's vulnerability database was built, if known
+	DbBuiltOn string `json:"dbBuiltOn,omitempty"`
+}
+
+// InTotoV01Statement is an in-toto statement about a subject image, with the
+// vuln predicate above, as "cosign attest" would sign it
+type InTotoV01Statement struct {
+	Type          string          `json:"_type"`
+	PredicateType string          `json:"predicateType"`
+	Subject       []InTotoSubject `json:"subject"`
+	Predicate     InTotoStatement `json:"predicate"`
+}
+
+type InTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// DSSEEnvelope is a DSSE envelope, left unsigned when written by rumble
+// itself so that a separate step can sign it
+type DSSEEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     []byte          `json:"payload"`
+	Signatures  []DSSESignature `json:"signatures"`
+}
+
+type DSSESignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
 }
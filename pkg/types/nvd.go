@@ -0,0 +1,31 @@
+package types
+
+type NvdCveOutput struct {
+	Vulnerabilities []NvdCveOutputVulnerability `json:"vulnerabilities"`
+}
+
+type NvdCveOutputVulnerability struct {
+	Cve NvdCveOutputVulnerabilityCve `json:"cve"`
+}
+
+type NvdCveOutputVulnerabilityCve struct {
+	ID      string                              `json:"id"`
+	Metrics NvdCveOutputVulnerabilityCveMetrics `json:"metrics"`
+}
+
+type NvdCveOutputVulnerabilityCveMetrics struct {
+	CvssMetricV31 []NvdCveOutputCvssMetric `json:"cvssMetricV31"`
+	CvssMetricV30 []NvdCveOutputCvssMetric `json:"cvssMetricV30"`
+	CvssMetricV2  []NvdCveOutputCvssMetric `json:"cvssMetricV2"`
+}
+
+type NvdCveOutputCvssMetric struct {
+	Source   string                         `json:"source"`
+	Type     string                         `json:"type"`
+	CvssData NvdCveOutputCvssMetricCvssData `json:"cvssData"`
+}
+
+type NvdCveOutputCvssMetricCvssData struct {
+	Version   string  `json:"version"`
+	BaseScore float64 `json:"baseScore"`
+}
@@ -0,0 +1,18 @@
+package types
+
+type OsvVulnOutput struct {
+	ID       string                  `json:"id"`
+	Aliases  []string                `json:"aliases"`
+	Related  []string                `json:"related"`
+	Affected []OsvVulnOutputAffected `json:"affected"`
+}
+
+type OsvVulnOutputAffected struct {
+	Package OsvVulnOutputAffectedPackage `json:"package"`
+}
+
+type OsvVulnOutputAffectedPackage struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Purl      string `json:"purl"`
+}
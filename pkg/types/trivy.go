@@ -1,20 +1,83 @@
 package types
 
 type TrivyScanOutput struct {
-	Metadata TrivyScanOutputMetadata `json:"Metadata"`
-	Results  []TrivyScanOutputResult `json:"Results"`
+	SchemaVersion int                     `json:"SchemaVersion"`
+	Metadata      TrivyScanOutputMetadata `json:"Metadata"`
+	Results       []TrivyScanOutputResult `json:"Results"`
 }
 
 type TrivyScanOutputMetadata struct {
-	RepoDigests []string `json:"RepoDigests"`
+	OS          TrivyScanOutputMetadataOS `json:"OS"`
+	RepoDigests []string                  `json:"RepoDigests"`
+}
+
+type TrivyScanOutputMetadataOS struct {
+	Family string `json:"Family"`
+	Name   string `json:"Name"`
 }
 
 type TrivyScanOutputResult struct {
-	Vulnerabilities []TrivyScanOutputResultVulnerability `json:"Vulnerabilities"`
+	Target            string                                  `json:"Target"`
+	Type              string                                  `json:"Type"`
+	Vulnerabilities   []TrivyScanOutputResultVulnerability    `json:"Vulnerabilities"`
+	Secrets           []TrivyScanOutputResultSecret           `json:"Secrets"`
+	Misconfigurations []TrivyScanOutputResultMisconfiguration `json:"Misconfigurations"`
+	Licenses          []TrivyScanOutputResultLicense          `json:"Licenses"`
+
+	// Packages is only listed with --list-all-pkgs
+	Packages []TrivyScanOutputResultPackage `json:"Packages"`
 }
 
-type TrivyScanOutputResultVulnerability struct {
+type TrivyScanOutputResultPackage struct {
+	Name       string                                 `json:"Name"`
+	Version    string                                 `json:"Version"`
+	Identifier TrivyScanOutputResultPackageIdentifier `json:"Identifier"`
+	Layer      TrivyScanOutputResultPackageLayer      `json:"Layer"`
+}
+
+type TrivyScanOutputResultPackageIdentifier struct {
+	PURL string `json:"PURL"`
+}
+
+type TrivyScanOutputResultPackageLayer struct {
+	Digest string `json:"Digest"`
+	DiffID string `json:"DiffID"`
+}
+
+type TrivyScanOutputResultLicense struct {
 	Severity string `json:"Severity"`
+	Category string `json:"Category"`
+	PkgName  string `json:"PkgName"`
+	FilePath string `json:"FilePath"`
+	Name     string `json:"Name"`
+}
+
+type TrivyScanOutputResultSecret struct {
+	RuleID    string `json:"RuleID"`
+	Category  string `json:"Category"`
+	Severity  string `json:"Severity"`
+	Title     string `json:"Title"`
+	StartLine int    `json:"StartLine"`
+	EndLine   int    `json:"EndLine"`
+}
+
+type TrivyScanOutputResultMisconfiguration struct {
+	Type     string `json:"Type"`
+	ID       string `json:"ID"`
+	Title    string `json:"Title"`
+	Severity string `json:"Severity"`
+	Status   string `json:"Status"`
+}
+
+type TrivyScanOutputResultVulnerability struct {
+	VulnerabilityID  string `json:"VulnerabilityID"`
+	PkgName          string `json:"PkgName"`
+	PkgPath          string `json:"PkgPath"`
+	InstalledVersion string `json:"InstalledVersion"`
+	FixedVersion     string `json:"FixedVersion"`
+	Severity         string `json:"Severity"`
+	PrimaryURL       string `json:"PrimaryURL"`
+	Description      string `json:"Description"`
 }
 
 type TrivyVersionOutput struct {
@@ -30,3 +93,30 @@ type TrivyVersionOutputVulnerabilityDB struct {
 	UpdatedAt    string `json:"UpdatedAt"`
 	DownloadedAt string `json:"DownloadedAt"`
 }
+
+// Ignore drops the vulnerabilities ignore returns a reason for, given the
+// type of the result they're in, and returns them as ignored matches
+func (output *TrivyScanOutput) Ignore(ignore func(resultType string, vuln *TrivyScanOutputResultVulnerability) string) []IgnoredMatch {
+	ignored := []IgnoredMatch{}
+	for i := range output.Results {
+		result := &output.Results[i]
+		kept := result.Vulnerabilities[:0]
+		for _, vuln := range result.Vulnerabilities {
+			reason := ignore(result.Type, &vuln)
+			if reason == "" {
+				kept = append(kept, vuln)
+				continue
+			}
+			ignored = append(ignored, IgnoredMatch{
+				Vulnerability: vuln.VulnerabilityID,
+				Name:          vuln.PkgName,
+				Version:       vuln.InstalledVersion,
+				Type:          result.Type,
+				Severity:      vuln.Severity,
+				Reason:        reason,
+			})
+		}
+		result.Vulnerabilities = kept
+	}
+	return ignored
+}
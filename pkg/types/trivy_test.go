@@ -0,0 +1,27 @@
+package types
+
+import "testing"
+
+func TestTrivyIgnore(t *testing.T) {
+	output := &TrivyScanOutput{Results: []TrivyScanOutputResult{
+		{Type: "wolfi", Vulnerabilities: []TrivyScanOutputResultVulnerability{
+			{VulnerabilityID: "CVE-2024-0001", PkgName: "openssl", InstalledVersion: "3.1.0", Severity: "HIGH"},
+			{VulnerabilityID: "CVE-2024-0002", PkgName: "zlib", InstalledVersion: "1.3", Severity: "LOW"},
+		}},
+		{Type: "gomod", Vulnerabilities: []TrivyScanOutputResultVulnerability{
+			{VulnerabilityID: "CVE-2024-0001", PkgName: "golang.org/x/net", InstalledVersion: "0.1.0", Severity: "HIGH"},
+		}},
+	}}
+	ignored := output.Ignore(func(resultType string, vuln *TrivyScanOutputResultVulnerability) string {
+		if resultType == "wolfi" && vuln.VulnerabilityID == "CVE-2024-0001" {
+			return "not reachable"
+		}
+		return ""
+	})
+	if len(ignored) != 1 || ignored[0].Name != "openssl" || ignored[0].Type != "wolfi" || ignored[0].Reason != "not reachable" {
+		t.Errorf("expected the openssl vuln to be ignored, got %+v", ignored)
+	}
+	if len(output.Results[0].Vulnerabilities) != 1 || output.Results[0].Vulnerabilities[0].PkgName != "zlib" || len(output.Results[1].Vulnerabilities) != 1 {
+		t.Errorf("expected the other vulns to be kept, got %+v", output.Results)
+	}
+}
@@ -0,0 +1,180 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/report"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// Prefix is the path the UI is served under
+const Prefix = "/ui/"
+
+//go:embed templates static
+var files embed.FS
+
+// Source reads the recorded results the UI shows, as the query commands do
+type Source struct {
+	Summaries func(ctx context.Context, filter query.Filter) ([]*types.ImageScanSummary, error)
+	ScanVulns func(ctx context.Context, scanID string) ([]*types.Vuln, error)
+	Search    func(ctx context.Context, search query.Search) ([]*query.SearchResult, error)
+}
+
+type handler struct {
+	src   Source
+	pages map[string]*template.Template
+}
+
+var funcs = template.FuncMap{
+	"severity": func(vuln *types.Vuln) string { return report.Severities[report.SeverityRank(vuln)] },
+	"short": func(digest string) string {
+		if i := strings.Index(digest, ":"); i >= 0 && len(digest) > i+13 {
+			return digest[:i+13]
+		}
+		return digest
+	},
+	"advisory": advisory,
+	"lower":    strings.ToLower,
+	"link":     link,
+}
+
+// Handler serves a read-only UI for browsing scans under Prefix: the latest
+// scan of each image, the history of an image, the vulns found by a scan
+// and the images a vuln is found in
+func Handler(src Source) http.Handler {
+	h := &handler{src: src, pages: map[string]*template.Template{}}
+	for _, page := range []string{"images", "history", "scan", "vuln"} {
+		h.pages[page] = template.Must(template.New("layout.html").Funcs(funcs).ParseFS(files, "templates/layout.html", "templates/"+page+".html"))
+	}
+	static, err := fs.Sub(files, "static")
+	if err != nil {
+		panic(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(Prefix+"static/", http.StripPrefix(Prefix+"static/", http.FileServer(http.FS(static))))
+	mux.HandleFunc(Prefix, h.images)
+	mux.HandleFunc(Prefix+"image", h.history)
+	mux.HandleFunc(Prefix+"scan", h.scan)
+	mux.HandleFunc(Prefix+"vuln", h.vuln)
+	return mux
+}
+
+func (h *handler) images(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != Prefix {
+		http.NotFound(w, r)
+		return
+	}
+	scans, err := h.src.Summaries(r.Context(), query.Filter{LatestOnly: true, Scanner: r.URL.Query().Get("scanner")})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.SliceStable(scans, func(i, j int) bool {
+		if scans[i].Image != scans[j].Image {
+			return scans[i].Image < scans[j].Image
+		}
+		return scans[i].Scanner < scans[j].Scanner
+	})
+	h.render(w, "images", map[string]interface{}{"Title": "Images", "Scans": scans})
+}
+
+func (h *handler) history(w http.ResponseWriter, r *http.Request) {
+	image, scanner := r.URL.Query().Get("image"), r.URL.Query().Get("scanner")
+	if image == "" {
+		http.Error(w, "image is required", http.StatusBadRequest)
+		return
+	}
+	scans, err := h.src.Summaries(r.Context(), query.Filter{Image: image, Scanner: scanner, Limit: 100})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.render(w, "history", map[string]interface{}{"Title": image, "Image": image, "Scanner": scanner, "Scans": scans})
+}
+
+func (h *handler) scan(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	scans, err := h.src.Summaries(r.Context(), query.Filter{ID: id})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(scans) == 0 {
+		http.Error(w, fmt.Sprintf("no scan with id %s", id), http.StatusNotFound)
+		return
+	}
+	vulns, err := h.src.ScanVulns(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.SliceStable(vulns, func(i, j int) bool {
+		if ri, rj := report.SeverityRank(vulns[i]), report.SeverityRank(vulns[j]); ri != rj {
+			return ri < rj
+		}
+		return vulns[i].Vulnerability < vulns[j].Vulnerability
+	})
+	h.render(w, "scan", map[string]interface{}{"Title": scans[0].Image, "Scan": scans[0], "Vulns": vulns})
+}
+
+func (h *handler) vuln(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	results, err := h.src.Search(r.Context(), query.Search{CVE: id})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.render(w, "vuln", map[string]interface{}{"Title": id, "ID": id, "Results": results})
+}
+
+// render executes a page before writing it, so errors aren't sent after
+// part of the page
+func (h *handler) render(w http.ResponseWriter, page string, data interface{}) {
+	var buf bytes.Buffer
+	if err := h.pages[page].Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// link returns the URL of a UI page with query parameters, given as name
+// and value pairs. The values are escaped here, so the URL is trusted.
+func link(page string, pairs ...string) template.URL {
+	values := url.Values{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		values.Set(pairs[i], pairs[i+1])
+	}
+	return template.URL(Prefix + page + "?" + values.Encode())
+}
+
+// advisory returns a link to the public advisory for a vuln ID, if it is a
+// CVE or GitHub advisory
+func advisory(id string) string {
+	switch {
+	case strings.HasPrefix(id, "CVE-"):
+		return "https://nvd.nist.gov/vuln/detail/" + url.PathEscape(id)
+	case strings.HasPrefix(id, "GHSA-"):
+		return "https://github.com/advisories/" + url.PathEscape(id)
+	}
+	return ""
+}
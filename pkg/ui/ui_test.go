@@ -0,0 +1,118 @@
+package ui
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+func testServer(t *testing.T) *httptest.Server {
+	scans := []*types.ImageScanSummary{
+		{ID: "scan-2", Image: "cgr.dev/chainguard/static:latest", Scanner: "grype", Time: "2023-06-23T02:38:46Z", CritCveCount: 1, TotCveCount: 2},
+		{ID: "scan-1", Image: "cgr.dev/chainguard/static:latest", Scanner: "grype", Time: "2023-06-22T02:38:46Z", CritCveCount: 1, TotCveCount: 1},
+		{ID: "scan-3", Image: "example.com/<script>:latest", Scanner: "grype", Time: "2023-06-22T02:38:46Z"},
+	}
+	src := Source{
+		Summaries: func(ctx context.Context, filter query.Filter) ([]*types.ImageScanSummary, error) {
+			matching := []*types.ImageScanSummary{}
+			seen := map[string]bool{}
+			for _, scan := range scans {
+				if (filter.ID != "" && scan.ID != filter.ID) || (filter.Image != "" && scan.Image != filter.Image) || (filter.LatestOnly && seen[scan.Image]) {
+					continue
+				}
+				seen[scan.Image] = true
+				matching = append(matching, scan)
+			}
+			return matching, nil
+		},
+		ScanVulns: func(ctx context.Context, scanID string) ([]*types.Vuln, error) {
+			return []*types.Vuln{
+				{ScanID: scanID, Vulnerability: "CVE-2023-0002", Name: "zlib", Installed: "1.2.13", Severity: "Low"},
+				{ScanID: scanID, Vulnerability: "CVE-2023-0001", Name: "openssl", Installed: "3.1.0", FixedIn: "3.1.1", Severity: "Critical"},
+			}, nil
+		},
+		Search: func(ctx context.Context, search query.Search) ([]*query.SearchResult, error) {
+			return []*query.SearchResult{{Image: "cgr.dev/chainguard/static:latest", Scanner: "grype", ScanID: "scan-2", Package: "openssl", Version: "3.1.0", Vulnerability: search.CVE}}, nil
+		},
+	}
+	srv := httptest.NewServer(Handler(src))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, srv *httptest.Server, path string) (int, string) {
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatalf("expected no error on GET %s, got %v", path, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("expected no error reading %s, got %v", path, err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func TestHandler(t *testing.T) {
+	srv := testServer(t)
+	for _, tc := range []struct {
+		path     string
+		status   int
+		contains []string
+		excludes []string
+	}{{
+		path:     "/ui/",
+		status:   http.StatusOK,
+		contains: []string{`href="/ui/image?image=cgr.dev%2Fchainguard%2Fstatic%3Alatest&amp;scanner=grype"`, `href="/ui/scan?id=scan-2"`, "example.com/&lt;script&gt;:latest"},
+		excludes: []string{"scan-1", "<script>"},
+	}, {
+		path:     "/ui/image?image=cgr.dev%2Fchainguard%2Fstatic%3Alatest&scanner=grype",
+		status:   http.StatusOK,
+		contains: []string{"scan?id=scan-2", "scan?id=scan-1"},
+	}, {
+		path:     "/ui/scan?id=scan-2",
+		status:   http.StatusOK,
+		contains: []string{"<code>scan-2</code>", `href="/ui/vuln?id=CVE-2023-0001"`, `<td class="critical">Critical</td>`},
+	}, {
+		path:     "/ui/vuln?id=CVE-2023-0001",
+		status:   http.StatusOK,
+		contains: []string{"https://nvd.nist.gov/vuln/detail/CVE-2023-0001", "openssl"},
+	}, {
+		path:   "/ui/scan?id=missing",
+		status: http.StatusNotFound,
+	}, {
+		path:   "/ui/image",
+		status: http.StatusBadRequest,
+	}, {
+		path:     "/ui/static/style.css",
+		status:   http.StatusOK,
+		contains: []string{".critical"},
+	}} {
+		status, body := get(t, srv, tc.path)
+		if status != tc.status {
+			t.Errorf("expected status %d for %s, got %d", tc.status, tc.path, status)
+		}
+		for _, s := range tc.contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("expected %s to contain %q, got %s", tc.path, s, body)
+			}
+		}
+		for _, s := range tc.excludes {
+			if strings.Contains(body, s) {
+				t.Errorf("expected %s not to contain %q", tc.path, s)
+			}
+		}
+	}
+
+	// The most severe vulns are listed first
+	_, body := get(t, srv, "/ui/scan?id=scan-2")
+	if strings.Index(body, "CVE-2023-0001") > strings.Index(body, "CVE-2023-0002") {
+		t.Errorf("expected the critical vuln to be listed first, got %s", body)
+	}
+}
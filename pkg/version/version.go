@@ -0,0 +1,133 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Compare compares two package versions, returning -1, 0 or 1. Versions are
+// split into runs of digits and non-digits, with digits compared
+// numerically, which is close enough to most ecosystems' rules (e.g. apk,
+// semver, maven) for searching. A leading "v" is ignored.
+func Compare(a string, b string) int {
+	as, bs := tokens(strings.TrimPrefix(a, "v")), tokens(strings.TrimPrefix(b, "v"))
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := compareToken(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+func tokens(v string) []string {
+	toks := []string{}
+	for _, part := range strings.FieldsFunc(v, func(r rune) bool { return r == '.' || r == '-' || r == '_' || r == '+' || r == ':' || r == '~' }) {
+		start := 0
+		for i := 1; i <= len(part); i++ {
+			if i == len(part) || unicode.IsDigit(rune(part[i])) != unicode.IsDigit(rune(part[i-1])) {
+				toks = append(toks, part[start:i])
+				start = i
+			}
+		}
+	}
+	return toks
+}
+
+func compareToken(a string, b string) int {
+	an, aerr := strconv.ParseUint(a, 10, 64)
+	bn, berr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aerr == nil && berr == nil:
+		if an < bn {
+			return -1
+		} else if an > bn {
+			return 1
+		}
+		return 0
+	case aerr == nil:
+		// Numbers sort after letters, so 1.0.1 > 1.0.rc1
+		return 1
+	case berr == nil:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+// Constraint is a set of comparisons that a version must all satisfy, e.g.
+// ">=2.0.0, <2.17.1"
+type Constraint struct {
+	comparisons []comparison
+}
+
+type comparison struct {
+	op      string
+	version string
+}
+
+var operators = []string{"<=", ">=", "!=", "==", "<", ">", "="}
+
+// ParseConstraint parses a comma-separated list of comparisons using the
+// operators <, <=, >, >=, = (or ==) and !=. A version on its own must match
+// exactly.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		op := "="
+		for _, candidate := range operators {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				part = strings.TrimSpace(strings.TrimPrefix(part, candidate))
+				break
+			}
+		}
+		if op == "==" {
+			op = "="
+		}
+		if part == "" {
+			return nil, fmt.Errorf("invalid version constraint: %s", s)
+		}
+		c.comparisons = append(c.comparisons, comparison{op, part})
+	}
+	if len(c.comparisons) == 0 {
+		return nil, fmt.Errorf("invalid version constraint: %s", s)
+	}
+	return c, nil
+}
+
+// Check reports whether a version satisfies the constraint
+func (c *Constraint) Check(v string) bool {
+	for _, comp := range c.comparisons {
+		result := Compare(v, comp.version)
+		var ok bool
+		switch comp.op {
+		case "<":
+			ok = result < 0
+		case "<=":
+			ok = result <= 0
+		case ">":
+			ok = result > 0
+		case ">=":
+			ok = result >= 0
+		case "=":
+			ok = result == 0
+		case "!=":
+			ok = result != 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
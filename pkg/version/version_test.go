@@ -0,0 +1,42 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"3.0.13", "3.0.14", -1},
+		{"3.0.9", "3.0.14", -1},
+		{"3.1.4-r5", "3.1.4-r10", -1},
+		{"v1.2.3", "1.2.3", 0},
+		{"2.17.1", "2.17", 1},
+		{"1.0.rc1", "1.0.1", -1},
+		{"1.1.1t", "1.1.1u", -1},
+	} {
+		if actual := Compare(tc.a, tc.b); actual != tc.expected {
+			t.Errorf("Compare(%q, %q) is %d, wanted %d", tc.a, tc.b, actual, tc.expected)
+		}
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	c, err := ParseConstraint(">=2.0, <2.17.1")
+	if err != nil {
+		t.Fatalf("expected no error on ParseConstraint(), got %v", err)
+	}
+	for v, expected := range map[string]bool{
+		"1.2.17": false,
+		"2.0":    true,
+		"2.14.1": true,
+		"2.17.1": false,
+	} {
+		if actual := c.Check(v); actual != expected {
+			t.Errorf("Check(%q) is %v, wanted %v", v, actual, expected)
+		}
+	}
+	if _, err := ParseConstraint("<"); err == nil {
+		t.Errorf("expected error on invalid constraint, got nil")
+	}
+}
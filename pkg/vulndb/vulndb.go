@@ -0,0 +1,334 @@
+package vulndb
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestFile is the name of the manifest in a snapshot directory and at
+// the start of a bundle
+const ManifestFile = "rumble-db.json"
+
+// A snapshot directory has a subdirectory per scanner, used as the
+// scanner's database cache directory
+const (
+	grypeDir = "grype"
+	trivyDir = "trivy"
+)
+
+// Manifest describes a snapshot of the scanners' vulnerability databases
+type Manifest struct {
+	// ID identifies the snapshot, as a hash of its files' checksums
+	ID       string   `json:"id"`
+	Created  string   `json:"created"`
+	Scanners []string `json:"scanners"`
+	Files    []File   `json:"files"`
+}
+
+// File is a file of a snapshot, with its path relative to the directory
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// DefaultDir is where snapshots are pulled and imported by default
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "rumble", "db")
+}
+
+// Env returns the environment variables that have the scanners use the
+// databases of a snapshot directory without updating them
+func Env(dir string) []string {
+	return []string{
+		"GRYPE_DB_CACHE_DIR=" + filepath.Join(dir, grypeDir),
+		"GRYPE_DB_AUTO_UPDATE=false",
+		"TRIVY_CACHE_DIR=" + filepath.Join(dir, trivyDir),
+		"TRIVY_SKIP_DB_UPDATE=true",
+		"TRIVY_SKIP_JAVA_DB_UPDATE=true",
+	}
+}
+
+// Pull downloads the current databases of the scanners into dir with the
+// scanners themselves, and writes a new manifest
+func Pull(ctx context.Context, dir string, scanners []string) (*Manifest, error) {
+	for _, scanner := range scanners {
+		var cmds []*exec.Cmd
+		switch scanner {
+		case "grype":
+			cmd := exec.CommandContext(ctx, "grype", "db", "update")
+			cmd.Env = append(os.Environ(), "GRYPE_DB_CACHE_DIR="+filepath.Join(dir, grypeDir))
+			cmds = append(cmds, cmd)
+		case "trivy":
+			for _, only := range []string{"--download-db-only", "--download-java-db-only"} {
+				cmds = append(cmds, exec.CommandContext(ctx, "trivy", "image", only, "--cache-dir", filepath.Join(dir, trivyDir)))
+			}
+		default:
+			return nil, fmt.Errorf("invalid scanner: %s", scanner)
+		}
+		for _, cmd := range cmds {
+			fmt.Printf("Running \"%s\"...\n", strings.Join(cmd.Args, " "))
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return nil, fmt.Errorf("pulling the %s database: %w", scanner, err)
+			}
+		}
+	}
+	return WriteManifest(dir, time.Now())
+}
+
+// WriteManifest checksums the files of dir and writes its manifest
+func WriteManifest(dir string, now time.Time) (*Manifest, error) {
+	m := &Manifest{Created: now.UTC().Format(time.RFC3339)}
+	for _, scanner := range []string{grypeDir, trivyDir} {
+		if info, err := os.Stat(filepath.Join(dir, scanner)); err == nil && info.IsDir() {
+			m.Scanners = append(m.Scanners, scanner)
+		}
+	}
+	if len(m.Scanners) == 0 {
+		return nil, fmt.Errorf("%s has no scanner databases, pull them first", dir)
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == ManifestFile || !d.Type().IsRegular() {
+			return nil
+		}
+		file, err := checksum(path)
+		if err != nil {
+			return err
+		}
+		file.Path = rel
+		m.Files = append(m.Files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.ID = m.id()
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), b, 0644); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// id hashes the paths and checksums of the files, so the same databases
+// always have the same ID
+func (m *Manifest) id() string {
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	h := sha256.New()
+	for _, file := range m.Files {
+		fmt.Fprintf(h, "%s %s\n", file.SHA256, file.Path)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// ReadManifest reads the manifest of a snapshot directory
+func ReadManifest(dir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Join(dir, ManifestFile), err)
+	}
+	return &m, nil
+}
+
+// Verify checks that the files of dir match its manifest
+func Verify(dir string, m *Manifest) error {
+	for _, want := range m.Files {
+		got, err := checksum(filepath.Join(dir, filepath.FromSlash(want.Path)))
+		if err != nil {
+			return err
+		}
+		if got.SHA256 != want.SHA256 || got.Size != want.Size {
+			return fmt.Errorf("%s doesn't match the manifest of snapshot %s (sha256 %s, expected %s)", want.Path, m.ID, got.SHA256, want.SHA256)
+		}
+	}
+	return nil
+}
+
+// Export verifies the snapshot in dir and writes it to w as a gzipped tar
+// bundle, starting with the manifest
+func Export(dir string, w io.Writer) (*Manifest, error) {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := Verify(dir, m); err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, path := range append([]string{ManifestFile}, paths(m)...) {
+		if err := addFile(tw, dir, path); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return m, gz.Close()
+}
+
+// Import reads a bundle written by Export into dir, replacing the snapshot
+// there once every file is checked against the bundle's manifest
+func Import(r io.Reader, dir string) (*Manifest, error) {
+	parent := filepath.Dir(filepath.Clean(dir))
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp(parent, ".rumble-db-import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	extracted := []string{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("bundle has %s, which isn't a regular file", hdr.Name)
+		}
+		path := filepath.FromSlash(hdr.Name)
+		if !local(path) {
+			return nil, fmt.Errorf("bundle has %s, which is outside the snapshot", hdr.Name)
+		}
+		if err := extract(tr, filepath.Join(tmp, path)); err != nil {
+			return nil, err
+		}
+		extracted = append(extracted, filepath.ToSlash(filepath.Clean(path)))
+	}
+
+	m, err := ReadManifest(tmp)
+	if err != nil {
+		return nil, fmt.Errorf("bundle has no manifest: %w", err)
+	}
+	if err := Verify(tmp, m); err != nil {
+		return nil, err
+	}
+	// The ID names the snapshot in scan summaries, so it has to be the one
+	// of the files actually imported
+	if id := m.id(); id != m.ID {
+		return nil, fmt.Errorf("bundle manifest has ID %s, but its files make it %s", m.ID, id)
+	}
+	listed := map[string]bool{ManifestFile: true}
+	for _, file := range m.Files {
+		listed[file.Path] = true
+	}
+	for _, path := range extracted {
+		if !listed[path] {
+			return nil, fmt.Errorf("bundle has %s, which isn't in its manifest", path)
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// local reports whether a path stays within the directory it's relative to
+func local(path string) bool {
+	clean := filepath.Clean(path)
+	return clean != "." && !filepath.IsAbs(clean) && clean != ".." && !strings.HasPrefix(clean, ".."+string(filepath.Separator))
+}
+
+func paths(m *Manifest) []string {
+	paths := make([]string, len(m.Files))
+	for i, file := range m.Files {
+		paths[i] = file.Path
+	}
+	return paths
+}
+
+func addFile(tw *tar.Writer, dir string, path string) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(path)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: path, Mode: 0644, Size: info.Size(), ModTime: info.ModTime(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func extract(r io.Reader, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func checksum(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
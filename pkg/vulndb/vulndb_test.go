@@ -0,0 +1,101 @@
+package vulndb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeSnapshot(t *testing.T, dir string) {
+	for path, content := range map[string]string{
+		"grype/5/vulnerability.db":    "grype database",
+		"grype/5/metadata.json":       `{"built": "2024-06-01T00:00:00Z"}`,
+		"trivy/db/trivy.db":           "trivy database",
+		"trivy/java-db/trivy-java.db": "trivy java database",
+	} {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("expected no error creating %s, got %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("expected no error writing %s, got %v", path, err)
+		}
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	writeSnapshot(t, dir)
+	m, err := WriteManifest(dir, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("expected no error on WriteManifest(), got %v", err)
+	}
+	if len(m.Files) != 4 || len(m.ID) != 16 || strings.Join(m.Scanners, ",") != "grype,trivy" {
+		t.Errorf("expected a manifest of 4 files for both scanners, got %+v", m)
+	}
+
+	var bundle bytes.Buffer
+	if _, err := Export(dir, &bundle); err != nil {
+		t.Fatalf("expected no error on Export(), got %v", err)
+	}
+	offline := filepath.Join(t.TempDir(), "offline", "db")
+	imported, err := Import(bytes.NewReader(bundle.Bytes()), offline)
+	if err != nil {
+		t.Fatalf("expected no error on Import(), got %v", err)
+	}
+	if imported.ID != m.ID {
+		t.Errorf("expected snapshot %s to be imported, got %s", m.ID, imported.ID)
+	}
+	if b, err := os.ReadFile(filepath.Join(offline, "trivy", "db", "trivy.db")); err != nil || string(b) != "trivy database" {
+		t.Errorf("expected the trivy database to be imported, got %q, %v", b, err)
+	}
+
+	// A snapshot that changed since its manifest was written isn't exported
+	if err := os.WriteFile(filepath.Join(dir, "grype", "5", "vulnerability.db"), []byte("tampered"), 0644); err != nil {
+		t.Fatalf("expected no error writing the database, got %v", err)
+	}
+	if _, err := Export(dir, &bytes.Buffer{}); err == nil {
+		t.Errorf("expected error on Export() of a changed snapshot, got nil")
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	bundle := func(files map[string]string) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		for name, content := range files {
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+			tw.Write([]byte(content))
+		}
+		tw.Close()
+		gz.Close()
+		return buf.Bytes()
+	}
+	manifest := `{"id": "0e89da920b8eff66", "files": [{"path": "grype/vulnerability.db", "size": 5, "sha256": "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"}]}`
+	for name, files := range map[string]map[string]string{
+		"no manifest":       {"grype/vulnerability.db": "hello"},
+		"id mismatch":       {ManifestFile: strings.Replace(manifest, "0e89da920b8eff66", "0123456789abcdef", 1), "grype/vulnerability.db": "hello"},
+		"checksum mismatch": {ManifestFile: manifest, "grype/vulnerability.db": "HELLO"},
+		"unlisted file":     {ManifestFile: manifest, "grype/vulnerability.db": "hello", "grype/extra": "x"},
+		"path traversal":    {ManifestFile: manifest, "grype/vulnerability.db": "hello", "../escape": "x"},
+	} {
+		dir := filepath.Join(t.TempDir(), "db")
+		if _, err := Import(bytes.NewReader(bundle(files)), dir); err == nil {
+			t.Errorf("expected error on Import() with %s, got nil", name)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("expected nothing to be imported with %s", name)
+		}
+	}
+
+	// The valid bundle imports
+	if _, err := Import(bytes.NewReader(bundle(map[string]string{ManifestFile: manifest, "grype/vulnerability.db": "hello"})), filepath.Join(t.TempDir(), "db")); err != nil {
+		t.Errorf("expected no error on Import(), got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const progressJSON = "json"
+
+// progress writes lifecycle events as NDJSON for orchestrators to follow
+// (with --progress json). A nil progress writes nothing.
+type progress struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	image   string
+	scanner string
+	start   time.Time
+	phases  map[string]time.Time
+}
+
+// progressEvent is one line of --progress json output. Events for a phase
+// come in pairs, e.g. "scan_started" and "scan_finished" (or "scan_failed"),
+// with the finished one saying how long the phase took.
+type progressEvent struct {
+	Event           string  `json:"event"`
+	Time            string  `json:"time"`
+	Image           string  `json:"image"`
+	Scanner         string  `json:"scanner,omitempty"`
+	ElapsedSeconds  float64 `json:"elapsed_seconds"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	Error           string  `json:"error,omitempty"`
+
+	// Set on scan_finished and upload_finished
+	ScanID      string `json:"scan_id,omitempty"`
+	TotCveCount *int   `json:"tot_cve_count,omitempty"`
+	CacheHit    bool   `json:"cache_hit,omitempty"`
+	Sink        string `json:"sink,omitempty"`
+}
+
+func newProgress(format string, w io.Writer, image string, scanner string) (*progress, error) {
+	switch format {
+	case "":
+		return nil, nil
+	case progressJSON:
+		return &progress{enc: json.NewEncoder(w), image: image, scanner: scanner, start: time.Now(), phases: map[string]time.Time{}}, nil
+	}
+	return nil, fmt.Errorf("invalid progress format: %s", format)
+}
+
+// started emits the event starting a phase (e.g. "scan_started" for "scan")
+func (p *progress) started(phase string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.phases[phase] = time.Now()
+	p.mu.Unlock()
+	p.emit(progressEvent{Event: phase + "_started"})
+}
+
+// finished emits the event finishing a phase, which is "<phase>_failed"
+// with the error if err is set
+func (p *progress) finished(phase string, err error, event progressEvent) {
+	if p == nil {
+		return
+	}
+	event.Event = phase + "_finished"
+	if err != nil {
+		event.Event, event.Error = phase+"_failed", err.Error()
+	}
+	p.mu.Lock()
+	if start, ok := p.phases[phase]; ok {
+		event.DurationSeconds = time.Since(start).Seconds()
+	}
+	p.mu.Unlock()
+	p.emit(event)
+}
+
+func (p *progress) emit(event progressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	event.Time = now.UTC().Format(time.RFC3339Nano)
+	event.Image, event.Scanner = p.image, p.scanner
+	event.ElapsedSeconds = now.Sub(p.start).Seconds()
+	// Each event is a single write, so those of concurrent scans don't
+	// interleave
+	p.enc.Encode(event)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+)
+
+// runPrune implements "rumble prune", which deletes (and optionally archives)
+// old summary and vuln rows
+func runPrune(args []string) {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	tables := addTableFlags(fs)
+	olderThan := fs.String("older-than", "", "Prune rows older than this, as an age (e.g. \"180d\") or a date (e.g. \"2006-01-02\")")
+	keepLatest := fs.Bool("keep-latest-per-image", false, "Never prune the latest scan of each image (per scanner)")
+	archive := fs.String("archive", "", "If set, export pruned rows to this Cloud Storage prefix (e.g. gs://bucket/path) before deleting them")
+	archiveFormat := fs.String("archive-format", "parquet", "Format of archived rows, (\"parquet\", \"csv\", \"json\" or \"avro\")")
+	dryRun := fs.Bool("dry-run", false, "Only report how many rows would be pruned")
+	fs.Parse(args)
+	tables.check(true)
+	if *olderThan == "" {
+		panic(fmt.Errorf("--older-than is required"))
+	}
+
+	cutoff, err := query.ParseSince(*olderThan, time.Now())
+	if err != nil {
+		panic(err)
+	}
+	opts := query.PruneOptions{
+		SummaryTable:  tables.summaryTable(),
+		VulnsTable:    tables.vulnsTableName(),
+		OlderThan:     cutoff,
+		KeepLatest:    *keepLatest,
+		ArchivePrefix: *archive,
+		ArchiveFormat: *archiveFormat,
+	}
+
+	ctx := context.Background()
+	client, err := tables.client(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if *dryRun {
+		result, err := query.PruneCount(ctx, client, opts)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Would prune %d row(s) from table \"%s\" and %d row(s) from table \"%s\" older than %s\n",
+			result.Summaries, opts.SummaryTable, result.Vulns, opts.VulnsTable, cutoff.UTC().Format(time.RFC3339))
+		return
+	}
+	result, err := query.Prune(ctx, client, opts)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Pruned %d row(s) from table \"%s\" and %d row(s) from table \"%s\" older than %s\n",
+		result.Summaries, opts.SummaryTable, result.Vulns, opts.VulnsTable, cutoff.UTC().Format(time.RFC3339))
+}
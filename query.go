@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/mirror"
+	"github.com/chainguard-dev/rumble/pkg/postgres"
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/types"
+	"google.golang.org/api/iterator"
+)
+
+// tableFlags are the flags shared by subcommands that read from BigQuery
+type tableFlags struct {
+	project    *string
+	dataset    *string
+	table      *string
+	vulnsTable *string
+	local      *string
+	postgres   *string
+
+	maxBytesBilled *int64
+
+	credentials *credentialFlags
+
+	// The local mirror or PostgreSQL store, opened on first use and reused
+	// by every later read (e.g. by each request of "rumble serve")
+	mu    sync.Mutex
+	db    *sql.DB
+	store *postgres.Store
+}
+
+func addTableFlags(fs *flag.FlagSet) *tableFlags {
+	return &tableFlags{
+		project:    fs.String("project", GcloudProject, "Google Cloud project containing the dataset (defaults to $GCLOUD_PROJECT)"),
+		dataset:    fs.String("dataset", GcloudDataset, "BigQuery dataset (defaults to $GCLOUD_DATASET)"),
+		table:      fs.String("table", GcloudTable, "BigQuery summary table (defaults to $GCLOUD_TABLE)"),
+		vulnsTable: fs.String("vulns-table", GcloudTableVulns, "BigQuery vulns table (defaults to $GCLOUD_TABLE_VULNS)"),
+		local:      fs.String("local", "", "If set, read from this local mirror database (see \"rumble sync\") instead of BigQuery"),
+		postgres:   fs.String("postgres", os.Getenv("RUMBLE_POSTGRES"), "If set, read from the PostgreSQL database with this connection string (see --sink=postgres) instead of BigQuery (defaults to $RUMBLE_POSTGRES)"),
+
+		maxBytesBilled: fs.Int64("maximum-bytes-billed", 0, "If set, fail BigQuery queries that would bill more than this many bytes instead of running them"),
+
+		credentials: addCredentialFlags(fs),
+	}
+}
+
+// check exits with an error if the flags needed to reach BigQuery are
+// missing. Nothing is needed when reading from a local mirror or PostgreSQL.
+func (t *tableFlags) check(vulns bool) {
+	if *t.local != "" || *t.postgres != "" {
+		return
+	}
+	settings := map[string]string{
+		"--project ($GCLOUD_PROJECT)": *t.project,
+		"--dataset ($GCLOUD_DATASET)": *t.dataset,
+		"--table ($GCLOUD_TABLE)":     *t.table,
+	}
+	if vulns {
+		settings["--vulns-table ($GCLOUD_TABLE_VULNS)"] = *t.vulnsTable
+	}
+	if err := checkTableConfig("BigQuery", settings); err != nil {
+		panic(err)
+	}
+}
+
+// summaryTable returns the fully qualified name of the summary table
+func (t *tableFlags) summaryTable() string {
+	return fmt.Sprintf("%s.%s.%s", *t.project, *t.dataset, *t.table)
+}
+
+// vulnsTableName returns the fully qualified name of the vulns table
+func (t *tableFlags) vulnsTableName() string {
+	return fmt.Sprintf("%s.%s.%s", *t.project, *t.dataset, *t.vulnsTable)
+}
+
+// localDB returns the local mirror, opening it on first use
+func (t *tableFlags) localDB() (*sql.DB, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.db == nil {
+		db, err := mirror.Open(*t.local)
+		if err != nil {
+			return nil, err
+		}
+		t.db = db
+	}
+	return t.db, nil
+}
+
+// postgresStore returns the PostgreSQL store, opening it (and so migrating
+// its schema) on first use
+func (t *tableFlags) postgresStore(ctx context.Context) (*postgres.Store, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.store == nil {
+		store, err := postgres.Open(ctx, *t.postgres)
+		if err != nil {
+			return nil, err
+		}
+		t.store = store
+	}
+	return t.store, nil
+}
+
+// close closes the local mirror or PostgreSQL store, if either was opened
+func (t *tableFlags) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.db != nil {
+		t.db.Close()
+		t.db = nil
+	}
+	if t.store != nil {
+		t.store.Close()
+		t.store = nil
+	}
+}
+
+// summaries runs a summary query against the local mirror, PostgreSQL or BigQuery
+func (t *tableFlags) summaries(ctx context.Context, filter query.Filter) ([]*types.ImageScanSummary, error) {
+	if *t.local != "" {
+		db, err := t.localDB()
+		if err != nil {
+			return nil, err
+		}
+		return mirror.Summaries(ctx, db, filter)
+	}
+	if *t.postgres != "" {
+		store, err := t.postgresStore(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return store.Summaries(ctx, filter)
+	}
+	client, err := t.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return query.Summaries(ctx, client, t.summaryTable(), filter)
+}
+
+// scanVulns reads the vulns of a single scan from the local mirror,
+// PostgreSQL or BigQuery
+func (t *tableFlags) scanVulns(ctx context.Context, scanID string) ([]*types.Vuln, error) {
+	if *t.local != "" {
+		db, err := t.localDB()
+		if err != nil {
+			return nil, err
+		}
+		return mirror.ScanVulns(ctx, db, scanID)
+	}
+	if *t.postgres != "" {
+		store, err := t.postgresStore(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return store.ScanVulns(ctx, scanID)
+	}
+	if *t.vulnsTable == "" {
+		return nil, fmt.Errorf("--vulns-table ($GCLOUD_TABLE_VULNS) is required to read vulns")
+	}
+	client, err := t.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return query.ScanVulns(ctx, client, t.summaryTable(), t.vulnsTableName(), scanID)
+}
+
+func (t *tableFlags) client(ctx context.Context) (*query.Client, error) {
+	client, err := t.credentials.bigqueryClient(ctx, *t.project)
+	if err != nil {
+		return nil, err
+	}
+	return &query.Client{Client: client, MaxBytesBilled: *t.maxBytesBilled}, nil
+}
+
+// runQuery implements "rumble query", which prints scan summaries from BigQuery
+func runQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	tables := addTableFlags(fs)
+	image := fs.String("image", "", "Only show scans of this image")
+	scanner := fs.String("scanner", "", "Only show scans by this scanner, (\"trivy\" or \"grype\")")
+	since := fs.String("since", "", "Only show scans since this time, as an age (e.g. \"30d\", \"12h\") or a date (e.g. \"2006-01-02\")")
+	severity := fs.String("severity", "", "Only show scans with at least one CVE at or above this severity")
+	latestOnly := fs.Bool("latest-only", false, "Only show the latest scan for each image and scanner")
+	output := fs.String("output", outputTable, "Output format, (\"table\", \"wide\", \"json\" or \"csv\")")
+	matches := fs.Bool("matches", false, "Include the parsed grype matches of each scan (requires --output json)")
+	pageSize := fs.Int("page-size", 0, "If set, only show this many scans, and print a token for fetching the next page")
+	pageToken := fs.String("page-token", "", "Token printed by a previous --page-size query, to fetch the next page")
+	fs.Parse(args)
+	tables.check(false)
+	defer tables.close()
+	if err := checkOutputFormat(*output); err != nil {
+		panic(err)
+	}
+	if *matches && *output != outputJSON {
+		panic(fmt.Errorf("--matches requires --output json"))
+	}
+
+	sinceTime, err := query.ParseSince(*since, time.Now())
+	if err != nil {
+		panic(err)
+	}
+	filter := query.Filter{
+		Image:          *image,
+		Scanner:        *scanner,
+		Since:          sinceTime,
+		Severity:       *severity,
+		LatestOnly:     *latestOnly,
+		IncludeMatches: *matches,
+	}
+
+	ctx := context.Background()
+	if *tables.local != "" || *tables.postgres != "" {
+		if *pageSize > 0 || *pageToken != "" {
+			panic(fmt.Errorf("--page-size and --page-token are not supported with --local or --postgres"))
+		}
+		summaries, err := tables.summaries(ctx, filter)
+		if err != nil {
+			panic(err)
+		}
+		if err := printSummaries(os.Stdout, *output, summaries); err != nil {
+			panic(err)
+		}
+		return
+	}
+	client, err := tables.client(ctx)
+	if err != nil {
+		panic(err)
+	}
+	it, err := query.Query(ctx, client, tables.summaryTable(), filter, query.Page{Size: *pageSize, Token: *pageToken})
+	if err != nil {
+		panic(err)
+	}
+	results := []*query.Result{}
+	summaries := []*types.ImageScanSummary{}
+	for {
+		result, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			panic(err)
+		}
+		results = append(results, result)
+		summaries = append(summaries, result.Summary)
+	}
+
+	if *matches {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		err = enc.Encode(results)
+	} else {
+		err = printSummaries(os.Stdout, *output, summaries)
+	}
+	if err != nil {
+		panic(err)
+	}
+	if token := it.NextPageToken(); token != "" {
+		fmt.Fprintf(os.Stderr, "Next page: --page-token=%s\n", token)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+)
+
+func TestTableFlagsReuse(t *testing.T) {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	tables := addTableFlags(fs)
+	if err := fs.Parse([]string{"--local", filepath.Join(t.TempDir(), "rumble.db")}); err != nil {
+		t.Fatalf("expected no error on Parse(), got %v", err)
+	}
+	defer tables.close()
+
+	ctx := context.Background()
+	if _, err := tables.summaries(ctx, query.Filter{}); err != nil {
+		t.Fatalf("expected no error on summaries(), got %v", err)
+	}
+	db := tables.db
+	if _, err := tables.scanVulns(ctx, "1"); err != nil {
+		t.Fatalf("expected no error on scanVulns(), got %v", err)
+	}
+	if db == nil || tables.db != db {
+		t.Errorf("expected the local mirror to be opened once and reused")
+	}
+	tables.close()
+	if tables.db != nil {
+		t.Errorf("expected close() to close the local mirror")
+	}
+}
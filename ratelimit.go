@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/oci"
+	"github.com/chainguard-dev/rumble/pkg/scanner"
+)
+
+// rateLimitFlags control how registry requests and scanner pulls are
+// limited per registry, and retried when throttled
+type rateLimitFlags struct {
+	concurrency *string
+	retries     *int
+	backoff     *time.Duration
+}
+
+func addRateLimitFlags(fs *flag.FlagSet) *rateLimitFlags {
+	return &rateLimitFlags{
+		concurrency: fs.String("registry-concurrency", os.Getenv("RUMBLE_REGISTRY_CONCURRENCY"), "Comma-separated limits on concurrent pulls per registry, with \"*\" for any other registry, e.g. \"docker.io=2,*=8\" (defaults to $RUMBLE_REGISTRY_CONCURRENCY)"),
+		retries:     fs.Int("registry-retries", 5, "How many times to retry registry requests and scanner pulls that are rate limited (429) or hit a server error"),
+		backoff:     fs.Duration("registry-backoff", 2*time.Second, "How long to wait before the first registry retry, doubling after each one"),
+	}
+}
+
+// configure makes registry requests go through the limits and retries,
+// returning them for scanner pulls too
+func (f *rateLimitFlags) configure() (*oci.Limiter, oci.Backoff, error) {
+	limiter, err := oci.ParseLimiter(*f.concurrency)
+	if err != nil {
+		return nil, oci.Backoff{}, err
+	}
+	backoff := oci.Backoff{Retries: *f.retries, Base: *f.backoff}
+	oci.Transport = oci.NewTransport(oci.Transport, limiter, backoff)
+	return limiter, backoff, nil
+}
+
+// runScanner runs a scanner command, retrying it with backoff when its
+// output suggests the image pull was throttled. Scanners pull images
+// themselves, so their requests can't go through oci.Transport. The
+// output of every attempt is kept in output rather than written to the
+// console, where several scans would interleave. It returns the command
+// that was run last.
+func runScanner(ctx context.Context, image string, newCmd func() *exec.Cmd, output *scanner.Output, opts *summaryOptions) (*exec.Cmd, error) {
+	if opts.sourceType == sourceTypeImage && localImagePath(image) == "" {
+		release, err := opts.limiter.AcquireImage(ctx, image)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+	for retry := 1; ; retry++ {
+		attempt := &scanner.Output{}
+		cmd := newCmd()
+		cmd.Stdout = io.MultiWriter(output, attempt)
+		cmd.Stderr = cmd.Stdout
+		err := cmd.Run()
+		if err == nil || retry > opts.pullBackoff.Retries || !oci.Throttled(attempt.String()) {
+			return cmd, err
+		}
+		delay := opts.pullBackoff.Delay(retry, 0)
+		fmt.Printf("WARNING: pulling %s looks throttled, retrying the scan in %s (%d of %d)\n", image, delay, retry, opts.pullBackoff.Retries)
+		select {
+		case <-ctx.Done():
+			return cmd, err
+		case <-time.After(delay):
+		}
+	}
+}
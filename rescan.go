@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"github.com/chainguard-dev/rumble/pkg/osv"
+	"github.com/chainguard-dev/rumble/pkg/priority"
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// runRescan implements "rumble rescan", which rescans only the images that
+// may be affected by newly published CVEs, going by the packages found in
+// their latest scans. Flags after "--" are passed on to each scan.
+func runRescan(args []string) {
+	fs := flag.NewFlagSet("rescan", flag.ExitOnError)
+	tables := addTableFlags(fs)
+	packagesTable := fs.String("packages-table", GcloudTablePackages, "BigQuery packages table to find candidate images in, instead of only vulnerable packages in the vulns table (defaults to $GCLOUD_TABLE_PACKAGES)")
+	cves := fs.String("cve", "", "Comma-separated CVEs (or other OSV IDs) to rescan for")
+	cveFile := fs.String("cve-file", "", "File listing CVEs to rescan for, one per line (\"-\" for stdin), e.g. a feed of newly published CVEs")
+	since := fs.String("since", "30d", "Only consider images scanned since this time, as an age (e.g. \"30d\", \"12h\") or a date (e.g. \"2006-01-02\")")
+	osvURL := fs.String("osv-url", osv.DefaultBaseURL, "Base URL of the OSV API, used to look up the packages affected by each CVE")
+	dryRun := fs.Bool("dry-run", false, "If enabled, only list the images that would be rescanned")
+	priorityPolicy := fs.String("priority", priority.FIFO, "Which images to rescan first, (\"fifo\" by name, \"recent\" for recently built images first, \"severity\" for images with the most critical CVEs first, or \"balanced\" for both)")
+	configFile := fs.String("config", os.Getenv("RUMBLE_CONFIG"), "Path to a JSON config file, with the images to skip (defaults to $RUMBLE_CONFIG)")
+	imageFilter := addImageFilterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: rumble rescan [flags] [-- scan flags]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	tables.check(true)
+	defer tables.close()
+
+	ids, err := rescanCVEs(*cves, *cveFile)
+	if err != nil {
+		panic(err)
+	}
+	if len(ids) == 0 {
+		panic(fmt.Errorf("--cve or --cve-file is required"))
+	}
+	sinceTime, err := query.ParseSince(*since, time.Now())
+	if err != nil {
+		panic(err)
+	}
+	policy, err := priority.Parse(*priorityPolicy)
+	if err != nil {
+		panic(err)
+	}
+	var cfg *config.Config
+	if *configFile != "" {
+		if cfg, err = config.Load(*configFile); err != nil {
+			panic(err)
+		}
+	}
+	filter := imageFilter.filter(cfg)
+
+	// Find the images whose latest scan has any of the affected packages
+	ctx := context.Background()
+	client := osv.NewClient()
+	client.BaseURL = *osvURL
+	candidates := map[string]*query.SearchResult{}
+	skipped := map[string]bool{}
+	for _, id := range ids {
+		// One ID missing from OSV (e.g. a CVE it hasn't imported yet)
+		// shouldn't hold up rescans for the rest
+		packages, err := client.Packages(id)
+		if err != nil {
+			fmt.Printf("WARNING: skipping %s, as its affected packages could not be looked up: %s\n", id, err.Error())
+			continue
+		}
+		fmt.Printf("%s affects package(s): %s\n", id, strings.Join(packages, ", "))
+		for _, pkg := range packages {
+			search := query.Search{
+				Package: pkg,
+				Since:   sinceTime,
+			}
+			results, err := searchImages(ctx, tables, *packagesTable, search)
+			if err != nil {
+				panic(err)
+			}
+			for _, result := range results {
+				key := result.Image + " " + result.Scanner
+				if _, ok := candidates[key]; ok || skipped[key] {
+					continue
+				}
+				if reason := filter.Skip(result.Image); reason != "" {
+					fmt.Printf("Skipping %s, as %s\n", result.Image, reason)
+					skipped[key] = true
+					continue
+				}
+				candidates[key] = result
+			}
+		}
+	}
+	keys := []string{}
+	for key := range candidates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Printf("Found %d image(s) to rescan\n", len(keys))
+	if *priorityPolicy != priority.FIFO {
+		if keys, err = prioritize(ctx, tables, keys, candidates, policy, sinceTime); err != nil {
+			panic(err)
+		}
+	}
+
+	// Each image is rescanned by running rumble itself, so the scan flags
+	// work just as they do for a single image
+	self, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+	failed := 0
+	for _, key := range keys {
+		candidate := candidates[key]
+		scanArgs := append([]string{"--image", candidate.Image, "--scanner", candidate.Scanner}, fs.Args()...)
+		fmt.Printf("Rescanning %s with %s (last scanned %s, digest %s)\n", candidate.Image, candidate.Scanner, candidate.Time, candidate.Digest)
+		if *dryRun {
+			continue
+		}
+		cmd := exec.Command(self, scanArgs...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Printf("WARNING: rescan of %s failed: %s\n", candidate.Image, err.Error())
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d rescan(s) failed\n", failed, len(keys))
+		os.Exit(1)
+	}
+}
+
+// prioritize orders the candidates (keyed by image and scanner) by the
+// policy, going by their latest scans and, if the policy needs it, when
+// each image was built
+func prioritize(ctx context.Context, tables *tableFlags, keys []string, candidates map[string]*query.SearchResult, policy priority.Policy, since time.Time) ([]string, error) {
+	latest, err := tables.summaries(ctx, query.Filter{LatestOnly: true, Since: since})
+	if err != nil {
+		return nil, err
+	}
+	summaries := map[string]*types.ImageScanSummary{}
+	for _, summary := range latest {
+		summaries[summary.Image+" "+summary.Scanner] = summary
+	}
+	ranked := make([]*priority.Candidate, len(keys))
+	byCandidate := map[*priority.Candidate]string{}
+	for i, key := range keys {
+		c := &priority.Candidate{Image: candidates[key].Image, Scanner: candidates[key].Scanner, Latest: summaries[key]}
+		if policy.NeedsPublished() {
+			if c.Published, err = imagePublished(ctx, c.Image); err != nil {
+				fmt.Printf("WARNING: could not look up when %s was built: %s\n", c.Image, err.Error())
+			}
+		}
+		ranked[i] = c
+		byCandidate[c] = key
+	}
+	priority.Sort(ranked, policy, time.Now())
+	for i, c := range ranked {
+		keys[i] = byCandidate[c]
+	}
+	return keys, nil
+}
+
+// rescanCVEs returns the distinct CVEs given with --cve and --cve-file
+func rescanCVEs(cves string, cveFile string) ([]string, error) {
+	ids := []string{}
+	for _, id := range strings.Split(cves, ",") {
+		ids = append(ids, strings.TrimSpace(id))
+	}
+	if cveFile != "" {
+		f := os.Stdin
+		if cveFile != "-" {
+			var err error
+			f, err = os.Open(cveFile)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			ids = append(ids, strings.TrimSpace(scanner.Text()))
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	seen := map[string]bool{}
+	distinct := []string{}
+	for _, id := range ids {
+		if id == "" || strings.HasPrefix(id, "#") || seen[id] {
+			continue
+		}
+		seen[id] = true
+		distinct = append(distinct, id)
+	}
+	return distinct, nil
+}
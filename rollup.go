@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"github.com/chainguard-dev/rumble/pkg/query"
+)
+
+// runRollup implements "rumble rollup", which recomputes the recent days of
+// the daily rollup tables, meant to run on a schedule
+func runRollup(args []string) {
+	fs := flag.NewFlagSet("rollup", flag.ExitOnError)
+	tables := addTableFlags(fs)
+	prefix := fs.String("prefix", "", "Prefix of the rollup tables, created in the summary table's dataset (defaults to the --table name with a \"_rollup_\" suffix)")
+	since := fs.String("since", "3d", "Recompute the days since this time (in UTC), as an age (e.g. \"3d\") or a date (e.g. \"2006-01-02\"), so scans recorded late are counted")
+	configFile := fs.String("config", os.Getenv("RUMBLE_CONFIG"), "Path to a JSON config file with the teams owning images (defaults to $RUMBLE_CONFIG)")
+	dryRun := fs.Bool("dry-run", false, "Only print the statements that would be run, e.g. to set them up as scheduled queries instead")
+	fs.Parse(args)
+	tables.check(false)
+	if *tables.local != "" || *tables.postgres != "" {
+		panic(fmt.Errorf("--local and --postgres are not supported by rollup, which maintains BigQuery tables"))
+	}
+	if *prefix == "" {
+		*prefix = *tables.table + "_rollup_"
+	}
+	sinceTime, err := query.ParseSince(*since, time.Now())
+	if err != nil {
+		panic(err)
+	}
+	if sinceTime.IsZero() {
+		panic(fmt.Errorf("--since is required"))
+	}
+	teams := []config.Team{}
+	if *configFile != "" {
+		cfg, err := config.Load(*configFile)
+		if err != nil {
+			panic(err)
+		}
+		teams = cfg.Teams
+	}
+
+	rollups := query.Rollups(*prefix)
+	if *dryRun {
+		for _, rollup := range rollups {
+			create, merge, params := query.RollupSQL(tables.summaryTable(), rollup, teams, sinceTime, false)
+			fmt.Printf("-- %s (@since = %q)\n%s;\n%s;\n", rollup.Table, params[0].Value, create, merge)
+		}
+		return
+	}
+
+	ctx := context.Background()
+	client, err := tables.client(ctx)
+	if err != nil {
+		panic(err)
+	}
+	for _, rollup := range rollups {
+		changed, err := query.UpdateRollup(ctx, client, tables.summaryTable(), rollup, teams, sinceTime)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Updated %d row(s) of table \"%s\" since %s\n", changed, rollup.Table, sinceTime.UTC().Format("2006-01-02"))
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/sink"
+)
+
+// Outcomes of a run and of each of its scans
+const (
+	outcomeSucceeded = "succeeded"
+	outcomeFailed    = "failed"
+)
+
+// runManifest records what a run did, for orchestrators that need more than
+// its exit status. It is written to --run-manifest when the run finishes,
+// whether or not it succeeded.
+type runManifest struct {
+	path        string
+	metricsPath string
+	start       time.Time
+	usage       bigqueryUsage
+
+	StartedAt       string          `json:"started_at"`
+	FinishedAt      string          `json:"finished_at"`
+	DurationSeconds float64         `json:"duration_seconds"`
+	Outcome         string          `json:"outcome"`
+	Error           string          `json:"error,omitempty"`
+	Scans           []*manifestScan `json:"scans"`
+
+	// BigQuery is what the run used of BigQuery, including the scans it
+	// ran with several scanners
+	BigQuery *bigqueryUsage `json:"bigquery,omitempty"`
+}
+
+// bigqueryUsage is what BigQuery bills a run for: the rows streamed into
+// tables (each billed as at least 1 KB) and the bytes its queries billed
+type bigqueryUsage struct {
+	RowsInserted     int64 `json:"rows_inserted"`
+	BytesInserted    int64 `json:"bytes_inserted"`
+	Queries          int64 `json:"queries"`
+	QueryBytesBilled int64 `json:"query_bytes_billed"`
+}
+
+func (u *bigqueryUsage) add(other bigqueryUsage) {
+	u.RowsInserted += other.RowsInserted
+	u.BytesInserted += other.BytesInserted
+	u.Queries += other.Queries
+	u.QueryBytesBilled += other.QueryBytesBilled
+}
+
+// manifestScan is a scan of an image by one scanner. With several scanners,
+// each scan's own manifest is merged into the run's.
+type manifestScan struct {
+	start time.Time
+
+	Image           string  `json:"image"`
+	Scanner         string  `json:"scanner"`
+	Digest          string  `json:"digest,omitempty"`
+	ScanID          string  `json:"scan_id,omitempty"`
+	Outcome         string  `json:"outcome"`
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	CacheHit        bool    `json:"cache_hit,omitempty"`
+	TotCveCount     *int    `json:"tot_cve_count,omitempty"`
+
+	// Diagnostics is the end of the scanner's output, if the scan failed
+	Diagnostics string `json:"diagnostics,omitempty"`
+
+	// Sink is where the scan was recorded, and RowsInserted how many rows
+	// of each kind ("summary", "vulns", "findings", "licenses" and
+	// "packages") went in
+	Sink         string         `json:"sink,omitempty"`
+	RowsInserted map[string]int `json:"rows_inserted,omitempty"`
+
+	Attestations []*manifestAttestation `json:"attestations,omitempty"`
+}
+
+// manifestAttestation is an attestation created for a scan
+type manifestAttestation struct {
+	PredicateType string `json:"predicate_type"`
+	RekorLogIndex int64  `json:"rekor_log_index,omitempty"`
+	Verification  string `json:"verification,omitempty"`
+
+	// File is the unsigned attestation written with --attestation-output
+	File string `json:"file,omitempty"`
+}
+
+// newRunManifest starts the manifest of a run, which is only written if
+// path is set
+func newRunManifest(path string) *runManifest {
+	return &runManifest{path: path, start: time.Now(), Scans: []*manifestScan{}}
+}
+
+// add starts the record of a scan
+func (m *runManifest) add(image string, scanner string) *manifestScan {
+	scan := &manifestScan{start: time.Now(), Image: image, Scanner: scanner}
+	m.Scans = append(m.Scans, scan)
+	return scan
+}
+
+// merge adds the scans in the manifest written by a scan run on its own
+// (one of several scanners), or a failed scan if it didn't write one
+func (m *runManifest) merge(path string, scanner string, scanErr error) {
+	child := &runManifest{}
+	b, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(b, child)
+	}
+	if err != nil || len(child.Scans) == 0 {
+		scan := &manifestScan{Scanner: scanner, Outcome: outcomeFailed}
+		if scanErr != nil {
+			scan.Error = scanErr.Error()
+		} else if err != nil {
+			scan.Error = fmt.Sprintf("reading run manifest: %s", err.Error())
+		}
+		m.Scans = append(m.Scans, scan)
+		return
+	}
+	m.Scans = append(m.Scans, child.Scans...)
+	if child.BigQuery != nil {
+		m.usage.add(*child.BigQuery)
+	}
+}
+
+// finish writes the manifest, and must be deferred: a run that panics is
+// recorded as failed (as is each unfinished scan) before panicking again
+func (m *runManifest) finish() {
+	r := recover()
+	m.Outcome = outcomeSucceeded
+	if r != nil {
+		m.Outcome, m.Error = outcomeFailed, fmt.Sprint(r)
+	}
+	now := time.Now()
+	m.StartedAt, m.FinishedAt = m.start.UTC().Format(time.RFC3339), now.UTC().Format(time.RFC3339)
+	m.DurationSeconds = now.Sub(m.start).Seconds()
+	for _, scan := range m.Scans {
+		if scan.Outcome == "" {
+			scan.Outcome, scan.Error = m.Outcome, m.Error
+			scan.DurationSeconds = now.Sub(scan.start).Seconds()
+		}
+	}
+	queries := query.TotalUsage()
+	m.usage.add(bigqueryUsage{Queries: queries.Queries, QueryBytesBilled: queries.BytesBilled})
+	if m.usage != (bigqueryUsage{}) {
+		u := m.usage
+		m.BigQuery = &u
+		fmt.Printf("BigQuery usage: %d row(s) (%d bytes) inserted, %d bytes billed by %d query(s)\n", u.RowsInserted, u.BytesInserted, u.QueryBytesBilled, u.Queries)
+	}
+	if m.path != "" {
+		if err := m.write(); err != nil {
+			fmt.Printf("WARNING: could not write run manifest to %s: %s\n", m.path, err.Error())
+		}
+	}
+	if m.metricsPath != "" {
+		if err := writeMetrics(m.metricsPath, m.usage); err != nil {
+			fmt.Printf("WARNING: could not write metrics to %s: %s\n", m.metricsPath, err.Error())
+		}
+	}
+	if r != nil {
+		panic(r)
+	}
+}
+
+func (m *runManifest) write() error {
+	b, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Writing run manifest to %s\n", m.path)
+	return os.WriteFile(m.path, b, 0644)
+}
+
+// metrics are the counters written to --metrics-file, as the values of a
+// run's bigqueryUsage
+var metrics = []struct {
+	name  string
+	help  string
+	value func(u bigqueryUsage) int64
+}{
+	{"rumble_bigquery_rows_inserted_total", "Rows streamed into BigQuery tables by the run.", func(u bigqueryUsage) int64 { return u.RowsInserted }},
+	{"rumble_bigquery_bytes_inserted_total", "Bytes billed for the rows streamed into BigQuery tables by the run.", func(u bigqueryUsage) int64 { return u.BytesInserted }},
+	{"rumble_bigquery_queries_total", "BigQuery queries run by the run.", func(u bigqueryUsage) int64 { return u.Queries }},
+	{"rumble_bigquery_query_bytes_billed_total", "Bytes billed for the BigQuery queries run by the run.", func(u bigqueryUsage) int64 { return u.QueryBytesBilled }},
+}
+
+// writeMetrics writes a run's usage in the Prometheus text format, e.g. for
+// node_exporter's textfile collector, replacing any earlier file in one
+// step so the collector never reads half of it
+func writeMetrics(path string, u bigqueryUsage) error {
+	var b strings.Builder
+	for _, metric := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", metric.name, metric.help, metric.name, metric.name, metric.value(u))
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".rumble-metrics-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Writing metrics to %s\n", path)
+	return os.Rename(f.Name(), path)
+}
+
+// insertedRows counts the rows of each kind recorded for a scan
+func insertedRows(scan *sink.Scan) map[string]int {
+	rows := map[string]int{"summary": 1}
+	for kind, n := range map[string]int{"vulns": len(scan.Vulns), "findings": len(scan.Findings), "licenses": len(scan.Licenses), "packages": len(scan.Packages)} {
+		if n > 0 {
+			rows[kind] = n
+		}
+	}
+	return rows
+}
+
+// writeDiagnostics writes the scanners' output to --diagnostics-file, only
+// warning if it can't, as it's no reason to fail the run
+func writeDiagnostics(path string, diagnostics string) {
+	if err := os.WriteFile(path, []byte(diagnostics), 0644); err != nil {
+		fmt.Printf("WARNING: could not write diagnostics to %s: %s\n", path, err.Error())
+	}
+}
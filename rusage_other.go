@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// maxRssBytes is not available on this platform
+func maxRssBytes(state *os.ProcessState) int64 {
+	return 0
+}
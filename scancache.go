@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/cache"
+	"github.com/chainguard-dev/rumble/pkg/oci"
+	"github.com/chainguard-dev/rumble/pkg/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// scanCache reuses scanner output from --cache-dir for scans of the same
+// image digest with the same scanner, scanner database and options. The
+// output is still turned into a summary as usual, so options like
+// --dedup-key and --severity-source apply to cached results too. A nil
+// scanCache caches nothing.
+type scanCache struct {
+	cache   *cache.Cache
+	digest  string
+	scanner string
+	options []string
+}
+
+func newScanCache(ctx context.Context, dir string, image string, registryRef string, scanner string, sarif bool, keychain authn.Keychain, opts *summaryOptions) (*scanCache, error) {
+	var digest string
+	if path := localImagePath(image); path != "" {
+		hash, err := oci.LocalDigest(path)
+		if err != nil {
+			return nil, err
+		}
+		digest = hash.String()
+	} else {
+		ref, err := oci.Digest(ctx, registryRef, keychain)
+		if err != nil {
+			return nil, err
+		}
+		digest = ref.DigestStr()
+	}
+	// Only options that change what the scanner outputs are part of the key
+	options := []string{
+		"sarif=" + strconv.FormatBool(sarif),
+		"scan-types=" + strings.Join(opts.scanTypes, ","),
+		"licenses=" + strconv.FormatBool(opts.licenses),
+		"packages=" + strconv.FormatBool(opts.packages),
+		"offline=" + strconv.FormatBool(opts.offline),
+	}
+	if opts.platform != "" {
+		options = append(options, "platform="+opts.platform)
+	}
+	if len(opts.scannerArgs) > 0 {
+		options = append(options, "args="+strings.Join(opts.scannerArgs, " "))
+	}
+	// As are the grype config and VEX documents, by their contents
+	if scanner == "grype" {
+		b, err := opts.grypeConfig()
+		if err != nil {
+			return nil, err
+		}
+		if b != nil {
+			options = append(options, fmt.Sprintf("grype-config=%x", sha256.Sum256(b)))
+		}
+	}
+	for _, document := range opts.vexDocuments {
+		b, err := os.ReadFile(document)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, fmt.Sprintf("vex=%x", sha256.Sum256(b)))
+	}
+	return &scanCache{cache: &cache.Cache{Dir: dir}, digest: digest, scanner: scanner, options: options}, nil
+}
+
+// key returns the cache key given the current scanner database, or "" if
+// the database can't be identified
+func (c *scanCache) key(env []string) string {
+	checksum, err := scannerDBChecksum(c.scanner, env)
+	if err != nil {
+		fmt.Printf("WARNING: could not identify the %s database, not caching: %s\n", c.scanner, err.Error())
+		return ""
+	}
+	if checksum == "" {
+		return ""
+	}
+	return cache.Key(c.digest, c.scanner, checksum, c.options...)
+}
+
+// restore copies cached output to the files (cache names to paths),
+// returning false on a miss
+func (c *scanCache) restore(env []string, files map[string]string) bool {
+	if c == nil {
+		return false
+	}
+	key := c.key(env)
+	if key == "" {
+		return false
+	}
+	hit, err := c.cache.Get(key, files)
+	if err != nil {
+		fmt.Printf("WARNING: could not read cached %s output: %s\n", c.scanner, err.Error())
+		return false
+	}
+	if hit {
+		fmt.Printf("Reusing cached %s output for %s (key=\"%s\")\n", c.scanner, c.digest, key)
+	}
+	return hit
+}
+
+// save caches the output files (cache names to paths). The key is worked
+// out again, since the scanner may have updated its database.
+func (c *scanCache) save(env []string, files map[string]string) {
+	if c == nil {
+		return
+	}
+	key := c.key(env)
+	if key == "" {
+		return
+	}
+	if err := c.cache.Put(key, files); err != nil {
+		fmt.Printf("WARNING: could not cache %s output: %s\n", c.scanner, err.Error())
+	}
+}
+
+var grypeDBChecksumRE = regexp.MustCompile(`(?m)^\s*Checksum:\s*(\S+)|"checksum":\s*"([^"]+)"`)
+
+// scannerDBChecksum identifies the scanner's local vulnerability database.
+// It returns "" (without an error) if the scanner is going to update the
+// database before scanning, as trivy does once it's due.
+func scannerDBChecksum(scanner string, env []string) (string, error) {
+	var out bytes.Buffer
+	var cmd *exec.Cmd
+	switch scanner {
+	case "grype":
+		cmd = exec.Command("grype", "db", "status")
+	case "trivy":
+		cmd = exec.Command("trivy", "--version", "-f", "json")
+	default:
+		return "", fmt.Errorf("invalid scanner: %s", scanner)
+	}
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	if scanner == "grype" {
+		m := grypeDBChecksumRE.FindStringSubmatch(out.String())
+		if m == nil {
+			return "", fmt.Errorf("no checksum in \"grype db status\" output")
+		}
+		return m[1] + m[2], nil
+	}
+	var version types.TrivyVersionOutput
+	if err := json.Unmarshal(out.Bytes(), &version); err != nil {
+		return "", err
+	}
+	db := version.VulnerabilityDB
+	if db.UpdatedAt == "" {
+		return "", fmt.Errorf("no vulnerability database in \"trivy --version\" output")
+	}
+	if next, err := time.Parse(time.RFC3339, db.NextUpdate); err == nil && time.Now().After(next) {
+		return "", nil
+	}
+	return db.UpdatedAt + "/" + db.DownloadedAt, nil
+}
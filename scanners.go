@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+
+	"github.com/chainguard-dev/rumble/pkg/scanner"
+)
+
+// installedScannerVersion returns the version of the scanner on the PATH
+func installedScannerVersion(name string) (string, error) {
+	var cmd *exec.Cmd
+	switch name {
+	case scanner.Grype:
+		cmd = exec.Command("grype", "version", "-o", "json")
+	case scanner.Trivy:
+		cmd = exec.Command("trivy", "--version", "-f", "json")
+	default:
+		return "", fmt.Errorf("invalid scanner: %s", name)
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("getting the %s version: %w", name, err)
+	}
+	// grype reports "version" and trivy "Version", which encoding/json
+	// matches either way
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &version); err != nil {
+		return "", fmt.Errorf("parsing the %s version: %w", name, err)
+	}
+	return version.Version, nil
+}
+
+// checkScannerVersion fails before anything is scanned if the installed
+// scanner is older than rumble supports
+func checkScannerVersion(name string) error {
+	v, err := installedScannerVersion(name)
+	if err != nil {
+		return err
+	}
+	if err := scanner.CheckVersion(name, v); err != nil {
+		return fmt.Errorf("%w (pass --skip-scanner-check to scan anyway)", err)
+	}
+	fmt.Printf("Using %s %s\n", name, v)
+	return nil
+}
+
+// defaultScannersDir is where --ensure-scanners installs scanners by default
+// (main's --scanner flag shadows the package there)
+func defaultScannersDir() string {
+	return scanner.DefaultDir()
+}
+
+// useEmbeddedScanner puts the scanner compiled in with -tags
+// embedded_scanners (if any) first on the PATH, extracting it into dir
+func useEmbeddedScanner(name string, dir string) error {
+	binDir, err := scanner.InstallEmbedded(dir, name)
+	if err != nil || binDir == "" {
+		return err
+	}
+	fmt.Printf("Using the embedded %s, extracted to %s\n", name, binDir)
+	return os.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// ensureScanner installs the pinned release of a scanner into dir if it
+// isn't on the PATH, and puts it on the PATH for this and child processes
+func ensureScanner(name string, dir string) error {
+	if _, err := exec.LookPath(name); err == nil {
+		return nil
+	}
+	release, ok := scanner.Releases[name]
+	if !ok {
+		return fmt.Errorf("invalid scanner: %s", name)
+	}
+	binDir, err := release.Install(context.Background(), http.DefaultClient, dir, name)
+	if err != nil {
+		return fmt.Errorf("installing %s %s: %w", name, release.Version, err)
+	}
+	fmt.Printf("Using %s %s installed in %s\n", name, release.Version, binDir)
+	return os.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
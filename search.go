@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/mirror"
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/version"
+)
+
+// runSearch implements "rumble search", which lists the images whose latest
+// scan contains a CVE or package
+func runSearch(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	tables := addTableFlags(fs)
+	packagesTable := fs.String("packages-table", GcloudTablePackages, "BigQuery packages table to search for --package, instead of only vulnerable packages in the vulns table (defaults to $GCLOUD_TABLE_PACKAGES)")
+	cve := fs.String("cve", "", "Find images with this vulnerability, e.g. CVE-2024-1234")
+	pkg := fs.String("package", "", "Find images with this package, e.g. openssl")
+	versions := fs.String("version", "", "Only match --package versions satisfying this constraint, e.g. \"<3.0.14\" or \">=2.0,<2.17.1\"")
+	since := fs.String("since", "", "Only consider scans since this time, as an age (e.g. \"30d\", \"12h\") or a date (e.g. \"2006-01-02\")")
+	output := fs.String("output", outputTable, "Output format, (\"table\", \"json\" or \"csv\")")
+	fs.Parse(args)
+	tables.check(true)
+	defer tables.close()
+	if *cve == "" && *pkg == "" {
+		panic(fmt.Errorf("--cve or --package is required"))
+	}
+	if *versions != "" && *pkg == "" {
+		panic(fmt.Errorf("--version requires --package"))
+	}
+	if *output != outputTable && *output != outputJSON && *output != outputCSV {
+		panic(fmt.Errorf("invalid output format: %s", *output))
+	}
+	var constraint *version.Constraint
+	if *versions != "" {
+		var err error
+		constraint, err = version.ParseConstraint(*versions)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	sinceTime, err := query.ParseSince(*since, time.Now())
+	if err != nil {
+		panic(err)
+	}
+	search := query.Search{
+		CVE:     *cve,
+		Package: *pkg,
+		Since:   sinceTime,
+	}
+
+	results, err := searchImages(context.Background(), tables, *packagesTable, search)
+	if err != nil {
+		panic(err)
+	}
+	if constraint != nil {
+		matching := []*query.SearchResult{}
+		for _, result := range results {
+			if constraint.Check(result.Version) {
+				matching = append(matching, result)
+			}
+		}
+		results = matching
+	}
+	if err := printSearchResults(os.Stdout, *output, results); err != nil {
+		panic(err)
+	}
+}
+
+// searchImages runs a search against the local mirror, PostgreSQL or
+// BigQuery. Packages are searched for in the packages table where there is
+// one, and otherwise only vulnerable packages can be found.
+func searchImages(ctx context.Context, tables *tableFlags, packagesTable string, search query.Search) ([]*query.SearchResult, error) {
+	if *tables.local != "" {
+		db, err := tables.localDB()
+		if err != nil {
+			return nil, err
+		}
+		return mirror.Search(ctx, db, search)
+	}
+	if *tables.postgres != "" {
+		store, err := tables.postgresStore(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if search.CVE == "" {
+			if search.Packages, err = store.HasPackages(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return store.Search(ctx, search)
+	}
+	client, err := tables.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	table := tables.vulnsTableName()
+	search.Packages = search.CVE == "" && packagesTable != ""
+	if search.Packages {
+		table = fmt.Sprintf("%s.%s.%s", *tables.project, *tables.dataset, packagesTable)
+	}
+	return query.SearchImages(ctx, client, tables.summaryTable(), table, search)
+}
+
+var searchHeaders = []string{"image", "scanner", "digest", "time", "package", "version", "type", "vulnerability", "fixed_in"}
+
+func printSearchResults(w io.Writer, format string, results []*query.SearchResult) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(results)
+	case outputCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(searchHeaders); err != nil {
+			return err
+		}
+		for _, r := range results {
+			if err := cw.Write([]string{r.Image, r.Scanner, r.Digest, r.Time, r.Package, r.Version, r.Type, r.Vulnerability, r.FixedIn}); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	if len(results) == 0 {
+		_, err := fmt.Fprintln(w, "No images found")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "IMAGE\tSCANNER\tDIGEST\tTIME\tPACKAGE\tVERSION\tVULNERABILITY\tFIXED IN")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Image, r.Scanner, r.Digest, r.Time, r.Package, r.Version, r.Vulnerability, r.FixedIn)
+	}
+	return tw.Flush()
+}
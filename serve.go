@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/api"
+	"github.com/chainguard-dev/rumble/pkg/auth"
+	"github.com/chainguard-dev/rumble/pkg/config"
+	"github.com/chainguard-dev/rumble/pkg/gql"
+	"github.com/chainguard-dev/rumble/pkg/oci"
+	"github.com/chainguard-dev/rumble/pkg/priority"
+	"github.com/chainguard-dev/rumble/pkg/query"
+	"github.com/chainguard-dev/rumble/pkg/scanner"
+	"github.com/chainguard-dev/rumble/pkg/server"
+	"github.com/chainguard-dev/rumble/pkg/types"
+	"github.com/chainguard-dev/rumble/pkg/ui"
+	"google.golang.org/grpc"
+)
+
+// runServe implements "rumble serve", which serves the gRPC API for
+// submitting scans and reading recorded results. Flags after "--" are passed
+// on to each scan, e.g. to choose the sink.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	tables := addTableFlags(fs)
+	grpcAddr := fs.String("grpc-addr", ":50051", "Address to serve the gRPC API on")
+	httpAddr := fs.String("http-addr", ":8080", "Address to serve the HTTP endpoints (GET /queue, /healthz, /readyz, GraphQL and the web UI) on")
+	graphQL := fs.Bool("graphql", true, "Serve a GraphQL API over recorded scans at /graphql on --http-addr")
+	configFile := fs.String("config", os.Getenv("RUMBLE_CONFIG"), "Path to a JSON config file, with the API tokens to accept and webhooks to post to (defaults to $RUMBLE_CONFIG)")
+	tokensSecret := fs.String("tokens-secret", os.Getenv("RUMBLE_TOKENS_SECRET"), "Google Secret Manager secret with API tokens to accept, as projects/<project>/secrets/<secret>[/versions/<version>] (defaults to $RUMBLE_TOKENS_SECRET)")
+	drainTimeout := fs.Duration("drain-timeout", 10*time.Minute, "How long to wait for running scans to finish when stopping, after which they're interrupted and requeued")
+	shutdownDelay := fs.Duration("shutdown-delay", 0, "How long to keep serving after being signalled to stop, with GET /readyz failing, so load balancers stop sending requests first")
+	readyScanners := fs.String("ready-scanners", scanner.Grype, "Comma-separated scanners that must be installed for GET /readyz to succeed")
+	webUI := fs.Bool("ui", true, "Serve a web UI for browsing recorded scans under /ui/ on --http-addr")
+	workers := fs.Int("workers", 1, "How many submitted scans to run at once")
+	queueType := fs.String("queue", queueMemory, "Where to keep the job queue, (\"memory\", or \"postgres\" in the --postgres database so jobs survive restarts)")
+	maxAttempts := fs.Int("max-attempts", 3, "How many times to run a job before it fails and is dead-lettered")
+	retryDelay := fs.Duration("retry-delay", time.Minute, "How long to wait before retrying a failed job, doubling after each attempt")
+	registryConcurrency := fs.String("registry-concurrency", os.Getenv("RUMBLE_REGISTRY_CONCURRENCY"), "Comma-separated limits on concurrent jobs scanning images from each registry, with \"*\" for any other registry, e.g. \"docker.io=2,*=8\" (defaults to $RUMBLE_REGISTRY_CONCURRENCY)")
+	priorityPolicy := fs.String("priority", priority.FIFO, "How to order queued jobs, (\"fifo\", \"recent\" for recently built images first, \"severity\" for images with the most critical CVEs last scanned first, or \"balanced\" for both)")
+	jobLease := fs.Duration("job-lease", time.Hour, "How long a job may run for before it's assumed lost and run again")
+	imageFilter := addImageFilterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: rumble serve [flags] [-- scan flags]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	tables.check(false)
+	defer tables.close()
+	if *workers < 1 {
+		panic(fmt.Errorf("--workers must be at least 1"))
+	}
+	if *maxAttempts < 1 {
+		panic(fmt.Errorf("--max-attempts must be at least 1"))
+	}
+
+	// Each scan is run by running rumble itself, so the scan flags work just
+	// as they do for a single image
+	self, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+	scanArgs := fs.Args()
+	scan := func(ctx context.Context, image string, scanner string) (*types.ImageScanSummary, error) {
+		return runScan(ctx, self, image, scanner, scanArgs)
+	}
+
+	// The first signal drains the server, and ctx is only cancelled after
+	// that, interrupting any scans still running
+	signalled, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	limiter, err := oci.ParseLimiter(*registryConcurrency)
+	if err != nil {
+		panic(err)
+	}
+	policy, err := priority.Parse(*priorityPolicy)
+	if err != nil {
+		panic(err)
+	}
+	opts := server.Options{
+		MaxAttempts: *maxAttempts,
+		RetryDelay:  *retryDelay,
+		Lease:       *jobLease,
+		Limiter:     limiter,
+		Policy:      policy,
+		Published:   imagePublished,
+		Digest:      imageDigest,
+	}
+	switch *queueType {
+	case queueMemory:
+	case queuePostgres:
+		if err := checkTableConfig("Postgres", map[string]string{"--postgres ($RUMBLE_POSTGRES)": *tables.postgres}); err != nil {
+			panic(err)
+		}
+		store, err := tables.postgresStore(ctx)
+		if err != nil {
+			panic(err)
+		}
+		opts.Queue = store.Queue()
+	default:
+		panic(fmt.Errorf("invalid queue: %s", *queueType))
+	}
+	tokens := []config.Token{}
+	var cfg *config.Config
+	if *configFile != "" {
+		if cfg, err = config.Load(*configFile); err != nil {
+			panic(err)
+		}
+		tokens = append(tokens, cfg.Tokens...)
+		for _, webhook := range cfg.Webhooks {
+			secret, err := webhook.SigningSecret()
+			if err != nil {
+				panic(err)
+			}
+			if secret == "" {
+				fmt.Printf("WARNING: webhook %s has no secret, so its requests aren't signed\n", webhook.Name)
+			}
+			opts.Webhooks = append(opts.Webhooks, server.Webhook{Name: webhook.Name, URL: webhook.URL, Secret: secret, Images: webhook.Images})
+		}
+		if len(opts.Webhooks) > 0 {
+			fmt.Printf("Posting finished jobs to %d webhook(s)\n", len(opts.Webhooks))
+		}
+	}
+	opts.Images = imageFilter.filter(cfg)
+	if *tokensSecret != "" {
+		secretTokens, err := auth.LoadSecret(ctx, *tokensSecret)
+		if err != nil {
+			panic(err)
+		}
+		tokens = append(tokens, secretTokens...)
+	}
+	authenticator, err := auth.New(tokens)
+	if err != nil {
+		panic(err)
+	}
+	if authenticator.Enabled() {
+		fmt.Printf("Requiring one of %d API token(s)\n", len(tokens))
+	} else {
+		fmt.Println("WARNING: no API tokens are configured, so anyone who can reach the server can submit scans")
+	}
+
+	s := server.New(scan, tables.summaries, opts)
+	s.Start(ctx, *workers)
+
+	lis, err := net.Listen("tcp", *grpcAddr)
+	if err != nil {
+		panic(err)
+	}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(authenticator.UnaryInterceptor()), grpc.StreamInterceptor(authenticator.StreamInterceptor()))
+	api.RegisterRumbleServer(srv, s)
+	mux := http.NewServeMux()
+	mux.Handle("/queue", s.QueueHandler())
+	search := func(ctx context.Context, search query.Search) ([]*query.SearchResult, error) {
+		return searchImages(ctx, tables, "", search)
+	}
+	if *graphQL {
+		schema, err := gql.NewSchema(gql.Source{Summaries: tables.summaries, ScanVulns: tables.scanVulns, Search: search})
+		if err != nil {
+			panic(err)
+		}
+		mux.Handle("/graphql", gql.Handler(schema))
+	}
+	if *webUI {
+		mux.Handle(ui.Prefix, ui.Handler(ui.Source{Summaries: tables.summaries, ScanVulns: tables.scanVulns, Search: search}))
+	}
+	checks := []server.Check{tables.readyCheck()}
+	for _, name := range strings.Split(*readyScanners, ",") {
+		if name != "" {
+			checks = append(checks, scannerCheck(name))
+		}
+	}
+	// The probes are left out of authentication, for load balancers
+	root := http.NewServeMux()
+	root.Handle("/healthz", s.HealthHandler())
+	root.Handle("/readyz", s.ReadyHandler(checks...))
+	root.Handle("/", authenticator.Handler(config.ScopeRead, mux))
+	httpServer := &http.Server{Addr: *httpAddr, Handler: root}
+	go func() {
+		fmt.Printf("Serving HTTP on %s\n", *httpAddr)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			panic(err)
+		}
+	}()
+	go func() {
+		<-signalled.Done()
+		signalledAt := time.Now()
+		// Reads keep being served while draining, and /readyz fails so load
+		// balancers stop sending requests
+		fmt.Printf("Draining: waiting up to %s for running scans to finish (signal again to stop now)...\n", *drainTimeout)
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), *drainTimeout)
+		go func() {
+			again, stopAgain := signal.NotifyContext(drainCtx, os.Interrupt, syscall.SIGTERM)
+			defer stopAgain()
+			<-again.Done()
+			cancelDrain()
+		}()
+		if err := s.Drain(drainCtx); err != nil {
+			fmt.Println("WARNING: stopping before all running scans finished, they will be requeued")
+		}
+		cancelDrain()
+		cancel()
+		time.Sleep(time.Until(signalledAt.Add(*shutdownDelay)))
+		fmt.Println("Shutting down...")
+		httpServer.Close()
+		// Streams following every job never end by themselves
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(10 * time.Second):
+			srv.Stop()
+		}
+	}()
+	fmt.Printf("Serving the gRPC API on %s\n", lis.Addr())
+	if err := srv.Serve(lis); err != nil {
+		panic(err)
+	}
+}
+
+// readyCheck checks that the recorded scans can be read, for GET /readyz
+func (t *tableFlags) readyCheck() server.Check {
+	if *t.local != "" {
+		return server.Check{Name: "local", Check: func(ctx context.Context) error {
+			db, err := t.localDB()
+			if err != nil {
+				return err
+			}
+			return db.PingContext(ctx)
+		}}
+	}
+	if *t.postgres != "" {
+		return server.Check{Name: "postgres", Check: func(ctx context.Context) error {
+			store, err := t.postgresStore(ctx)
+			if err != nil {
+				return err
+			}
+			return store.DB.PingContext(ctx)
+		}}
+	}
+	return server.Check{Name: "bigquery", Check: func(ctx context.Context) error {
+		client, err := t.client(ctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		_, err = client.Dataset(*t.dataset).Table(*t.table).Metadata(ctx)
+		return err
+	}}
+}
+
+// scannerCheck checks that a scanner is installed and supported, for GET
+// /readyz
+func scannerCheck(name string) server.Check {
+	return server.Check{Name: name, Check: func(ctx context.Context) error {
+		v, err := installedScannerVersion(name)
+		if err != nil {
+			return err
+		}
+		return scanner.CheckVersion(name, v)
+	}}
+}
+
+// imagePublished returns when an image was built, for priority policies
+func imagePublished(ctx context.Context, image string) (time.Time, error) {
+	created, err := oci.ImageBuildTime(ctx, image, oci.Keychain(false))
+	if err != nil || created == nil {
+		return time.Time{}, err
+	}
+	return *created, nil
+}
+
+// imageDigest resolves an image to its digest, for coalescing submissions
+func imageDigest(ctx context.Context, image string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	digest, err := oci.Digest(ctx, image, oci.Keychain(false))
+	if err != nil {
+		return "", err
+	}
+	return digest.String(), nil
+}
+
+// runScan scans an image by running rumble, returning the recorded summary.
+// A failed scan's error ends with the last line its scanner wrote, which is
+// kept with the job.
+func runScan(ctx context.Context, self string, image string, scanner string, args []string) (*types.ImageScanSummary, error) {
+	dir, err := os.MkdirTemp("", "rumble-serve-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	summaryFile := filepath.Join(dir, "summary.json")
+	diagnosticsFile := filepath.Join(dir, "diagnostics.txt")
+	cmd := exec.CommandContext(ctx, self, append([]string{"--image", image, "--scanner", scanner, "--summary-output", summaryFile, "--diagnostics-file", diagnosticsFile}, args...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		b, _ := os.ReadFile(diagnosticsFile)
+		if diagnostics := strings.TrimSpace(string(b)); diagnostics != "" {
+			lines := strings.Split(diagnostics, "\n")
+			return nil, fmt.Errorf("scan of %s with %s failed: %w: %s", image, scanner, err, strings.TrimSpace(lines[len(lines)-1]))
+		}
+		return nil, fmt.Errorf("scan of %s with %s failed: %w", image, scanner, err)
+	}
+	b, err := os.ReadFile(summaryFile)
+	if err != nil {
+		return nil, err
+	}
+	summary := &types.ImageScanSummary{}
+	if err := json.Unmarshal(b, summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+const (
+	queueMemory   = "memory"
+	queuePostgres = "postgres"
+)
@@ -0,0 +1,57 @@
+package main
+
+import "flag"
+
+// sigstoreFlags point cosign at a private Sigstore deployment, or at an
+// RFC 3161 timestamp authority instead of the Rekor transparency log
+type sigstoreFlags struct {
+	rekorURL          *string
+	fulcioURL         *string
+	timestampServer   *string
+	timestampCertPath *string
+	tlogUpload        *bool
+}
+
+func addSigstoreFlags(fs *flag.FlagSet) *sigstoreFlags {
+	return &sigstoreFlags{
+		rekorURL:          fs.String("rekor-url", "", "Rekor transparency log URL, for a private Sigstore deployment"),
+		fulcioURL:         fs.String("fulcio-url", "", "Fulcio certificate authority URL, for a private Sigstore deployment"),
+		timestampServer:   fs.String("timestamp-server", "", "RFC 3161 timestamp authority URL used to timestamp attestations"),
+		timestampCertPath: fs.String("timestamp-certificate-chain", "", "PEM certificate chain of the timestamp authority, used when verifying"),
+		tlogUpload:        fs.Bool("tlog-upload", true, "If disabled, don't upload attestations to the transparency log (and don't require it when verifying)"),
+	}
+}
+
+// signArgs are the extra arguments for "cosign attest"
+func (s *sigstoreFlags) signArgs() []string {
+	args := []string{}
+	if *s.rekorURL != "" {
+		args = append(args, "--rekor-url", *s.rekorURL)
+	}
+	if *s.fulcioURL != "" {
+		args = append(args, "--fulcio-url", *s.fulcioURL)
+	}
+	if *s.timestampServer != "" {
+		args = append(args, "--timestamp-server-url", *s.timestampServer)
+	}
+	if !*s.tlogUpload {
+		args = append(args, "--tlog-upload=false")
+	}
+	return args
+}
+
+// verifyArgs are the extra arguments for "cosign verify" and
+// "cosign verify-attestation"
+func (s *sigstoreFlags) verifyArgs() []string {
+	args := []string{}
+	if *s.rekorURL != "" {
+		args = append(args, "--rekor-url", *s.rekorURL)
+	}
+	if *s.timestampCertPath != "" {
+		args = append(args, "--timestamp-certificate-chain", *s.timestampCertPath)
+	}
+	if !*s.tlogUpload {
+		args = append(args, "--insecure-ignore-tlog")
+	}
+	return args
+}
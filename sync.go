@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/chainguard-dev/rumble/pkg/mirror"
+	"github.com/chainguard-dev/rumble/pkg/query"
+)
+
+// runSync implements "rumble sync", which mirrors recent summary and vuln
+// rows from BigQuery into a local SQLite database for use with --local
+func runSync(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	tables := addTableFlags(fs)
+	db := fs.String("db", "rumble.db", "Path of the local mirror database")
+	since := fs.String("since", "30d", "Mirror rows since this time, as an age (e.g. \"30d\", \"12h\") or a date (e.g. \"2006-01-02\")")
+	vulns := fs.Bool("vulns", true, "Also mirror rows from the vulns table")
+	fs.Parse(args)
+	tables.check(*vulns)
+
+	sinceTime, err := query.ParseSince(*since, time.Now())
+	if err != nil {
+		panic(err)
+	}
+	ctx := context.Background()
+	client, err := tables.client(ctx)
+	if err != nil {
+		panic(err)
+	}
+	local, err := mirror.Open(*db)
+	if err != nil {
+		panic(err)
+	}
+	defer local.Close()
+
+	summaries, err := query.Summaries(ctx, client, tables.summaryTable(), query.Filter{Since: sinceTime})
+	if err != nil {
+		panic(err)
+	}
+	if err := mirror.PutSummaries(ctx, local, summaries); err != nil {
+		panic(err)
+	}
+	fmt.Printf("Mirrored %d row(s) from table \"%s\" to %s\n", len(summaries), tables.summaryTable(), *db)
+
+	if *vulns {
+		rows, err := query.Vulns(ctx, client, tables.summaryTable(), tables.vulnsTableName(), sinceTime)
+		if err != nil {
+			panic(err)
+		}
+		if err := mirror.PutVulns(ctx, local, rows); err != nil {
+			panic(err)
+		}
+		fmt.Printf("Mirrored %d row(s) from table \"%s\" to %s\n", len(rows), tables.vulnsTableName(), *db)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Default time limit of each registry request made by rumble itself, so a
+// registry that stops responding can't hang the job
+const defaultRegistryTimeout = 5 * time.Minute
+
+// deadline is the context of the whole run (--timeout) or a phase of it
+// (e.g. --scan-timeout), limited to the timeout set with its flag unless
+// that is 0
+type deadline struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	parent  *deadline
+	flag    string
+	timeout time.Duration
+}
+
+// newDeadline starts a deadline within parent, or of the whole run if
+// parent is nil
+func newDeadline(parent *deadline, flag string, timeout time.Duration) *deadline {
+	ctx := context.Background()
+	if parent != nil {
+		ctx = parent.ctx
+	}
+	d := &deadline{parent: parent, flag: flag, timeout: timeout}
+	if timeout > 0 {
+		d.ctx, d.cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		d.ctx, d.cancel = context.WithCancel(ctx)
+	}
+	return d
+}
+
+// explain adds which timeout ran out to an error caused by running out of
+// time, blaming the outermost deadline that has passed
+func (d *deadline) explain(err error) error {
+	if err == nil || !errors.Is(d.ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	for d.parent != nil && errors.Is(d.parent.ctx.Err(), context.DeadlineExceeded) {
+		d = d.parent
+	}
+	return fmt.Errorf("%w (%s of %s exceeded)", err, d.flag, d.timeout)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/chainguard-dev/rumble/pkg/query"
+)
+
+// runTop implements "rumble top", which ranks images by the CVE counts of
+// their latest scan
+func runTop(args []string) {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	tables := addTableFlags(fs)
+	by := fs.String("by", "critical", "Severity to rank images by, (\"critical\", \"high\", \"medium\", \"low\", \"negligible\", \"unknown\" or \"total\")")
+	limit := fs.Int("limit", 20, "Number of images to show")
+	scanner := fs.String("scanner", "", "Only consider scans by this scanner, (\"trivy\" or \"grype\")")
+	output := fs.String("output", outputTable, "Output format, (\"table\", \"wide\", \"json\" or \"csv\")")
+	fs.Parse(args)
+	tables.check(false)
+	defer tables.close()
+	if err := checkOutputFormat(*output); err != nil {
+		panic(err)
+	}
+
+	filter := query.Filter{
+		Scanner:    *scanner,
+		LatestOnly: true,
+		OrderBy:    *by,
+		Limit:      *limit,
+	}
+
+	summaries, err := tables.summaries(context.Background(), filter)
+	if err != nil {
+		panic(err)
+	}
+	if err := printSummaries(os.Stdout, *output, summaries); err != nil {
+		panic(err)
+	}
+}
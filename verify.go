@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// signatureFlags constrain who must have signed an image for it to be
+// scanned, mirroring the flags of "cosign verify"
+type signatureFlags struct {
+	verify         *bool
+	key            *string
+	identity       *string
+	identityRegexp *string
+	issuer         *string
+	issuerRegexp   *string
+}
+
+func addSignatureFlags(fs *flag.FlagSet) *signatureFlags {
+	return &signatureFlags{
+		verify:         fs.Bool("verify-signature", false, "If enabled, verify the image's cosign signature before scanning, and fail if it can't be verified"),
+		key:            fs.String("verify-key", "", "Public key (or KMS URI) to verify the signature with, instead of a keyless certificate"),
+		identity:       fs.String("certificate-identity", "", "Identity expected in a keyless signing certificate"),
+		identityRegexp: fs.String("certificate-identity-regexp", "", "Regular expression for the identity expected in a keyless signing certificate"),
+		issuer:         fs.String("certificate-oidc-issuer", "", "OIDC issuer expected in a keyless signing certificate"),
+		issuerRegexp:   fs.String("certificate-oidc-issuer-regexp", "", "Regular expression for the OIDC issuer expected in a keyless signing certificate"),
+	}
+}
+
+// check makes sure keyless verification is constrained to an identity and
+// issuer, since accepting any signer would make the check meaningless
+func (s *signatureFlags) check() error {
+	if !*s.verify || *s.key != "" {
+		return nil
+	}
+	if *s.identity == "" && *s.identityRegexp == "" {
+		return fmt.Errorf("--verify-signature requires --verify-key, --certificate-identity or --certificate-identity-regexp")
+	}
+	if *s.issuer == "" && *s.issuerRegexp == "" {
+		return fmt.Errorf("--verify-signature requires --verify-key, --certificate-oidc-issuer or --certificate-oidc-issuer-regexp")
+	}
+	return nil
+}
+
+func (s *signatureFlags) args() []string {
+	args := []string{}
+	for _, arg := range []struct {
+		flag  string
+		value string
+	}{
+		{"--key", *s.key},
+		{"--certificate-identity", *s.identity},
+		{"--certificate-identity-regexp", *s.identityRegexp},
+		{"--certificate-oidc-issuer", *s.issuer},
+		{"--certificate-oidc-issuer-regexp", *s.issuerRegexp},
+	} {
+		if arg.value != "" {
+			args = append(args, arg.flag, arg.value)
+		}
+	}
+	return args
+}
+
+// cosignVerifyOutput is a single verified signature printed by "cosign verify"
+type cosignVerifyOutput struct {
+	Optional struct {
+		Subject string `json:"Subject"`
+		Issuer  string `json:"Issuer"`
+	} `json:"optional"`
+}
+
+// verifyImageSignature runs "cosign verify" against an image, returning the
+// identity of the signing certificate (empty for key-based signatures)
+func verifyImageSignature(ctx context.Context, image string, signature *signatureFlags, sigstore *sigstoreFlags, dockerConfig string) (string, error) {
+	env := os.Environ()
+	if dockerConfig != "" {
+		env = append(env, fmt.Sprintf("DOCKER_CONFIG=%s", dockerConfig))
+	}
+	args := append([]string{"verify", "--output", "json"}, signature.args()...)
+	args = append(append(args, sigstore.verifyArgs()...), image)
+	fmt.Printf("Running signature verification command \"cosign %s\"...\n", strings.Join(args, " "))
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "cosign", args...)
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("verifying signature of %s: %w", image, err)
+	}
+	var verified []cosignVerifyOutput
+	if err := json.Unmarshal(out.Bytes(), &verified); err != nil {
+		return "", fmt.Errorf("parsing cosign verify output: %w", err)
+	}
+	if len(verified) == 0 {
+		return "", fmt.Errorf("verifying signature of %s: no signatures found", image)
+	}
+	return verified[0].Optional.Subject, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chainguard-dev/rumble/pkg/dsse"
+	"github.com/chainguard-dev/rumble/pkg/sink"
+	"github.com/chainguard-dev/rumble/pkg/types"
+)
+
+// runVerifySummary implements "rumble verify-summary", which checks the
+// DSSE envelopes written alongside scans with --signing-key. An envelope
+// next to the file it signed (e.g. summary.json.dsse by summary.json) must
+// also match that file. It fails unless every envelope verifies.
+func runVerifySummary(args []string) {
+	fs := flag.NewFlagSet("verify-summary", flag.ExitOnError)
+	key := fs.String("key", "", "PEM public key to verify the envelopes with")
+	payloadOutput := fs.String("payload-output", "", "If set, write the verified payload of the (single) envelope to this file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: rumble verify-summary --key <public key> <envelope>...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *key == "" || fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *payloadOutput != "" && fs.NArg() != 1 {
+		panic(fmt.Errorf("--payload-output needs a single envelope, got %d", fs.NArg()))
+	}
+	publicKey, err := dsse.LoadPublicKey(*key)
+	if err != nil {
+		panic(err)
+	}
+	keyID, err := dsse.KeyID(publicKey)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, filename := range fs.Args() {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			panic(err)
+		}
+		var envelope types.DSSEEnvelope
+		if err := json.Unmarshal(b, &envelope); err != nil {
+			panic(fmt.Errorf("parsing %s: %w", filename, err))
+		}
+		if envelope.PayloadType != sink.SummaryPayloadType && envelope.PayloadType != sink.ScanPayloadType {
+			panic(fmt.Errorf("%s has payload type %q, expected %s or %s", filename, envelope.PayloadType, sink.SummaryPayloadType, sink.ScanPayloadType))
+		}
+		if err := dsse.Verify(&envelope, publicKey); err != nil {
+			panic(fmt.Errorf("verifying %s: %w", filename, err))
+		}
+		signed := strings.TrimSuffix(filename, sink.EnvelopeSuffix)
+		if signed != filename {
+			if contents, err := os.ReadFile(signed); err == nil && !bytes.Equal(contents, envelope.Payload) {
+				panic(fmt.Errorf("%s doesn't match its verified envelope %s", signed, filename))
+			}
+		}
+		fmt.Printf("Verified %s (%s) with key %s\n", filename, envelope.PayloadType, keyID)
+		if *payloadOutput != "" {
+			if err := os.WriteFile(*payloadOutput, envelope.Payload, 0644); err != nil {
+				panic(err)
+			}
+		}
+	}
+}